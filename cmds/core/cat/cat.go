@@ -6,27 +6,54 @@
 //
 // Synopsis:
 //
-//	cat [-u] [FILES]...
+//	cat [-u] [--squeeze-trailing] [--line-buffered] [FILES]...
+//	cat [--squeeze-trailing] [--line-buffered] --bytes-range START-END [FILE]
 //
 // Description:
 //
 //	If no files are specified, read from stdin.
 //
+//	With --bytes-range, only bytes START to END, inclusive and counted
+//	from zero, of a single file are printed. Seekable files are seeked to
+//	START; anything else, like a pipe, has the bytes before START read and
+//	dropped. A range reaching past the end of the file stops at the end.
+//
+//	With --squeeze-trailing, empty lines at the very end of the output are
+//	dropped; empty lines anywhere else are kept.
+//
+//	With --line-buffered, output is written a whole line at a time and
+//	flushed at every newline, so a line-oriented reader at the other end
+//	of a pipe sees each line as soon as it is complete and never half of
+//	one. Lines longer than the buffer are written in pieces.
+//
 // Options:
 //
 //	-u: ignored flag
+//	--bytes-range: print only this inclusive range of bytes
+//	--squeeze-trailing: drop empty lines at the end of the output
+//	--line-buffered: flush the output at every newline
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 var _ = flag.Bool("u", false, "ignored")
+var bytesRange = flag.String("bytes-range", "", "print only bytes START-END (inclusive) of a single file")
+var squeezeTrailing = flag.Bool("squeeze-trailing", false, "drop empty lines at the end of the output")
+var lineBuffered = flag.Bool("line-buffered", false, "flush the output at every newline")
 var errCopy = fmt.Errorf("error concatenating stdin to stdout")
+var errRange = fmt.Errorf("byte range must be START-END with 0 <= START <= END")
+var errRangeFiles = fmt.Errorf("--bytes-range takes at most one file")
 
 func cat(reader io.Reader, writer io.Writer) error {
 	if _, err := io.Copy(writer, reader); err != nil {
@@ -35,6 +62,78 @@ func cat(reader io.Reader, writer io.Writer) error {
 	return nil
 }
 
+// trailingWriter passes everything on to w, except for empty lines at the
+// very end. It holds back newlines until it knows whether more text follows
+// them, so all it has to remember is how many there are.
+type trailingWriter struct {
+	w io.Writer
+	// newlines is the number of newlines held back.
+	newlines int
+	// text is set once anything but a newline was written.
+	text bool
+}
+
+// Write implements io.Writer.
+func (t *trailingWriter) Write(p []byte) (int, error) {
+	end := len(bytes.TrimRight(p, "\n"))
+	if end == 0 {
+		t.newlines += len(p)
+		return len(p), nil
+	}
+	if _, err := t.w.Write(bytes.Repeat([]byte{'\n'}, t.newlines)); err != nil {
+		return 0, err
+	}
+	if _, err := t.w.Write(p[:end]); err != nil {
+		return 0, err
+	}
+	t.newlines = len(p) - end
+	t.text = true
+	return len(p), nil
+}
+
+// Flush ends the last line of text with a newline, if it had one.
+func (t *trailingWriter) Flush() error {
+	if !t.text || t.newlines == 0 {
+		return nil
+	}
+	t.newlines = 0
+	_, err := t.w.Write([]byte{'\n'})
+	return err
+}
+
+// lineWriter buffers what is written to it and flushes it at newlines, so
+// that w gets whole lines and gets them as soon as they are complete.
+type lineWriter struct {
+	w *bufio.Writer
+}
+
+// newLineWriter returns a lineWriter on w.
+func newLineWriter(w io.Writer) *lineWriter {
+	return &lineWriter{w: bufio.NewWriter(w)}
+}
+
+// Write implements io.Writer.
+func (l *lineWriter) Write(p []byte) (int, error) {
+	i := bytes.LastIndexByte(p, '\n')
+	if i < 0 {
+		return l.w.Write(p)
+	}
+	n, err := l.w.Write(p[:i+1])
+	if err != nil {
+		return n, err
+	}
+	if err := l.w.Flush(); err != nil {
+		return n, err
+	}
+	m, err := l.w.Write(p[i+1:])
+	return n + m, err
+}
+
+// Flush writes out the last line, if it did not end in a newline.
+func (l *lineWriter) Flush() error {
+	return l.w.Flush()
+}
+
 func run(stdin io.Reader, stdout io.Writer, args ...string) error {
 	if len(args) == 0 {
 		return cat(stdin, stdout)
@@ -59,9 +158,92 @@ func run(stdin io.Reader, stdout io.Writer, args ...string) error {
 	return nil
 }
 
+// parseByteRange parses an inclusive START-END byte range.
+func parseByteRange(s string) (start, end int64, err error) {
+	first, last, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q: %w", s, errRange)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("%q: %w", s, errRange)
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+		return 0, 0, fmt.Errorf("%q: %w", s, errRange)
+	}
+	return start, end, nil
+}
+
+// catRange copies bytes start to end, inclusive, of reader to writer. It
+// seeks to start if reader can, and reads up to it otherwise. It is not an
+// error for reader to end before end, or even before start.
+func catRange(reader io.Reader, writer io.Writer, start, end int64) error {
+	seeked := false
+	if s, ok := reader.(io.Seeker); ok {
+		_, err := s.Seek(start, io.SeekStart)
+		seeked = err == nil
+	}
+	if !seeked {
+		if _, err := io.CopyN(io.Discard, reader, start); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return errCopy
+		}
+	}
+	if _, err := io.CopyN(writer, reader, end-start+1); err != nil && !errors.Is(err, io.EOF) {
+		return errCopy
+	}
+	return nil
+}
+
+// runRange prints the byte range given by spec of the single file in args,
+// or of stdin.
+func runRange(stdin io.Reader, stdout io.Writer, spec string, args ...string) error {
+	start, end, err := parseByteRange(spec)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errRangeFiles
+	}
+	if len(args) == 0 || args[0] == "-" {
+		return catRange(stdin, stdout, start, end)
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := catRange(f, stdout, start, end); err != nil {
+		return fmt.Errorf("failed to concatenate file %s to given writer", f.Name())
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
-	if err := run(os.Stdin, os.Stdout, flag.Args()...); err != nil {
+	var stdout io.Writer = os.Stdout
+	l := newLineWriter(os.Stdout)
+	if *lineBuffered {
+		stdout = l
+	}
+	t := &trailingWriter{w: stdout}
+	if *squeezeTrailing {
+		stdout = t
+	}
+	var err error
+	if *bytesRange != "" {
+		err = runRange(os.Stdin, stdout, *bytesRange, flag.Args()...)
+	} else {
+		err = run(os.Stdin, stdout, flag.Args()...)
+	}
+	if err == nil {
+		err = t.Flush()
+	}
+	if err == nil {
+		err = l.Flush()
+	}
+	if err != nil {
 		log.Fatalf("cat failed with: %v", err)
 	}
 }
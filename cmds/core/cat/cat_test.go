@@ -13,8 +13,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 )
 
 // setup writes a set of files, putting 1 byte in each file.
@@ -161,3 +163,205 @@ func TestCatDash(t *testing.T) {
 		t.Errorf("want: %s, got: %s", want, got)
 	}
 }
+
+func TestBytesRange(t *testing.T) {
+	const data = "0123456789"
+	f := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(f, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		spec string
+		want string
+	}{
+		{spec: "0-9", want: data},
+		{spec: "2-4", want: "234"},
+		{spec: "5-5", want: "5"},
+		{spec: "7-100", want: "789"},
+		{spec: "10-20", want: ""},
+		{spec: "50-60", want: ""},
+	} {
+		t.Run(tt.spec, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := runRange(nil, &stdout, tt.spec, f); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("file: got %q, want %q", stdout.String(), tt.want)
+			}
+
+			// A pipe can't seek, so the leading bytes get read instead.
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			go func() {
+				w.WriteString(data)
+				w.Close()
+			}()
+			stdout.Reset()
+			if err := runRange(r, &stdout, tt.spec); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("pipe: got %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBytesRangeErrors(t *testing.T) {
+	for _, spec := range []string{"", "5", "4-2", "-1-3", "a-3", "1-b", "1-"} {
+		if err := runRange(nil, &bytes.Buffer{}, spec); !errors.Is(err, errRange) {
+			t.Errorf("runRange(%q) = %v, want %v", spec, err, errRange)
+		}
+	}
+	if err := runRange(nil, &bytes.Buffer{}, "0-1", "a", "b"); !errors.Is(err, errRangeFiles) {
+		t.Errorf("runRange with two files = %v, want %v", err, errRangeFiles)
+	}
+	errReader := iotest.ErrReader(errors.New("read error"))
+	if err := runRange(errReader, &bytes.Buffer{}, "2-4"); !errors.Is(err, errCopy) {
+		t.Errorf("runRange on a failing reader = %v, want %v", err, errCopy)
+	}
+}
+
+func TestSqueezeTrailing(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   []string
+		want string
+	}{
+		{name: "no trailing blanks", in: []string{"a\nb\n"}, want: "a\nb\n"},
+		{name: "no final newline", in: []string{"a\nb"}, want: "a\nb"},
+		{name: "trailing blanks", in: []string{"a\n\n\n\n"}, want: "a\n"},
+		{name: "interior blanks", in: []string{"a\n\n\nb\n\n"}, want: "a\n\n\nb\n"},
+		{name: "blanks across writes", in: []string{"a\n", "\n", "\n\n", "b", "\n", "\n"}, want: "a\n\n\n\nb\n"},
+		{name: "trailing blanks across files", in: []string{"a\n\n", "\n", ""}, want: "a\n"},
+		{name: "only blanks", in: []string{"\n\n", "\n"}, want: ""},
+		{name: "nothing", in: nil, want: ""},
+		{name: "spaces are not blank", in: []string{"a\n \n\n"}, want: "a\n \n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &trailingWriter{w: &out}
+			for _, s := range tt.in {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", s, n, err, len(s))
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSqueezeTrailingRun(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, s := range []string{"x\n\n", "\ny\n\n\n", "\n\n"} {
+		f := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(f, []byte(s), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	var out bytes.Buffer
+	w := &trailingWriter{w: &out}
+	if err := run(nil, w, files...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "x\n\n\ny\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   []string
+		// want is what has been written out after each of in.
+		want []string
+	}{
+		{name: "whole lines", in: []string{"a\n", "b\nc\n"}, want: []string{"a\n", "a\nb\nc\n"}},
+		{name: "partial line", in: []string{"a", "b", "c\nd"}, want: []string{"", "", "abc\n"}},
+		{name: "no newline", in: []string{"abc"}, want: []string{""}},
+		{name: "blank lines", in: []string{"\n\n", "\n"}, want: []string{"\n\n", "\n\n\n"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newLineWriter(&out)
+			for i, s := range tt.in {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", s, n, err, len(s))
+				}
+				if out.String() != tt.want[i] {
+					t.Errorf("after Write(%q): got %q, want %q", s, out.String(), tt.want[i])
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := out.String(), strings.Join(tt.in, ""); got != want {
+				t.Errorf("after Flush: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// lineReader reports every Write to it as one line.
+type lineReader chan string
+
+func (l lineReader) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestLineBufferedRun(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	lines := make(lineReader)
+	l := newLineWriter(lines)
+	done := make(chan error)
+	go func() {
+		done <- run(r, l)
+	}()
+
+	// A slow producer: each line is only written once the previous one
+	// has come out the other end, which it would never do if cat held on
+	// to it. Lines are written in two halves to make sure cat does not let
+	// half a line through.
+	for _, s := range []string{"one\n", "two\n", "three\n"} {
+		half := len(s) / 2
+		if _, err := w.WriteString(s[:half]); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if _, err := w.WriteString(s[half:]); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-lines:
+			if got != s {
+				t.Fatalf("got %q, want %q", got, s)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("line %q did not come out", s)
+		}
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
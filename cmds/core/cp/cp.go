@@ -6,21 +6,27 @@
 //
 // Synopsis:
 //
-//	cp [-rRfivwP] FROM... TO
+//	cp [-aRrfivPx] [-j n] [--verify] FROM... TO
 //
 // Options:
 //
-//	-w n: number of worker goroutines
-//	-R: copy file hierarchies
+//	-j n: with -R, copy up to n files at once
+//	-a: archive mode, same as -R -P
+//	-R: copy file hierarchies; FIFOs, sockets and device nodes are
+//	    recreated rather than read
 //	-r: alias to -R recursive mode
 //	-i: prompt about overwriting file
 //	-f: force overwrite files
 //	-v: verbose copy mode
 //	-P: don't follow symlinks
+//	-x: with -R, don't descend into directories on other file systems
+//	--verify: read each regular file back after copying it and fail if its
+//	    SHA-256 differs from the source's
 package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -28,17 +34,24 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/u-root/u-root/pkg/cp"
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
+var errJobs = errors.New("number of jobs must be positive")
+
 type flags struct {
 	recursive        bool
 	ask              bool
 	force            bool
 	verbose          bool
 	noFollowSymlinks bool
+	archive          bool
+	oneFileSystem    bool
+	verify           bool
+	jobs             int
 }
 
 // promptOverwrite ask if the user wants overwrite file
@@ -93,8 +106,12 @@ func setupPreCallback(recursive, ask, force bool, writer io.Writer, reader bufio
 }
 
 func setupPostCallback(verbose bool, w io.Writer) func(src, dst string) {
+	// With -j, files are done copying in several goroutines at once.
+	var mu sync.Mutex
 	return func(src, dst string) {
 		if verbose {
+			mu.Lock()
+			defer mu.Unlock()
 			fmt.Fprintf(w, "%q -> %q\n", src, dst)
 		}
 	}
@@ -124,13 +141,33 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 	fs.BoolVar(&f.noFollowSymlinks, "no-dereference", false, "don't follow symlinks")
 	fs.BoolVar(&f.noFollowSymlinks, "P", false, "don't follow symlinks (shorthand)")
 
+	fs.BoolVar(&f.archive, "archive", false, "same as -R -P")
+	fs.BoolVar(&f.archive, "a", false, "same as -R -P (shorthand)")
+
+	fs.BoolVar(&f.oneFileSystem, "one-file-system", false, "stay on the file system of each source")
+	fs.BoolVar(&f.oneFileSystem, "x", false, "stay on the file system of each source (shorthand)")
+
+	fs.BoolVar(&f.verify, "verify", false, "check each copied file against the source's SHA-256")
+
+	fs.IntVar(&f.jobs, "jobs", 1, "number of files to copy at once with -R")
+	fs.IntVar(&f.jobs, "j", 1, "number of files to copy at once with -R (shorthand)")
+
 	fs.Usage = func() {
-		fmt.Fprintf(fs.Output(), "Usage: cp [-RrifvP] file[s] ... dest\n\n")
+		fmt.Fprintf(fs.Output(), "Usage: cp [-aRrifvPx] [-j n] [--verify] file[s] ... dest\n\n")
 		fs.PrintDefaults()
 	}
 
 	fs.Parse(unixflag.ArgsToGoArgs(args[1:]))
 
+	if f.archive {
+		f.recursive = true
+		f.noFollowSymlinks = true
+	}
+
+	if f.jobs < 1 {
+		return errJobs
+	}
+
 	if fs.NArg() < 2 {
 		fs.Usage()
 		os.Exit(1)
@@ -149,6 +186,14 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 	opts := cp.Options{
 		NoFollowSymlinks: f.noFollowSymlinks,
 
+		// Like other cps, copy special files found in a hierarchy
+		// as special files instead of reading from them.
+		CopySpecial: f.recursive,
+
+		OneFileSystem: f.oneFileSystem,
+
+		Verify: f.verify,
+
 		// cp the command makes sure that
 		//
 		// (1) the files it's copying aren't already the same,
@@ -157,6 +202,8 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 		PreCallback: setupPreCallback(f.recursive, f.ask, f.force, w, *i),
 
 		PostCallback: setupPostCallback(f.verbose, w),
+
+		Jobs: f.jobs,
 	}
 
 	var lastErr error
@@ -165,10 +212,15 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 		if todir {
 			dst = filepath.Join(dst, filepath.Base(file))
 		}
+		var err error
 		if f.recursive {
-			lastErr = opts.CopyTree(file, dst)
+			err = opts.CopyTree(file, dst)
 		} else {
-			lastErr = opts.Copy(file, dst)
+			err = opts.Copy(file, dst)
+		}
+		// Keep going, but don't let a later success hide a failure.
+		if err != nil {
+			lastErr = err
 		}
 	}
 	return lastErr
@@ -18,6 +18,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/u-root/u-root/pkg/cp"
@@ -446,3 +447,147 @@ func IsEqualTree(o cp.Options, src, dst string) error {
 		return fmt.Errorf("unsupported mode: %s", srcInfo.Mode())
 	}
 }
+
+// cp -a recreates special files instead of reading them.
+// cmd-line equivalent: $ cp -a dev/ newroot/dev
+func TestCpArchiveSpecial(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "dev")
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Mkfifo(filepath.Join(src, "initctl"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	haveDev := true
+	if err := unix.Mknod(filepath.Join(src, "zero"), unix.S_IFCHR|0o666, int(unix.Mkdev(1, 5))); errors.Is(err, unix.EPERM) {
+		haveDev = false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(tempDir, "newdev")
+	var out bytes.Buffer
+	var in bufio.Reader
+	if err := run([]string{"cp", "-a", src, dst}, &out, &in); err != nil {
+		t.Fatalf(`run([]string{"cp", "-a", src, dst}, &out, &in) = %q, not nil`, err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "initctl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Type() != fs.ModeNamedPipe {
+		t.Errorf("initctl: got mode %v, want a named pipe", fi.Mode())
+	}
+	if !haveDev {
+		return
+	}
+	fi, err = os.Lstat(filepath.Join(dst, "zero"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Type() != fs.ModeDevice|fs.ModeCharDevice {
+		t.Errorf("zero: got mode %v, want a character device", fi.Mode())
+	}
+	if rdev := fi.Sys().(*syscall.Stat_t).Rdev; rdev != unix.Mkdev(1, 5) {
+		t.Errorf("zero: got device %d:%d, want 1:5", unix.Major(rdev), unix.Minor(rdev))
+	}
+}
+
+// cp -j copies several files at once.
+// cmd-line equivalent: $ cp -R -j 4 -v src/ dst/
+func TestCpJobs(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFilesTree(srcDir, maxDirDepth, 0); err != nil {
+		t.Fatalf(`createFilesTree(srcDir, maxDirDepth, 0) = %q, not nil`, err)
+	}
+
+	dstDir := filepath.Join(tempDir, "dst")
+	var out bytes.Buffer
+	var in bufio.Reader
+	if err := run([]string{"cp", "-R", "-j", "4", "-v", srcDir, dstDir}, &out, &in); err != nil {
+		t.Fatalf(`run([]string{"cp", "-R", "-j", "4", "-v", srcDir, dstDir}, &out, &in) = %q, not nil`, err)
+	}
+	if err := IsEqualTree(cp.Default, srcDir, dstDir); err != nil {
+		t.Fatalf(`IsEqualTree(cp.Default, srcDir, dstDir) = %q, not nil`, err)
+	}
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.Contains(l, " -> ") {
+			t.Errorf("verbose output has a garbled line %q", l)
+		}
+	}
+
+	if err := run([]string{"cp", "-R", "-j", "0", srcDir, dstDir}, &out, &in); !errors.Is(err, errJobs) {
+		t.Errorf(`run([]string{"cp", "-R", "-j", "0", srcDir, dstDir}, &out, &in) = %v, want %v`, err, errJobs)
+	}
+}
+
+func TestCpOneFileSystem(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFilesTree(srcDir, maxDirDepth, 0); err != nil {
+		t.Fatalf(`createFilesTree(srcDir, maxDirDepth, 0) = %q, not nil`, err)
+	}
+
+	// The whole tree is on one file system, so -x copies all of it.
+	dstDir := filepath.Join(tempDir, "dst")
+	var out bytes.Buffer
+	var in bufio.Reader
+	if err := run([]string{"cp", "-R", "-x", srcDir, dstDir}, &out, &in); err != nil {
+		t.Fatalf(`run([]string{"cp", "-R", "-x", srcDir, dstDir}, &out, &in) = %q, not nil`, err)
+	}
+	if err := IsEqualTree(cp.Default, srcDir, dstDir); err != nil {
+		t.Fatalf(`IsEqualTree(cp.Default, srcDir, dstDir) = %q, not nil`, err)
+	}
+}
+
+func TestCpVerify(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFilesTree(srcDir, maxDirDepth, 0); err != nil {
+		t.Fatalf(`createFilesTree(srcDir, maxDirDepth, 0) = %q, not nil`, err)
+	}
+
+	dstDir := filepath.Join(tempDir, "dst")
+	var out bytes.Buffer
+	var in bufio.Reader
+	if err := run([]string{"cp", "-R", "--verify", srcDir, dstDir}, &out, &in); err != nil {
+		t.Fatalf(`run([]string{"cp", "-R", "--verify", srcDir, dstDir}, &out, &in) = %q, not nil`, err)
+	}
+	if err := IsEqualTree(cp.Default, srcDir, dstDir); err != nil {
+		t.Fatalf(`IsEqualTree(cp.Default, srcDir, dstDir) = %q, not nil`, err)
+	}
+}
+
+func TestCpFirstErrorNotHidden(t *testing.T) {
+	tempDir := t.TempDir()
+	good := filepath.Join(tempDir, "good")
+	if err := os.WriteFile(good, []byte("good"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dstDir := filepath.Join(tempDir, "dst")
+	if err := os.Mkdir(dstDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	var in bufio.Reader
+	missing := filepath.Join(tempDir, "missing")
+	if err := run([]string{"cp", missing, good, dstDir}, &out, &in); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("copying a missing file before a good one = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "good")); err != nil {
+		t.Errorf("good file was not copied: %v", err)
+	}
+}
@@ -17,6 +17,9 @@
 //	i: output files from a stdin stream
 //	t: print table of contents
 //	-v: debug prints
+//	-reproducible: in o mode, zero mtimes, uids and gids and sort the
+//	               entries by name so that the same tree always produces
+//	               the same archive
 //
 // Bugs: in i mode, it can't use non-seekable stdin, i.e. a pipe. Yep, this sucks.
 // But if we implement seek on such things, we have to do it by reading, which
@@ -34,6 +37,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 
 	"github.com/u-root/u-root/pkg/cpio"
 )
@@ -42,11 +46,12 @@ var (
 	debug  = func(string, ...interface{}) {}
 	d      = flag.Bool("v", false, "Debug prints")
 	format = flag.String("H", "newc", "format")
+	repro  = flag.Bool("reproducible", false, "Create a reproducible archive")
 
 	errInvalidArgs = errors.New("usage of the command:\ncpio o < name-list [> archive]\ncpio i [< archive]\ncpio p destination-directory < name-list\nOptions: -H format (default: newc) -v Debug prints ")
 )
 
-func run(args []string, stdin *os.File, stdout io.Writer, d bool, format string) error {
+func run(args []string, stdin *os.File, stdout io.Writer, d bool, format string, reproducible bool) error {
 	if d {
 		debug = log.Printf
 	}
@@ -127,21 +132,33 @@ func run(args []string, stdin *os.File, stdout io.Writer, d bool, format string)
 	case "o":
 		rw := archiver.Writer(stdout)
 		cr := cpio.NewRecorder()
+		var names []string
 		scanner := bufio.NewScanner(stdin)
 		for scanner.Scan() {
-			name := scanner.Text()
+			names = append(names, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading stdin: %w", err)
+		}
+
+		if reproducible {
+			// Inode numbers are handed out in the order records are
+			// created, so sort before creating any of them.
+			sort.Strings(names)
+		}
+		for _, name := range names {
 			rec, err := cr.GetRecord(name)
 			if err != nil {
 				return fmt.Errorf("getting record of %q failed: %w", name, err)
 			}
+			if reproducible {
+				rec = cpio.MakeReproducible(rec)
+			}
 			if err := rw.WriteRecord(rec); err != nil {
 				return fmt.Errorf("writing record %q failed: %w", name, err)
 			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("error reading stdin: %w", err)
-		}
 		if err := cpio.WriteTrailer(rw); err != nil {
 			return fmt.Errorf("error writing trailer record: %w", err)
 		}
@@ -171,7 +188,7 @@ func run(args []string, stdin *os.File, stdout io.Writer, d bool, format string)
 
 func main() {
 	flag.Parse()
-	if err := run(flag.Args(), os.Stdin, os.Stdout, *d, *format); err != nil {
+	if err := run(flag.Args(), os.Stdin, os.Stdout, *d, *format, *repro); err != nil {
 		log.Fatalf("cpio: %v", err)
 	}
 }
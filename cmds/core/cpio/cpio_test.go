@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 type dirEnt struct {
@@ -103,13 +104,13 @@ func TestCpioList(t *testing.T) {
 		t.Fatalf("failed to create temporary archive file: %v", err)
 	}
 
-	err = run([]string{"o"}, inputFile, archive, false, "newc")
+	err = run([]string{"o"}, inputFile, archive, false, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to build archive from filepaths: %v", err)
 	}
 
 	stdout := &bytes.Buffer{}
-	err = run([]string{"t"}, archive, stdout, false, "newc")
+	err = run([]string{"t"}, archive, stdout, false, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to list archive: %v", err)
 	}
@@ -129,7 +130,7 @@ func TestCpio(t *testing.T) {
 	targets, inputFile := prepareTestDir(t, tempDir)
 
 	archive := &bytes.Buffer{}
-	err := run([]string{"o"}, inputFile, archive, true, "newc")
+	err := run([]string{"o"}, inputFile, archive, true, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to build archive from filepaths: %v", err)
 	}
@@ -161,7 +162,7 @@ func TestCpio(t *testing.T) {
 		t.Fatalf("Change to extraction directory %v failed: %#v", tempExtractDir, err)
 	}
 
-	err = run([]string{"i"}, archiveFile, out, true, "newc")
+	err = run([]string{"i"}, archiveFile, out, true, "newc", false)
 	if err != nil {
 		t.Fatalf("Extraction failed:\n%#v\n%v\n", out, err)
 	}
@@ -209,9 +210,58 @@ func TestDirectoryHardLink(t *testing.T) {
 	}
 
 	want := &bytes.Buffer{}
-	err = run([]string{"i"}, archiveFile, want, true, "newc")
+	err = run([]string{"i"}, archiveFile, want, true, "newc", false)
 
 	if err != nil {
 		t.Fatalf("Extraction failed:\n%v\n%v\n", want, err)
 	}
 }
+
+func TestCpioReproducible(t *testing.T) {
+	tempDir := t.TempDir()
+	targets, _ := prepareTestDir(t, tempDir)
+
+	build := func(names []string) []byte {
+		t.Helper()
+		list, err := os.CreateTemp(tempDir, "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer list.Close()
+		for _, name := range names {
+			if _, err := fmt.Fprintln(list, filepath.Join(tempDir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := list.Seek(0, 0); err != nil {
+			t.Fatal(err)
+		}
+		archive := &bytes.Buffer{}
+		if err := run([]string{"o"}, list, archive, false, "newc", true); err != nil {
+			t.Fatalf("failed to build archive from filepaths: %v", err)
+		}
+		return archive.Bytes()
+	}
+
+	var names []string
+	for _, ent := range targets {
+		names = append(names, ent.Name)
+	}
+	first := build(names)
+
+	// Change the mtimes and present the files in reverse order.
+	later := time.Now().Add(time.Hour)
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	for _, name := range names {
+		if err := os.Chtimes(filepath.Join(tempDir, name), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second := build(names)
+
+	if !bytes.Equal(first, second) {
+		t.Errorf("reproducible archives differ")
+	}
+}
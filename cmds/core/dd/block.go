@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	errBlockUnblock = errors.New("conv=block and conv=unblock are mutually exclusive")
+	errCBS          = errors.New("conv=block and conv=unblock need a cbs greater than zero")
+	errBlockResume  = errors.New("conv=block and conv=unblock can not be combined with checkpoint")
+)
+
+// recordWriter converts between newline terminated and fixed size records
+// on the way to w. It must be flushed once all input has been written.
+type recordWriter interface {
+	io.Writer
+	Flush() error
+}
+
+// blockWriter implements conv=block. Every newline terminated record is
+// written as a cbs sized one, padded with spaces. Longer records are cut
+// off at cbs bytes.
+type blockWriter struct {
+	w   io.Writer
+	cbs int
+	rec []byte
+	// long is set once the current record no longer fits in cbs bytes.
+	long bool
+	// truncated counts the records that were cut off.
+	truncated int64
+}
+
+func newBlockWriter(w io.Writer, cbs int) *blockWriter {
+	return &blockWriter{w: w, cbs: cbs, rec: make([]byte, 0, cbs)}
+}
+
+// record appends the padded current record to out and starts a new one.
+func (b *blockWriter) record(out []byte) []byte {
+	out = append(out, b.rec...)
+	out = append(out, bytes.Repeat([]byte{' '}, b.cbs-len(b.rec))...)
+	b.rec = b.rec[:0]
+	b.long = false
+	return out
+}
+
+// Write implements io.Writer. It reports all of p as written, as the
+// output differs in size from the input.
+func (b *blockWriter) Write(p []byte) (int, error) {
+	var out []byte
+	for _, c := range p {
+		switch {
+		case c == '\n':
+			out = b.record(out)
+		case len(b.rec) < b.cbs:
+			b.rec = append(b.rec, c)
+		case !b.long:
+			b.long = true
+			b.truncated++
+		}
+	}
+	if _, err := b.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out a last record that lacks a newline.
+func (b *blockWriter) Flush() error {
+	if len(b.rec) == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.record(nil))
+	return err
+}
+
+// unblockWriter implements conv=unblock. Every cbs sized record loses its
+// trailing spaces and gets a newline instead.
+type unblockWriter struct {
+	w   io.Writer
+	cbs int
+	rec []byte
+}
+
+func newUnblockWriter(w io.Writer, cbs int) *unblockWriter {
+	return &unblockWriter{w: w, cbs: cbs, rec: make([]byte, 0, cbs)}
+}
+
+// record appends the trimmed current record to out and starts a new one.
+func (u *unblockWriter) record(out []byte) []byte {
+	out = append(out, bytes.TrimRight(u.rec, " ")...)
+	out = append(out, '\n')
+	u.rec = u.rec[:0]
+	return out
+}
+
+// Write implements io.Writer. It reports all of p as written, as the
+// output differs in size from the input.
+func (u *unblockWriter) Write(p []byte) (int, error) {
+	var out []byte
+	for _, c := range p {
+		u.rec = append(u.rec, c)
+		if len(u.rec) == u.cbs {
+			out = u.record(out)
+		}
+	}
+	if _, err := u.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out a last record shorter than cbs.
+func (u *unblockWriter) Flush() error {
+	if len(u.rec) == 0 {
+		return nil
+	}
+	_, err := u.w.Write(u.record(nil))
+	return err
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecordWriters(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		unblock   bool
+		cbs       int
+		in        string
+		want      string
+		truncated int64
+	}{
+		{name: "block short records", cbs: 4, in: "ab\ncd\n", want: "ab  cd  "},
+		{name: "block exact records", cbs: 2, in: "ab\ncd\n", want: "abcd"},
+		{name: "block long records", cbs: 3, in: "abcdef\ng\nhijk\n", want: "abcg  hij", truncated: 2},
+		{name: "block empty records", cbs: 2, in: "\n\na\n", want: "    a "},
+		{name: "block unterminated record", cbs: 4, in: "ab\ncd", want: "ab  cd  "},
+		{name: "block nothing", cbs: 4, in: "", want: ""},
+		{name: "unblock records", unblock: true, cbs: 4, in: "ab  cd  ", want: "ab\ncd\n"},
+		{name: "unblock full records", unblock: true, cbs: 2, in: "abcd", want: "ab\ncd\n"},
+		{name: "unblock blank records", unblock: true, cbs: 2, in: "    a ", want: "\n\na\n"},
+		{name: "unblock short last record", unblock: true, cbs: 4, in: "ab  c ", want: "ab\nc\n"},
+		{name: "unblock keeps leading spaces", unblock: true, cbs: 4, in: " a b", want: " a b\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var rw recordWriter
+			if tt.unblock {
+				rw = newUnblockWriter(&out, tt.cbs)
+			} else {
+				rw = newBlockWriter(&out, tt.cbs)
+			}
+			// Write a byte at a time, so records span writes.
+			for i := range len(tt.in) {
+				if n, err := rw.Write([]byte{tt.in[i]}); n != 1 || err != nil {
+					t.Fatalf("Write() = %d, %v, want 1, nil", n, err)
+				}
+			}
+			if err := rw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if b, ok := rw.(*blockWriter); ok && b.truncated != tt.truncated {
+				t.Errorf("truncated %d records, want %d", b.truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestBlockRun(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		args   []string
+		in     string
+		out    string
+		stderr string
+		err    error
+	}{
+		{
+			name:   "block",
+			args:   []string{"conv=block", "cbs=4", "status=xfer"},
+			in:     "ab\ncdefg\nhijklm\n",
+			out:    "ab  cdefhijk",
+			stderr: "2 truncated records\n",
+		},
+		{
+			name:   "one truncated record",
+			args:   []string{"conv=block", "cbs=4", "bs=3"},
+			in:     "abcdefg\n",
+			out:    "abcd",
+			stderr: "1 truncated record\n",
+		},
+		{
+			name:   "quiet",
+			args:   []string{"conv=block", "cbs=4", "status=none"},
+			in:     "abcdefg\n",
+			out:    "abcd",
+			stderr: "",
+		},
+		{
+			name: "unblock",
+			args: []string{"conv=unblock,notrunc", "cbs=4"},
+			in:   "ab  cdefhijk",
+			out:  "ab\ncdef\nhijk\n",
+		},
+		{name: "no cbs", args: []string{"conv=block"}, err: errCBS},
+		{name: "both", args: []string{"conv=block,unblock", "cbs=4"}, err: errBlockUnblock},
+		{name: "checkpoint", args: []string{"conv=unblock", "cbs=4", "checkpoint=x"}, err: errBlockResume},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(strings.NewReader(tt.in), &ws{Writer: &stdout}, &stderr, tt.name, tt.args)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("run(%q) = %v, want %v", tt.args, err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := stdout.String(); got != tt.out {
+				t.Errorf("output = %q, want %q", got, tt.out)
+			}
+			// The count of truncated records follows the records and
+			// comes before the transfer line.
+			got := stderr.String()
+			_, rest, _ := strings.Cut(got, "records out\n")
+			if !strings.HasPrefix(rest, tt.stderr) || (tt.stderr == "" && strings.Contains(got, "truncated")) {
+				t.Errorf("stderr = %q, want %q after the records", got, tt.stderr)
+			}
+		})
+	}
+}
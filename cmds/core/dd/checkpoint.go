@@ -0,0 +1,146 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointInterval is how often the checkpoint file is updated.
+var checkpointInterval = time.Second
+
+var errResume = errors.New("resume requires a checkpoint file")
+
+// readCheckpoint returns the number of bytes an earlier run recorded as
+// copied. A missing checkpoint means nothing was copied yet.
+func readCheckpoint(name string) (int64, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid checkpoint %q in %q", b, name)
+	}
+	return n, nil
+}
+
+// writeCheckpoint atomically replaces the checkpoint file with n. It writes
+// a temporary file next to it and renames that, so an interruption leaves
+// either the old or the new checkpoint, never half of one.
+func writeCheckpoint(name string, n int64) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := fmt.Fprintf(f, "%d\n", n); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// checkpointer records the progress of a copy every checkpointInterval.
+type checkpointer struct {
+	name    string
+	out     io.Writer
+	base    int64
+	written *int64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+	err  error
+}
+
+// startCheckpoints starts recording base plus the bytes written to out, as
+// counted in written, in the checkpoint file name.
+func startCheckpoints(name string, out io.Writer, base int64, written *int64) *checkpointer {
+	c := &checkpointer{name: name, out: out, base: base, written: written, quit: make(chan struct{})}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		t := time.NewTicker(checkpointInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.quit:
+				return
+			case <-t.C:
+				if err := c.save(); err != nil {
+					c.err = err
+				}
+			}
+		}
+	}()
+	return c
+}
+
+// save syncs the output, so that the bytes about to be recorded are on
+// stable storage, and updates the checkpoint.
+func (c *checkpointer) save() error {
+	n := atomic.LoadInt64(c.written)
+	if s, ok := c.out.(interface{ Sync() error }); ok {
+		// Pipes and terminals can't be synced. That's fine, they
+		// can't be resumed either.
+		_ = s.Sync()
+	}
+	return writeCheckpoint(c.name, c.base+n)
+}
+
+// stop stops the periodic updates and records the final count.
+func (c *checkpointer) stop() error {
+	close(c.quit)
+	c.wg.Wait()
+	if err := c.save(); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if c.err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", c.err)
+	}
+	return nil
+}
+
+// resumeInput advances in past the n bytes already copied.
+func resumeInput(in io.Reader, n int64) error {
+	if s, ok := in.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, in, n)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// resumeOutput advances out past the n bytes already copied.
+func resumeOutput(out io.Writer, n int64) error {
+	s, ok := out.(io.Seeker)
+	if !ok {
+		return fmt.Errorf("can't resume on output that can't seek")
+	}
+	_, err := s.Seek(n, io.SeekCurrent)
+	return err
+}
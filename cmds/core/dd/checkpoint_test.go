@@ -0,0 +1,108 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789abcdef", 4))
+	input := append([]byte("SKIP"), data...)
+
+	d := t.TempDir()
+	in := filepath.Join(d, "in")
+	out := filepath.Join(d, "out")
+	cp := filepath.Join(d, "checkpoint")
+	if err := os.WriteFile(in, input, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("KEEP"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"bs=4", "skip=1", "seek=1", "of=" + out, "checkpoint=" + cp, "-resume"}
+
+	// The medium fails after 20 bytes of data.
+	errFlaky := errors.New("flaky medium")
+	flaky := io.MultiReader(bytes.NewReader(input[:24]), iotest.ErrReader(errFlaky))
+	if err := run(flaky, &ws{Writer: io.Discard}, &ws{Writer: io.Discard}, "dd", args); !errors.Is(err, errFlaky) {
+		t.Fatalf("interrupted run: got %v, want %v", err, errFlaky)
+	}
+	if n, err := readCheckpoint(cp); err != nil || n != 20 {
+		t.Fatalf("checkpoint after interruption = %d, %v; want 20, nil", n, err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "KEEP" + string(data[:20]); string(got) != want {
+		t.Fatalf("output after interruption = %q, want %q", got, want)
+	}
+
+	// Only the rest of the input is read when resuming.
+	rest := iotest.ErrReader(errors.New("read from the start"))
+	if err := run(rest, &ws{Writer: io.Discard}, &ws{Writer: io.Discard}, "dd", append(args, "if="+in)); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if n, err := readCheckpoint(cp); err != nil || n != int64(len(data)) {
+		t.Errorf("checkpoint after resuming = %d, %v; want %d, nil", n, err, len(data))
+	}
+	got, err = os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "KEEP" + string(data); string(got) != want {
+		t.Errorf("output after resuming = %q, want %q", got, want)
+	}
+
+	matches, err := filepath.Glob(cp + ".tmp*")
+	if err != nil || len(matches) != 0 {
+		t.Errorf("temporary checkpoint files were left behind: %q, %v", matches, err)
+	}
+}
+
+func TestCheckpointFirstRun(t *testing.T) {
+	d := t.TempDir()
+	out := filepath.Join(d, "out")
+	cp := filepath.Join(d, "checkpoint")
+	if err := os.WriteFile(out, []byte("old contents to be truncated"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a checkpoint, -resume starts from scratch.
+	stdin := strings.NewReader("hello")
+	if err := run(stdin, &ws{Writer: io.Discard}, &ws{Writer: io.Discard}, "dd", []string{"of=" + out, "checkpoint=" + cp, "-resume"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(out); err != nil || string(got) != "hello" {
+		t.Errorf("output = %q, %v; want %q", got, err, "hello")
+	}
+	if n, err := readCheckpoint(cp); err != nil || n != 5 {
+		t.Errorf("checkpoint = %d, %v; want 5, nil", n, err)
+	}
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	if err := run(strings.NewReader(""), &ws{Writer: io.Discard}, &ws{Writer: io.Discard}, "dd", []string{"-resume"}); !errors.Is(err, errResume) {
+		t.Errorf("-resume without checkpoint: got %v, want %v", err, errResume)
+	}
+
+	cp := filepath.Join(t.TempDir(), "checkpoint")
+	for _, c := range []string{"", "abc", "-5\n"} {
+		if err := os.WriteFile(cp, []byte(c), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readCheckpoint(cp); err == nil {
+			t.Errorf("readCheckpoint(%q) succeeded, want an error", c)
+		}
+	}
+}
@@ -19,24 +19,60 @@
 //	-bs n:    input and output block size (default=0)
 //	-skip n:  skip n ibs-sized input blocks before reading (default=0)
 //	-seek n:  seek n obs-sized output blocks before writing (default=0)
-//	-conv s:  comma separated list of conversions (none|notrunc)
+//	-conv s:  comma separated list of conversions
+//	          (none|notrunc|trunc|block|unblock)
+//	-cbs n:   conversion record size for conv=block and conv=unblock
 //	-count n: copy only n ibs-sized input blocks
 //	-if:      defaults to stdin
 //	-of:      defaults to stdout
-//	-oflag:   comma separated list of out flags (none|sync|dsync)
+//	-oflag:   comma separated list of out flags (none|sync|dsync|append)
 //	-status:  print transfer stats to stderr, can be one of:
 //	    none:     do not display
 //	    xfer:     print on completion (default)
 //	    progress: print throughout transfer (GNU)
+//	-checkpoint file: record the number of bytes copied in file, every
+//	          second and when the copy stops
+//	-resume:  continue the copy recorded in the checkpoint file
 //
 // Notes:
 //
+//	The output file is truncated before writing, unless conv=notrunc is
+//	given or seek is not zero. With seek, dd patches a region of the file
+//	in place and leaves the bytes before and after it alone; conv=trunc
+//	makes it cut the file off at the seek offset instead.
+//
+//	oflag=append opens the output file in append mode, so that every write
+//	goes to its end, even when another process is writing to it as well.
+//	Append wins over positioning: the output is never truncated, and seek
+//	is ignored, as are conv=trunc and the truncation dd does by default.
+//
+//	conv=block turns newline terminated records into cbs sized ones,
+//	padded with spaces; records longer than cbs are cut off and counted
+//	in the summary. conv=unblock does the reverse: it strips the trailing
+//	spaces off every cbs sized record and ends it with a newline.
+//
+//	Before the transfer line, dd reports the blocks it read and wrote as
+//	"N+M records in" and "N+M records out", where N counts full blocks and
+//	M partial ones, as dd(1) does. Partial input blocks come from short
+//	reads, e.g. from a pipe, and from the end of the input.
+//
+//	if=/dev/zero, if=/dev/random and if=/dev/urandom are made up by dd
+//	itself, as zeros and as random bytes from crypto/rand, so they work in
+//	an initramfs without those device nodes. count limits them as usual;
+//	without it they never end.
+//
 //	Because UTF-8 clashes with block-oriented copying, `conv=lcase` and
 //	`conv=ucase` will not be supported. Additionally, research showed these
 //	arguments are rarely useful. Use tr instead.
+//
+//	To survive flaky media, run the same command with checkpoint=FILE and
+//	-resume until it succeeds. The first run finds no checkpoint and
+//	starts from the beginning; later ones skip the input and seek the
+//	output past what was copied before, and leave the output untruncated.
 package main
 
 import (
+	"crypto/rand"
 	"errors"
 	"flag"
 	"fmt"
@@ -62,13 +98,15 @@ type bitClearAndSet struct {
 // kernels.
 var convMap = map[string]bitClearAndSet{
 	"notrunc": {clear: os.O_TRUNC},
+	"trunc":   {set: os.O_TRUNC},
 }
 
 var flagMap = map[string]bitClearAndSet{
-	"sync": {set: os.O_SYNC},
+	"sync":   {set: os.O_SYNC},
+	"append": {set: os.O_APPEND},
 }
 
-var allowedFlags = os.O_TRUNC | os.O_SYNC
+var allowedFlags = os.O_TRUNC | os.O_SYNC | os.O_APPEND
 
 // intermediateBuffer is a buffer that one can write to and read from.
 type intermediateBuffer interface {
@@ -170,7 +208,18 @@ func (bp bufferPool) Destroy() {
 	close(bp.c)
 }
 
-func parallelChunkedCopy(r io.Reader, w io.Writer, inBufSize, outBufSize int64, bytesWritten *int64, flags int) error {
+// records counts the full and partial blocks read and written.
+type records struct {
+	fullIn, partialIn   int64
+	fullOut, partialOut int64
+}
+
+// print writes the counts in the form dd(1) uses.
+func (r *records) print(w io.Writer) {
+	fmt.Fprintf(w, "%d+%d records in\n%d+%d records out\n", r.fullIn, r.partialIn, r.fullOut, r.partialOut)
+}
+
+func parallelChunkedCopy(r io.Reader, w io.Writer, inBufSize, outBufSize int64, bytesWritten *int64, rec *records, flags int) error {
 	if inBufSize == 0 {
 		return fmt.Errorf("inBufSize is not allowed to be zero")
 	}
@@ -209,6 +258,11 @@ func parallelChunkedCopy(r io.Reader, w io.Writer, inBufSize, outBufSize int64,
 			default:
 				buf := pool.Get()
 				n, err := buf.ReadFrom(r)
+				if n == inBufSize {
+					rec.fullIn++
+				} else if n > 0 {
+					rec.partialIn++
+				}
 				if n > 0 {
 					readyBufs <- buf
 				}
@@ -230,6 +284,12 @@ func parallelChunkedCopy(r io.Reader, w io.Writer, inBufSize, outBufSize int64,
 			break
 		} else {
 			atomic.AddInt64(bytesWritten, n)
+			// WriteTo writes outBufSize chunks, the last of which
+			// may be short.
+			rec.fullOut += n / outBufSize
+			if n%outBufSize != 0 {
+				rec.partialOut++
+			}
 		}
 		pool.Put(buf)
 	}
@@ -300,6 +360,23 @@ func (s *sectionReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// generators are the input files which dd makes up itself rather than
+// opens.
+var generators = map[string]io.Reader{
+	"/dev/zero":    zeroReader{},
+	"/dev/random":  rand.Reader,
+	"/dev/urandom": rand.Reader,
+}
+
+// zeroReader reads an endless stream of zeros.
+type zeroReader struct{}
+
+// Read implements io.Reader.
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
 // inFile opens the input file and seeks to the right position.
 func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count int64) (io.Reader, error) {
 	maxRead := int64(math.MaxInt64)
@@ -313,6 +390,11 @@ func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count in
 		return newStreamSectionReader(stdin, inputBytes*skip, maxRead), nil
 	}
 
+	if g, ok := generators[name]; ok {
+		// Skipping over generated input would not change what is read.
+		return io.LimitReader(g, maxRead), nil
+	}
+
 	in, err := os.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("error opening input file %q: %w", name, err)
@@ -320,29 +402,59 @@ func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count in
 	return io.NewSectionReader(in, inputBytes*skip, maxRead), nil
 }
 
-// outFile opens the output file and seeks to the right position.
+// outFile opens the output file and seeks to the right position. If flags
+// include O_TRUNC, the file is truncated at that position, so that whatever
+// lies before it is preserved. If flags include O_APPEND, every write goes
+// to the end of the file, so there is nothing to seek or truncate.
 func outFile(stdout io.WriteSeeker, name string, outputBytes int64, seek int64, flags int) (io.Writer, error) {
+	if flags&os.O_APPEND != 0 {
+		seek = 0
+		flags &^= os.O_TRUNC
+	}
 	var out io.WriteSeeker
+	var file *os.File
 	var err error
 	if name == "" {
 		out = stdout
 	} else {
-		perm := os.O_CREATE | os.O_WRONLY | (flags & allowedFlags)
-		if out, err = os.OpenFile(name, perm, 0o666); err != nil {
+		// O_TRUNC would throw away the data in front of the seek
+		// offset as well, so truncate by hand below.
+		perm := os.O_CREATE | os.O_WRONLY | (flags & allowedFlags &^ os.O_TRUNC)
+		if file, err = os.OpenFile(name, perm, 0o666); err != nil {
 			return nil, fmt.Errorf("error opening output file %q: %w", name, err)
 		}
+		out = file
 	}
 	if seek*outputBytes != 0 {
 		if _, err := out.Seek(seek*outputBytes, io.SeekCurrent); err != nil {
 			return nil, fmt.Errorf("error seeking output file: %w", err)
 		}
 	}
+	if file != nil && flags&os.O_TRUNC != 0 {
+		if err := truncate(file, seek*outputBytes); err != nil {
+			return nil, fmt.Errorf("error truncating output file: %w", err)
+		}
+	}
 	return out, nil
 }
 
+// truncate cuts f off at size. Devices and pipes can not be truncated, and
+// that is not an error.
+func truncate(f *os.File, size int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	return f.Truncate(size)
+}
+
 func usage() {
-	log.Fatal(`Usage: dd [if=file] [of=file] [conv=none|notrunc] [seek=#] [skip=#]
-			     [count=#] [bs=#] [ibs=#] [obs=#] [status=none|xfer|progress] [oflag=none|sync|dsync]
+	log.Fatal(`Usage: dd [if=file] [of=file] [conv=none|notrunc|trunc|block|unblock] [seek=#] [skip=#]
+			     [count=#] [bs=#] [ibs=#] [obs=#] [cbs=#] [status=none|xfer|progress] [oflag=none|sync|dsync|append]
+			     [checkpoint=file [-resume]]
 		options may also be invoked Go-style as -opt value or -opt=value
 		bs, if specified, overrides ibs and obs`)
 }
@@ -375,12 +487,15 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 	var (
 		skip    = f.Int64("skip", 0, "skip N ibs-sized blocks before reading")
 		seek    = f.Int64("seek", 0, "seek N obs-sized blocks before writing")
-		conv    = f.String("conv", "none", "comma separated list of conversions (none|notrunc)")
+		conv    = f.String("conv", "none", "comma separated list of conversions (none|notrunc|trunc|block|unblock)")
 		count   = f.Int64("count", math.MaxInt64, "copy only N input blocks")
 		inName  = f.String("if", "", "Input file")
 		outName = f.String("of", "", "Output file")
-		oFlag   = f.String("oflag", "none", "comma separated list of out flags (none|sync|dsync)")
+		oFlag   = f.String("oflag", "none", "comma separated list of out flags (none|sync|dsync|append)")
 		status  = f.String("status", "xfer", "display status of transfer (none|xfer|progress)")
+
+		checkpoint = f.String("checkpoint", "", "record the number of bytes copied in this file")
+		resume     = f.Bool("resume", false, "continue the copy recorded in the checkpoint file")
 	)
 	ddUnits := unit.DefaultUnits
 	ddUnits["c"] = 1
@@ -392,10 +507,12 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		ibs = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
 		obs = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
 		bs  = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
+		cbs = unit.MustNewUnit(ddUnits).MustNewValue(0, unit.None)
 	)
 	f.Var(ibs, "ibs", "Default input block size")
 	f.Var(obs, "obs", "Default output block size")
 	f.Var(bs, "bs", "Default input and output block size")
+	f.Var(cbs, "cbs", "Conversion record size for conv=block and conv=unblock")
 
 	// rather than, in essence, recreating all the apparatus of flag.xxxx
 	// with the if= bits, including dup checking, conversion, etc. we just
@@ -408,11 +525,20 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		usage()
 	}
 
-	// Convert conv argument to bit set.
-	flags := os.O_TRUNC
+	// Convert conv argument to bit set. Writing at an offset patches the
+	// file in place, unless conv=trunc asks for more.
+	var flags int
+	if *seek == 0 {
+		flags = os.O_TRUNC
+	}
+	var block, unblock bool
 	if *conv != "none" {
 		for _, c := range strings.Split(*conv, ",") {
-			if v, ok := convMap[c]; ok {
+			if c == "block" {
+				block = true
+			} else if c == "unblock" {
+				unblock = true
+			} else if v, ok := convMap[c]; ok {
 				flags &= ^v.clear
 				flags |= v.set
 			} else {
@@ -422,6 +548,19 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		}
 	}
 
+	if block || unblock {
+		switch {
+		case block && unblock:
+			return errBlockUnblock
+		case cbs.Value <= 0:
+			return errCBS
+		case *checkpoint != "":
+			// The checkpoint counts input bytes, which no longer
+			// match the output offset.
+			return errBlockResume
+		}
+	}
+
 	// Convert oflag argument to bit set.
 	if *oFlag != "none" {
 		for _, f := range strings.Split(*oFlag, ",") {
@@ -439,7 +578,24 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		usage()
 	}
 
+	var done int64
+	if *resume {
+		if *checkpoint == "" {
+			return errResume
+		}
+		var err error
+		if done, err = readCheckpoint(*checkpoint); err != nil {
+			return err
+		}
+		// The first run truncated the output already, if it was asked
+		// to. Doing it again would throw away what it copied.
+		if done > 0 {
+			flags &^= os.O_TRUNC
+		}
+	}
+
 	var bytesWritten int64
+	var rec records
 	progress := progress.New(stderr, *status, &bytesWritten)
 	progress.Begin()
 
@@ -457,10 +613,52 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 	if err != nil {
 		return err
 	}
-	if err := parallelChunkedCopy(in, out, ibs.Value, obs.Value, &bytesWritten, flags); err != nil {
+	var rw recordWriter
+	switch {
+	case block:
+		rw = newBlockWriter(out, int(cbs.Value))
+	case unblock:
+		rw = newUnblockWriter(out, int(cbs.Value))
+	}
+	if rw != nil {
+		out = rw
+	}
+	if done > 0 {
+		if err := resumeInput(in, done); err != nil {
+			return fmt.Errorf("error resuming input: %w", err)
+		}
+		if err := resumeOutput(out, done); err != nil {
+			return fmt.Errorf("error resuming output: %w", err)
+		}
+	}
+	if *checkpoint == "" {
+		err = parallelChunkedCopy(in, out, ibs.Value, obs.Value, &bytesWritten, &rec, flags)
+	} else {
+		c := startCheckpoints(*checkpoint, out, done, &bytesWritten)
+		err = parallelChunkedCopy(in, out, ibs.Value, obs.Value, &bytesWritten, &rec, flags)
+		err = errors.Join(err, c.stop())
+	}
+	if err != nil {
 		return err
 	}
+	if rw != nil {
+		if err := rw.Flush(); err != nil {
+			return fmt.Errorf("output error: %w", err)
+		}
+	}
 
+	// dd(1) reports the records before the transfer line.
+	progress.Stop()
+	if *status != "none" {
+		rec.print(stderr)
+	}
+	if b, ok := rw.(*blockWriter); ok && b.truncated > 0 && *status != "none" {
+		s := "s"
+		if b.truncated == 1 {
+			s = ""
+		}
+		fmt.Fprintf(stderr, "%d truncated record%s\n", b.truncated, s)
+	}
 	progress.End()
 	return nil
 }
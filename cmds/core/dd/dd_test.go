@@ -197,7 +197,7 @@ func TestParallelChunkedCopy(t *testing.T) {
 			readBuf := bytes.NewReader(tt.inputBuffer)
 
 			var bytesWritten int64
-			err := parallelChunkedCopy(readBuf, writeBuf, int64(len(tt.inputBuffer)), 8, &bytesWritten, 0)
+			err := parallelChunkedCopy(readBuf, writeBuf, int64(len(tt.inputBuffer)), 8, &bytesWritten, &records{}, 0)
 
 			if err != nil && !tt.wantError {
 				t.Errorf("parallelChunkedCopy failed with %v", err)
@@ -547,6 +547,78 @@ func TestDd(t *testing.T) {
 	}
 }
 
+func TestRecords(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flags  []string
+		writes []string
+		want   string
+	}{
+		{
+			name:   "full blocks",
+			flags:  []string{"bs=4"},
+			writes: []string{"abcd", "efgh"},
+			want:   "2+0 records in\n2+0 records out\n",
+		},
+		{
+			// Each write is one read, cut at bs: 2, 4, 4 and 2 bytes.
+			name:   "short reads",
+			flags:  []string{"bs=4"},
+			writes: []string{"ab", "cdef", "ghijkl"},
+			want:   "2+2 records in\n2+2 records out\n",
+		},
+		{
+			name:   "smaller output blocks",
+			flags:  []string{"ibs=4", "obs=2"},
+			writes: []string{"ab", "cdef", "ghijkl"},
+			want:   "2+2 records in\n6+0 records out\n",
+		},
+		{
+			name:   "larger output blocks",
+			flags:  []string{"ibs=2", "obs=4"},
+			writes: []string{"abc"},
+			want:   "1+1 records in\n0+2 records out\n",
+		},
+		{
+			name:  "no input",
+			flags: []string{"bs=4"},
+			want:  "0+0 records in\n0+0 records out\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// A pipe hands each write to a single read, so short
+			// writes make short reads.
+			r, w := io.Pipe()
+			go func() {
+				for _, s := range tt.writes {
+					io.WriteString(w, s)
+				}
+				w.Close()
+			}()
+			var stdout, stderr bytes.Buffer
+			if err := run(r, &ws{Writer: &stdout}, &stderr, tt.name, tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := stdout.String(), strings.Join(tt.writes, ""); got != want {
+				t.Errorf("stdout = %q, want %q", got, want)
+			}
+			// As with dd(1), the records come before the transfer line.
+			got := stderr.String()
+			if !strings.HasPrefix(got, tt.want) || strings.Count(got[len(tt.want):], "\n") != 1 || !strings.Contains(got[len(tt.want):], "copied") {
+				t.Errorf("stderr = %q, want %q followed by the transfer line", got, tt.want)
+			}
+		})
+	}
+
+	var stderr bytes.Buffer
+	if err := run(strings.NewReader("abc"), &ws{Writer: io.Discard}, &stderr, "status=none", []string{"status=none"}); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("status=none: stderr = %q, want nothing", stderr.String())
+	}
+}
+
 // stdoutEqual creates a bufio Reader from io.Reader, then compares a byte at a time input []byte.
 // The third argument (int64) is ignored and only exists to make the function signature compatible
 // with func byteCount.
@@ -648,6 +720,68 @@ func TestFiles(t *testing.T) {
 			outFile:  []byte("abcde"),
 			expected: []byte("1234e"),
 		},
+		{
+			name:     "seek patches in place",
+			flags:    []string{"bs=1", "seek=2"},
+			inFile:   []byte("12"),
+			outFile:  []byte("abcdefgh"),
+			expected: []byte("ab12efgh"),
+		},
+		{
+			name:     "seek with notrunc",
+			flags:    []string{"bs=2", "seek=1", "conv=notrunc"},
+			inFile:   []byte("12"),
+			outFile:  []byte("abcdefgh"),
+			expected: []byte("ab12efgh"),
+		},
+		{
+			name:     "seek with trunc",
+			flags:    []string{"bs=1", "seek=2", "conv=trunc"},
+			inFile:   []byte("12"),
+			outFile:  []byte("abcdefgh"),
+			expected: []byte("ab12"),
+		},
+		{
+			name:     "seek past the end",
+			flags:    []string{"bs=1", "seek=4"},
+			inFile:   []byte("12"),
+			outFile:  []byte("ab"),
+			expected: []byte("ab\x00\x0012"),
+		},
+		{
+			name:     "patch a block of a larger image",
+			flags:    []string{"bs=4", "seek=1", "count=1", "conv=notrunc"},
+			inFile:   []byte("XXXXYYYY"),
+			outFile:  []byte("aaaabbbbccccdddd"),
+			expected: []byte("aaaaXXXXccccdddd"),
+		},
+		{
+			name:     "append",
+			flags:    []string{"bs=1", "oflag=append"},
+			inFile:   []byte("34\n"),
+			outFile:  []byte("12\n"),
+			expected: []byte("12\n34\n"),
+		},
+		{
+			name:     "append wins over seek",
+			flags:    []string{"bs=1", "seek=1", "oflag=append"},
+			inFile:   []byte("34\n"),
+			outFile:  []byte("12\n"),
+			expected: []byte("12\n34\n"),
+		},
+		{
+			name:     "append wins over trunc",
+			flags:    []string{"bs=1", "seek=1", "conv=trunc", "oflag=append,sync"},
+			inFile:   []byte("34\n"),
+			outFile:  []byte("12\n"),
+			expected: []byte("12\n34\n"),
+		},
+		{
+			name:     "append to an empty file",
+			flags:    []string{"oflag=append"},
+			inFile:   []byte("12\n"),
+			expected: []byte("12\n"),
+		},
 		{
 			// Fully testing the file is synchronous would require something more.
 			name:     "sync",
@@ -715,3 +849,67 @@ func BenchmarkDd(b *testing.B) {
 		b.Fatal(err)
 	}
 }
+
+func TestGenerators(t *testing.T) {
+	for _, name := range []string{"/dev/zero", "/dev/random", "/dev/urandom"} {
+		in, err := inFile(nil, name, 512, 0, 1)
+		if err != nil {
+			t.Fatalf("inFile(%q): %v", name, err)
+		}
+		if _, ok := in.(*io.LimitedReader); !ok {
+			t.Errorf("inFile(%q) = %T, want the generator", name, in)
+		}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		size  int
+		check func([]byte) error
+	}{
+		{
+			name:  "zero",
+			flags: []string{"if=/dev/zero", "bs=1K", "count=4"},
+			size:  4096,
+			check: func(b []byte) error {
+				if i := bytes.IndexFunc(b, func(r rune) bool { return r != 0 }); i >= 0 {
+					return fmt.Errorf("byte %d is %#x, want 0", i, b[i])
+				}
+				return nil
+			},
+		},
+		{
+			name:  "urandom",
+			flags: []string{"if=/dev/urandom", "bs=512", "count=2", "skip=3"},
+			size:  1024,
+			check: notConstant,
+		},
+		{
+			name:  "random",
+			flags: []string{"if=/dev/random", "ibs=100", "obs=7", "count=3"},
+			size:  300,
+			check: notConstant,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := run(strings.NewReader(""), &ws{Writer: &stdout}, &ws{Writer: io.Discard}, tt.name, tt.flags); err != nil {
+				t.Fatalf("run: got %v, want nil", err)
+			}
+			if stdout.Len() != tt.size {
+				t.Errorf("got %d bytes, want %d", stdout.Len(), tt.size)
+			}
+			if err := tt.check(stdout.Bytes()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// notConstant returns an error if all bytes of b are the same.
+func notConstant(b []byte) error {
+	if len(b) > 0 && bytes.Count(b, b[:1]) == len(b) {
+		return fmt.Errorf("all %d bytes are %#x", len(b), b[0])
+	}
+	return nil
+}
@@ -6,20 +6,29 @@
 //
 // Synopsis:
 //
-//	dmesg [-clear|-read-clear]
+//	dmesg [-clear|-read-clear|-file FILE] [-since DURATION]
 //
 // Options:
 //
 //	-clear: clear the log
 //	-read-clear: clear the log after printing
+//	-file: print a log saved from /dev/kmsg instead of the live log
+//	-since: only print messages logged within DURATION, e.g. 5m, of now.
+//	    Messages are timestamped with the time since boot, so a saved log
+//	    only makes sense with -since if it is from the running boot.
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -29,17 +38,187 @@ type cmd struct {
 	readClear bool
 }
 
+// errMalformed is returned for lines of a /dev/kmsg dump which can not be
+// decoded, such as the remains of a record that was cut off.
+var errMalformed = errors.New("malformed kmsg record")
+
+// uptime returns the time since boot on the clock printk timestamps messages
+// with. It can be replaced in tests.
+var uptime = func() (time.Duration, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, err
+	}
+	return time.Duration(ts.Nano()), nil
+}
+
+// sinceCutoff returns the timestamp, as time since boot, of messages logged
+// d ago. Earlier ones are not printed with -since.
+func sinceCutoff(d time.Duration) (time.Duration, error) {
+	up, err := uptime()
+	if err != nil {
+		return 0, err
+	}
+	return max(up-d, 0), nil
+}
+
+// kmsgRecord is a record as read from /dev/kmsg, see
+// Documentation/ABI/testing/dev-kmsg in the kernel tree.
+type kmsgRecord struct {
+	// prio holds facility<<3 | level.
+	prio int
+	seq  uint64
+	// usec is the time since boot in microseconds.
+	usec uint64
+	msg  string
+}
+
+// parseKmsg parses a /dev/kmsg record header and message, e.g.
+// "6,339,5140900,-;NET: Registered protocol family 10".
+func parseKmsg(line string) (kmsgRecord, error) {
+	hdr, msg, ok := strings.Cut(line, ";")
+	if !ok {
+		return kmsgRecord{}, fmt.Errorf("%w: %q: no message", errMalformed, line)
+	}
+	f := strings.Split(hdr, ",")
+	if len(f) < 4 {
+		return kmsgRecord{}, fmt.Errorf("%w: %q: short header", errMalformed, line)
+	}
+	prio, err := strconv.Atoi(f[0])
+	if err != nil {
+		return kmsgRecord{}, fmt.Errorf("%w: %q: %w", errMalformed, line, err)
+	}
+	seq, err := strconv.ParseUint(f[1], 10, 64)
+	if err != nil {
+		return kmsgRecord{}, fmt.Errorf("%w: %q: %w", errMalformed, line, err)
+	}
+	usec, err := strconv.ParseUint(f[2], 10, 64)
+	if err != nil {
+		return kmsgRecord{}, fmt.Errorf("%w: %q: %w", errMalformed, line, err)
+	}
+	return kmsgRecord{prio: prio, seq: seq, usec: usec, msg: msg}, nil
+}
+
+// String formats r the way syslog(2) returns it, which is what dmesg prints
+// for the live log.
+func (r kmsgRecord) String() string {
+	return fmt.Sprintf("<%d>[%5d.%06d] %s", r.prio, r.usec/1e6, r.usec%1e6, r.msg)
+}
+
+// printKmsg prints the records of a /dev/kmsg dump logged at cutoff or later.
+// Continuation lines, which start with a space and hold key/value pairs, are
+// skipped. Lines that can not be decoded, e.g. because the dump was cut off
+// mid record, are skipped and reported in the returned error once everything
+// else is printed.
+func printKmsg(out io.Writer, in io.Reader, cutoff time.Duration) error {
+	var errs []error
+	s := bufio.NewScanner(in)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || line[0] == ' ' {
+			continue
+		}
+		r, err := parseKmsg(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if time.Duration(r.usec)*time.Microsecond < cutoff {
+			continue
+		}
+		if _, err := fmt.Fprintln(out, r); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// syslogTime returns the timestamp of a line of the live log, e.g.
+// "<6>[    5.140900] NET: Registered protocol family 10".
+func syslogTime(line string) (time.Duration, bool) {
+	_, rest, ok := strings.Cut(line, "[")
+	if !ok {
+		return 0, false
+	}
+	ts, _, ok := strings.Cut(rest, "]")
+	if !ok {
+		return 0, false
+	}
+	sec, usec, ok := strings.Cut(strings.TrimSpace(ts), ".")
+	if !ok {
+		return 0, false
+	}
+	s, err := strconv.ParseUint(sec, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	us, err := strconv.ParseUint(usec, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(s)*time.Second + time.Duration(us)*time.Microsecond, true
+}
+
+// printSince prints the lines of the live log logged at cutoff or later.
+// Lines without a timestamp go with the line before them.
+func printSince(out io.Writer, b []byte, cutoff time.Duration) error {
+	keep := false
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if ts, ok := syslogTime(line); ok {
+			keep = ts >= cutoff
+		}
+		if !keep {
+			continue
+		}
+		if _, err := io.WriteString(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func run(out io.Writer, args []string) error {
 	var clear, readClear bool
+	var file string
+	var since time.Duration
 
 	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	f.BoolVar(&clear, "clear", false, "Clear the log")
 	f.BoolVar(&readClear, "read-clear", false, "Clear the log after printing")
-	f.Parse(args[1:])
+	f.StringVar(&file, "file", "", "Print a log saved from /dev/kmsg")
+	f.DurationVar(&since, "since", 0, "Only print messages logged within this duration of now, e.g. 5m")
+	if err := f.Parse(args[1:]); err != nil {
+		return err
+	}
 
 	if clear && readClear {
 		return fmt.Errorf("cannot specify both -clear and -read-clear:%w", os.ErrInvalid)
 	}
+	if since < 0 {
+		return fmt.Errorf("-since must not be negative:%w", os.ErrInvalid)
+	}
+	var cutoff time.Duration
+	if since > 0 {
+		var err error
+		if cutoff, err = sinceCutoff(since); err != nil {
+			return err
+		}
+	}
+	if file != "" {
+		if clear || readClear {
+			return fmt.Errorf("cannot clear a saved log:%w", os.ErrInvalid)
+		}
+		in, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return printKmsg(out, in, cutoff)
+	}
 
 	level := unix.SYSLOG_ACTION_READ_ALL
 	if clear {
@@ -55,6 +234,9 @@ func run(out io.Writer, args []string) error {
 		return fmt.Errorf("syslog failed: %w", err)
 	}
 
+	if since > 0 {
+		return printSince(out, b[:amt], cutoff)
+	}
 	_, err = out.Write(b[:amt])
 	return err
 }
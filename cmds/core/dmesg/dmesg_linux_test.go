@@ -8,9 +8,11 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hugelgupf/vmtest/guest"
 )
@@ -86,3 +88,161 @@ func TestDmesg(t *testing.T) {
 		})
 	}
 }
+
+func TestFile(t *testing.T) {
+	var out bytes.Buffer
+	err := run(&out, []string{"dmesg", "-file", "testdata/kmsg.txt"})
+	want := `<6>[    0.000000] Linux version 6.1.0 (builder@host) #1 SMP PREEMPT_DYNAMIC
+<6>[    0.000000] Command line: console=ttyS0 quiet
+<4>[    0.001523] x86/fpu: Supporting XSAVE feature 0x001
+<3>[ 1234.567890] EXT4-fs (vda1): bad geometry
+<30>[    2.000000] systemd[1]: Started Journal Service.
+<6>[    2.500001] virtio_net virtio0 eth0: renamed
+<5>[    3.000000] random: crng init do
+`
+	if out.String() != want {
+		t.Errorf("dmesg -file = \n%s\nwant\n%s", out.String(), want)
+	}
+	if !errors.Is(err, errMalformed) {
+		t.Errorf("dmesg -file = %v, want %v", err, errMalformed)
+	}
+	for _, bad := range []string{"garbage without a header", "bad timestamp"} {
+		if err == nil || !strings.Contains(err.Error(), bad) {
+			t.Errorf("dmesg -file = %v, want it to report %q", err, bad)
+		}
+	}
+}
+
+func TestFileTruncated(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(&out, []string{"dmesg", "-file", "testdata/truncated.txt"}); !errors.Is(err, errMalformed) {
+		t.Errorf("dmesg -file = %v, want %v", err, errMalformed)
+	}
+	if out.Len() != 0 {
+		t.Errorf("dmesg -file = %q, want no output", out.String())
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{name: "missing", args: []string{"dmesg", "-file", "testdata/missing"}, err: os.ErrNotExist},
+		{name: "clear", args: []string{"dmesg", "-clear", "-file", "testdata/kmsg.txt"}, err: os.ErrInvalid},
+		{name: "read clear", args: []string{"dmesg", "-read-clear", "-file", "testdata/kmsg.txt"}, err: os.ErrInvalid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(&bytes.Buffer{}, tt.args); !errors.Is(err, tt.err) {
+				t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseKmsg(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want kmsgRecord
+		err  bool
+	}{
+		{line: "6,339,5140900,-;NET: Registered protocol family 10", want: kmsgRecord{prio: 6, seq: 339, usec: 5140900, msg: "NET: Registered protocol family 10"}},
+		{line: "12,1,2,-,caller=T1;msg; with; semicolons", want: kmsgRecord{prio: 12, seq: 1, usec: 2, msg: "msg; with; semicolons"}},
+		{line: "6,1,2,-;", want: kmsgRecord{prio: 6, seq: 1, usec: 2}},
+		{line: "6,1,2;short", err: true},
+		{line: "x,1,2,-;bad prio", err: true},
+		{line: "6,1,2,-", err: true},
+	} {
+		got, err := parseKmsg(tt.line)
+		if (err != nil) != tt.err {
+			t.Errorf("parseKmsg(%q) = %v, want error %t", tt.line, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseKmsg(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSince(t *testing.T) {
+	defer func(u func() (time.Duration, error)) { uptime = u }(uptime)
+	uptime = func() (time.Duration, error) { return 100 * time.Second, nil }
+
+	// Records right around the cutoff, 90s after boot with -since 10s.
+	kmsg := filepath.Join(t.TempDir(), "kmsg")
+	if err := os.WriteFile(kmsg, []byte(`6,0,0,-;booting
+6,1,89999999,-;just too old
+ SUBSYSTEM=net
+6,2,90000000,-;right at the cutoff
+4,3,90000001,-;just recent enough
+3,4,99500000,-;recent
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		since string
+		want  string
+	}{
+		{
+			name:  "cutoff",
+			since: "10s",
+			want: `<6>[   90.000000] right at the cutoff
+<4>[   90.000001] just recent enough
+<3>[   99.500000] recent
+`,
+		},
+		{
+			name:  "recent only",
+			since: "1s",
+			want:  "<3>[   99.500000] recent\n",
+		},
+		{
+			name:  "longer than the uptime",
+			since: "1h",
+			want: `<6>[    0.000000] booting
+<6>[   89.999999] just too old
+<6>[   90.000000] right at the cutoff
+<4>[   90.000001] just recent enough
+<3>[   99.500000] recent
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(&out, []string{"dmesg", "-file", kmsg, "-since", tt.since}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("dmesg -since %s = \n%s\nwant\n%s", tt.since, out.String(), tt.want)
+			}
+		})
+	}
+
+	if err := run(&bytes.Buffer{}, []string{"dmesg", "-since", "-5m"}); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("dmesg -since -5m = %v, want %v", err, os.ErrInvalid)
+	}
+}
+
+func TestPrintSince(t *testing.T) {
+	const log = `<6>[    0.000000] booting
+<6>[   89.999999] just too old
+continued from the line before
+<6>[   90.000000] right at the cutoff
+continued from the line before
+<3>[ 1234.567890] recent
+`
+	var out bytes.Buffer
+	if err := printSince(&out, []byte(log), 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := `<6>[   90.000000] right at the cutoff
+continued from the line before
+<3>[ 1234.567890] recent
+`
+	if out.String() != want {
+		t.Errorf("printSince() = \n%s\nwant\n%s", out.String(), want)
+	}
+}
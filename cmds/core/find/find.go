@@ -11,7 +11,17 @@
 //	-mode integer-arg: match against mode, e.g. -mode 0755
 //	-type: match against a file type, e.g. -type f will match files
 //	-name: glob to match against file
+//	-empty: match empty regular files and empty directories
+//	-perm [-/]mode: match permission bits, octal or symbolic (e.g. u=rw,go=r).
+//	  mode matches the bits exactly, -mode matches if all of the bits are
+//	  set, /mode matches if any of the bits are set.
+//	-regex pattern: match the whole path, as it is printed, against a Go
+//	  regular expression. Like in GNU find, the pattern is anchored at both
+//	  ends, so -regex 'dir/.*' matches dir/a but not sub/dir/a.
+//	-iregex pattern: like -regex, but case insensitive
 //	-l: long listing. It's not very good, yet, but it's useful enough.
+//
+// All given predicates must match for a file to be printed.
 package main
 
 import (
@@ -21,6 +31,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/u-root/u-root/pkg/find"
@@ -30,6 +42,10 @@ type params struct {
 	fileType string
 	name     string
 	perm     int
+	permSpec string
+	regex    string
+	iregex   string
+	empty    bool
 	long     bool
 	debug    bool
 }
@@ -50,6 +66,165 @@ func command(stdout, stderr io.Writer, params params, args []string) *cmd {
 	}
 }
 
+// predicate reports whether a found file should be printed.
+type predicate func(*find.File) bool
+
+// permMatch is the comparison done by -perm.
+type permMatch byte
+
+const (
+	permExact permMatch = iota
+	permAll
+	permAny
+)
+
+// unixPerm returns the permission and set-id/sticky bits of m in their
+// traditional octal positions.
+func unixPerm(m os.FileMode) uint32 {
+	p := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		p |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		p |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		p |= 0o1000
+	}
+	return p
+}
+
+// parseSymbolicPerm parses a chmod-like symbolic mode, e.g. "u=rwx,go+r",
+// applied to a mode of 0.
+func parseSymbolicPerm(s string) (uint32, error) {
+	var mode uint32
+	for _, clause := range strings.Split(s, ",") {
+		op := strings.IndexAny(clause, "=+-")
+		if op < 0 {
+			return 0, fmt.Errorf("invalid symbolic mode %q", clause)
+		}
+		var who uint32
+		for _, w := range clause[:op] {
+			switch w {
+			case 'u':
+				who |= 0o4700
+			case 'g':
+				who |= 0o2070
+			case 'o':
+				who |= 0o1007
+			case 'a':
+				who |= 0o7777
+			default:
+				return 0, fmt.Errorf("invalid symbolic mode %q: unknown who %q", clause, w)
+			}
+		}
+		if who == 0 {
+			who = 0o7777
+		}
+		var bits uint32
+		for _, p := range clause[op+1:] {
+			switch p {
+			case 'r':
+				bits |= 0o444
+			case 'w':
+				bits |= 0o222
+			case 'x':
+				bits |= 0o111
+			case 's':
+				bits |= 0o6000
+			case 't':
+				bits |= 0o1000
+			default:
+				return 0, fmt.Errorf("invalid symbolic mode %q: unknown permission %q", clause, p)
+			}
+		}
+		bits &= who
+		switch clause[op] {
+		case '=':
+			mode = mode&^who | bits
+		case '+':
+			mode |= bits
+		case '-':
+			mode &^= bits
+		}
+	}
+	return mode, nil
+}
+
+// parsePerm parses a -perm argument, which is an octal or symbolic mode
+// optionally prefixed with - (all bits set) or / (any bit set).
+func parsePerm(spec string) (uint32, permMatch, error) {
+	match := permExact
+	switch {
+	case strings.HasPrefix(spec, "-"):
+		match, spec = permAll, spec[1:]
+	case strings.HasPrefix(spec, "/"):
+		match, spec = permAny, spec[1:]
+	}
+	if spec == "" {
+		return 0, 0, fmt.Errorf("empty permission")
+	}
+	if spec[0] >= '0' && spec[0] <= '9' {
+		m, err := strconv.ParseUint(spec, 8, 32)
+		if err != nil || m > 0o7777 {
+			return 0, 0, fmt.Errorf("invalid octal mode %q", spec)
+		}
+		return uint32(m), match, nil
+	}
+	m, err := parseSymbolicPerm(spec)
+	return m, match, err
+}
+
+func permPredicate(spec string) (predicate, error) {
+	want, match, err := parsePerm(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *find.File) bool {
+		got := unixPerm(f.Mode())
+		switch match {
+		case permAll:
+			return got&want == want
+		case permAny:
+			// GNU find matches everything for /000.
+			return want == 0 || got&want != 0
+		default:
+			return got == want
+		}
+	}, nil
+}
+
+// regexPredicate matches the whole path of a file against pattern.
+func regexPredicate(pattern string, fold bool) (predicate, error) {
+	pattern = `^(?:` + pattern + `)$`
+	if fold {
+		pattern = `(?i)` + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *find.File) bool {
+		return re.MatchString(f.Name)
+	}, nil
+}
+
+func isEmpty(f *find.File) bool {
+	switch {
+	case f.Mode().IsRegular():
+		return f.Size() == 0
+	case f.IsDir():
+		d, err := os.Open(f.Name)
+		if err != nil {
+			return false
+		}
+		defer d.Close()
+		_, err = d.Readdirnames(1)
+		return err == io.EOF
+	}
+	return false
+}
+
 func init() {
 	defUsage := flag.Usage
 	flag.Usage = func() {
@@ -95,6 +270,34 @@ func (c *cmd) run() error {
 		mask |= os.ModeType
 	}
 
+	var preds []predicate
+	if c.params.empty {
+		preds = append(preds, isEmpty)
+	}
+	if c.params.permSpec != "" {
+		p, err := permPredicate(c.params.permSpec)
+		if err != nil {
+			return fmt.Errorf("-perm: %w", err)
+		}
+		preds = append(preds, p)
+	}
+	for _, re := range []struct {
+		flag, pattern string
+		fold          bool
+	}{
+		{"-regex", c.params.regex, false},
+		{"-iregex", c.params.iregex, true},
+	} {
+		if re.pattern == "" {
+			continue
+		}
+		p, err := regexPredicate(re.pattern, re.fold)
+		if err != nil {
+			return fmt.Errorf("%s: %w", re.flag, err)
+		}
+		preds = append(preds, p)
+	}
+
 	debugLog := func(string, ...interface{}) {}
 	if c.params.debug {
 		debugLog = log.Printf
@@ -111,6 +314,9 @@ func (c *cmd) run() error {
 			fmt.Fprintf(c.stderr, "%s: %v\n", l.Name, l.Err)
 			continue
 		}
+		if !matchAll(preds, l) {
+			continue
+		}
 		if c.params.long {
 			fmt.Fprintf(c.stdout, "%s\n", l)
 			continue
@@ -121,14 +327,27 @@ func (c *cmd) run() error {
 	return nil
 }
 
+func matchAll(preds []predicate, f *find.File) bool {
+	for _, p := range preds {
+		if !p(f) {
+			return false
+		}
+	}
+	return true
+}
+
 func main() {
 	perm := flag.Int("mode", -1, "permissions")
 	fileType := flag.String("type", "", "file type")
 	name := flag.String("name", "", "glob for name")
+	permSpec := flag.String("perm", "", "permission bits: mode (exact), -mode (all of), /mode (any of)")
+	regex := flag.String("regex", "", "regular expression for the whole path")
+	iregex := flag.String("iregex", "", "case insensitive regular expression for the whole path")
+	empty := flag.Bool("empty", false, "match empty files and directories")
 	long := flag.Bool("l", false, "long listing")
 	debug := flag.Bool("d", false, "enable debugging in the find package")
 	flag.Parse()
-	p := params{perm: *perm, fileType: *fileType, name: *name, long: *long, debug: *debug}
+	p := params{perm: *perm, permSpec: *permSpec, regex: *regex, iregex: *iregex, empty: *empty, fileType: *fileType, name: *name, long: *long, debug: *debug}
 	if err := command(os.Stdout, os.Stderr, p, flag.Args()).run(); err != nil {
 		log.Fatalf("find: %v", err)
 	}
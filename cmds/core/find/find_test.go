@@ -147,3 +147,198 @@ func TestFindLong(t *testing.T) {
 		t.Errorf("want suffix: file1, got suffix: %s", res[len(res)-5:])
 	}
 }
+
+func preparePermLayout(t *testing.T) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"empty", "full"} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Don't depend on the umask.
+	for _, d := range []string{".", "empty", "full"} {
+		if err := os.Chmod(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{name: "full/a", content: "", mode: 0o644},
+		{name: "full/b", content: "data", mode: 0o600},
+		{name: "full/c", content: "data", mode: 0o755},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.name, []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(f.name, f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindEmptyPerm(t *testing.T) {
+	preparePermLayout(t)
+
+	for _, tt := range []struct {
+		name       string
+		params     params
+		wantStdout string
+		wantErr    bool
+	}{
+		{
+			name:       "empty",
+			params:     params{perm: -1, empty: true},
+			wantStdout: "empty\nfull/a\n",
+		},
+		{
+			name:       "exact octal",
+			params:     params{perm: -1, permSpec: "600"},
+			wantStdout: "full/b\n",
+		},
+		{
+			name:       "exact symbolic",
+			params:     params{perm: -1, permSpec: "u=rw,go=r"},
+			wantStdout: "full/a\n",
+		},
+		{
+			name:       "all of",
+			params:     params{perm: -1, permSpec: "-u=rw", fileType: "f"},
+			wantStdout: "full/a\nfull/b\nfull/c\n",
+		},
+		{
+			name:       "any of",
+			params:     params{perm: -1, permSpec: "/011"},
+			wantStdout: ".\nempty\nfull\nfull/c\n",
+		},
+		{
+			name:       "combined with empty",
+			params:     params{perm: -1, permSpec: "-g+r", empty: true},
+			wantStdout: "empty\nfull/a\n",
+		},
+		{
+			name:       "combined with type",
+			params:     params{perm: -1, permSpec: "/o=x", fileType: "f"},
+			wantStdout: "full/c\n",
+		},
+		{
+			name:    "bad octal",
+			params:  params{perm: -1, permSpec: "8"},
+			wantErr: true,
+		},
+		{
+			name:    "bad symbolic",
+			params:  params{perm: -1, permSpec: "u=q"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := command(&stdout, nil, tt.params, []string{"."}).run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Errorf("want\n%s, got\n%s", tt.wantStdout, got)
+			}
+		})
+	}
+}
+
+func TestFindRegex(t *testing.T) {
+	prepareDirLayout(t)
+
+	for _, tt := range []struct {
+		name       string
+		params     params
+		wantStdout string
+		wantErr    bool
+	}{
+		{
+			name:       "whole path",
+			params:     params{perm: -1, regex: "dir1/.*"},
+			wantStdout: "dir1/file1\ndir1/file2\n",
+		},
+		{
+			name:   "anchored",
+			params: params{perm: -1, regex: "file"},
+		},
+		{
+			name:       "alternatives",
+			params:     params{perm: -1, regex: ".*file[13]"},
+			wantStdout: "dir1/file1\ndir2/file1\ndir2/file3\nfile1\n",
+		},
+		{
+			name:   "case sensitive",
+			params: params{perm: -1, regex: "DIR2/.*"},
+		},
+		{
+			name:       "case insensitive",
+			params:     params{perm: -1, iregex: "DIR2/.*"},
+			wantStdout: "dir2/file1\ndir2/file3\n",
+		},
+		{
+			name:       "with type",
+			params:     params{perm: -1, regex: "dir.*", fileType: "d"},
+			wantStdout: "dir1\ndir2\n",
+		},
+		{
+			name:       "with name",
+			params:     params{perm: -1, regex: "dir2/.*", name: "file1"},
+			wantStdout: "dir2/file1\n",
+		},
+		{
+			name:       "regex and iregex",
+			params:     params{perm: -1, regex: "dir./.*", iregex: ".*FILE2"},
+			wantStdout: "dir1/file2\n",
+		},
+		{
+			name:    "bad regex",
+			params:  params{perm: -1, regex: "("},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := command(&stdout, nil, tt.params, []string{"."}).run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Errorf("want\n%s, got\n%s", tt.wantStdout, got)
+			}
+		})
+	}
+}
+
+func TestParsePerm(t *testing.T) {
+	for _, tt := range []struct {
+		spec      string
+		wantMode  uint32
+		wantMatch permMatch
+	}{
+		{spec: "644", wantMode: 0o644, wantMatch: permExact},
+		{spec: "-4000", wantMode: 0o4000, wantMatch: permAll},
+		{spec: "/222", wantMode: 0o222, wantMatch: permAny},
+		{spec: "a=r,u+w", wantMode: 0o644, wantMatch: permExact},
+		{spec: "u=rwxs", wantMode: 0o4700, wantMatch: permExact},
+		{spec: "/o=t", wantMode: 0o1000, wantMatch: permAny},
+		{spec: "=rw", wantMode: 0o666, wantMatch: permExact},
+	} {
+		mode, match, err := parsePerm(tt.spec)
+		if err != nil {
+			t.Errorf("parsePerm(%q) = %v, want nil", tt.spec, err)
+			continue
+		}
+		if mode != tt.wantMode || match != tt.wantMatch {
+			t.Errorf("parsePerm(%q) = %o, %v, want %o, %v", tt.spec, mode, match, tt.wantMode, tt.wantMatch)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	// defaultBarWidth is the width of the --bar lines when it can't be
+	// read from the terminal.
+	defaultBarWidth = 50
+
+	// barUsed, barCache and barFree draw the used memory, the buffers and
+	// cache, and the free memory.
+	barUsed  = "#"
+	barCache = "="
+	barFree  = "."
+)
+
+// barWidth returns the width of the --bar lines written to w: that of the
+// terminal, or defaultBarWidth if it is unknown. It returns 0, no bars, with
+// mode auto if w is not a terminal, and with mode never or empty.
+func barWidth(mode string, w io.Writer) int {
+	if mode == "" || !when(mode, w) {
+		return 0
+	}
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		if cols, _, err := term.GetSize(int(f.Fd())); err == nil && cols > 0 {
+			return cols
+		}
+	}
+	return defaultBarWidth
+}
+
+// bar returns n characters showing used and cache out of total, as barUsed
+// and barCache, with the rest as barFree. The ends of the used and cache
+// segments are rounded, so the three always add up to n.
+func bar(n int, total, used, cache uint64) string {
+	if total == 0 {
+		return strings.Repeat(barFree, n)
+	}
+	scale := func(v uint64) int {
+		return int(min((v*uint64(n)+total/2)/total, uint64(n)))
+	}
+	u := scale(used)
+	uc := max(scale(used+cache), u)
+	return strings.Repeat(barUsed, u) + strings.Repeat(barCache, uc-u) + strings.Repeat(barFree, n-uc)
+}
+
+// printBars writes a bar of the used memory, the buffers and cache, and the
+// free memory, and one of the used and free swap, c.barWidth wide, followed
+// by a legend. There is no Swap bar without swap.
+func (c *cmd) printBars(mi *MemInfo) {
+	// Leave room for the label and the brackets.
+	n := max(c.barWidth-len("Swap:   []"), 1)
+	fmt.Fprintf(c.stdout, "%-7s [%s]\n", "Mem:", bar(n, mi.Mem.Total, mi.Mem.Used, mi.Mem.Buffers+mi.Mem.Cached))
+	if mi.Swap.Total > 0 {
+		fmt.Fprintf(c.stdout, "%-7s [%s]\n", "Swap:", bar(n, mi.Swap.Total, mi.Swap.Used, 0))
+	}
+	fmt.Fprintf(c.stdout, "%-7s %s used  %s buff/cache  %s free\n", "", barUsed, barCache, barFree)
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestBar(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		n                  int
+		total, used, cache uint64
+		want               string
+	}{
+		{name: "quarters", n: 8, total: 4 << 30, used: 1 << 30, cache: 1 << 30, want: "##==...."},
+		{name: "rounded", n: 10, total: 3, used: 1, cache: 1, want: "###====..."},
+		{name: "all used", n: 4, total: 100, used: 100, want: "####"},
+		{name: "all free", n: 4, total: 100, want: "...."},
+		{name: "over total", n: 4, total: 100, used: 75, cache: 50, want: "###="},
+		{name: "no total", n: 4, want: "...."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bar(tt.n, tt.total, tt.used, tt.cache)
+			if got != tt.want {
+				t.Errorf("bar(%d, %d, %d, %d) = %q, want %q", tt.n, tt.total, tt.used, tt.cache, got, tt.want)
+			}
+			if len(got) != tt.n {
+				t.Errorf("bar is %d wide, want %d", len(got), tt.n)
+			}
+		})
+	}
+}
+
+func TestBars(t *testing.T) {
+	mi := &MemInfo{
+		// 50% used, 25% buffers and cache, 25% free.
+		Mem:  meminfo.MainMemInfo{Total: 16 << 30, Used: 8 << 30, Free: 4 << 30, Buffers: 1 << 30, Cached: 3 << 30, Available: 8 << 30},
+		Swap: meminfo.SwapInfo{Total: 4 << 30, Used: 1 << 30, Free: 3 << 30},
+	}
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{human: true, bar: "always"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.barWidth != defaultBarWidth {
+		t.Errorf("barWidth = %d, want %d", c.barWidth, defaultBarWidth)
+	}
+	if err := c.print(mi); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+              total        used        free      shared  buff/cache   available
+Mem:          16.0G       8.00G       4.00G          0B       4.00G       8.00G
+Swap:         4.00G       1.00G       3.00G
+Mem:    [####################==========..........]
+Swap:   [##########..............................]
+        # used  = buff/cache  . free
+`
+	if got := stdout.String(); got != want[1:] {
+		t.Errorf("got\n%s\nwant\n%s", got, want[1:])
+	}
+	for _, l := range strings.Split(strings.TrimSpace(stdout.String()), "\n")[3:5] {
+		if len(l) != defaultBarWidth {
+			t.Errorf("%q is %d wide, want %d", l, len(l), defaultBarWidth)
+		}
+	}
+}
+
+func TestBarMode(t *testing.T) {
+	// A bytes.Buffer is not a terminal.
+	for _, tt := range []struct {
+		mode string
+		want int
+	}{
+		{mode: "", want: 0},
+		{mode: "never", want: 0},
+		{mode: "auto", want: 0},
+		{mode: "always", want: defaultBarWidth},
+	} {
+		if got := barWidth(tt.mode, &bytes.Buffer{}); got != tt.want {
+			t.Errorf("barWidth(%q) = %d, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestBarOptionErrors(t *testing.T) {
+	for _, o := range []options{
+		{bar: "always", output: formatJSON},
+		{bar: "auto", output: formatYAML},
+		{bar: "always", output: formatCSV},
+		{bar: "always", output: formatLine},
+		{bar: "always", cache: true},
+		{bar: "always", output: formatTemplate, template: "{{.Mem.Used}}"},
+		{bar: "always", showFields: []string{"MemFree"}},
+	} {
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+	if _, err := command(nil, options{bar: "never", output: formatJSON}); err != nil {
+		t.Errorf("--bar=never -json: got %v, want nil", err)
+	}
+}
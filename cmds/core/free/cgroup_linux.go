@@ -0,0 +1,153 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+const cgroupDir = "/sys/fs/cgroup"
+
+// cgroupMem is the memory use of a cgroup v2 and its limit. A limit of zero
+// means the cgroup is not limited.
+type cgroupMem struct {
+	current uint64
+	max     uint64
+}
+
+// readCgroupValue reads a single number from a cgroup v2 interface file.
+// "max", which stands for no limit, is returned as zero.
+func readCgroupValue(dir, name string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "max" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// readCgroupMem reads memory.current and memory.max of the cgroup in dir.
+func readCgroupMem(dir string) (*cgroupMem, error) {
+	current, err := readCgroupValue(dir, "memory.current")
+	if err != nil {
+		return nil, err
+	}
+	max, err := readCgroupValue(dir, "memory.max")
+	if err != nil {
+		return nil, err
+	}
+	return &cgroupMem{current: current, max: max}, nil
+}
+
+// readCgroupStat reads memory.stat of the cgroup in dir, whose lines are a
+// name and a number of bytes.
+func readCgroupStat(dir string) (map[string]uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stat := map[string]uint64{}
+	for _, l := range strings.Split(string(b), "\n") {
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("memory.stat: malformed line %q", l)
+		}
+		v, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("memory.stat: %w", err)
+		}
+		stat[f[0]] = v
+	}
+	if _, ok := stat["file"]; !ok {
+		return nil, fmt.Errorf("memory.stat: no file field")
+	}
+	return stat, nil
+}
+
+// cgroupMemInfo returns the memory of the cgroup v2 in dir in the shape of
+// host, the /proc/meminfo one, which it takes the place of. The total is
+// memory.max, or the host's total if there is no limit or it is above that.
+// The used memory is memory.current less the cache, as for /proc/meminfo.
+//
+// memory.stat names things differently: the page cache is file, shared
+// memory shmem, and the slab slab, slab_reclaimable and slab_unreclaimable,
+// which kernels before 5.9 do not have. Cgroups have no buffers.
+func cgroupMemInfo(dir string, host meminfo.MainMemInfo, classic bool) (meminfo.MainMemInfo, error) {
+	cg, err := readCgroupMem(dir)
+	if err != nil {
+		return meminfo.MainMemInfo{}, err
+	}
+	stat, err := readCgroupStat(dir)
+	if err != nil {
+		return meminfo.MainMemInfo{}, err
+	}
+	total := host.Total
+	if cg.max != 0 && cg.max < total {
+		total = cg.max
+	}
+	cached := stat["file"]
+	if !classic {
+		cached += stat["slab_reclaimable"]
+	}
+	var used, free uint64
+	if cg.current > cached {
+		used = cg.current - cached
+	}
+	if total > cg.current {
+		free = total - cg.current
+	}
+	return meminfo.MainMemInfo{
+		Total:        total,
+		Used:         used,
+		Free:         free,
+		Shared:       stat["shmem"],
+		Cached:       cached,
+		Available:    min(free+stat["file"]+stat["slab_reclaimable"], total),
+		Slab:         stat["slab"],
+		SReclaimable: stat["slab_reclaimable"],
+		SUnreclaim:   stat["slab_unreclaimable"],
+		Dirty:        stat["file_dirty"],
+		Writeback:    stat["file_writeback"],
+	}, nil
+}
+
+// usage returns the fraction of its limit the cgroup uses, or zero if it
+// has no limit.
+func (cg *cgroupMem) usage() float64 {
+	if cg.max == 0 {
+		return 0
+	}
+	return float64(cg.current) / float64(cg.max)
+}
+
+// cgroupWarning warns on stderr if the memory cgroup free runs in uses more
+// than c.cgroupWarn of its limit, since the OOM killer is not far off then.
+func (c *cmd) cgroupWarning() error {
+	cg, err := readCgroupMem(c.cgroupDir)
+	if err != nil {
+		return fmt.Errorf("reading memory cgroup: %w", err)
+	}
+	if u := cg.usage(); u >= c.cgroupWarn {
+		fmt.Fprintf(c.stderr, "WARNING: memory cgroup uses %s, %.0f%% of its %s limit\n",
+			c.humanReadable(cg.current), u*100, c.humanReadable(cg.max))
+	}
+	return nil
+}
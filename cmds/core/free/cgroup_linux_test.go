@@ -0,0 +1,209 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func fakeCgroup(t *testing.T, current, max string) string {
+	t.Helper()
+	d := t.TempDir()
+	for name, v := range map[string]string{"memory.current": current, "memory.max": max} {
+		if v == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(d, name), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+func TestCgroupWarning(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Free: 1 << 30},
+	}
+	for _, tt := range []struct {
+		name    string
+		current string
+		max     string
+		warn    float64
+		want    string
+		wantErr bool
+	}{
+		{name: "half", current: "536870912", max: "1073741824", warn: 0.9},
+		{name: "nearly full", current: "1020054732", max: "1073741824", warn: 0.9, want: "WARNING: memory cgroup uses 972M, 95% of its 1.00G limit\n"},
+		{name: "at the threshold", current: "966367642", max: "1073741824", warn: 0.9, want: "WARNING: memory cgroup uses 921M, 90% of its 1.00G limit\n"},
+		{name: "lower threshold", current: "644245094", max: "1073741824", warn: 0.5, want: "WARNING: memory cgroup uses 614M, 60% of its 1.00G limit\n"},
+		{name: "unlimited", current: "1073741824", max: "max", warn: 0.9},
+		{name: "no cgroup v2", warn: 0.9, wantErr: true},
+		{name: "malformed", current: "lots", max: "max", warn: 0.9, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, options{cgroup: true, cgroupWarn: tt.warn})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.stderr = &stderr
+			c.cgroupDir = fakeCgroup(t, tt.current, tt.max)
+			err = c.print(mi)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("print() = %v, want error %t", err, tt.wantErr)
+			}
+			if stderr.String() != tt.want {
+				t.Errorf("warning = %q, want %q", stderr.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCgroupWarnOption(t *testing.T) {
+	for _, warn := range []float64{0, -0.5, 1.5} {
+		if _, err := command(nil, options{cgroup: true, cgroupWarn: warn}); err != errCgroupWarn {
+			t.Errorf("--cgroup-warn %v: got %v, want %v", warn, err, errCgroupWarn)
+		}
+	}
+	if _, err := command(nil, options{cgroup: true, cgroupWarn: 1}); err != nil {
+		t.Errorf("--cgroup-warn 1: got %v, want nil", err)
+	}
+}
+
+// fakeCgroupStat is fakeCgroup with a memory.stat, copied from
+// testdata/memory.stat unless stat is given.
+func fakeCgroupStat(t *testing.T, current, max, stat string) string {
+	t.Helper()
+	d := fakeCgroup(t, current, max)
+	if stat == "" {
+		b, err := os.ReadFile("testdata/memory.stat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat = string(b)
+	}
+	if err := os.WriteFile(filepath.Join(d, "memory.stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCgroupMemInfo(t *testing.T) {
+	// The host has 7.67G.
+	host := meminfo.MainMemInfo{Total: 8246247424, Used: 1 << 30, Free: 721716 << 10, Available: 2774100 << 10}
+	// testdata/memory.stat has 200M of page cache, 12M of it reclaimable
+	// slab, and the cgroup uses 320M.
+	const current = "335544320"
+	limited := meminfo.MainMemInfo{
+		Total:        512 << 20,
+		Used:         108 << 20,
+		Free:         192 << 20,
+		Shared:       10 << 20,
+		Cached:       212 << 20,
+		Available:    404 << 20,
+		Slab:         16 << 20,
+		SReclaimable: 12 << 20,
+		SUnreclaim:   4 << 20,
+		Dirty:        1 << 20,
+		Writeback:    64 << 10,
+	}
+	unlimited := limited
+	unlimited.Total = host.Total
+	unlimited.Free = host.Total - 320<<20
+	unlimited.Available = unlimited.Free + 212<<20
+	classic := limited
+	classic.Used = 120 << 20
+	classic.Cached = 200 << 20
+	for _, tt := range []struct {
+		name    string
+		max     string
+		classic bool
+		want    meminfo.MainMemInfo
+	}{
+		{name: "hard limit", max: "536870912", want: limited},
+		{name: "no limit", max: "max", want: unlimited},
+		{name: "limit above the host", max: "17179869184", want: unlimited},
+		{name: "classic", max: "536870912", classic: true, want: classic},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cgroupMemInfo(fakeCgroupStat(t, current, tt.max, ""), host, tt.classic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("cgroupMemInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCgroupMemInfoErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		dir  func(t *testing.T) string
+	}{
+		{name: "no memory.stat", dir: func(t *testing.T) string { return fakeCgroup(t, "1024", "max") }},
+		{name: "no memory.current", dir: func(t *testing.T) string { return fakeCgroupStat(t, "", "max", "") }},
+		{name: "no file field", dir: func(t *testing.T) string { return fakeCgroupStat(t, "1024", "max", "anon 1024\n") }},
+		{name: "malformed line", dir: func(t *testing.T) string { return fakeCgroupStat(t, "1024", "max", "file 1024 kB\n") }},
+		{name: "malformed value", dir: func(t *testing.T) string { return fakeCgroupStat(t, "1024", "max", "file lots\n") }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cgroupMemInfo(tt.dir(t), meminfo.MainMemInfo{}, false); err == nil {
+				t.Error("cgroupMemInfo() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestCgroupRun(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		max  string
+		want string
+	}{
+		{
+			name: "hard limit",
+			max:  "536870912",
+			want: `
+Mem:           512M        108M        192M       10.0M        212M        404M
+Swap:         7.88G        768K       7.88G
+`,
+		},
+		{
+			name: "no limit",
+			max:  "max",
+			want: `
+Mem:          7.67G        108M       7.36G       10.0M        212M       7.57G
+Swap:         7.88G        768K       7.88G
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, options{human: true, cgroup: true, cgroupWarn: 0.9, source: "testdata/meminfo.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.stderr = &stderr
+			c.cgroupDir = fakeCgroupStat(t, "335544320", tt.max, "")
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			// Skip the header.
+			_, got, _ := strings.Cut(stdout.String(), "\n")
+			if got != tt.want[1:] {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want[1:])
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+	"golang.org/x/term"
+)
+
+const (
+	colorRed   = "\x1b[31m"
+	colorReset = "\x1b[m"
+)
+
+// whenFlag is the value of --color and --bar: always, auto or never. Given
+// without a value, it means auto.
+type whenFlag string
+
+func (f *whenFlag) String() string { return string(*f) }
+
+func (f *whenFlag) Set(s string) error {
+	switch s {
+	case "true":
+		s = "auto"
+	case "false":
+		s = "never"
+	case "always", "auto", "never":
+	default:
+		return fmt.Errorf("invalid mode %q, must be always, auto or never", s)
+	}
+	*f = whenFlag(s)
+	return nil
+}
+
+// IsBoolFlag lets the flag go without a value.
+func (f *whenFlag) IsBoolFlag() bool { return true }
+
+// when reports whether something the mode asks for is written to w: with
+// always, and with auto if w is a terminal.
+func when(mode string, w io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "auto":
+		f, ok := w.(*os.File)
+		return ok && term.IsTerminal(int(f.Fd()))
+	}
+	return false
+}
+
+// lowAvailable returns whether the available memory of mmi is below the
+// --color-low fraction of its total, so that it is shown in red.
+func (c *cmd) lowAvailable(mmi *meminfo.MainMemInfo) bool {
+	return c.color && float64(mmi.Available) < c.colorLow*float64(mmi.Total)
+}
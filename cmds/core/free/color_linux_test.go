@@ -0,0 +1,138 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestWhenFlag(t *testing.T) {
+	var f whenFlag
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{in: "true", want: "auto"},
+		{in: "false", want: "never"},
+		{in: "always", want: "always"},
+		{in: "auto", want: "auto"},
+		{in: "never", want: "never"},
+	} {
+		if err := f.Set(tt.in); err != nil || string(f) != tt.want {
+			t.Errorf("Set(%q) = %v, %q, want nil, %q", tt.in, err, f, tt.want)
+		}
+	}
+	if err := f.Set("sometimes"); err == nil {
+		t.Errorf("Set(%q) = nil, want an error", "sometimes")
+	}
+}
+
+func TestColor(t *testing.T) {
+	// 5% of the memory is available.
+	low := &MemInfo{Mem: meminfo.MainMemInfo{Total: 16 << 30, Used: 15 << 30, Free: 512 << 20, Cached: 512 << 20, Available: 800 << 20}}
+	plenty := &MemInfo{Mem: meminfo.MainMemInfo{Total: 16 << 30, Used: 4 << 30, Free: 8 << 30, Cached: 4 << 30, Available: 12 << 30}}
+	for _, tt := range []struct {
+		name string
+		o    options
+		mi   *MemInfo
+		want string
+	}{
+		{
+			name: "always",
+			o:    options{human: true, color: "always", colorLow: 0.1},
+			mi:   low,
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          16.0G       15.0G        512M          0B        512M ` + colorRed + `       800M` + colorReset + `
+Swap:            0B          0B          0B
+`,
+		},
+		{
+			name: "always with plenty available",
+			o:    options{human: true, color: "always", colorLow: 0.1},
+			mi:   plenty,
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          16.0G       4.00G       8.00G          0B       4.00G       12.0G
+Swap:            0B          0B          0B
+`,
+		},
+		{
+			name: "never",
+			o:    options{human: true, color: "never", colorLow: 0.1},
+			mi:   low,
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          16.0G       15.0G        512M          0B        512M        800M
+Swap:            0B          0B          0B
+`,
+		},
+		{
+			name: "auto without a terminal",
+			o:    options{human: true, color: "auto", colorLow: 0.1},
+			mi:   low,
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          16.0G       15.0G        512M          0B        512M        800M
+Swap:            0B          0B          0B
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := *tt.mi
+			if err := c.print(&m); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tt.want[1:] {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want[1:])
+			}
+		})
+	}
+}
+
+func TestColorNotInData(t *testing.T) {
+	for _, o := range []options{
+		{output: formatJSON, color: "always", colorLow: 0.1},
+		{output: formatYAML, color: "always", colorLow: 0.1},
+		{output: formatCSV, color: "always", colorLow: 0.1},
+	} {
+		var stdout bytes.Buffer
+		c, err := command(&stdout, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mi := &MemInfo{Mem: meminfo.MainMemInfo{Total: 16 << 30, Used: 15 << 30, Available: 1 << 20}}
+		if err := c.print(mi); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(stdout.String(), "\x1b") {
+			t.Errorf("%+v: got escape sequences in %q", o, stdout.String())
+		}
+	}
+}
+
+func TestColorOptionErrors(t *testing.T) {
+	for _, o := range []options{
+		{color: "always"},
+		{color: "always", colorLow: 1.5},
+	} {
+		if _, err := command(nil, o); !errors.Is(err, errColorLow) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errColorLow)
+		}
+	}
+	// Without color, the fraction does not matter.
+	if _, err := command(nil, options{color: "never", colorLow: 2}); err != nil {
+		t.Errorf("--color=never: got %v, want nil", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+const csvHeader = "type,total,used,free,shared,buff_cache,available"
+
+// printCSV writes the rows of mi as comma separated values, after the
+// header if this is the first sample. Swap and Total have no shared,
+// buff_cache and available values, which are left empty.
+func (c *cmd) printCSV(mi *MemInfo) {
+	if !c.csvHeader {
+		fmt.Fprintln(c.stdout, csvHeader)
+		c.csvHeader = true
+	}
+	row := func(name string, values ...uint64) {
+		fields := []string{name}
+		for _, v := range values {
+			fields = append(fields, c.formatValueByConfig(v))
+		}
+		for len(fields) < strings.Count(csvHeader, ",")+1 {
+			fields = append(fields, "")
+		}
+		fmt.Fprintln(c.stdout, strings.Join(fields, ","))
+	}
+	mem := func(name string, mmi *meminfo.MainMemInfo) {
+		row(name, mmi.Total, mmi.Used, mmi.Free, mmi.Shared, mmi.Buffers+mmi.Cached, mmi.Available)
+	}
+	mem("Mem", &mi.Mem)
+	row("Swap", mi.Swap.Total, mi.Swap.Used, mi.Swap.Free)
+	if ti := mi.Total; ti != nil {
+		row("Total", ti.Total, ti.Used, ti.Free)
+	}
+	for _, n := range mi.Nodes {
+		mem(fmt.Sprintf("Node %d", n.Node), &n.MainMemInfo)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestCSV(t *testing.T) {
+	const snapshot = "MemTotal: 4096 kB\nMemFree: 1024 kB\nMemAvailable: 2048 kB\nBuffers: 256 kB\nCached: 512 kB\nShmem: 128 kB\nSReclaimable: 0 kB\nSwapTotal: 2048 kB\nSwapFree: 1536 kB\n"
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "header once",
+			o:    options{output: formatCSV, count: 2},
+			want: `
+type,total,used,free,shared,buff_cache,available
+Mem,4096,2304,1024,128,768,2048
+Swap,2048,512,1536,,,
+Mem,4096,2304,1024,128,768,2048
+Swap,2048,512,1536,,,
+`,
+		},
+		{
+			name: "comma",
+			o:    options{output: formatCSV, bytes: true, comma: true},
+			want: `
+type,total,used,free,shared,buff_cache,available
+Mem,4194304,2359296,1048576,131072,786432,2097152
+Swap,2097152,524288,1572864,,,
+`,
+		},
+		{
+			name: "human total",
+			o:    options{output: formatCSV, human: true, total: true},
+			want: `
+type,total,used,free,shared,buff_cache,available
+Mem,4.00M,2.25M,1.00M,128K,768K,2.00M
+Swap,2.00M,512K,1.50M,,,
+Total,6.00M,2.75M,2.50M,,,
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.meminfo = func() (map[string]uint64, error) {
+				return meminfo.Read(strings.NewReader(snapshot))
+			}
+			c.sleep = func(time.Duration) {}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tt.want[1:] {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want[1:])
+			}
+		})
+	}
+}
+
+func TestCSVOptionErrors(t *testing.T) {
+	for _, o := range []options{
+		{output: formatCSV, cache: true},
+		{output: formatCSV, raw: true},
+		{output: formatCSV, showFields: []string{"MemFree"}},
+	} {
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+}
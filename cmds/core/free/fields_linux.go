@@ -0,0 +1,91 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+// field is meminfo.FieldByName for --format templates.
+func field(mi *MemInfo, name string) (uint64, error) {
+	v, ok := meminfo.FieldByName(meminfo.MemInfo{Mem: mi.Mem, Swap: mi.Swap}, name)
+	if !ok {
+		return 0, fmt.Errorf("unknown field %q", name)
+	}
+	return v, nil
+}
+
+// isCount returns true if the /proc/meminfo field name is a number of
+// huge pages rather than a size in kibibytes.
+func isCount(name string) bool {
+	return strings.HasPrefix(name, "HugePages_")
+}
+
+// getFields picks the /proc/meminfo fields in names out of m for
+// --show-field. Sizes are converted to bytes. It fails, naming them, if
+// any of the fields are not in m.
+func getFields(m map[string]uint64, names []string) (map[string]uint64, error) {
+	fields := make(map[string]uint64, len(names))
+	var missing []string
+	for _, name := range names {
+		v, ok := m[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if !isCount(name) {
+			v <<= KB
+		}
+		fields[name] = v
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", errShowFieldName, strings.Join(missing, ", "))
+	}
+	return fields, nil
+}
+
+// printFields writes the --show-field fields in the order they were asked
+// for, or as a flat JSON or YAML object.
+func (c *cmd) printFields(fields map[string]uint64) error {
+	if c.output == formatJSON || c.output == formatYAML {
+		return c.printData(fields)
+	}
+	for _, name := range c.showFields {
+		c.printField(name, fields[name])
+	}
+	return nil
+}
+
+// printField writes the /proc/meminfo field name as "NAME: value", in the
+// unit the options ask for unless it is a count.
+func (c *cmd) printField(name string, v uint64) {
+	s := strconv.FormatUint(v, 10)
+	if !isCount(name) {
+		s = c.formatValueByConfig(v)
+	}
+	fmt.Fprintf(c.stdout, "%s: %s\n", name, s)
+}
+
+// sortedNames returns the names of the fields in m in sorted order.
+func sortedNames(m map[string]uint64) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printRaw writes every /proc/meminfo field, sorted by name, for -v.
+func (c *cmd) printRaw(raw map[string]uint64) {
+	for _, name := range sortedNames(raw) {
+		c.printField(name, raw[name])
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestFormatField(t *testing.T) {
+	mi := &MemInfo{Mem: meminfo.MainMemInfo{Available: 1 << 20}}
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{output: formatTemplate, template: `{{field . "MemAvailable"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.print(mi); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "1048576\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	c, err = command(&stdout, options{output: formatTemplate, template: `{{field . "Bogus"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.print(mi); err == nil {
+		t.Error("unknown field: got nil, want an error")
+	}
+}
+
+func TestShowField(t *testing.T) {
+	const snapshot = `MemTotal:        8053004 kB
+MemFree:          721716 kB
+MemAvailable:    2774100 kB
+Buffers:          244880 kB
+Cached:          3470876 kB
+Shmem:           1617788 kB
+SReclaimable:     171124 kB
+SwapTotal:       8265724 kB
+SwapFree:        8264956 kB
+Dirty:              1024 kB
+Committed_AS:   12582912 kB
+HugePages_Total:      16
+`
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+		err  error
+	}{
+		{
+			name: "one field",
+			o:    options{showFields: []string{"Dirty"}},
+			want: "Dirty: 1024\n",
+		},
+		{
+			name: "in the order given",
+			o:    options{mbytes: true, showFields: []string{"Committed_AS", "Dirty", "MemTotal"}},
+			want: "Committed_AS: 12288\nDirty: 1\nMemTotal: 7864\n",
+		},
+		{
+			name: "human",
+			o:    options{human: true, showFields: []string{"Committed_AS", "HugePages_Total"}},
+			want: "Committed_AS: 12.0G\nHugePages_Total: 16\n",
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, showFields: []string{"Dirty", "Committed_AS", "HugePages_Total"}},
+			want: `{"Committed_AS":12884901888,"Dirty":1048576,"HugePages_Total":16}` + "\n",
+		},
+		{
+			name: "unknown fields",
+			o:    options{showFields: []string{"Dirty", "Bogus", "AlsoBogus"}},
+			err:  errShowFieldName,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.meminfo = func() (map[string]uint64, error) {
+				return meminfo.Read(strings.NewReader(snapshot))
+			}
+			err = c.run()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "Bogus, AlsoBogus") {
+				t.Errorf("run() = %v, want it to list the unknown fields", err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	for _, o := range []options{{output: formatLine}, {cache: true}, {output: formatTemplate, template: "{{.Mem.Total}}"}} {
+		o.showFields = []string{"Dirty"}
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+}
+
+func TestAverageFields(t *testing.T) {
+	got := averageMemInfo([]*MemInfo{
+		{Fields: map[string]uint64{"Dirty": 1 << 20}},
+		{Fields: map[string]uint64{"Dirty": 3 << 20}},
+	})
+	if got.Fields["Dirty"] != 2<<20 {
+		t.Errorf("average Dirty = %d, want %d", got.Fields["Dirty"], 2<<20)
+	}
+}
+
+func TestVerboseGolden(t *testing.T) {
+	golden, err := os.ReadFile("testdata/verbose.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{verbose: true, source: "testdata/meminfo.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	// The table, then the fields in sorted order.
+	if stdout.String() != string(golden) {
+		t.Errorf("got\n%s\nwant\n%s", stdout.String(), golden)
+	}
+}
+
+func TestVerbose(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "json",
+			o:    options{verbose: true, output: formatJSON},
+			want: `"raw":{"Active":3648372736,"Buffers":250757120,"Cached":3545214976,"Inactive":2908786688,"MemAvailable":2840678400,"MemFree":739037184,"MemTotal":8246247424,"SReclaimable":184168448,"SUnreclaim":124674048,"Shmem":1656614912,"Slab":308842496,"SwapCached":0,"SwapFree":8463314944,"SwapTotal":8464101376}}`,
+		},
+		{
+			name: "yaml",
+			o:    options{verbose: true, output: formatYAML},
+			want: "raw:\n  Active: 3648372736\n  Buffers: 250757120\n",
+		},
+		{
+			name: "human",
+			o:    options{verbose: true, human: true},
+			want: "MemFree: 704M\nMemTotal: 7.67G\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			tt.o.source = "testdata/meminfo.txt"
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("got\n%s\nwant it to contain\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	for _, o := range []options{{output: formatCSV}, {output: formatLine}, {cache: true}, {output: formatTemplate, template: "{{.Mem.Total}}"}, {showFields: []string{"Dirty"}}} {
+		o.verbose = true
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+}
+
+func TestAverageRaw(t *testing.T) {
+	got := averageMemInfo([]*MemInfo{
+		{Raw: map[string]uint64{"MemFree": 1 << 20}},
+		{Raw: map[string]uint64{"MemFree": 3 << 20}},
+	})
+	if got.Raw["MemFree"] != 2<<20 {
+		t.Errorf("average MemFree = %d, want %d", got.Raw["MemFree"], 2<<20)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// outputFormat is the form free prints the memory information in.
+type outputFormat int
+
+const (
+	formatTable outputFormat = iota
+	formatLine
+	formatJSON
+	formatYAML
+	formatCSV
+	formatTemplate
+)
+
+// formatFlags are the flags that choose each output format.
+var formatFlags = [...]string{
+	formatTable:    "",
+	formatLine:     "-L",
+	formatJSON:     "-json",
+	formatYAML:     "--yaml",
+	formatCSV:      "--csv",
+	formatTemplate: "--format",
+}
+
+func (f outputFormat) String() string { return formatFlags[f] }
+
+// choose makes f the output format, or, with on false, goes back to the
+// table if f was it. Only one output format can be chosen.
+func (o *outputFormat) choose(f outputFormat, on bool) error {
+	if !on {
+		if *o == f {
+			*o = formatTable
+		}
+		return nil
+	}
+	if *o != formatTable && *o != f {
+		return fmt.Errorf("%v %w with %v", f, errConflict, *o)
+	}
+	*o = f
+	return nil
+}
+
+// formatFlag is one of -L, -json, --yaml and --csv. They all set the same
+// output format, so that giving two of them is an error.
+type formatFlag struct {
+	output *outputFormat
+	format outputFormat
+}
+
+func (f formatFlag) String() string {
+	return strconv.FormatBool(f.output != nil && *f.output == f.format)
+}
+
+func (f formatFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	return f.output.choose(f.format, on)
+}
+
+// IsBoolFlag lets the flag go without a value.
+func (f formatFlag) IsBoolFlag() bool { return true }
+
+// templateFlag is --format, which sets the output format as well as the
+// template.
+type templateFlag struct {
+	output   *outputFormat
+	template *string
+}
+
+func (f templateFlag) String() string {
+	if f.template == nil {
+		return ""
+	}
+	return *f.template
+}
+
+func (f templateFlag) Set(s string) error {
+	if err := f.output.choose(formatTemplate, s != ""); err != nil {
+		return err
+	}
+	*f.template = s
+	return nil
+}
+
+// addFormatFlags adds -L, -json, --yaml, --csv and --format to f. They set
+// output, and --format template as well.
+func addFormatFlags(f *flag.FlagSet, output *outputFormat, template *string) {
+	f.Var(formatFlag{output, formatLine}, "L", "Show a single line summary")
+	f.Var(formatFlag{output, formatJSON}, "json", "Use JSON for output")
+	f.Var(formatFlag{output, formatYAML}, "yaml", "Use YAML for output")
+	f.Var(formatFlag{output, formatCSV}, "csv", "Use comma separated values for output, with a header line once")
+	f.Var(templateFlag{output, template}, "format", "Render the output with this Go `template`")
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormatFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		want     outputFormat
+		template string
+		// conflict is whether the flags can't be combined.
+		conflict bool
+	}{
+		{name: "none", want: formatTable},
+		{name: "line", args: []string{"-L"}, want: formatLine},
+		{name: "json", args: []string{"-json"}, want: formatJSON},
+		{name: "yaml", args: []string{"--yaml"}, want: formatYAML},
+		{name: "csv", args: []string{"--csv"}, want: formatCSV},
+		{name: "template", args: []string{"--format", "{{.Mem.Total}}"}, want: formatTemplate, template: "{{.Mem.Total}}"},
+		{name: "twice", args: []string{"-json", "-json"}, want: formatJSON},
+		{name: "turned off", args: []string{"-json", "-json=false", "--csv"}, want: formatCSV},
+		{name: "empty template", args: []string{"--format", ""}, want: formatTable},
+		{name: "csv json", args: []string{"--csv", "-json"}, conflict: true},
+		{name: "csv yaml", args: []string{"--csv", "--yaml"}, conflict: true},
+		{name: "csv line", args: []string{"--csv", "-L"}, conflict: true},
+		{name: "yaml json", args: []string{"--yaml", "-json"}, conflict: true},
+		{name: "line json", args: []string{"-L", "-json"}, conflict: true},
+		{name: "template json", args: []string{"--format", "{{.Mem.Total}}", "-json"}, conflict: true},
+		{name: "line template", args: []string{"-L", "--format", "{{.Mem.Used}}"}, conflict: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				output   outputFormat
+				template string
+			)
+			f := flag.NewFlagSet("free", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+			addFormatFlags(f, &output, &template)
+			// flag does not wrap the errors of Set, so only the message
+			// tells the conflict apart.
+			err := f.Parse(tt.args)
+			if tt.conflict {
+				if err == nil || !strings.Contains(err.Error(), errConflict.Error()) {
+					t.Errorf("Parse(%q) = %v, want %v", tt.args, err, errConflict)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v, want nil", tt.args, err)
+			}
+			if output != tt.want || template != tt.template {
+				t.Errorf("Parse(%q): format %v, template %q, want %v, %q", tt.args, output, template, tt.want, tt.template)
+			}
+		})
+	}
+}
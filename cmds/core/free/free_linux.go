@@ -6,12 +6,103 @@
 //
 // Synopsis:
 //
-//	free [-k] [-m] [-g] [-t] [-h] [-json]
+//	free [-b] [-k] [-m] [-g] [-t] [-h [--round]] [--si] [--raw] [--comma]
+//	     [-L | -json | --yaml | --csv | --format template]
+//	     [-s delay [-c count]] [--once] [--avg count]
+//	     [-C] [-w] [--total] [--percent] [--used-classic] [--swaps]
+//	     [--show-field name]... [-v] [--top n] [--psi] [--zswap]
+//	     [--numa [--numa-warn fraction]] [--cgroup [--cgroup-warn fraction]]
+//	     [--bar[=when]] [--color[=when] [--color-low fraction]]
+//	     [--wait-until-available percent [--wait-timeout seconds]]
+//	     [--min-available size] [--dump-history [--history n]]
+//	     [--source file [--strict]] [--validate] [--output file [--append]]
 //
 // Description:
 //
-//	Read memory information from /proc/meminfo and display a summary for
-//	physical memory and swap space. The unit options use powers of 1024.
+//	Read memory information from /proc/meminfo, or the --source file, and
+//	display a summary for physical memory and swap space. The unit options
+//	use powers of 1024, or of 1000 with --si.
+//
+//	With -s, free keeps printing a new table every delay seconds, until it
+//	is interrupted. The delay may be fractional, e.g. 0.5. The first table
+//	is printed immediately; the delay only separates later ones, and a
+//	blank line separates the tables. With -c, free exits after count
+//	tables; -c without -s waits one second between them. --once prints
+//	the first table and exits.
+//
+//	With --avg, count samples are taken delay seconds apart (one second if
+//	-s is not given) and a single table of the mean values is printed.
+//
+//	With --psi, the memory pressure stall information from
+//	/proc/pressure/memory is shown as well: the percentage of time in which
+//	some or all tasks were stalled on memory over the last 10, 60 and 300
+//	seconds. Kernels without PSI support simply don't show it.
+//
+//	With --cgroup, free shows the memory of the cgroup v2 at /sys/fs/cgroup,
+//	as seen from inside a container, rather than the host's: the total is
+//	memory.max, used is memory.current less the page cache, and the cache,
+//	shared memory and slab come from memory.stat. A memory.max of "max"
+//	means there is no limit, and the host's total is shown. Swap is still
+//	the host's. free also prints a warning on stderr when memory.current
+//	reaches the --cgroup-warn fraction of memory.max.
+//
+//	With --dump-history, free keeps the last --history samples it polled
+//	and writes them to stderr as a JSON array, oldest first, whenever it
+//	gets SIGUSR1. This shows what a long running free has seen without
+//	restarting it.
+//
+//	The used column is MemTotal - MemFree - Buffers - Cached - SReclaimable
+//	from /proc/meminfo, and buff/cache is Buffers + Cached + SReclaimable,
+//	as in procps-ng 3.3.10 and later. --used-classic uses the older formula,
+//	MemTotal - MemFree - Buffers - Cached, where reclaimable slab counts as
+//	used and buff/cache is Buffers + Cached. available is MemAvailable, or
+//	on kernels older than 3.14, which lack it, an estimate from MemFree,
+//	the file pages and SReclaimable.
+//
+//	--wait-until-available makes free a condition to wait for in scripts:
+//	it samples every delay seconds (one second if -s is not given), prints
+//	nothing and exits successfully as soon as available memory is above
+//	the given percentage of the total. With --wait-timeout, it gives up
+//	with an error once that many seconds have passed.
+//
+//	-C shows only the page cache: Buffers, Cached and SReclaimable from
+//	/proc/meminfo, and their sum, which the kernel can reclaim when it
+//	needs the memory. With -json, only those values are printed.
+//
+//	--swaps lists each swap partition or file from /proc/swaps below the
+//	table, with its size, the amount used and its priority. The devices
+//	are sorted by priority, highest first, which is the order the kernel
+//	fills them in. With -json they are added as "swaps".
+//
+//	--numa adds a row for each NUMA node, labelled Node N:, from
+//	/sys/devices/system/node/node*/meminfo. Nodes have no buffers and no
+//	estimate of the available memory of their own, so available is their
+//	free memory and cache. Without NUMA support in the kernel, the whole
+//	system is node 0. With -json they are added as "nodes".
+//
+//	Memory is allocated from the node a task runs on first, so a node can
+//	run short, and its tasks slow down, while the system as a whole has
+//	plenty free. With --numa-warn, free warns on stderr about each node
+//	whose free memory is below that fraction of the free memory of the
+//	node with the most, and lists the free memory of every node.
+//
+//	--bar draws a bar below the table for Mem, with # for used memory, =
+//	for buffers and cache and . for free memory, and one for Swap, if there
+//	is any swap, followed by a legend. The bars are as wide as the terminal,
+//	or 50 columns if its width is unknown. --bar or --bar=auto draws them
+//	only when stdout is a terminal; --bar=always draws them anyway.
+//
+//	--validate is meant for debugging free on unusual kernels. Rather
+//	than the table, it checks that the memory information parses and is
+//	consistent, e.g. that MemFree and MemAvailable are at most MemTotal and
+//	SwapFree at most SwapTotal, prints each check that fails and exits with
+//	status 1 if any did.
+//
+//	With --color, the available memory of the Mem row, and of the Node
+//	rows, is shown in red when it is below the --color-low fraction of the
+//	total. The default, auto, only does so when stdout is a terminal;
+//	--color=always does it anyway and --color=never never does. Other
+//	output than the table, such as -json or --csv, is never colored.
 //
 // Options:
 //
@@ -19,19 +110,122 @@
 //	-m: display the values in mebibytes
 //	-g: display the values in gibibytes
 //	-t: display the values in tebibytes
-//	-h: display the values in human-readable form
-//	-json: use JSON output
+//	-h: display the values in human-readable form, truncated to three
+//	    significant digits, so that the actual value is always at least the
+//	    one shown. Each value gets the unit that suits it, so rows of very
+//	    different sizes do not share one, and zero is 0B
+//	--round: with -h, round values to the nearest three digits, as GNU free
+//	         does, rather than truncate them
+//	--si: use powers of 1000 rather than 1024, and KB, MB, GB and TB
+//	      suffixes with -h
+//	--raw: also show the exact number of bytes next to each value; not
+//	       with --csv, whose fields hold a single number each
+//	--comma, --thousands: group the digits of the values in thousands,
+//	    e.g. 16,384,000; -h, -json, --yaml and --csv are not affected
+//	-L: print a single line, SwapUse, CachUse, MemUse and MemFree, in that
+//	    order, as procps does; handy for status bars
+//	-json: use JSON output, which also has the slab, sreclaimable,
+//	       sunreclaim, dirty and writeback memory. The values are always
+//	       in bytes; with -b, -k, -m, -g or -t, a unit field names the
+//	       unit asked for, e.g. "M", or "MB" with --si
+//	--yaml: use YAML output, with the same fields as -json; each sample
+//	        is a document of its own, starting with ---
+//	--csv: print comma separated values: a header line, once, then a Mem
+//	       and a Swap row for each sample, and Total and Node rows with
+//	       --total and --numa
+//	--format: render the output with a Go text/template, e.g.
+//	          '{{.Mem.Available}}' or '{{unit .Mem.Used}}/{{unit .Mem.Total}}'.
+//	          The template sees the MemInfo struct, with values in bytes;
+//	          unit formats a value as the unit options ask for and human
+//	          always formats it in human-readable form. field looks a
+//	          value up by its /proc/meminfo name, e.g.
+//	          '{{field . "MemAvailable"}}'. Cached has no such name, as
+//	          Mem.Cached includes SReclaimable; -v has the kernel's value
+//	          in .Raw.
+//	-s: delay between samples in seconds
+//	-c: number of samples to print with -s
+//	--once: print a single sample and exit
+//	--avg: print the average of this many samples
+//	--psi: also show memory pressure stall information
+//	--cgroup: show the memory of the memory cgroup, and warn when it is
+//	          close to its limit
+//	--cgroup-warn: fraction of the cgroup limit to warn at (default 0.9)
+//	--dump-history: dump the recent samples on SIGUSR1
+//	--history: number of samples to keep for --dump-history (default 60)
+//	--used-classic: count reclaimable slab as used, as older procps does
+//	-C: show only buffers, cache and reclaimable slab
+//	--swaps, --swap-details: also list the swap devices by priority
+//	-w: wide output, with separate buffers and cache columns rather than
+//	    buff/cache
+//	--total: add a Total row, the sum of Mem and Swap; with -json, a
+//	         "total" object
+//	--wait-until-available: wait until available memory is above this
+//	                        percentage of the total
+//	--wait-timeout: give up waiting after this many seconds (default 0,
+//	                wait forever)
+//	--source: read this file rather than /proc/meminfo, e.g. the meminfo
+//	          of another namespace's proc
+//	--strict: fail on a malformed line in the memory information; by
+//	          default free warns about it and leaves the field out
+//	--percent: add a %used column to the Mem and Swap rows, or "-" if
+//	           there is no swap; with -json, "used_percent" values
+//	--min-available: fail when available memory is below this size, a
+//	                 number of bytes with an optional K, M, G or T suffix
+//	                 in powers of 1024, e.g. 512M
+//	--top: after the table, list the n processes with the largest resident
+//	       set, from /proc/*/statm; with -json, a "top" list
+//	--show-field: rather than the table, print this /proc/meminfo field,
+//	              e.g. Committed_AS, as "NAME: value" in the unit the
+//	              options ask for; may be repeated. HugePages_ fields are
+//	              counts and printed as they are. With -json, a flat
+//	              object of the fields
+//	-v: after the table, list every /proc/meminfo field, sorted by name, as
+//	    --show-field prints them; with -json, a "raw" object of them all
+//	--numa: also show the memory of each NUMA node
+//	--numa-warn: with --numa, warn when a node has less than this fraction
+//	             of the free memory of the node with the most, e.g. 0.25
+//	--bar: draw bars of the memory and swap usage: always, auto (the
+//	       default for --bar alone) or never
+//	--color: show low available memory in red: always, auto (the default)
+//	         or never
+//	--color-low: fraction of the total below which available memory is
+//	             low (default 0.1)
+//	--output: write to this file rather than stdout, replacing what it
+//	          holds; with --append, add to it instead, e.g. to log the
+//	          samples of -s
+//	--zswap: add a Zswap: row with the memory the compressed pages of zswap
+//	         take up, what they took up before they were compressed, and
+//	         the ratio of the two; with -json, a "zswap" object. Without
+//	         zswap, the row says it is not available, and -json leaves the
+//	         object out and says so on stderr
+//
+// Exit status:
+//
+//	With --min-available, free prints its output as usual and then exits
+//	with status 1 if the available memory is below the size given; with
+//	--avg that is the average, and with -s or -c the first sample below it
+//	stops free. Otherwise, and with enough memory available, it exits with
+//	status 0 unless it fails to read the memory information.
 package main
 
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -41,9 +235,57 @@ var (
 	inMB        = flag.Bool("m", false, "Express the values in mebibytes")
 	inGB        = flag.Bool("g", false, "Express the values in gibibytes")
 	inTB        = flag.Bool("t", false, "Express the values in tebibytes")
-	toJSON      = flag.Bool("json", false, "Use JSON for output")
+	si          = flag.Bool("si", false, "Use powers of 1000 rather than 1024")
+	round       = flag.Bool("round", false, "With -h, round values to the nearest three digits rather than truncate them")
+	raw         = flag.Bool("raw", false, "Also show the exact number of bytes next to each value")
+	comma       = flag.Bool("comma", false, "Group the digits of the values in thousands")
+	delay       = flag.Float64("s", 0, "Delay between samples in seconds")
+	count       = flag.Int("c", 0, "Number of samples to print, one second apart unless -s is given")
+	avg         = flag.Int("avg", 0, "Print the average of this many samples")
+	once        = flag.Bool("once", false, "Print a single sample and exit, even with -s")
+	psi         = flag.Bool("psi", false, "Also show memory pressure stall information")
+	cgroup      = flag.Bool("cgroup", false, "Show the memory of the memory cgroup and warn when it is close to its limit")
+	cgroupWarn  = flag.Float64("cgroup-warn", 0.9, "Fraction of the memory cgroup limit to warn at")
+	dumpHistory = flag.Bool("dump-history", false, "Dump the recent samples as JSON to stderr on SIGUSR1")
+	historySize = flag.Int("history", 60, "Number of samples to keep for --dump-history")
+	usedClassic = flag.Bool("used-classic", false, "Count reclaimable slab as used, not as cache, like older procps")
+	cache       = flag.Bool("C", false, "Show only buffers, cache and reclaimable slab")
+	swaps       = flag.Bool("swaps", false, "Also list the swap devices by priority")
+	wide        = flag.Bool("w", false, "Wide output: show buffers and cache in separate columns")
+	total       = flag.Bool("total", false, "Show a row with the sum of memory and swap")
+	waitAvail   = flag.Float64("wait-until-available", 0, "Wait until available memory is above this percentage of the total")
+	waitTimeout = flag.Float64("wait-timeout", 0, "Give up waiting for available memory after this many seconds")
+	source      = flag.String("source", meminfo.File, "Read the memory information from this file")
+	percent     = flag.Bool("percent", false, "Add a column with the percentage of memory and swap used")
+	minAvail    = flag.String("min-available", "", "Fail when available memory is below this size, e.g. 512M")
+	top         = flag.Int("top", 0, "Also list the n processes using the most memory")
+	validate    = flag.Bool("validate", false, "Check that the memory information is consistent, and print what is not")
+	numa        = flag.Bool("numa", false, "Also show the memory of each NUMA node")
+	numaWarn    = flag.Float64("numa-warn", 0, "With --numa, warn when a node has less than this fraction of the free memory of the node with the most")
+	verbose     = flag.Bool("v", false, "Also list every /proc/meminfo field, sorted by name")
+	output      = flag.String("output", "", "Write to this file rather than stdout")
+	appendOut   = flag.Bool("append", false, "Append to the --output file rather than replace it")
+	strict      = flag.Bool("strict", false, "Fail on malformed lines in the memory information rather than warn")
+	zswap       = flag.Bool("zswap", false, "Also show the compressed and original size of the zswap pages")
+	colorLow    = flag.Float64("color-low", 0.1, "Fraction of the total memory below which available memory is shown in red")
+	showFields  unixflag.StringArray
+	bars        whenFlag
+	colors      = whenFlag("auto")
+	// outFormat is the output format -L, -json, --yaml, --csv or --format
+	// chooses, and format the --format template.
+	outFormat outputFormat
+	format    string
 )
 
+func init() {
+	addFormatFlags(flag.CommandLine, &outFormat, &format)
+	flag.BoolVar(swaps, "swap-details", false, "Also list the swap devices by priority (same as --swaps)")
+	flag.BoolVar(comma, "thousands", false, "Group the digits of the values in thousands (same as --comma)")
+	flag.Var(&showFields, "show-field", "Print only this /proc/meminfo field; may be repeated")
+	flag.Var(&bars, "bar", "Draw bars of the memory usage: always, auto or never")
+	flag.Var(&colors, "color", "Show low available memory in red: always, auto or never")
+}
+
 type unit uint
 
 const (
@@ -61,184 +303,308 @@ const (
 
 var units = [...]string{"B", "K", "M", "G", "T"}
 
-var errMultipleUnits = fmt.Errorf("multiple unit options doesn't make sense")
+// siUnits are the suffixes of humanReadableSI.
+var siUnits = [...]string{"B", "KB", "MB", "GB", "TB"}
 
-// the following types are used for JSON serialization
-type mainMemInfo struct {
-	Total     uint64 `json:"total"`
-	Used      uint64 `json:"used"`
-	Free      uint64 `json:"free"`
-	Shared    uint64 `json:"shared"`
-	Cached    uint64 `json:"cached"`
-	Buffers   uint64 `json:"buffers"`
-	Available uint64 `json:"available"`
-}
+var (
+	errMultipleUnits = fmt.Errorf("multiple unit options doesn't make sense")
+	errAvgCount      = fmt.Errorf("number of samples to average must be positive")
+	errDelay         = fmt.Errorf("delay between samples must be positive")
+	errCount         = fmt.Errorf("number of samples to print must be positive")
+	errCgroupWarn    = fmt.Errorf("--cgroup-warn must be a fraction between 0 and 1")
+	errHistory       = fmt.Errorf("--history must be positive")
+	errWaitPercent   = fmt.Errorf("--wait-until-available must be a percentage between 0 and 100")
+	errWaitTimeout   = fmt.Errorf("--wait-timeout must be positive and requires --wait-until-available")
+	errWaitAvg       = fmt.Errorf("--wait-until-available can't be combined with --avg")
+	errWaitTimedOut  = fmt.Errorf("timed out waiting for available memory")
+	errMinAvail      = fmt.Errorf("--min-available must be a size like 512M or 2G")
+	errMinAvailWait  = fmt.Errorf("--min-available can't be combined with --wait-until-available")
+	errLowMemory     = fmt.Errorf("available memory is below --min-available")
+	errTop           = fmt.Errorf("--top must be positive")
+	errShowFieldName = fmt.Errorf("no such /proc/meminfo field")
+	errNumaWarn      = fmt.Errorf("--numa-warn must be a fraction between 0 and 1 and requires --numa")
+	errColorLow      = fmt.Errorf("--color-low must be a fraction between 0 and 1")
+	errInvalid       = fmt.Errorf("memory information is inconsistent")
+	errAppend        = fmt.Errorf("--append requires --output")
+	errConflict      = fmt.Errorf("can't be combined")
+)
 
-type swapInfo struct {
+// totalInfo is the sum of the main memory and swap space.
+type totalInfo struct {
 	Total uint64 `json:"total"`
 	Used  uint64 `json:"used"`
 	Free  uint64 `json:"free"`
 }
 
-// MemInfo represents the main memory and swap space information in a structured
-// manner, suitable for JSON encoding.
-type MemInfo struct {
-	Mem  mainMemInfo `json:"mem"`
-	Swap swapInfo    `json:"swap"`
+type cacheInfo struct {
+	Buffers      uint64 `json:"buffers"`
+	Cached       uint64 `json:"cached"`
+	SReclaimable uint64 `json:"sreclaimable"`
+	Reclaimable  uint64 `json:"reclaimable"`
 }
 
-type meminfomap map[string]uint64
-
-const meminfoFile = "/proc/meminfo"
-
-// meminfo returns a mapping that represents the fields contained in
-// /proc/meminfo
-func meminfo() (meminfomap, error) {
-	buf, err := os.ReadFile(meminfoFile)
-	if err != nil {
-		return nil, err
-	}
-	return meminfoFromBytes(buf)
+// MemInfo is the meminfo.MemInfo free prints, with the extra information
+// its options ask for.
+type MemInfo struct {
+	// Unit is the unit option given, for -json and --yaml. The values
+	// are in bytes all the same; it tells consumers how to scale them.
+	Unit  string              `json:"unit,omitempty"`
+	Mem   meminfo.MainMemInfo `json:"mem"`
+	Swap  meminfo.SwapInfo    `json:"swap"`
+	PSI   *psiInfo            `json:"psi,omitempty"`
+	Cache *cacheInfo          `json:"cache,omitempty"`
+	Swaps []swapDevice        `json:"swaps,omitempty"`
+	Total *totalInfo          `json:"total,omitempty"`
+	Top   []topProcess        `json:"top,omitempty"`
+	Nodes []nodeInfo          `json:"nodes,omitempty"`
+	// Zswap is the zswap information, for --zswap. It is nil without
+	// zswap.
+	Zswap *meminfo.ZswapInfo `json:"zswap,omitempty"`
+	// Raw are all the /proc/meminfo fields, for -v, with sizes in bytes.
+	Raw map[string]uint64 `json:"raw,omitempty"`
+	// Fields are the /proc/meminfo fields --show-field asks for, with
+	// sizes in bytes. They are printed on their own.
+	Fields map[string]uint64 `json:"-"`
 }
 
-// meminfoFromBytes returns a mapping that represents the fields contained in a
-// byte stream with a content compatible with /proc/meminfo
-func meminfoFromBytes(buf []byte) (meminfomap, error) {
-	ret := make(meminfomap)
-	for _, line := range bytes.Split(buf, []byte{'\n'}) {
-		kv := bytes.SplitN(line, []byte{':'}, 2)
-		if len(kv) != 2 {
-			// invalid line?
-			continue
-		}
-		key := string(kv[0])
-		tokens := bytes.SplitN(bytes.TrimSpace(kv[1]), []byte{' '}, 2)
-		if len(tokens) > 0 {
-			value, err := strconv.ParseUint(string(tokens[0]), 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			ret[key] = value
+// getCacheInfo returns the page cache information for -C. Unlike the Cached
+// of the main memory, Cached is the plain /proc/meminfo value here, without
+// SReclaimable.
+func getCacheInfo(m map[string]uint64) (*cacheInfo, error) {
+	for _, f := range []string{"Buffers", "Cached", "SReclaimable"} {
+		if _, ok := m[f]; !ok {
+			return nil, fmt.Errorf("%s: %w", f, meminfo.ErrMissingField)
 		}
 	}
-	return ret, nil
-}
 
-// getMainMemInfo prints the physical memory information in the specified units. Only
-// the relevant fields will be used from the input map.
-func getMainMemInfo(m meminfomap) (*mainMemInfo, error) {
-	fields := []string{
-		"MemTotal",
-		"MemFree",
-		"Buffers",
-		"Cached",
-		"Shmem",
-		"SReclaimable",
-		"MemAvailable",
-	}
-	if missingRequiredFields(m, fields) {
-		return nil, fmt.Errorf("missing required fields from meminfo")
+	ci := cacheInfo{
+		Buffers:      m["Buffers"] << KB,
+		Cached:       m["Cached"] << KB,
+		SReclaimable: m["SReclaimable"] << KB,
 	}
+	ci.Reclaimable = ci.Buffers + ci.Cached + ci.SReclaimable
+	return &ci, nil
+}
 
-	// These values are expressed in kibibytes, convert to the desired unit
-	memTotal := m["MemTotal"] << KB
-	memFree := m["MemFree"] << KB
-	memShared := m["Shmem"] << KB
-	memCached := (m["Cached"] + m["SReclaimable"]) << KB
-	memBuffers := (m["Buffers"]) << KB
-	memUsed := memTotal - memFree - memCached - memBuffers
-	memAvailable := m["MemAvailable"] << KB
+// humanDigits is the number of significant digits human-readable values are
+// shown with, as GNU free does.
+const humanDigits = 3
 
-	mmi := mainMemInfo{
-		Total:     memTotal,
-		Used:      memUsed,
-		Free:      memFree,
-		Shared:    memShared,
-		Cached:    memCached,
-		Buffers:   memBuffers,
-		Available: memAvailable,
-	}
-	return &mmi, nil
+// humanReadableValue returns a string representing the input value, treated as
+// a size in bytes, interpreted in a human readable form, rounded to the given
+// number of significant digits. E.g. with 3 digits the number 1500000 would
+// return the string "1.43M". Integer parts longer than that are kept whole,
+// and a value that rounds up to 1024 moves on to the next unit, so 1048575
+// returns "1.00M" rather than "1024K". Bytes never have a decimal part.
+func humanReadableValue(value uint64, digits int) string {
+	return humanize(value, 1024, units[:], digits, roundTo)
 }
 
-// getSwapInfo prints the swap space information in the specified units. Only the
-// relevant fields will be used from the input map.
-func getSwapInfo(m meminfomap) (*swapInfo, error) {
-	fields := []string{
-		"SwapTotal",
-		"SwapFree",
+// humanReadableSI is humanReadableValue in powers of 1000. E.g. 1500000
+// returns "1.50MB" with 3 digits.
+func humanReadableSI(value uint64, digits int) string {
+	return humanize(value, 1000, siUnits[:], digits, roundTo)
+}
+
+// humanize formats value in the largest of units, each base times the one
+// before, that keeps it at least 1, rounded to digits significant digits by
+// round, which is roundTo or truncateTo.
+func humanize(value uint64, base float64, units []string, digits int, round func(float64, int) float64) string {
+	v := float64(value)
+	i := 0
+	for i < len(units)-1 && v >= base {
+		v /= base
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", value, units[0])
 	}
-	if missingRequiredFields(m, fields) {
-		return nil, fmt.Errorf("missing required fields from meminfo")
+	prec := decimals(v, digits)
+	r := round(v, prec)
+	if r >= base && i < len(units)-1 {
+		v /= base
+		i++
+		prec = decimals(v, digits)
+		r = round(v, prec)
 	}
-	// These values are expressed in kibibytes, convert to the desired unit
-	swapTotal := m["SwapTotal"] << KB
-	swapUsed := (m["SwapTotal"] - m["SwapFree"]) << KB
-	swapFree := m["SwapFree"] << KB
+	// Rounding may add an integer digit, as in 9.996 to 10.0.
+	prec = min(prec, decimals(r, digits))
+	return strconv.FormatFloat(r, 'f', prec, 64) + units[i]
+}
 
-	si := swapInfo{
-		Total: swapTotal,
-		Used:  swapUsed,
-		Free:  swapFree,
+// decimals returns how many decimal places v needs for digits significant
+// digits.
+func decimals(v float64, digits int) int {
+	n := 1
+	for ; v >= 10; v /= 10 {
+		n++
 	}
-	return &si, nil
+	return max(0, digits-n)
 }
 
-// missingRequiredFields checks if any of the specified fields are present in
-// the input map.
-func missingRequiredFields(m meminfomap, fields []string) bool {
-	for _, f := range fields {
-		if _, ok := m[f]; !ok {
-			log.Printf("Missing field '%v'", f)
-			return true
+// roundTo rounds v to prec decimal places.
+func roundTo(v float64, prec int) float64 {
+	p := math.Pow10(prec)
+	return math.Round(v*p) / p
+}
+
+// truncateTo truncates v to prec decimal places, so that the value shown is
+// never more than v.
+func truncateTo(v float64, prec int) float64 {
+	p := math.Pow10(prec)
+	return math.Trunc(v*p) / p
+}
+
+// parseSize parses a number of bytes with an optional suffix from units,
+// e.g. 512M or 1.5G, in powers of 1024. Lower case suffixes work as well.
+func parseSize(s string) (uint64, error) {
+	num, shift := s, 0
+	if s != "" {
+		for i, u := range units {
+			if strings.EqualFold(s[len(s)-1:], u) {
+				num, shift = s[:len(s)-1], i*10
+				break
+			}
 		}
 	}
-	return false
+	v, err := strconv.ParseFloat(num, 64)
+	v *= float64(uint64(1) << shift)
+	// The negated comparison catches NaN as well.
+	if err != nil || !(v >= 0) || v >= math.MaxUint64 {
+		return 0, fmt.Errorf("%q: %w", s, errMinAvail)
+	}
+	return uint64(v), nil
 }
 
-// humanReadableValue returns a string representing the input value, treated as
-// a size in bytes, interpreted in a human readable form. E.g. the number 10240
-// woud return the string "10 kB". Note that the decimal part is truncated, not
-// rounded, so the values are guaranteed to be "at least X"
-func humanReadableValue(value uint64) string {
-	v := value
-	// bits to shift. 0 means bytes, 10 means kB, and so on. 40 is the highest
-	// and it means tB
-	var shift uint
-	for shift < uint(len(units)*10) {
-		if v/1024 < 1 {
-			break
-		}
-		v /= 1024
-		shift += 10
-	}
-	var decimal uint64
-	if shift > 0 {
-		// no rounding. Is there a better way to do this?
-		decimal = ((value - (value >> shift << shift)) >> (shift - 10)) * 1000 / 1024 / 100
-	}
-	return fmt.Sprintf("%v.%v%v",
-		value>>shift,
-		decimal,
-		units[shift/10],
-	)
+// humanReadable formats value in human-readable form, in powers of 1000
+// with --si, and rounded rather than truncated with --round.
+func (c *cmd) humanReadable(value uint64) string {
+	round := truncateTo
+	if c.round {
+		round = roundTo
+	}
+	if c.si {
+		return humanize(value, 1000, siUnits[:], humanDigits, round)
+	}
+	return humanize(value, 1024, units[:], humanDigits, round)
 }
 
 // formatValueByConfig formats a size in bytes in the appropriate unit,
 // depending on whether FreeConfig specifies a human-readable format or a
 // specific unit
 func (c *cmd) formatValueByConfig(value uint64) string {
-	if c.human {
-		return humanReadableValue(value)
+	var s string
+	switch {
+	case c.human:
+		s = c.humanReadable(value)
+	case c.si:
+		// The unit constants are shifts, 10 for each power of 1024.
+		v := value
+		for u := B; u < c.unit; u += 10 {
+			v /= 1000
+		}
+		s = c.number(v)
+	default:
+		// units and decimal part are not printed when a unit is explicitly specified
+		s = c.number(value >> c.unit)
+	}
+	if c.raw {
+		s = fmt.Sprintf("%s (%s)", s, c.number(value))
+	}
+	return s
+}
+
+// number formats v in decimal, with commas between the thousands if --comma
+// asks for them.
+func (c *cmd) number(v uint64) string {
+	s := strconv.FormatUint(v, 10)
+	if !c.comma {
+		return s
+	}
+	return groupThousands(s)
+}
+
+// groupThousands puts a comma between every three digits of s, counting
+// from the right. It does the same in every locale.
+func groupThousands(s string) string {
+	if len(s) <= 3 {
+		return s
+	}
+	var b strings.Builder
+	first := len(s) % 3
+	if first == 0 {
+		first = 3
 	}
-	// units and decimal part are not printed when a unit is explicitly specified
-	return fmt.Sprintf("%v", value>>c.unit)
+	b.WriteString(s[:first])
+	for i := first; i < len(s); i += 3 {
+		b.WriteByte(',')
+		b.WriteString(s[i : i+3])
+	}
+	return b.String()
 }
 
 func main() {
 	flag.Parse()
-	o := options{human: *humanOutput, bytes: *inBytes, kbytes: *inKB, mbytes: *inMB, gbytes: *inGB, tbytes: *inTB, json: *toJSON}
-	cmd, err := command(os.Stdout, o)
+	var delaySet bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "s" {
+			delaySet = true
+		}
+	})
+	o := options{
+		delaySet: delaySet,
+		count:    *count,
+		delay:    *delay,
+		avg:      *avg,
+		once:     *once,
+
+		human:  *humanOutput,
+		round:  *round,
+		bytes:  *inBytes,
+		kbytes: *inKB,
+		mbytes: *inMB,
+		gbytes: *inGB,
+		tbytes: *inTB,
+		si:     *si,
+		raw:    *raw,
+		comma:  *comma,
+
+		output:   outFormat,
+		template: format,
+
+		psi:          *psi,
+		cgroup:       *cgroup,
+		cgroupWarn:   *cgroupWarn,
+		dumpHistory:  *dumpHistory,
+		history:      *historySize,
+		usedClassic:  *usedClassic,
+		cache:        *cache,
+		swaps:        *swaps,
+		wide:         *wide,
+		total:        *total,
+		waitAvail:    *waitAvail,
+		waitTimeout:  *waitTimeout,
+		source:       *source,
+		percent:      *percent,
+		minAvailable: *minAvail,
+		top:          *top,
+		showFields:   showFields,
+		numa:         *numa,
+		numaWarn:     *numaWarn,
+		bar:          string(bars),
+		color:        string(colors),
+		colorLow:     *colorLow,
+		validate:     *validate,
+		verbose:      *verbose,
+		strict:       *strict,
+		zswap:        *zswap,
+	}
+	stdout, err := openOutput(*output, *appendOut)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stdout.Close()
+	cmd, err := command(stdout, o)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -248,10 +614,90 @@ func main() {
 }
 
 type cmd struct {
-	stdout io.Writer
-	unit   unit
-	human  bool
-	toJSON bool
+	stdout   io.Writer
+	stderr   io.Writer
+	unit     unit
+	human    bool
+	round    bool
+	output   outputFormat
+	raw      bool
+	comma    bool
+	si       bool
+	tmpl     *template.Template
+	interval time.Duration
+	avg      int
+	// count is the number of tables to print. Zero means keep going
+	// until killed.
+	count int
+	// sleep waits between samples. It can be replaced in tests.
+	sleep func(time.Duration)
+	// meminfo reads the current memory information. It can be replaced in
+	// tests.
+	meminfo func() (map[string]uint64, error)
+	// psiFile is the memory pressure stall information file. Empty means
+	// not to show PSI.
+	psiFile string
+	// cgroupDir is the cgroup v2 directory to show the memory of and check
+	// the limit of. Empty means the host's memory and no check.
+	cgroupDir  string
+	cgroupWarn float64
+	// usedClassic leaves SReclaimable out of the cache, so that it counts
+	// as used.
+	usedClassic bool
+	// cache shows only the page cache, as -C does.
+	cache bool
+	// wide shows buffers and cache in columns of their own.
+	wide bool
+	// total adds the sum of memory and swap.
+	total bool
+	// percent adds the percentage of memory and swap used.
+	percent bool
+	// minAvailable is the available memory, in bytes, below which run
+	// fails. Zero means not to check.
+	minAvailable uint64
+	// waitAvail is the percentage of available memory to wait for. Zero
+	// means not to wait.
+	waitAvail float64
+	// waitTimeout is how long to wait for it. Zero means forever.
+	waitTimeout time.Duration
+	// swapsFile is the list of swap devices. Empty means not to list
+	// them.
+	swapsFile string
+	// procDir is the proc file system to find the top processes in, and
+	// top is how many of them to list. Empty means not to list any.
+	procDir string
+	top     int
+	// numaDir is the sysfs directory of the NUMA nodes to show the memory
+	// of. Empty means not to show them.
+	numaDir string
+	// numaWarn is the fraction of the free memory of the node with the
+	// most below which a node's free memory is warned about. Zero means
+	// not to warn.
+	numaWarn float64
+	// barWidth is the width of the --bar lines. Zero means not to draw
+	// them.
+	barWidth int
+	// color shows the available memory in red when it is below colorLow
+	// times the total.
+	color    bool
+	colorLow float64
+	// showFields are the /proc/meminfo fields to print rather than the
+	// table.
+	showFields []string
+	// validate checks the memory information rather than print it.
+	validate bool
+	// verbose lists every /proc/meminfo field after the table.
+	verbose bool
+	// zswap shows the zswap information.
+	zswap bool
+	// unitName is the unit a unit option asks for, for the unit field of
+	// -json and --yaml. Empty means none was given.
+	unitName string
+	// csvHeader is whether the --csv header has been printed.
+	csvHeader bool
+	// history keeps the most recent samples for --dump-history. nil
+	// means not to keep any.
+	history *history
 }
 
 type options struct {
@@ -261,7 +707,113 @@ type options struct {
 	mbytes bool
 	gbytes bool
 	tbytes bool
-	json   bool
+	raw    bool
+	comma  bool
+	si     bool
+	delay  float64
+	avg    int
+	count  int
+	once   bool
+	psi    bool
+	cgroup bool
+
+	cgroupWarn float64
+
+	// output is the output format, and template the --format template
+	// for formatTemplate.
+	output   outputFormat
+	template string
+
+	// round rounds human-readable values rather than cut them short.
+	round bool
+
+	// delaySet is whether -s was given, so that -s 0 is rejected rather
+	// than taken for the default.
+	delaySet bool
+
+	dumpHistory bool
+	history     int
+
+	usedClassic bool
+	cache       bool
+	swaps       bool
+	wide        bool
+	total       bool
+
+	waitAvail   float64
+	waitTimeout float64
+
+	// source is the meminfo file to read. Empty means /proc/meminfo.
+	source string
+
+	percent bool
+
+	// minAvailable is a size as parseSize takes it. Empty means not to
+	// check.
+	minAvailable string
+
+	top int
+
+	showFields []string
+
+	numa     bool
+	numaWarn float64
+
+	// bar is the --bar mode: always, auto or never. Empty means never.
+	bar string
+
+	// color is the --color mode: always, auto or never. Empty means
+	// never.
+	color    string
+	colorLow float64
+
+	validate bool
+
+	verbose bool
+
+	// strict fails on malformed meminfo lines rather than leave them out.
+	strict bool
+
+	zswap bool
+}
+
+// conflicts are the options that can't be combined: the first of each can't
+// be given with any of the others.
+var conflicts = [][]string{
+	{"-w", "-json", "--yaml", "-L"},
+	{"-C", "-L", "--csv", "--format"},
+	{"--raw", "--csv"},
+	{"--show-field", "-L", "-C", "--csv", "--format"},
+	{"--top", "-L", "-C", "--format"},
+	{"--numa", "-L", "-C"},
+	{"--bar", "-json", "--yaml", "--csv", "-L", "-C", "--format", "--show-field"},
+	{"-v", "--csv", "-L", "-C", "--format", "--show-field"},
+	{"--zswap", "--csv", "-L", "-C", "--show-field"},
+}
+
+// given returns whether the option flag, as conflicts names it, was given.
+func (o options) given(flag string) bool {
+	switch flag {
+	case "-w":
+		return o.wide
+	case "-C":
+		return o.cache
+	case "--raw":
+		return o.raw
+	case "--show-field":
+		return len(o.showFields) > 0
+	case "--top":
+		return o.top > 0
+	case "--numa":
+		return o.numa
+	case "--bar":
+		return o.bar != "" && o.bar != "never"
+	case "-v":
+		return o.verbose
+	case "--zswap":
+		return o.zswap
+	}
+	return o.output != formatTable && o.output.String() == flag
 }
 
 func countTrue(b ...bool) int {
@@ -282,9 +834,137 @@ func command(stdout io.Writer, o options) (*cmd, error) {
 		return nil, errMultipleUnits
 	}
 
+	if o.avg < 0 {
+		return nil, errAvgCount
+	}
+	if o.delay < 0 || o.delaySet && o.delay == 0 {
+		return nil, errDelay
+	}
+	if o.count < 0 {
+		return nil, errCount
+	}
+	if o.cgroup && (o.cgroupWarn <= 0 || o.cgroupWarn > 1) {
+		return nil, errCgroupWarn
+	}
+	if o.dumpHistory && o.history < 1 {
+		return nil, errHistory
+	}
+	if o.waitAvail < 0 || o.waitAvail >= 100 {
+		return nil, errWaitPercent
+	}
+	if o.waitTimeout < 0 || o.waitTimeout > 0 && o.waitAvail == 0 {
+		return nil, errWaitTimeout
+	}
+	if o.waitAvail > 0 && o.avg > 0 {
+		return nil, errWaitAvg
+	}
+	if o.waitAvail > 0 && o.minAvailable != "" {
+		return nil, errMinAvailWait
+	}
+	if o.top < 0 {
+		return nil, errTop
+	}
+	if o.numaWarn < 0 || o.numaWarn > 1 || o.numaWarn > 0 && !o.numa {
+		return nil, errNumaWarn
+	}
+	if when(o.color, stdout) && (o.colorLow <= 0 || o.colorLow > 1) {
+		return nil, errColorLow
+	}
+	for _, opts := range conflicts {
+		if !o.given(opts[0]) {
+			continue
+		}
+		for _, other := range opts[1:] {
+			if o.given(other) {
+				return nil, fmt.Errorf("%s %w with %s", opts[0], errConflict, other)
+			}
+		}
+	}
+
 	c := &cmd{
 		stdout: stdout,
-		toJSON: o.json,
+		stderr: os.Stderr,
+		round:  o.round,
+		output: o.output,
+		raw:    o.raw,
+		// CSV fields are plain numbers, and --csv is the only output
+		// then, so --comma has nothing to do.
+		comma:    o.comma && o.output != formatCSV,
+		si:       o.si,
+		avg:      o.avg,
+		interval: time.Duration(o.delay * float64(time.Second)),
+		count:    1,
+		sleep:    time.Sleep,
+
+		usedClassic: o.usedClassic,
+		cache:       o.cache,
+		wide:        o.wide,
+		total:       o.total,
+		percent:     o.percent,
+		showFields:  o.showFields,
+		validate:    o.validate,
+		verbose:     o.verbose,
+		zswap:       o.zswap,
+
+		waitAvail:   o.waitAvail,
+		waitTimeout: time.Duration(o.waitTimeout * float64(time.Second)),
+	}
+	if o.minAvailable != "" {
+		size, err := parseSize(o.minAvailable)
+		if err != nil {
+			return nil, err
+		}
+		c.minAvailable = size
+	}
+	source := o.source
+	if source == "" {
+		source = meminfo.File
+	}
+	c.meminfo = func() (map[string]uint64, error) {
+		m, err := meminfo.ReadFile(source)
+		if errors.Is(err, meminfo.ErrMalformedLine) && !o.strict {
+			log.Printf("warning: %v", err)
+			return m, nil
+		}
+		return m, err
+	}
+	if o.psi {
+		c.psiFile = psiFile
+	}
+	if o.swaps {
+		c.swapsFile = swapsFile
+	}
+	if o.top > 0 {
+		c.procDir = procDir
+		c.top = o.top
+	}
+	if o.numa {
+		c.numaDir = numaDir
+		c.numaWarn = o.numaWarn
+	}
+	c.barWidth = barWidth(o.bar, stdout)
+	if when(o.color, stdout) {
+		c.color = true
+		c.colorLow = o.colorLow
+	}
+	if o.cgroup {
+		c.cgroupDir = cgroupDir
+		c.cgroupWarn = o.cgroupWarn
+	}
+	if o.dumpHistory {
+		c.history = newHistory(o.history)
+	}
+	if (c.avg > 0 || c.waitAvail > 0) && c.interval == 0 {
+		c.interval = time.Second
+	}
+	if c.interval > 0 && c.avg == 0 && !o.once {
+		c.count = 0
+	}
+	if o.count > 0 && c.avg == 0 && !o.once {
+		c.count = o.count
+		if c.interval == 0 {
+			c.interval = time.Second
+		}
 	}
 
 	if o.human {
@@ -302,6 +982,24 @@ func command(stdout io.Writer, o options) (*cmd, error) {
 		default:
 			c.unit = KB
 		}
+		if count > 0 {
+			c.unitName = units[c.unit/10]
+			if c.si {
+				c.unitName = siUnits[c.unit/10]
+			}
+		}
+	}
+
+	if o.output == formatTemplate {
+		tmpl, err := template.New("format").Funcs(template.FuncMap{
+			"unit":  c.formatValueByConfig,
+			"human": c.humanReadable,
+			"field": field,
+		}).Parse(o.template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --format template: %w", err)
+		}
+		c.tmpl = tmpl
 	}
 
 	return c, nil
@@ -310,47 +1008,532 @@ func command(stdout io.Writer, o options) (*cmd, error) {
 // run prints physical memory and swap space information. The fields will be
 // expressed with the specified unit (e.g. KB, MB)
 func (c *cmd) run() error {
-	m, err := meminfo()
+	if c.validate {
+		return c.validateMemInfo()
+	}
+	if c.waitAvail > 0 {
+		return c.waitAvailable()
+	}
+	if c.avg > 0 {
+		mi, err := c.average()
+		if err != nil {
+			return err
+		}
+		if err := c.print(mi); err != nil {
+			return err
+		}
+		return c.checkAvailable(mi)
+	}
+
+	return c.poll()
+}
+
+// poll prints c.count tables, c.interval apart, or keeps printing forever if
+// c.count is zero. It prints before it sleeps, so the first table shows up
+// straight away.
+func (c *cmd) poll() error {
+	if c.history != nil {
+		defer c.dumpOnSignal()()
+	}
+	for i := 0; c.count == 0 || i < c.count; i++ {
+		if i > 0 {
+			c.sleep(c.interval)
+			if c.blankLines() {
+				fmt.Fprintln(c.stdout)
+			}
+		}
+		mi, err := c.sample()
+		if err != nil {
+			return err
+		}
+		if c.history != nil {
+			c.history.add(time.Now(), mi)
+		}
+		if err := c.print(mi); err != nil {
+			return err
+		}
+		if err := c.checkAvailable(mi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAvailable returns an error wrapping errLowMemory if mi has less
+// memory available than --min-available asks for.
+func (c *cmd) checkAvailable(mi *MemInfo) error {
+	if mi.Mem.Available >= c.minAvailable {
+		return nil
+	}
+	return fmt.Errorf("%w: %s available, want at least %s", errLowMemory,
+		humanReadableValue(mi.Mem.Available, humanDigits), humanReadableValue(c.minAvailable, humanDigits))
+}
+
+// waitAvailable samples the memory every c.interval until the available
+// memory is above c.waitAvail percent of the total, or c.waitTimeout has
+// passed.
+func (c *cmd) waitAvailable() error {
+	for waited := time.Duration(0); ; waited += c.interval {
+		mi, err := c.sample()
+		if err != nil {
+			return err
+		}
+		var pct float64
+		if mi.Mem.Total > 0 {
+			pct = float64(mi.Mem.Available) * 100 / float64(mi.Mem.Total)
+		}
+		if pct > c.waitAvail {
+			return nil
+		}
+		if c.waitTimeout > 0 && waited+c.interval > c.waitTimeout {
+			return fmt.Errorf("%w: %.1f%% available after %v, want more than %v%%", errWaitTimedOut, pct, waited, c.waitAvail)
+		}
+		c.sleep(c.interval)
+	}
+}
+
+// blankLines returns whether poll separates the tables it prints with blank
+// lines. -json, --yaml, --csv, -L and --format output is left as it is, for
+// other programs to read.
+func (c *cmd) blankLines() bool {
+	return c.output == formatTable
+}
+
+// sample reads and converts the current memory information.
+func (c *cmd) sample() (*MemInfo, error) {
+	m, err := c.meminfo()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return c.memInfo(m)
+}
 
-	return c.parse(m)
+// memInfo converts m, with the page cache information if -C asks for it and
+// the memory of the cgroup rather than the host's with --cgroup.
+func (c *cmd) memInfo(m map[string]uint64) (*MemInfo, error) {
+	mi, err := getMemInfo(m, c.usedClassic)
+	if err != nil {
+		return nil, err
+	}
+	if c.cgroupDir != "" {
+		if mi.Mem, err = cgroupMemInfo(c.cgroupDir, mi.Mem, c.usedClassic); err != nil {
+			return nil, fmt.Errorf("reading memory cgroup: %w", err)
+		}
+	}
+	if c.cache {
+		if mi.Cache, err = getCacheInfo(m); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.showFields) > 0 {
+		if mi.Fields, err = getFields(m, c.showFields); err != nil {
+			return nil, err
+		}
+	}
+	if c.verbose {
+		if mi.Raw, err = getFields(m, sortedNames(m)); err != nil {
+			return nil, err
+		}
+	}
+	if c.zswap {
+		// Without zswap, mi.Zswap is left nil.
+		if mi.Zswap, err = meminfo.ParseZswap(m); err != nil && !errors.Is(err, meminfo.ErrMissingField) {
+			return nil, err
+		}
+	}
+	return mi, nil
 }
 
-func (c *cmd) parse(m meminfomap) error {
-	mmi, err := getMainMemInfo(m)
+// average takes c.avg samples, c.interval apart, and returns their mean.
+func (c *cmd) average() (*MemInfo, error) {
+	samples := make([]*MemInfo, 0, c.avg)
+	for i := 0; i < c.avg; i++ {
+		if i > 0 {
+			c.sleep(c.interval)
+		}
+		mi, err := c.sample()
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, mi)
+	}
+	return averageMemInfo(samples), nil
+}
+
+// averageMemInfo returns the mean of each field across the samples.
+func averageMemInfo(samples []*MemInfo) *MemInfo {
+	var (
+		sum    MemInfo
+		cache  cacheInfo
+		zswap  meminfo.ZswapInfo
+		fields = map[string]uint64{}
+		raw    = map[string]uint64{}
+	)
+	for _, s := range samples {
+		for k, v := range s.Fields {
+			fields[k] += v
+		}
+		for k, v := range s.Raw {
+			raw[k] += v
+		}
+		if s.Cache != nil {
+			cache.Buffers += s.Cache.Buffers
+			cache.Cached += s.Cache.Cached
+			cache.SReclaimable += s.Cache.SReclaimable
+			cache.Reclaimable += s.Cache.Reclaimable
+		}
+		if s.Zswap != nil {
+			zswap.Compressed += s.Zswap.Compressed
+			zswap.Original += s.Zswap.Original
+			zswap.Ratio += s.Zswap.Ratio
+		}
+		sum.Mem.Total += s.Mem.Total
+		sum.Mem.Used += s.Mem.Used
+		sum.Mem.Free += s.Mem.Free
+		sum.Mem.Shared += s.Mem.Shared
+		sum.Mem.Cached += s.Mem.Cached
+		sum.Mem.Buffers += s.Mem.Buffers
+		sum.Mem.Available += s.Mem.Available
+		sum.Mem.Slab += s.Mem.Slab
+		sum.Mem.SReclaimable += s.Mem.SReclaimable
+		sum.Mem.SUnreclaim += s.Mem.SUnreclaim
+		sum.Mem.Dirty += s.Mem.Dirty
+		sum.Mem.Writeback += s.Mem.Writeback
+		sum.Swap.Total += s.Swap.Total
+		sum.Swap.Used += s.Swap.Used
+		sum.Swap.Free += s.Swap.Free
+	}
+	n := uint64(len(samples))
+	if n == 0 {
+		return &sum
+	}
+	avg := &MemInfo{
+		Mem: meminfo.MainMemInfo{
+			Total:     sum.Mem.Total / n,
+			Used:      sum.Mem.Used / n,
+			Free:      sum.Mem.Free / n,
+			Shared:    sum.Mem.Shared / n,
+			Cached:    sum.Mem.Cached / n,
+			Buffers:   sum.Mem.Buffers / n,
+			Available: sum.Mem.Available / n,
+
+			Slab:         sum.Mem.Slab / n,
+			SReclaimable: sum.Mem.SReclaimable / n,
+			SUnreclaim:   sum.Mem.SUnreclaim / n,
+			Dirty:        sum.Mem.Dirty / n,
+			Writeback:    sum.Mem.Writeback / n,
+		},
+		Swap: meminfo.SwapInfo{
+			Total: sum.Swap.Total / n,
+			Used:  sum.Swap.Used / n,
+			Free:  sum.Swap.Free / n,
+		},
+	}
+	if samples[0].Cache != nil {
+		avg.Cache = &cacheInfo{
+			Buffers:      cache.Buffers / n,
+			Cached:       cache.Cached / n,
+			SReclaimable: cache.SReclaimable / n,
+			Reclaimable:  cache.Reclaimable / n,
+		}
+	}
+	if samples[0].Zswap != nil {
+		avg.Zswap = &meminfo.ZswapInfo{
+			Compressed: zswap.Compressed / n,
+			Original:   zswap.Original / n,
+			Ratio:      zswap.Ratio / float64(n),
+		}
+	}
+	if samples[0].Fields != nil {
+		avg.Fields = make(map[string]uint64, len(fields))
+		for k, v := range fields {
+			avg.Fields[k] = v / n
+		}
+	}
+	if samples[0].Raw != nil {
+		avg.Raw = make(map[string]uint64, len(raw))
+		for k, v := range raw {
+			avg.Raw[k] = v / n
+		}
+	}
+	return avg
+}
+
+// getMemInfo returns the physical memory and swap space information from the
+// input map.
+func getMemInfo(m map[string]uint64, classic bool) (*MemInfo, error) {
+	pm, err := meminfo.ParseFields(m, classic)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	si, err := getSwapInfo(m)
+	return &MemInfo{Mem: pm.Mem, Swap: pm.Swap}, nil
+}
+
+func (c *cmd) parse(m map[string]uint64) error {
+	mi, err := c.memInfo(m)
 	if err != nil {
 		return err
 	}
-	mi := MemInfo{Mem: *mmi, Swap: *si}
-	if c.toJSON {
-		jsonData, err := json.Marshal(mi)
+	return c.print(mi)
+}
+
+// width returns the width of a value column.
+func (c *cmd) width() int {
+	// Leave room for the byte counts. Only machines with terabytes of
+	// memory overflow the wider columns.
+	w := 11
+	if c.raw {
+		w = 24
+	}
+	if c.comma {
+		// And for a comma every three digits.
+		w += w / 3
+	}
+	return w
+}
+
+// printMemRow writes a row of the table with the physical memory mmi.
+func (c *cmd) printMemRow(label string, mmi *meminfo.MainMemInfo) {
+	w := c.width()
+	values := []uint64{mmi.Total, mmi.Used, mmi.Free, mmi.Shared, mmi.Buffers + mmi.Cached, mmi.Available}
+	if c.wide {
+		values = []uint64{mmi.Total, mmi.Used, mmi.Free, mmi.Shared, mmi.Buffers, mmi.Cached, mmi.Available}
+	}
+	fmt.Fprintf(c.stdout, "%-7s", label)
+	for i, v := range values {
+		s := fmt.Sprintf("%*s", w, c.formatValueByConfig(v))
+		// Available is the last column.
+		if i == len(values)-1 && c.lowAvailable(mmi) {
+			s = colorRed + s + colorReset
+		}
+		fmt.Fprintf(c.stdout, " %s", s)
+	}
+	if c.percent {
+		fmt.Fprintf(c.stdout, " %*s", w, percentColumn(mmi.Used, mmi.Total))
+	}
+	fmt.Fprintln(c.stdout)
+}
+
+// print writes mi to stdout as a table, a single line, as JSON or through
+// the --format template.
+func (c *cmd) print(mi *MemInfo) error {
+	if c.psiFile != "" {
+		pi, err := readPSI(c.psiFile)
+		// Older kernels, or ones built without CONFIG_PSI, have no
+		// pressure file.
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		mi.PSI = pi
+	}
+	if c.total {
+		mi.Total = &totalInfo{
+			Total: mi.Mem.Total + mi.Swap.Total,
+			Used:  mi.Mem.Used + mi.Swap.Used,
+			Free:  mi.Mem.Free + mi.Swap.Free,
+		}
+	}
+	if c.percent {
+		mi.Mem.UsedPercent = usedPercent(mi.Mem.Used, mi.Mem.Total)
+		mi.Swap.UsedPercent = usedPercent(mi.Swap.Used, mi.Swap.Total)
+	}
+	if c.swapsFile != "" {
+		devs, err := readSwaps(c.swapsFile)
+		// Kernels built without CONFIG_SWAP have no swaps file, which
+		// is the same as having no swap.
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		mi.Swaps = devs
+	}
+	if c.procDir != "" {
+		procs, err := readTop(c.procDir, c.top, uint64(os.Getpagesize()))
 		if err != nil {
 			return err
 		}
-		fmt.Fprintln(c.stdout, string(jsonData))
-	} else {
-		fmt.Fprintf(c.stdout, "              total        used        free      shared  buff/cache   available\n")
-		fmt.Fprintf(c.stdout, "%-7s %11v %11v %11v %11v %11v %11v\n",
-			"Mem:",
-			c.formatValueByConfig(mmi.Total),
-			c.formatValueByConfig(mmi.Used),
-			c.formatValueByConfig(mmi.Free),
-			c.formatValueByConfig(mmi.Shared),
-			c.formatValueByConfig(mmi.Buffers+mmi.Cached),
-			c.formatValueByConfig(mmi.Available),
+		mi.Top = procs
+	}
+	if c.numaDir != "" {
+		nodes, err := readNodes(c.numaDir, mi.Mem, c.usedClassic)
+		if err != nil {
+			return err
+		}
+		if c.percent {
+			for i := range nodes {
+				nodes[i].UsedPercent = usedPercent(nodes[i].Used, nodes[i].Total)
+			}
+		}
+		mi.Nodes = nodes
+		c.numaWarning(nodes)
+	}
+	if c.cgroupDir != "" {
+		if err := c.cgroupWarning(); err != nil {
+			return err
+		}
+	}
+	if c.tmpl != nil {
+		var b bytes.Buffer
+		if err := c.tmpl.Execute(&b, mi); err != nil {
+			return err
+		}
+		b.WriteByte('\n')
+		_, err := c.stdout.Write(b.Bytes())
+		return err
+	}
+	if c.cache {
+		return c.printCache(mi.Cache)
+	}
+	if len(c.showFields) > 0 {
+		return c.printFields(mi.Fields)
+	}
+	switch c.output {
+	case formatCSV:
+		c.printCSV(mi)
+	case formatJSON, formatYAML:
+		mi.Unit = c.unitName
+		if c.zswap && mi.Zswap == nil {
+			fmt.Fprintln(c.stderr, noZswap)
+		}
+		return c.printData(mi)
+	case formatLine:
+		w := c.width()
+		fmt.Fprintf(c.stdout, "SwapUse %*s CachUse %*s MemUse %*s MemFree %*s\n",
+			w, c.formatValueByConfig(mi.Swap.Used),
+			w, c.formatValueByConfig(mi.Mem.Buffers+mi.Mem.Cached),
+			w, c.formatValueByConfig(mi.Mem.Used),
+			w, c.formatValueByConfig(mi.Mem.Free),
 		)
-		fmt.Fprintf(c.stdout, "%-7s %11v %11v %11v\n",
+	default:
+		si := &mi.Swap
+		w := c.width()
+		headers := []string{"total", "used", "free", "shared", "buff/cache", "available"}
+		if c.wide {
+			headers = []string{"total", "used", "free", "shared", "buffers", "cache", "available"}
+		}
+		cols := len(headers)
+		if c.percent {
+			headers = append(headers, "%used")
+		}
+		fmt.Fprintf(c.stdout, "%-7s", "")
+		for _, h := range headers {
+			fmt.Fprintf(c.stdout, " %*s", w, h)
+		}
+		fmt.Fprintln(c.stdout)
+		c.printMemRow("Mem:", &mi.Mem)
+		fmt.Fprintf(c.stdout, "%-7s %*v %*v %*v",
 			"Swap:",
-			c.formatValueByConfig(si.Total),
-			c.formatValueByConfig(si.Used),
-			c.formatValueByConfig(si.Free),
+			w, c.formatValueByConfig(si.Total),
+			w, c.formatValueByConfig(si.Used),
+			w, c.formatValueByConfig(si.Free),
 		)
+		if c.percent {
+			// Swap has no columns of its own between free and %used.
+			fmt.Fprintf(c.stdout, "%*s %*s", (cols-3)*(w+1), "", w, percentColumn(si.Used, si.Total))
+		}
+		fmt.Fprintln(c.stdout)
+		if ti := mi.Total; ti != nil {
+			fmt.Fprintf(c.stdout, "%-7s %*v %*v %*v\n",
+				"Total:",
+				w, c.formatValueByConfig(ti.Total),
+				w, c.formatValueByConfig(ti.Used),
+				w, c.formatValueByConfig(ti.Free),
+			)
+		}
+		if c.zswap {
+			c.printZswap(mi.Zswap)
+		}
+		for _, n := range mi.Nodes {
+			c.printMemRow(fmt.Sprintf("Node %d:", n.Node), &n.MainMemInfo)
+		}
+		if pi := mi.PSI; pi != nil {
+			label := "Stall:"
+			for _, l := range []struct {
+				name string
+				p    *pressure
+			}{{"some", pi.Some}, {"full", pi.Full}} {
+				if l.p == nil {
+					continue
+				}
+				fmt.Fprintf(c.stdout, "%-7s %s avg10=%.2f%% avg60=%.2f%% avg300=%.2f%%\n",
+					label, l.name, l.p.Avg10, l.p.Avg60, l.p.Avg300)
+				label = ""
+			}
+		}
+		if c.barWidth > 0 {
+			c.printBars(mi)
+		}
+		if c.swapsFile != "" {
+			c.printSwaps(mi.Swaps)
+		}
+		if c.procDir != "" {
+			c.printTop(mi.Top)
+		}
+		if c.verbose {
+			c.printRaw(mi.Raw)
+		}
+	}
+	return nil
+}
+
+// usedPercent returns used as a percentage of total, or 0 if total is 0.
+func usedPercent(used, total uint64) *float64 {
+	var p float64
+	if total > 0 {
+		p = float64(used) * 100 / float64(total)
+	}
+	return &p
+}
+
+// percentColumn returns the %used column for used out of total, truncated to
+// a whole percentage, or "-" if total is 0, as when there is no swap.
+func percentColumn(used, total uint64) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatUint(used*100/total, 10)
+}
+
+// printData writes v as JSON, or as a YAML document with --yaml. The YAML
+// is converted from the JSON, which YAML is a superset of, so that it has
+// the same fields, in the same order.
+func (c *cmd) printData(v any) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if c.output != formatYAML {
+		fmt.Fprintln(c.stdout, string(jsonData))
+		return nil
+	}
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal(jsonData, &m); err != nil {
+		return err
+	}
+	yamlData, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "---\n%s", yamlData)
+	return nil
+}
+
+// printCache writes the -C view of the page cache, as a table, or as JSON
+// or YAML.
+func (c *cmd) printCache(ci *cacheInfo) error {
+	if c.output == formatJSON || c.output == formatYAML {
+		return c.printData(ci)
+	}
+	w := c.width()
+	for _, l := range []struct {
+		name  string
+		value uint64
+	}{
+		{"Buffers:", ci.Buffers},
+		{"Cached:", ci.Cached},
+		{"SReclaimable:", ci.SReclaimable},
+		{"Reclaimable:", ci.Reclaimable},
+	} {
+		fmt.Fprintf(c.stdout, "%-13s %*s\n", l.name, w, c.formatValueByConfig(l.value))
 	}
 	return nil
 }
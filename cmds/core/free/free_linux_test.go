@@ -6,231 +6,1363 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/meminfo"
 )
 
-func TestMeminfoFromBytes(t *testing.T) {
+func TestParse(t *testing.T) {
 	input := []byte(`MemTotal:        8052976 kB
 MemFree:          721716 kB
 MemAvailable:    2774100 kB
 Buffers:          244880 kB
 Cached:          3462124 kB
+Shmem:           1617788 kB
 SwapTotal:       8265724 kB
 SwapFree:        8264956 kB
 SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
+	m, err := meminfo.Read(bytes.NewReader(input))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if m["MemFree"] != 721716 {
-		t.Fatalf("MemFree: got %v, want 721716", m["MemFree"])
-	}
-	if m["MemAvailable"] != 2774100 {
-		t.Fatalf("MemAvailable: got %v, want 2774100", m["MemAvailable"])
+
+	tests := []struct {
+		expectedTotalMem  string
+		expectedTotalSwap string
+		o                 options
+	}{
+		{
+			o:                 options{bytes: true},
+			expectedTotalMem:  "8246247424",
+			expectedTotalSwap: "8464101376",
+		},
+		{
+			expectedTotalMem:  "8052976",
+			expectedTotalSwap: "8265724",
+		},
+		{
+			o:                 options{mbytes: true},
+			expectedTotalMem:  "7864",
+			expectedTotalSwap: "8071",
+		},
+		{
+			o:                 options{gbytes: true},
+			expectedTotalMem:  "7",
+			expectedTotalSwap: "7",
+		},
+		{
+			o:                 options{tbytes: true},
+			expectedTotalMem:  "0",
+			expectedTotalSwap: "0",
+		},
+		{
+			o:                 options{human: true},
+			expectedTotalMem:  "7.67G",
+			expectedTotalSwap: "7.88G",
+		},
 	}
-	if m["Buffers"] != 244880 {
-		t.Fatalf("Buffers: got %v, want 244880", m["Buffers"])
+
+	for _, test := range tests {
+		var stdout bytes.Buffer
+		cmd, err := command(&stdout, test.o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = cmd.parse(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(stdout.String(), "\n")
+		memFields := strings.Fields(lines[1])
+		if memFields[1] != test.expectedTotalMem {
+			t.Errorf("expected total %s, got %s", test.expectedTotalMem, memFields[1])
+		}
+		swapFields := strings.Fields(lines[2])
+		if swapFields[1] != test.expectedTotalSwap {
+			t.Errorf("expected total %s, got %s", test.expectedTotalMem, swapFields[1])
+		}
 	}
-	if m["Cached"] != 3462124 {
-		t.Fatalf("Cached: got %v, want 3462124", m["Cached"])
+}
+
+func TestOptionError(t *testing.T) {
+	_, err := command(nil, options{kbytes: true, mbytes: true})
+	if err != errMultipleUnits {
+		t.Errorf("expected error: %v, got %v", errMultipleUnits, err)
 	}
-	if m["SwapTotal"] != 8265724 {
-		t.Fatalf("SwapTotal: got %v, want 8265724", m["SwapTotal"])
+}
+
+func TestAverageMemInfo(t *testing.T) {
+	samples := []*MemInfo{
+		{Mem: meminfo.MainMemInfo{Total: 1000, Used: 100, Free: 900, Available: 800, Slab: 60, Dirty: 10}, Swap: meminfo.SwapInfo{Total: 50, Used: 0, Free: 50}},
+		{Mem: meminfo.MainMemInfo{Total: 1000, Used: 300, Free: 700, Available: 600, Slab: 90, Dirty: 0}, Swap: meminfo.SwapInfo{Total: 50, Used: 20, Free: 30}},
+		{Mem: meminfo.MainMemInfo{Total: 1000, Used: 200, Free: 800, Available: 700, Slab: 60, Dirty: 5}, Swap: meminfo.SwapInfo{Total: 50, Used: 10, Free: 40}},
 	}
-	if m["SwapFree"] != 8264956 {
-		t.Fatalf("SwapFree: got %v, want 8264956", m["SwapFree"])
+	got := averageMemInfo(samples)
+	want := MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 1000, Used: 200, Free: 800, Available: 700, Slab: 70, Dirty: 5},
+		Swap: meminfo.SwapInfo{Total: 50, Used: 10, Free: 40},
 	}
-	if m["SReclaimable"] != 179852 {
-		t.Fatalf("SReclaimable: got %v, want 179852", m["SReclaimable"])
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("averageMemInfo() = %+v, want %+v", *got, want)
 	}
 }
 
-func TestPrintSwap(t *testing.T) {
-	input := []byte(`MemTotal:        8052976 kB
-MemFree:          721716 kB
-MemAvailable:    2774100 kB
-Buffers:          244880 kB
-Cached:          3462124 kB
-SwapTotal:       8265724 kB
-SwapFree:        8264956 kB
-SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
+func TestRunAverage(t *testing.T) {
+	snapshots := []string{
+		"MemTotal: 4096 kB\nMemFree: 1024 kB\nMemAvailable: 2048 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n",
+		"MemTotal: 4096 kB\nMemFree: 3072 kB\nMemAvailable: 3072 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n",
+	}
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{avg: len(snapshots), delay: 0.001})
 	if err != nil {
 		t.Fatal(err)
 	}
-	si, err := getSwapInfo(m)
-	if err != nil {
+	var calls int
+	c.meminfo = func() (map[string]uint64, error) {
+		m, err := meminfo.Read(strings.NewReader(snapshots[calls]))
+		calls++
+		return m, err
+	}
+	if err := c.run(); err != nil {
 		t.Fatal(err)
 	}
-	if si.Total != 8464101376 {
-		t.Fatalf("Swap.Total: got %v, want 8464101376", si.Total)
+	if calls != len(snapshots) {
+		t.Errorf("took %d samples, want %d", calls, len(snapshots))
+	}
+	want := `              total        used        free      shared  buff/cache   available
+Mem:           4096        2048        2048           0           0        2560
+Swap:             0           0           0
+`
+	if stdout.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout.String(), want)
 	}
-	if si.Used != 786432 {
-		t.Fatalf("Swap.Used: got %v, want 786432", si.Used)
+}
+
+func TestAverageOptionErrors(t *testing.T) {
+	if _, err := command(nil, options{avg: -1}); err != errAvgCount {
+		t.Errorf("expected error: %v, got %v", errAvgCount, err)
 	}
-	if si.Free != 8463314944 {
-		t.Fatalf("Swap.Free: got %v, want 8463314944", si.Free)
+	if _, err := command(nil, options{delay: -1}); err != errDelay {
+		t.Errorf("expected error: %v, got %v", errDelay, err)
 	}
 }
 
-func TestPrintSwapMissingFields(t *testing.T) {
-	input := []byte(`MemTotal:        8052976 kB
-MemFree:          721716 kB
-MemAvailable:    2774100 kB
-Buffers:          244880 kB
-Cached:          3462124 kB
-SwapTotal:       8265724 kB
-SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
-	if err != nil {
-		t.Fatal(err)
+func TestPollPrintsBeforeSleeping(t *testing.T) {
+	const snapshot = "MemTotal: 4096 kB\nMemFree: 1024 kB\nMemAvailable: 2048 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n"
+	readMeminfo := func() (map[string]uint64, error) {
+		return meminfo.Read(strings.NewReader(snapshot))
 	}
-	_, err = getSwapInfo(m)
-	// should error out for the missing field
-	if err == nil {
-		t.Fatal("printSwap: got no error when expecting one")
+
+	for _, tt := range []struct {
+		name   string
+		o      options
+		count  int
+		tables int
+		sleeps int
+	}{
+		{name: "once", o: options{delay: 3600, once: true}, tables: 1},
+		{name: "three samples", o: options{delay: 3600}, count: 3, tables: 3, sleeps: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.meminfo = readMeminfo
+			if tt.count != 0 {
+				c.count = tt.count
+			}
+			var sleeps int
+			c.sleep = func(d time.Duration) {
+				if d != time.Hour {
+					t.Errorf("sleep(%v), want %v", d, time.Hour)
+				}
+				if got := strings.Count(stdout.String(), "Mem:"); got != sleeps+1 {
+					t.Errorf("%d tables printed before sleep #%d, want %d", got, sleeps+1, sleeps+1)
+				}
+				sleeps++
+			}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(stdout.String(), "Mem:"); got != tt.tables {
+				t.Errorf("printed %d tables, want %d", got, tt.tables)
+			}
+			if sleeps != tt.sleeps {
+				t.Errorf("slept %d times, want %d", sleeps, tt.sleeps)
+			}
+		})
 	}
 }
 
-func TestPrintMem(t *testing.T) {
-	input := []byte(`MemTotal:        8052976 kB
-MemFree:          721716 kB
-MemAvailable:    2774100 kB
-Buffers:          244880 kB
-Cached:          3462124 kB
-Shmem:           1617788 kB
-SwapTotal:       8265724 kB
-SwapFree:        8264956 kB
-SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
+func TestOnceIsImmediate(t *testing.T) {
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{delay: 3600, once: true})
 	if err != nil {
 		t.Fatal(err)
 	}
-	mmi, err := getMainMemInfo(m)
-	if err != nil {
+	c.meminfo = func() (map[string]uint64, error) {
+		return meminfo.Read(strings.NewReader("MemTotal: 4096 kB\nMemFree: 1024 kB\nMemAvailable: 2048 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n"))
+	}
+	start := time.Now()
+	if err := c.run(); err != nil {
 		t.Fatal(err)
 	}
-	if mmi.Total != 8246247424 {
-		t.Fatalf("MainMem.Total: got %v, want 8246247424", mmi.Total)
+	if d := time.Since(start); d > time.Minute {
+		t.Errorf("--once took %v, want no delay", d)
 	}
-	if mmi.Free != 739037184 {
-		t.Fatalf("MainMem.Free: got %v, want 739037184", mmi.Free)
+	if stdout.Len() == 0 {
+		t.Error("--once printed nothing")
 	}
-	if mmi.Used != 3527069696 {
-		t.Fatalf("MainMem.Used: got %v, want 3527069696", mmi.Used)
+}
+
+func TestRaw(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Shared: 0, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Used: 0, Free: 1 << 30},
 	}
-	if mmi.Shared != 1656614912 {
-		t.Fatalf("MainMem.Shared: got %v, want 1656614912", mmi.Shared)
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "human",
+			o:    options{human: true, raw: true},
+			want: `                           total                     used                     free                   shared               buff/cache                available
+Mem:          2.00G (2147483648)       1.00G (1073741824)         512M (536870912)                   0B (0)         512M (536870912)       1.50G (1610612736)
+Swap:         1.00G (1073741824)                   0B (0)       1.00G (1073741824)
+`,
+		},
+		{
+			name: "mebibytes",
+			o:    options{mbytes: true, raw: true},
+			want: `                           total                     used                     free                   shared               buff/cache                available
+Mem:           2048 (2147483648)        1024 (1073741824)          512 (536870912)                    0 (0)          512 (536870912)        1536 (1610612736)
+Swap:          1024 (1073741824)                    0 (0)        1024 (1073741824)
+`,
+		},
+		{
+			name: "json ignores raw",
+			o:    options{output: formatJSON, raw: true},
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":1073741824,"used":0,"free":1073741824}}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
 	}
-	if mmi.Cached != 3729383424 {
-		t.Fatalf("MainMem.Cached: got %v, want 3729383424", mmi.Cached)
+}
+
+func TestJSONUnit(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{name: "default", o: options{}, want: ""},
+		{name: "human", o: options{human: true}, want: ""},
+		{name: "bytes", o: options{bytes: true}, want: "B"},
+		{name: "kibibytes", o: options{kbytes: true}, want: "K"},
+		{name: "mebibytes", o: options{mbytes: true}, want: "M"},
+		{name: "gibibytes", o: options{gbytes: true}, want: "G"},
+		{name: "tebibytes", o: options{tbytes: true}, want: "T"},
+		{name: "megabytes", o: options{mbytes: true, si: true}, want: "MB"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mi := &MemInfo{Mem: meminfo.MainMemInfo{Total: 2 << 30, Free: 1 << 30}}
+			var stdout bytes.Buffer
+			tt.o.output = formatJSON
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Unit *string `json:"unit"`
+				Mem  struct {
+					Total uint64 `json:"total"`
+				} `json:"mem"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == "" && got.Unit != nil:
+				t.Errorf("unit = %q, want none", *got.Unit)
+			case tt.want != "" && (got.Unit == nil || *got.Unit != tt.want):
+				t.Errorf("unit = %v, want %q", got.Unit, tt.want)
+			}
+			// The values stay in bytes.
+			if got.Mem.Total != 2<<30 {
+				t.Errorf("mem.total = %d, want %d", got.Mem.Total, uint64(2<<30))
+			}
+		})
 	}
-	if mmi.Buffers != 250757120 {
-		t.Fatalf("MainMem.Buffers: got %v, want 250757120", mmi.Buffers)
+}
+
+func TestFormat(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Free: 1 << 30},
 	}
-	if mmi.Available != 2840678400 {
-		t.Fatalf("MainMem.Available: got %v, want 2840678400", mmi.Available)
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "field",
+			o:    options{output: formatTemplate, template: "{{.Mem.Available}}"},
+			want: "1610612736\n",
+		},
+		{
+			name: "unit",
+			o:    options{mbytes: true, output: formatTemplate, template: "{{unit .Mem.Used}}/{{unit .Mem.Total}} MiB"},
+			want: "1024/2048 MiB\n",
+		},
+		{
+			name: "human",
+			o:    options{output: formatTemplate, template: "mem {{human .Mem.Total}} swap {{human .Swap.Free}}"},
+			want: "mem 2.00G swap 1.00G\n",
+		},
+		{
+			name: "unit follows -h",
+			o:    options{human: true, output: formatTemplate, template: "{{unit .Mem.Free}}"},
+			want: "512M\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got %q, want %q", stdout.String(), tt.want)
+			}
+		})
 	}
 }
 
-func TestPrintMemMissingFields(t *testing.T) {
-	input := []byte(`MemTotal:        8052976 kB
-MemFree:          721716 kB
-MemAvailable:    2774100 kB
-Buffers:          244880 kB
-SwapTotal:       8265724 kB
-SwapFree:        8264956 kB
-SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
+func TestFormatErrors(t *testing.T) {
+	if _, err := command(nil, options{output: formatTemplate, template: "{{.Mem.Total"}); err == nil || !strings.Contains(err.Error(), "invalid --format template") {
+		t.Errorf("unterminated action: got %v, want an invalid template error", err)
+	}
+	if _, err := command(nil, options{output: formatTemplate, template: "{{bogus .Mem.Total}}"}); err == nil {
+		t.Error("unknown function: got nil, want an error")
+	}
+
+	c, err := command(&bytes.Buffer{}, options{output: formatTemplate, template: "{{.Mem.Bogus}}"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = getMainMemInfo(m)
-	// should error out for the missing field
-	if err == nil {
-		t.Fatal("printMem: got no error when expecting one")
+	if err := c.print(&MemInfo{}); err == nil {
+		t.Error("unknown field: got nil, want an error")
 	}
 }
 
-func TestParse(t *testing.T) {
-	input := []byte(`MemTotal:        8052976 kB
-MemFree:          721716 kB
-MemAvailable:    2774100 kB
-Buffers:          244880 kB
-Cached:          3462124 kB
-Shmem:           1617788 kB
-SwapTotal:       8265724 kB
-SwapFree:        8264956 kB
-SReclaimable:     179852 kB`)
-	m, err := meminfoFromBytes(input)
+func TestLine(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Shared: 0, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Used: 3 << 20, Free: 1<<30 - 3<<20},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "kibibytes",
+			o:    options{output: formatLine},
+			want: "SwapUse        3072 CachUse      524288 MemUse     1048576 MemFree      524288\n",
+		},
+		{
+			name: "mebibytes",
+			o:    options{output: formatLine, mbytes: true},
+			want: "SwapUse           3 CachUse         512 MemUse        1024 MemFree         512\n",
+		},
+		{
+			name: "human",
+			o:    options{output: formatLine, human: true},
+			want: "SwapUse       3.00M CachUse        512M MemUse       1.00G MemFree        512M\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := command(nil, options{output: formatLine, wide: true}); !errors.Is(err, errConflict) {
+		t.Errorf("-L -w: got %v, want %v", err, errConflict)
+	}
+}
+
+func TestLineFixture(t *testing.T) {
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{output: formatLine, source: "testdata/meminfo.txt"})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	// Scripts read the values by position, so the labels must stay in
+	// this order.
+	want := "SwapUse         768 CachUse     3886856 MemUse     3444404 MemFree      721716\n"
+	if stdout.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", stdout.String(), want)
+	}
+}
 
-	tests := []struct {
-		expectedTotalMem  string
-		expectedTotalSwap string
-		o                 options
+func TestStrict(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	for _, tt := range []struct {
+		name   string
+		strict bool
+		err    error
+		want   string
 	}{
 		{
-			o:                 options{bytes: true},
-			expectedTotalMem:  "8246247424",
-			expectedTotalSwap: "8464101376",
+			// The broken Active line is not needed for the table.
+			name: "lenient",
+			want: "SwapUse         768 CachUse     3886856 MemUse     3444404 MemFree      721716\n",
 		},
 		{
-			expectedTotalMem:  "8052976",
-			expectedTotalSwap: "8265724",
+			name:   "strict",
+			strict: true,
+			err:    meminfo.ErrMalformedLine,
 		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+			var stdout bytes.Buffer
+			c, err := command(&stdout, options{output: formatLine, strict: tt.strict, source: "testdata/meminfo_malformed.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.run()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got %q, want %q", stdout.String(), tt.want)
+			}
+			// Either way the line is named, in the warning or the error.
+			msg := logged.String()
+			if err != nil {
+				msg = err.Error()
+			}
+			if want := `line 7: "Active:          35628x4 kB"`; !strings.Contains(msg, want) {
+				t.Errorf("got %q, want it to name %s", msg, want)
+			}
+			if tt.strict && logged.Len() > 0 {
+				t.Errorf("--strict logged %q, want nothing", logged.String())
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	b, err := os.ReadFile("testdata/meminfo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := meminfo.Read(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
 		{
-			o:                 options{mbytes: true},
-			expectedTotalMem:  "7864",
-			expectedTotalSwap: "8071",
+			name: "kibibytes",
+			o:    options{cache: true},
+			want: `Buffers:           244880
+Cached:           3462124
+SReclaimable:      179852
+Reclaimable:      3886856
+`,
 		},
 		{
-			o:                 options{gbytes: true},
-			expectedTotalMem:  "7",
-			expectedTotalSwap: "7",
+			name: "mebibytes",
+			o:    options{cache: true, mbytes: true},
+			want: `Buffers:              239
+Cached:              3380
+SReclaimable:         175
+Reclaimable:         3795
+`,
 		},
 		{
-			o:                 options{tbytes: true},
-			expectedTotalMem:  "0",
-			expectedTotalSwap: "0",
+			name: "human",
+			o:    options{cache: true, human: true},
+			want: `Buffers:             239M
+Cached:             3.30G
+SReclaimable:        175M
+Reclaimable:        3.70G
+`,
 		},
 		{
-			o:                 options{human: true},
-			expectedTotalMem:  "7.6G",
-			expectedTotalSwap: "7.8G",
+			name: "json",
+			o:    options{cache: true, output: formatJSON},
+			want: `{"buffers":250757120,"cached":3545214976,"sreclaimable":184168448,"reclaimable":3980140544}
+`,
 		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.parse(m); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
 	}
 
-	for _, test := range tests {
-		var stdout bytes.Buffer
-		cmd, err := command(&stdout, test.o)
-		if err != nil {
-			t.Fatal(err)
+	if _, err := command(nil, options{cache: true, output: formatLine}); !errors.Is(err, errConflict) {
+		t.Errorf("-C -L: got %v, want %v", err, errConflict)
+	}
+	if _, err := command(nil, options{cache: true, output: formatTemplate, template: "{{.Mem.Used}}"}); !errors.Is(err, errConflict) {
+		t.Errorf("-C --format: got %v, want %v", err, errConflict)
+	}
+	delete(m, "SReclaimable")
+	if _, err := getCacheInfo(m); err == nil {
+		t.Error("missing SReclaimable: got nil, want an error")
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	for _, tt := range []struct {
+		v    uint64
+		want string
+	}{
+		{0, "0"},
+		{7, "7"},
+		{42, "42"},
+		{123, "123"},
+		{999, "999"},
+		{1000, "1,000"},
+		{12345, "12,345"},
+		{999999, "999,999"},
+		{1000000, "1,000,000"},
+		{16384000, "16,384,000"},
+		{1234567890, "1,234,567,890"},
+		{8246247424, "8,246,247,424"},
+		{math.MaxUint64, "18,446,744,073,709,551,615"},
+	} {
+		if got := groupThousands(strconv.FormatUint(tt.v, 10)); got != tt.want {
+			t.Errorf("groupThousands(%d) = %q, want %q", tt.v, got, tt.want)
 		}
+	}
+}
 
-		err = cmd.parse(m)
-		if err != nil {
-			t.Fatal(err)
+func TestComma(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 16384000 << 10, Used: 512 << 10, Free: 999 << 10, Cached: 1000 << 10},
+		Swap: meminfo.SwapInfo{Total: 2 << 30, Free: 2 << 30},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "kibibytes",
+			o:    options{comma: true},
+			want: `                 total           used           free         shared     buff/cache      available
+Mem:        16,384,000            512            999              0          1,000              0
+Swap:        2,097,152              0      2,097,152
+`,
+		},
+		{
+			name: "line",
+			o:    options{comma: true, output: formatLine, bytes: true},
+			want: "SwapUse              0 CachUse      1,024,000 MemUse        524,288 MemFree      1,022,976\n",
+		},
+		{
+			name: "human",
+			o:    options{comma: true, output: formatLine, human: true},
+			want: "SwapUse             0B CachUse          1000K MemUse           512K MemFree           999K\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCommaNotInData(t *testing.T) {
+	mi := &MemInfo{
+		Mem: meminfo.MainMemInfo{Total: 1234567890 << 10, Free: 1000 << 10, Available: 123 << 10},
+	}
+	for _, o := range []options{{output: formatJSON}, {output: formatYAML}} {
+		var plain, grouped bytes.Buffer
+		for _, p := range []struct {
+			w     *bytes.Buffer
+			comma bool
+		}{{&plain, false}, {&grouped, true}} {
+			o.comma = p.comma
+			c, err := command(p.w, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if grouped.String() != plain.String() {
+			t.Errorf("%+v: --comma changed the output:\n%s\nwant\n%s", o, grouped.String(), plain.String())
 		}
+	}
+}
 
-		lines := strings.Split(stdout.String(), "\n")
-		memFields := strings.Fields(lines[1])
-		if memFields[1] != test.expectedTotalMem {
-			t.Errorf("expected total %s, got %s", test.expectedTotalMem, memFields[1])
+func TestThousandsFlag(t *testing.T) {
+	f := flag.Lookup("thousands")
+	if f == nil {
+		t.Fatal("no --thousands flag")
+	}
+	defer func() { *comma = false }()
+	if err := f.Value.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	if !*comma {
+		t.Errorf("--thousands did not set --comma")
+	}
+}
+
+func TestCount(t *testing.T) {
+	const snapshot = "MemTotal: 4096 kB\nMemFree: 1024 kB\nMemAvailable: 2048 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n"
+	for _, tt := range []struct {
+		name   string
+		o      options
+		sleep  time.Duration
+		tables int
+		blank  int
+	}{
+		{name: "count", o: options{count: 3}, sleep: time.Second, tables: 3, blank: 2},
+		{name: "fractional delay", o: options{delay: 0.5, delaySet: true, count: 2}, sleep: 500 * time.Millisecond, tables: 2, blank: 1},
+		{name: "json", o: options{count: 2, output: formatJSON}, sleep: time.Second, tables: 2},
+		{name: "line", o: options{count: 2, output: formatLine}, sleep: time.Second, tables: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reads int
+			c.meminfo = func() (map[string]uint64, error) {
+				reads++
+				return meminfo.Read(strings.NewReader(snapshot))
+			}
+			var sleeps int
+			c.sleep = func(d time.Duration) {
+				if d != tt.sleep {
+					t.Errorf("sleep(%v), want %v", d, tt.sleep)
+				}
+				sleeps++
+			}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if reads != tt.tables {
+				t.Errorf("read meminfo %d times, want %d", reads, tt.tables)
+			}
+			if sleeps != tt.tables-1 {
+				t.Errorf("slept %d times, want %d", sleeps, tt.tables-1)
+			}
+			if got := strings.Count(stdout.String(), "\n\n"); got != tt.blank {
+				t.Errorf("got %d blank lines, want %d:\n%s", got, tt.blank, stdout.String())
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		o    options
+		err  error
+	}{
+		{name: "zero delay", o: options{delaySet: true}, err: errDelay},
+		{name: "negative delay", o: options{delay: -1, delaySet: true}, err: errDelay},
+		{name: "negative count", o: options{count: -1}, err: errCount},
+	} {
+		if _, err := command(nil, tt.o); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
 		}
-		swapFields := strings.Fields(lines[2])
-		if swapFields[1] != test.expectedTotalSwap {
-			t.Errorf("expected total %s, got %s", test.expectedTotalMem, swapFields[1])
+	}
+}
+
+func TestSI(t *testing.T) {
+	for _, tt := range []struct {
+		v    uint64
+		want string
+	}{
+		{0, "0B"},
+		{999, "999B"},
+		{1000, "1.00KB"},
+		{1024, "1.02KB"},
+		{1500000, "1.50MB"},
+		{1999999, "2.00MB"},
+		{999999, "1.00MB"},
+		{8246247424, "8.25GB"},
+		{5000000000000, "5.00TB"},
+		{5000000000000000, "5000TB"},
+	} {
+		if got := humanReadableSI(tt.v, humanDigits); got != tt.want {
+			t.Errorf("humanReadableSI(%d) = %q, want %q", tt.v, got, tt.want)
 		}
 	}
+
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{name: "human", o: options{human: true}, want: "1.43M"},
+		{name: "human si", o: options{human: true, si: true}, want: "1.50MB"},
+		{name: "kilobytes", o: options{si: true}, want: "1500"},
+		{name: "kibibytes", o: options{}, want: "1464"},
+		{name: "megabytes", o: options{si: true, mbytes: true}, want: "1"},
+		{name: "bytes", o: options{si: true, bytes: true}, want: "1500000"},
+		{name: "raw", o: options{si: true, raw: true}, want: "1500 (1500000)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := command(nil, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.formatValueByConfig(1500000); got != tt.want {
+				t.Errorf("formatValueByConfig(1500000) = %q, want %q", got, tt.want)
+			}
+		})
+	}
 }
 
-func TestOptionError(t *testing.T) {
-	_, err := command(nil, options{kbytes: true, mbytes: true})
-	if err != errMultipleUnits {
-		t.Errorf("expected error: %v, got %v", errMultipleUnits, err)
+func TestRound(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		v     uint64
+		round bool
+		si    bool
+		want  string
+	}{
+		// 1.5M exactly is the same either way.
+		{name: "1.5M truncated", v: 1572864, want: "1.50M"},
+		{name: "1.5M rounded", v: 1572864, round: true, want: "1.50M"},
+		// 1610612 bytes are 1.536M.
+		{name: "1.536M truncated", v: 1610612, want: "1.53M"},
+		{name: "1.536M rounded", v: 1610612, round: true, want: "1.54M"},
+		// Just short of a unit, rounding moves on to the next one.
+		{name: "1048575 truncated", v: 1048575, want: "1023K"},
+		{name: "1048575 rounded", v: 1048575, round: true, want: "1.00M"},
+		{name: "si truncated", v: 1999999, si: true, want: "1.99MB"},
+		{name: "si rounded", v: 1999999, round: true, si: true, want: "2.00MB"},
+		{name: "bytes truncated", v: 1000, want: "1000B"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := command(nil, options{human: true, round: tt.round, si: tt.si})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.formatValueByConfig(tt.v); got != tt.want {
+				t.Errorf("formatValueByConfig(%d) = %q, want %q", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanReadableValue(t *testing.T) {
+	for _, tt := range []struct {
+		v      uint64
+		digits int
+		want   string
+	}{
+		{0, 3, "0B"},
+		{1023, 3, "1023B"},
+		{1024, 3, "1.00K"},
+		{1025, 3, "1.00K"},
+		{1535, 3, "1.50K"},
+		{10235, 3, "10.0K"},
+		{102399, 3, "100K"},
+		{1048063, 3, "1023K"},
+		{1048575, 3, "1.00M"},
+		{1048576, 3, "1.00M"},
+		{1500000, 3, "1.43M"},
+		{1073741823, 3, "1.00G"},
+		{8201027584, 3, "7.64G"},
+		{5 << 50, 3, "5120T"},
+		{1500000, 1, "1M"},
+		{1500000, 2, "1.4M"},
+		{1500000, 5, "1.4305M"},
+		{1048575, 5, "1.0000M"},
+		{1048575, 7, "1023.999K"},
+	} {
+		if got := humanReadableValue(tt.v, tt.digits); got != tt.want {
+			t.Errorf("humanReadableValue(%d, %d) = %q, want %q", tt.v, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestHumanMixedMagnitudes(t *testing.T) {
+	// Every value, in every row, picks its own unit, and an empty swap
+	// shows as 0B rather than blank.
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 64 << 30, Used: 1536 << 20, Free: 900 << 20, Shared: 12 << 10, Cached: 3 << 20, Buffers: 512, Available: 60 << 30},
+		Swap: meminfo.SwapInfo{},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "human",
+			o:    options{human: true, total: true},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          64.0G       1.50G        900M       12.0K       3.00M       60.0G
+Swap:            0B          0B          0B
+Total:        64.0G       1.50G        900M
+`,
+		},
+		{
+			name: "si",
+			o:    options{human: true, si: true, total: true},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:         68.7GB      1.61GB       943MB      12.2KB      3.14MB      64.4GB
+Swap:            0B          0B          0B
+Total:       68.7GB      1.61GB       943MB
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tt.want[1:] {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want[1:])
+			}
+		})
+	}
+}
+
+func TestWide(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Shared: 8 << 20, Cached: 384 << 20, Buffers: 128 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Free: 1 << 30},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "wide",
+			o:    options{mbytes: true, wide: true},
+			want: `              total        used        free      shared     buffers       cache   available
+Mem:           2048        1024         512           8         128         384        1536
+Swap:          1024           0        1024
+`,
+		},
+		{
+			name: "wide raw",
+			o:    options{mbytes: true, wide: true, raw: true},
+			want: `                           total                     used                     free                   shared                  buffers                    cache                available
+Mem:           2048 (2147483648)        1024 (1073741824)          512 (536870912)              8 (8388608)          128 (134217728)          384 (402653184)        1536 (1610612736)
+Swap:          1024 (1073741824)                    0 (0)        1024 (1073741824)
+`,
+		},
+		{
+			name: "default",
+			o:    options{mbytes: true},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           8         512        1536
+Swap:          1024           0        1024
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := command(nil, options{wide: true, output: formatJSON}); !errors.Is(err, errConflict) {
+		t.Errorf("-w -json: got %v, want %v", err, errConflict)
+	}
+}
+
+func TestTotal(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Used: 256 << 20, Free: 768 << 20},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{mbytes: true, total: true},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          1024         256         768
+Total:         3072        1280        1280
+`,
+		},
+		{
+			name: "human",
+			o:    options{human: true, total: true},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:          2.00G       1.00G        512M          0B        512M       1.50G
+Swap:         1.00G        256M        768M
+Total:        3.00G       1.25G       1.25G
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, total: true},
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":1073741824,"used":268435456,"free":805306368},"total":{"total":3221225472,"used":1342177280,"free":1342177280}}
+`,
+		},
+		{
+			name: "off",
+			o:    options{mbytes: true},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          1024         256         768
+`,
+		},
+		{
+			name: "json off",
+			o:    options{output: formatJSON},
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":1073741824,"used":268435456,"free":805306368}}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mi := *mi
+			if err := c.print(&mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	mem := meminfo.MainMemInfo{Total: 3 << 30, Used: 1 << 30, Free: 1 << 30, Cached: 512 << 20, Buffers: 512 << 20, Available: 2 << 30}
+	for _, tt := range []struct {
+		name string
+		o    options
+		swap meminfo.SwapInfo
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{mbytes: true, percent: true},
+			swap: meminfo.SwapInfo{Total: 1 << 30, Used: 256 << 20, Free: 768 << 20},
+			want: `              total        used        free      shared  buff/cache   available       %used
+Mem:           3072        1024        1024           0        1024        2048          33
+Swap:          1024         256         768                                              25
+`,
+		},
+		{
+			name: "no swap",
+			o:    options{mbytes: true, percent: true},
+			want: `              total        used        free      shared  buff/cache   available       %used
+Mem:           3072        1024        1024           0        1024        2048          33
+Swap:             0           0           0                                               -
+`,
+		},
+		{
+			name: "human wide",
+			o:    options{human: true, wide: true, percent: true},
+			want: `              total        used        free      shared     buffers       cache   available       %used
+Mem:          3.00G       1.00G       1.00G          0B        512M        512M       2.00G          33
+Swap:            0B          0B          0B                                                           -
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, percent: true},
+			swap: meminfo.SwapInfo{Total: 1 << 30, Used: 256 << 20, Free: 768 << 20},
+			want: `{"mem":{"total":3221225472,"used":1073741824,"free":1073741824,"shared":0,"cached":536870912,"buffers":536870912,"available":2147483648,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0,"used_percent":33.333333333333336},"swap":{"total":1073741824,"used":268435456,"free":805306368,"used_percent":25}}
+`,
+		},
+		{
+			name: "json no swap",
+			o:    options{output: formatJSON, percent: true},
+			want: `{"mem":{"total":3221225472,"used":1073741824,"free":1073741824,"shared":0,"cached":536870912,"buffers":536870912,"available":2147483648,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0,"used_percent":33.333333333333336},"swap":{"total":0,"used":0,"free":0,"used_percent":0}}
+`,
+		},
+		{
+			name: "json off",
+			o:    options{output: formatJSON},
+			want: `{"mem":{"total":3221225472,"used":1073741824,"free":1073741824,"shared":0,"cached":536870912,"buffers":536870912,"available":2147483648,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":0,"used":0,"free":0}}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.print(&MemInfo{Mem: mem, Swap: tt.swap}); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitUntilAvailable(t *testing.T) {
+	// Available memory in kibibytes, out of 1000, of each sample.
+	snapshot := func(avail int) string {
+		return fmt.Sprintf("MemTotal: 1000 kB\nMemFree: %d kB\nMemAvailable: %d kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n", avail, avail)
+	}
+	for _, tt := range []struct {
+		name    string
+		o       options
+		avail   []int
+		sleep   time.Duration
+		samples int
+		err     error
+	}{
+		{name: "already available", o: options{waitAvail: 50}, avail: []int{600}, sleep: time.Second, samples: 1},
+		{name: "crosses threshold", o: options{waitAvail: 50}, avail: []int{100, 300, 500, 501}, sleep: time.Second, samples: 4},
+		{name: "delay", o: options{waitAvail: 25, delay: 0.5, delaySet: true}, avail: []int{100, 200, 900}, sleep: 500 * time.Millisecond, samples: 3},
+		{name: "timeout", o: options{waitAvail: 50, waitTimeout: 3}, avail: []int{100, 100, 100, 100, 900}, sleep: time.Second, samples: 4, err: errWaitTimedOut},
+		{name: "in time", o: options{waitAvail: 50, waitTimeout: 3}, avail: []int{100, 100, 100, 900}, sleep: time.Second, samples: 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var samples int
+			c.meminfo = func() (map[string]uint64, error) {
+				if samples == len(tt.avail) {
+					t.Fatalf("sampled more than %d times", len(tt.avail))
+				}
+				samples++
+				return meminfo.Read(strings.NewReader(snapshot(tt.avail[samples-1])))
+			}
+			c.sleep = func(d time.Duration) {
+				if d != tt.sleep {
+					t.Errorf("sleep(%v), want %v", d, tt.sleep)
+				}
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			if samples != tt.samples {
+				t.Errorf("sampled %d times, want %d", samples, tt.samples)
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("printed %q, want nothing", stdout.String())
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		o    options
+		err  error
+	}{
+		{name: "negative percentage", o: options{waitAvail: -1}, err: errWaitPercent},
+		{name: "100 percent", o: options{waitAvail: 100}, err: errWaitPercent},
+		{name: "timeout without wait", o: options{waitTimeout: 5}, err: errWaitTimeout},
+		{name: "negative timeout", o: options{waitAvail: 50, waitTimeout: -1}, err: errWaitTimeout},
+		{name: "avg", o: options{waitAvail: 50, avg: 3}, err: errWaitAvg},
+	} {
+		if _, err := command(nil, tt.o); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	garbage := filepath.Join(t.TempDir(), "meminfo")
+	if err := os.WriteFile(garbage, []byte("MemTotal: lots kB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		source string
+		strict bool
+		want   string
+		err    error
+	}{
+		{
+			name:   "relative path",
+			source: "testdata/meminfo.txt",
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           7864        3363         704        1579        3795        2709
+Swap:          8071           0        8071
+`,
+		},
+		{
+			name:   "missing",
+			source: filepath.Join(t.TempDir(), "meminfo"),
+			err:    os.ErrNotExist,
+		},
+		{
+			name:   "directory",
+			source: t.TempDir(),
+			err:    syscall.EISDIR,
+		},
+		{
+			// Without --strict, the line would only be warned about.
+			name:   "garbage",
+			source: garbage,
+			strict: true,
+			err:    strconv.ErrSyntax,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, options{mbytes: true, source: tt.source, strict: tt.strict})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.run()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.source) {
+				t.Errorf("run() = %v, want it to name %s", err, tt.source)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want uint64
+		err  error
+	}{
+		{s: "0", want: 0},
+		{s: "4096", want: 4096},
+		{s: "100B", want: 100},
+		{s: "512K", want: 512 << 10},
+		{s: "512M", want: 512 << 20},
+		{s: "512m", want: 512 << 20},
+		{s: "2G", want: 2 << 30},
+		{s: "1.5G", want: 3 << 29},
+		{s: "1T", want: 1 << 40},
+		{s: "", err: errMinAvail},
+		{s: "G", err: errMinAvail},
+		{s: "2X", err: errMinAvail},
+		{s: "-1M", err: errMinAvail},
+		{s: "NaN", err: errMinAvail},
+		{s: "99999999T", err: errMinAvail},
+	} {
+		got, err := parseSize(tt.s)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMinAvailable(t *testing.T) {
+	// 100 MiB available out of 1 GiB.
+	low := filepath.Join(t.TempDir(), "meminfo")
+	if err := os.WriteFile(low, []byte("MemTotal: 1048576 kB\nMemFree: 51200 kB\nMemAvailable: 102400 kB\nBuffers: 0 kB\nCached: 51200 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		err  error
+	}{
+		{name: "below", o: options{source: low, minAvailable: "512M"}, err: errLowMemory},
+		{name: "just below", o: options{source: low, minAvailable: "102401K"}, err: errLowMemory},
+		{name: "exactly", o: options{source: low, minAvailable: "100M"}},
+		{name: "above", o: options{source: low, minAvailable: "64M"}},
+		{name: "json", o: options{source: low, output: formatJSON, minAvailable: "1G"}, err: errLowMemory},
+		{name: "plenty", o: options{source: "testdata/meminfo.txt", minAvailable: "2G"}},
+		{name: "not enough", o: options{source: "testdata/meminfo.txt", minAvailable: "3G"}, err: errLowMemory},
+		{name: "off", o: options{source: low}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			// The output comes first either way.
+			if stdout.Len() == 0 {
+				t.Errorf("run() printed nothing")
+			}
+		})
+	}
+
+	if _, err := command(nil, options{minAvailable: "lots"}); !errors.Is(err, errMinAvail) {
+		t.Errorf("command with --min-available lots = %v, want %v", err, errMinAvail)
+	}
+	if _, err := command(nil, options{minAvailable: "1G", waitAvail: 50}); !errors.Is(err, errMinAvailWait) {
+		t.Errorf("command with --min-available and --wait-until-available = %v, want %v", err, errMinAvailWait)
+	}
+
+	// Polling stops at the first sample below the threshold.
+	c, err := command(io.Discard, options{source: low, count: 5, minAvailable: "1G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept int
+	c.sleep = func(time.Duration) { slept++ }
+	if err := c.run(); !errors.Is(err, errLowMemory) || slept != 0 {
+		t.Errorf("run() with -c 5 = %v after %d sleeps, want %v after none", err, slept, errLowMemory)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	golden, err := os.ReadFile("testdata/free.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "golden",
+			o:    options{output: formatYAML, source: "testdata/meminfo.txt", percent: true, total: true},
+			want: string(golden),
+		},
+		{
+			name: "cache",
+			o:    options{output: formatYAML, source: "testdata/meminfo.txt", cache: true},
+			want: "---\nbuffers: 250757120\ncached: 3545214976\nsreclaimable: 184168448\nreclaimable: 3980140544\n",
+		},
+		{
+			name: "show field",
+			o:    options{output: formatYAML, source: "testdata/meminfo.txt", showFields: []string{"SwapTotal", "MemFree"}},
+			want: "---\nMemFree: 739037184\nSwapTotal: 8464101376\n",
+		},
+		{
+			name: "samples are documents",
+			o:    options{output: formatYAML, source: "testdata/meminfo.txt", count: 2, showFields: []string{"MemFree"}},
+			want: "---\nMemFree: 739037184\n---\nMemFree: 739037184\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.sleep = func(time.Duration) {}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLOptionErrors(t *testing.T) {
+	if _, err := command(nil, options{output: formatYAML, wide: true}); !errors.Is(err, errConflict) {
+		t.Errorf("--yaml -w: got %v, want %v", err, errConflict)
 	}
 }
@@ -0,0 +1,91 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// historySample is a sample kept in the history, along with when it was
+// taken.
+type historySample struct {
+	Time time.Time `json:"time"`
+	MemInfo
+}
+
+// history is a ring buffer of the most recent samples. It is safe for
+// concurrent use, as it is dumped from a signal handler while free keeps
+// polling.
+type history struct {
+	mu      sync.Mutex
+	samples []historySample
+	// next is where the next sample goes. Once the ring is full, it is
+	// also where the oldest sample is.
+	next int
+	full bool
+}
+
+func newHistory(size int) *history {
+	return &history{samples: make([]historySample, size)}
+}
+
+// add records mi, replacing the oldest sample if the ring is full.
+func (h *history) add(t time.Time, mi *MemInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.next] = historySample{Time: t, MemInfo: *mi}
+	h.next++
+	if h.next == len(h.samples) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// all returns the recorded samples, oldest first.
+func (h *history) all() []historySample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]historySample{}, h.samples[:h.next]...)
+	}
+	return append(append([]historySample{}, h.samples[h.next:]...), h.samples[:h.next]...)
+}
+
+// dumpHistory writes the history to stderr as a JSON array.
+func (c *cmd) dumpHistory() error {
+	b, err := json.Marshal(c.history.all())
+	if err != nil {
+		return err
+	}
+	_, err = c.stderr.Write(append(b, '\n'))
+	return err
+}
+
+// dumpOnSignal dumps the history whenever free gets SIGUSR1, until the
+// returned function is called.
+func (c *cmd) dumpOnSignal() func() {
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				c.dumpHistory()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func totals(samples []historySample) []uint64 {
+	var t []uint64
+	for _, s := range samples {
+		t = append(t, s.Mem.Total)
+	}
+	return t
+}
+
+func TestHistory(t *testing.T) {
+	for _, tt := range []struct {
+		size, samples int
+		want          []uint64
+	}{
+		{size: 3, samples: 0, want: nil},
+		{size: 3, samples: 2, want: []uint64{1, 2}},
+		{size: 3, samples: 3, want: []uint64{1, 2, 3}},
+		{size: 3, samples: 7, want: []uint64{5, 6, 7}},
+		{size: 1, samples: 4, want: []uint64{4}},
+	} {
+		t.Run(fmt.Sprintf("%d of %d", tt.samples, tt.size), func(t *testing.T) {
+			h := newHistory(tt.size)
+			for i := 1; i <= tt.samples; i++ {
+				h.add(time.Unix(int64(i), 0), &MemInfo{Mem: meminfo.MainMemInfo{Total: uint64(i)}})
+			}
+			got := totals(h.all())
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("history = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// signalWriter passes each write on to a channel.
+type signalWriter chan []byte
+
+func (w signalWriter) Write(b []byte) (int, error) {
+	w <- append([]byte{}, b...)
+	return len(b), nil
+}
+
+func TestDumpHistory(t *testing.T) {
+	var n int
+	readMeminfo := func() (map[string]uint64, error) {
+		n++
+		return meminfo.Read(strings.NewReader(fmt.Sprintf("MemTotal: %d kB\nMemFree: 0 kB\nMemAvailable: 0 kB\nBuffers: 0 kB\nCached: 0 kB\nShmem: 0 kB\nSReclaimable: 0 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n", n)))
+	}
+
+	c, err := command(io.Discard, options{delay: 1, dumpHistory: true, history: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := make(signalWriter)
+	c.stderr = stderr
+	c.count = 4
+	c.meminfo = readMeminfo
+	var dumped []byte
+	c.sleep = func(time.Duration) {
+		// Dump once three samples have been taken.
+		if n != 3 {
+			return
+		}
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case dumped = <-stderr:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no history dumped on SIGUSR1")
+		}
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var samples []historySample
+	if err := json.Unmarshal(dumped, &samples); err != nil {
+		t.Fatalf("dumped history %q: %v", dumped, err)
+	}
+	if got, want := totals(samples), []uint64{2 << KB, 3 << KB}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("dumped history = %v, want %v", got, want)
+	}
+	if samples[1].Time.Before(samples[0].Time) {
+		t.Errorf("samples out of order: %v before %v", samples[1].Time, samples[0].Time)
+	}
+
+	// After polling, the ring holds the last two samples.
+	var b bytes.Buffer
+	c.stderr = &b
+	if err := c.dumpHistory(); err != nil {
+		t.Fatal(err)
+	}
+	samples = nil
+	if err := json.Unmarshal(b.Bytes(), &samples); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := totals(samples), []uint64{3 << KB, 4 << KB}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("history after polling = %v, want %v", got, want)
+	}
+}
+
+func TestHistoryOption(t *testing.T) {
+	if _, err := command(nil, options{dumpHistory: true}); err != errHistory {
+		t.Errorf("command(history: 0) = %v, want %v", err, errHistory)
+	}
+	if _, err := command(nil, options{history: 0}); err != nil {
+		t.Errorf("command without --dump-history = %v, want nil", err)
+	}
+}
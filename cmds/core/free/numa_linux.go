@@ -0,0 +1,130 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+const numaDir = "/sys/devices/system/node"
+
+// nodeInfo is the memory of one NUMA node.
+type nodeInfo struct {
+	Node int `json:"node"`
+	meminfo.MainMemInfo
+}
+
+// readNodes reads the meminfo of each NUMA node in the sysfs directory dir,
+// in node order. Without a dir, e.g. on kernels built without CONFIG_NUMA,
+// the whole system, whose memory is mem, is node 0.
+func readNodes(dir string, mem meminfo.MainMemInfo, classic bool) ([]nodeInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []nodeInfo{{MainMemInfo: mem}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nodes []nodeInfo
+	for _, e := range entries {
+		n, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "node"))
+		if err != nil || !strings.HasPrefix(e.Name(), "node") || !e.IsDir() {
+			continue
+		}
+		m, err := meminfo.ReadFile(filepath.Join(dir, e.Name(), "meminfo"))
+		if err != nil {
+			return nil, err
+		}
+		mmi, err := nodeMemInfo(n, m, classic)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		nodes = append(nodes, nodeInfo{Node: n, MainMemInfo: *mmi})
+	}
+	if len(nodes) == 0 {
+		return []nodeInfo{{MainMemInfo: mem}}, nil
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return nodes, nil
+}
+
+// nodeMemInfo returns the memory of node n from the fields of its meminfo.
+// Those are the /proc/meminfo ones prefixed with "Node n ", but the page
+// cache is FilePages and there are no Buffers or MemAvailable. Available is
+// estimated as free memory and the cache.
+func nodeMemInfo(n int, m map[string]uint64, classic bool) (*meminfo.MainMemInfo, error) {
+	prefix := fmt.Sprintf("Node %d ", n)
+	f := make(map[string]uint64, len(m))
+	for k, v := range m {
+		f[strings.TrimPrefix(k, prefix)] = v
+	}
+	for _, k := range []string{"MemTotal", "MemFree", "FilePages"} {
+		if _, ok := f[k]; !ok {
+			return nil, fmt.Errorf("%w: %s", meminfo.ErrMissingField, k)
+		}
+	}
+	total := f["MemTotal"] << 10
+	free := f["MemFree"] << 10
+	cached := (f["FilePages"] + f["SReclaimable"]) << 10
+	if classic {
+		cached = f["FilePages"] << 10
+	}
+	var used uint64
+	if total > free+cached {
+		used = total - free - cached
+	}
+	return &meminfo.MainMemInfo{
+		Total:        total,
+		Used:         used,
+		Free:         free,
+		Shared:       f["Shmem"] << 10,
+		Cached:       cached,
+		Available:    min(free+(f["FilePages"]+f["SReclaimable"])<<10, total),
+		Slab:         f["Slab"] << 10,
+		SReclaimable: f["SReclaimable"] << 10,
+		SUnreclaim:   f["SUnreclaim"] << 10,
+		Dirty:        f["Dirty"] << 10,
+		Writeback:    f["Writeback"] << 10,
+	}, nil
+}
+
+// numaWarning warns on stderr about each node whose free memory is less
+// than c.numaWarn of that of the node with the most, and then lists the
+// free memory of every node. Tasks are given memory from their own node
+// first, so theirs may run out while the others have plenty.
+func (c *cmd) numaWarning(nodes []nodeInfo) {
+	if c.numaWarn == 0 || len(nodes) < 2 {
+		return
+	}
+	most := nodes[0]
+	for _, n := range nodes[1:] {
+		if n.Free > most.Free {
+			most = n
+		}
+	}
+	var warned bool
+	for _, n := range nodes {
+		if float64(n.Free) >= c.numaWarn*float64(most.Free) {
+			continue
+		}
+		fmt.Fprintf(c.stderr, "WARNING: NUMA node %d has %s free, %.0f%% of the %s of node %d\n",
+			n.Node, c.humanReadable(n.Free), float64(n.Free)*100/float64(most.Free), c.humanReadable(most.Free), most.Node)
+		warned = true
+	}
+	if !warned {
+		return
+	}
+	for _, n := range nodes {
+		fmt.Fprintf(c.stderr, "  node %d: %s free\n", n.Node, c.humanReadable(n.Free))
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+// fakeNodes makes a sysfs node directory with the given node meminfo files,
+// and the other files sysfs has next to them.
+func fakeNodes(t *testing.T, nodes map[string]string) string {
+	t.Helper()
+	d := t.TempDir()
+	for _, f := range []string{"online", "possible", "has_cpu", "has_memory"} {
+		if err := os.WriteFile(filepath.Join(d, f), []byte("0-1\n"), 0o444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(d, "power"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, mi := range nodes {
+		if err := os.Mkdir(filepath.Join(d, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(d, name, "meminfo"), []byte(mi), 0o444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+const (
+	node0 = `Node 0 MemTotal:       16777216 kB
+Node 0 MemFree:         8388608 kB
+Node 0 MemUsed:         8388608 kB
+Node 0 Active:          2097152 kB
+Node 0 Inactive:        3145728 kB
+Node 0 Dirty:              1024 kB
+Node 0 Writeback:             0 kB
+Node 0 FilePages:       4194304 kB
+Node 0 Mapped:           524288 kB
+Node 0 AnonPages:       1048576 kB
+Node 0 Shmem:            131072 kB
+Node 0 KernelStack:       16384 kB
+Node 0 PageTables:        32768 kB
+Node 0 Slab:             524288 kB
+Node 0 SReclaimable:     262144 kB
+Node 0 SUnreclaim:       262144 kB
+Node 0 HugePages_Total:     0
+Node 0 HugePages_Free:      0
+`
+	node1 = `Node 1 MemTotal:       16777216 kB
+Node 1 MemFree:         1048576 kB
+Node 1 MemUsed:        15728640 kB
+Node 1 Dirty:                 0 kB
+Node 1 Writeback:             0 kB
+Node 1 FilePages:       1048576 kB
+Node 1 Shmem:                 0 kB
+Node 1 Slab:             131072 kB
+Node 1 SReclaimable:      65536 kB
+Node 1 SUnreclaim:        65536 kB
+Node 1 HugePages_Total:     0
+`
+)
+
+func TestReadNodes(t *testing.T) {
+	dir := fakeNodes(t, map[string]string{"node0": node0, "node1": node1})
+	want := []nodeInfo{
+		{Node: 0, MainMemInfo: meminfo.MainMemInfo{
+			Total:        16 << 30,
+			Used:         3840 << 20,
+			Free:         8 << 30,
+			Shared:       128 << 20,
+			Cached:       4352 << 20,
+			Available:    12544 << 20,
+			Slab:         512 << 20,
+			SReclaimable: 256 << 20,
+			SUnreclaim:   256 << 20,
+			Dirty:        1 << 20,
+		}},
+		{Node: 1, MainMemInfo: meminfo.MainMemInfo{
+			Total:        16 << 30,
+			Used:         14272 << 20,
+			Free:         1 << 30,
+			Cached:       1088 << 20,
+			Available:    2112 << 20,
+			Slab:         128 << 20,
+			SReclaimable: 64 << 20,
+			SUnreclaim:   64 << 20,
+		}},
+	}
+	got, err := readNodes(dir, meminfo.MainMemInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNodes() = %+v, want %+v", got, want)
+	}
+
+	// Classic leaves the reclaimable slab out of the cache.
+	got, err = readNodes(dir, meminfo.MainMemInfo{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Cached != 4<<30 || got[0].Used != 4<<30 {
+		t.Errorf("classic node 0: cached %d, used %d, want %d, %d", got[0].Cached, got[0].Used, 4<<30, 4<<30)
+	}
+}
+
+func TestReadNodesWithoutNUMA(t *testing.T) {
+	mem := meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 1 << 30, Available: 1 << 30}
+	for _, tt := range []struct {
+		name string
+		dir  string
+	}{
+		{name: "no sysfs directory", dir: filepath.Join(t.TempDir(), "node")},
+		{name: "no nodes", dir: fakeNodes(t, nil)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readNodes(tt.dir, mem, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []nodeInfo{{MainMemInfo: mem}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("readNodes() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadNodesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mi   string
+		err  error
+	}{
+		{name: "missing field", mi: "Node 0 MemTotal: 1024 kB\nNode 0 MemFree: 512 kB\n", err: meminfo.ErrMissingField},
+		{name: "fields of another node", mi: strings.ReplaceAll(node1, "Node 1", "Node 2"), err: meminfo.ErrMissingField},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeNodes(t, map[string]string{"node0": tt.mi})
+			if _, err := readNodes(dir, meminfo.MainMemInfo{}, false); !errors.Is(err, tt.err) {
+				t.Errorf("readNodes() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNuma(t *testing.T) {
+	dir := fakeNodes(t, map[string]string{"node0": node0, "node1": node1})
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 32 << 30, Used: 18112 << 20, Free: 9 << 30, Shared: 128 << 20, Cached: 5440 << 20, Available: 14 << 30},
+		Swap: meminfo.SwapInfo{},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "human",
+			o:    options{human: true, numa: true},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          32.0G       17.6G       9.00G        128M       5.31G       14.0G
+Swap:            0B          0B          0B
+Node 0:       16.0G       3.75G       8.00G        128M       4.25G       12.2G
+Node 1:       16.0G       13.9G       1.00G          0B       1.06G       2.06G
+`,
+		},
+		{
+			name: "percent",
+			o:    options{gbytes: true, numa: true, percent: true},
+			want: `
+              total        used        free      shared  buff/cache   available       %used
+Mem:             32          17           9           0           5          14          55
+Swap:             0           0           0                                               -
+Node 0:          16           3           8           0           4          12          23
+Node 1:          16          13           1           0           1           2          87
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, numa: true},
+			want: `
+{"mem":{"total":34359738368,"used":18991808512,"free":9663676416,"shared":134217728,"cached":5704253440,"buffers":0,"available":15032385536,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":0,"used":0,"free":0},"nodes":[{"node":0,"total":17179869184,"used":4026531840,"free":8589934592,"shared":134217728,"cached":4563402752,"buffers":0,"available":13153337344,"slab":536870912,"sreclaimable":268435456,"sunreclaim":268435456,"dirty":1048576,"writeback":0},{"node":1,"total":17179869184,"used":14965276672,"free":1073741824,"shared":0,"cached":1140850688,"buffers":0,"available":2214592512,"slab":134217728,"sreclaimable":67108864,"sunreclaim":67108864,"dirty":0,"writeback":0}]}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.numaDir = dir
+			m := *mi
+			if err := c.print(&m); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tt.want[1:] {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want[1:])
+			}
+		})
+	}
+}
+
+func TestNumaOptionErrors(t *testing.T) {
+	for _, o := range []options{
+		{numa: true, output: formatLine},
+		{numa: true, cache: true},
+	} {
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+	for _, o := range []options{
+		{numaWarn: 0.5},
+		{numa: true, numaWarn: -0.5},
+		{numa: true, numaWarn: 1.5},
+	} {
+		if _, err := command(nil, o); !errors.Is(err, errNumaWarn) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errNumaWarn)
+		}
+	}
+}
+
+func TestNumaWarning(t *testing.T) {
+	// Node 1 has 1G free, an eighth of the 8G of node 0.
+	imbalanced := fakeNodes(t, map[string]string{"node0": node0, "node1": node1})
+	for _, tt := range []struct {
+		name string
+		dir  string
+		warn float64
+		want string
+	}{
+		{
+			name: "imbalanced",
+			dir:  imbalanced,
+			warn: 0.25,
+			want: `
+WARNING: NUMA node 1 has 1.00G free, 12% of the 8.00G of node 0
+  node 0: 8.00G free
+  node 1: 1.00G free
+`,
+		},
+		{
+			name: "within the fraction",
+			dir:  imbalanced,
+			warn: 0.1,
+			want: "\n",
+		},
+		{
+			name: "no --numa-warn",
+			dir:  imbalanced,
+			want: "\n",
+		},
+		{
+			name: "balanced",
+			dir:  fakeNodes(t, map[string]string{"node0": node0, "node1": strings.ReplaceAll(node0, "Node 0", "Node 1")}),
+			warn: 1,
+			want: "\n",
+		},
+		{
+			name: "single node",
+			dir:  fakeNodes(t, map[string]string{"node1": node1}),
+			warn: 1,
+			want: "\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, options{human: true, numa: true, numaWarn: tt.warn, source: "testdata/meminfo.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.stderr = &stderr
+			c.numaDir = tt.dir
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := stderr.String(); got != tt.want[1:] {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want[1:])
+			}
+			// The table is printed all the same.
+			if !strings.Contains(stdout.String(), "Node 1:") {
+				t.Errorf("got\n%s\nwant a row for node 1", stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+)
+
+// openOutput opens the --output file at path, truncating it, or with
+// appendTo, --append, adding to what it holds, as a log of samples. Without
+// --output, free writes to os.Stdout.
+func openOutput(path string, appendTo bool) (*os.File, error) {
+	if path == "" {
+		if appendTo {
+			return nil, errAppend
+		}
+		return os.Stdout, nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(path, flags, 0o644)
+}
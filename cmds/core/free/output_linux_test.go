@@ -0,0 +1,111 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runTo runs free with o, writing to the --output file path.
+func runTo(t *testing.T, path string, appendTo bool, o options) {
+	t.Helper()
+	f, err := openOutput(path, appendTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	o.source = "testdata/meminfo.txt"
+	c, err := command(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "text",
+			o:    options{mbytes: true},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           7864        3363         704        1579        3795        2709
+Swap:          8071           0        8071
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON},
+			want: `{"mem":{"total":8246247424,"used":3527069696,"free":739037184,"shared":1656614912,"cached":3729383424,"buffers":250757120,"available":2840678400,"slab":308842496,"sreclaimable":184168448,"sunreclaim":124674048,"dirty":0,"writeback":0},"swap":{"total":8464101376,"used":786432,"free":8463314944}}
+`,
+		},
+		{
+			name: "csv",
+			o:    options{output: formatCSV},
+			want: `type,total,used,free,shared,buff_cache,available
+Mem,8052976,3444404,721716,1617788,3886856,2774100
+Swap,8265724,768,8264956,,,
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "free.log")
+			runTo(t, path, false, tt.o)
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "free.log")
+	o := options{output: formatLine}
+	line := "SwapUse         768 CachUse     3886856 MemUse     3444404 MemFree      721716\n"
+
+	runTo(t, path, false, o)
+	runTo(t, path, true, o)
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat(line, 2); string(got) != want {
+		t.Errorf("--append: got\n%s\nwant\n%s", got, want)
+	}
+
+	// Without --append, the file starts over.
+	runTo(t, path, false, o)
+	if got, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != line {
+		t.Errorf("got\n%s\nwant\n%s", got, line)
+	}
+}
+
+func TestOutputErrors(t *testing.T) {
+	if _, err := openOutput("", true); !errors.Is(err, errAppend) {
+		t.Errorf("openOutput(\"\", true) = %v, want %v", err, errAppend)
+	}
+	if f, err := openOutput("", false); err != nil || f != os.Stdout {
+		t.Errorf("openOutput(\"\", false) = %v, %v, want os.Stdout", f, err)
+	}
+	if _, err := openOutput(filepath.Join(t.TempDir(), "no", "such", "dir"), false); err == nil {
+		t.Errorf("openOutput in a missing directory: got nil, want an error")
+	}
+}
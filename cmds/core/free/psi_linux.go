@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const psiFile = "/proc/pressure/memory"
+
+var errPSIFormat = errors.New("malformed pressure stall information")
+
+// pressure holds the share of time, in percent, in which tasks were stalled
+// on memory over the last 10, 60 and 300 seconds, and the total stall time
+// in microseconds.
+type pressure struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// psiInfo is the memory pressure stall information. Some is the time in
+// which at least one task was stalled, Full the time in which all of them
+// were. Full is nil if the kernel does not report it.
+type psiInfo struct {
+	Some *pressure `json:"some,omitempty"`
+	Full *pressure `json:"full,omitempty"`
+}
+
+// readPSI reads the pressure stall information from file.
+func readPSI(file string) (*psiInfo, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return psiFromBytes(buf)
+}
+
+// psiFromBytes parses pressure stall information in the format of
+// /proc/pressure/memory, i.e. lines like
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// Lines of unknown kinds and unknown keys are ignored, so that additions
+// by newer kernels don't break parsing.
+func psiFromBytes(buf []byte) (*psiInfo, error) {
+	var pi psiInfo
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var dst **pressure
+		switch fields[0] {
+		case "some":
+			dst = &pi.Some
+		case "full":
+			dst = &pi.Full
+		default:
+			continue
+		}
+		p, err := parsePressure(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s.Text(), err)
+		}
+		*dst = p
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if pi.Some == nil {
+		return nil, fmt.Errorf("no \"some\" line: %w", errPSIFormat)
+	}
+	return &pi, nil
+}
+
+// parsePressure parses the key=value pairs of a single PSI line.
+func parsePressure(fields []string) (*pressure, error) {
+	var p pressure
+	var seen int
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, errPSIFormat
+		}
+		var avg *float64
+		switch k {
+		case "avg10":
+			avg = &p.Avg10
+		case "avg60":
+			avg = &p.Avg60
+		case "avg300":
+			avg = &p.Avg300
+		case "total":
+			t, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errPSIFormat, err)
+			}
+			p.Total = t
+			continue
+		default:
+			continue
+		}
+		a, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errPSIFormat, err)
+		}
+		*avg = a
+		seen++
+	}
+	if seen != 3 {
+		return nil, fmt.Errorf("missing averages: %w", errPSIFormat)
+	}
+	return &p, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestPSIFromBytes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		want *psiInfo
+		err  error
+	}{
+		{
+			name: "some and full",
+			in:   "some avg10=1.25 avg60=0.50 avg300=0.10 total=123456\nfull avg10=0.75 avg60=0.20 avg300=0.00 total=65432\n",
+			want: &psiInfo{
+				Some: &pressure{Avg10: 1.25, Avg60: 0.5, Avg300: 0.1, Total: 123456},
+				Full: &pressure{Avg10: 0.75, Avg60: 0.2, Total: 65432},
+			},
+		},
+		{
+			name: "some only",
+			in:   "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want: &psiInfo{Some: &pressure{}},
+		},
+		{
+			name: "unknown lines and keys",
+			in:   "some avg10=2.00 avg60=1.00 avg300=0.50 avg900=0.25 total=7\nnew avg10=9.99\n\n",
+			want: &psiInfo{Some: &pressure{Avg10: 2, Avg60: 1, Avg300: 0.5, Total: 7}},
+		},
+		{
+			name: "empty",
+			in:   "",
+			err:  errPSIFormat,
+		},
+		{
+			name: "missing average",
+			in:   "some avg10=0.00 avg60=0.00 total=0\n",
+			err:  errPSIFormat,
+		},
+		{
+			name: "bad average",
+			in:   "some avg10=abc avg60=0.00 avg300=0.00 total=0\n",
+			err:  errPSIFormat,
+		},
+		{
+			name: "no key value pair",
+			in:   "some avg10 avg60=0.00 avg300=0.00 total=0\n",
+			err:  errPSIFormat,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := psiFromBytes([]byte(tt.in))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("psiFromBytes() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("psiFromBytes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintPSI(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Shared: 0, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Used: 0, Free: 1 << 30},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		file string
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{mbytes: true, psi: true},
+			file: "testdata/pressure_memory.txt",
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          1024           0        1024
+Stall:  some avg10=1.25% avg60=0.50% avg300=0.10%
+        full avg10=0.75% avg60=0.20% avg300=0.00%
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, psi: true},
+			file: "testdata/pressure_memory.txt",
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":1073741824,"used":0,"free":1073741824},"psi":{"some":{"avg10":1.25,"avg60":0.5,"avg300":0.1,"total":123456},"full":{"avg10":0.75,"avg60":0.2,"avg300":0,"total":65432}}}
+`,
+		},
+		{
+			name: "no pressure file",
+			o:    options{mbytes: true, psi: true},
+			file: filepath.Join(t.TempDir(), "memory"),
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          1024           0        1024
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.psiFile = tt.file
+			mi := *mi
+			if err := c.print(&mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const swapsFile = "/proc/swaps"
+
+var errSwapsFormat = errors.New("malformed swaps line")
+
+// swapDevice is a swap partition or file from /proc/swaps. Size and Used
+// are in bytes.
+type swapDevice struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+	Size     uint64 `json:"size"`
+	Used     uint64 `json:"used"`
+	Priority int    `json:"priority"`
+}
+
+// readSwaps reads the swap devices from file.
+func readSwaps(file string) ([]swapDevice, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return swapsFromBytes(buf)
+}
+
+// swapsFromBytes parses swap devices in the format of /proc/swaps, i.e. a
+// header line followed by lines like
+//
+//	/dev/sda2                               partition	8265724		768		-2
+//
+// with the sizes in kibibytes. The devices are returned by priority, the
+// highest, which the kernel uses first, at the top.
+func swapsFromBytes(buf []byte) ([]swapDevice, error) {
+	var devs []swapDevice
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0] == "Filename" {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%q: %w", s.Text(), errSwapsFormat)
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w: %w", s.Text(), errSwapsFormat, err)
+		}
+		used, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w: %w", s.Text(), errSwapsFormat, err)
+		}
+		prio, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w: %w", s.Text(), errSwapsFormat, err)
+		}
+		devs = append(devs, swapDevice{
+			Filename: unescapeSwapName(fields[0]),
+			Type:     fields[1],
+			Size:     size << 10,
+			Used:     used << 10,
+			Priority: prio,
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(devs, func(i, j int) bool {
+		return devs[i].Priority > devs[j].Priority
+	})
+	return devs, nil
+}
+
+// unescapeSwapName undoes the octal escapes, e.g. \040 for a space, the
+// kernel uses for white space and backslashes in swap file names.
+func unescapeSwapName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+4 <= len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// printSwaps writes the swap devices below the table, or a note if there
+// are none.
+func (c *cmd) printSwaps(devs []swapDevice) {
+	if len(devs) == 0 {
+		fmt.Fprintln(c.stdout, "No swap devices.")
+		return
+	}
+	nw := len("Filename")
+	for _, d := range devs {
+		nw = max(nw, len(d.Filename))
+	}
+	w := c.width()
+	fmt.Fprintf(c.stdout, "%-*s %-9s %*s %*s %8s\n", nw, "Filename", "Type", w, "size", w, "used", "priority")
+	for _, d := range devs {
+		fmt.Fprintf(c.stdout, "%-*s %-9s %*s %*s %8d\n", nw, d.Filename, d.Type,
+			w, c.formatValueByConfig(d.Size),
+			w, c.formatValueByConfig(d.Used),
+			d.Priority)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestSwapsFromBytes(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/swaps.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		in   string
+		want []swapDevice
+		err  error
+	}{
+		{
+			name: "two devices by priority",
+			in:   string(fixture),
+			want: []swapDevice{
+				{Filename: "/dev/sda2", Type: "partition", Size: 2 << 30, Used: 512 << 20, Priority: 10},
+				{Filename: "/swapfile", Type: "file", Size: 1 << 30, Priority: -2},
+			},
+		},
+		{
+			name: "no swap",
+			in:   "Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n",
+		},
+		{
+			name: "escaped name",
+			in:   "/swap\\040file file 4 0 -1\n",
+			want: []swapDevice{{Filename: "/swap file", Type: "file", Size: 4 << 10, Priority: -1}},
+		},
+		{
+			name: "missing column",
+			in:   "/swapfile file 1048576 0\n",
+			err:  errSwapsFormat,
+		},
+		{
+			name: "bad size",
+			in:   "/swapfile file lots 0 -2\n",
+			err:  errSwapsFormat,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := swapsFromBytes([]byte(tt.in))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("swapsFromBytes() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("swapsFromBytes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintSwaps(t *testing.T) {
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Shared: 0, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 3 << 30, Used: 512 << 20, Free: 2560 << 20},
+	}
+	empty := filepath.Join(t.TempDir(), "swaps")
+	if err := os.WriteFile(empty, []byte("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		file string
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{mbytes: true, swaps: true},
+			file: "testdata/swaps.txt",
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          3072         512        2560
+Filename  Type             size        used priority
+/dev/sda2 partition        2048         512       10
+/swapfile file             1024           0       -2
+`,
+		},
+		{
+			name: "no swap",
+			o:    options{mbytes: true, swaps: true},
+			file: empty,
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          3072         512        2560
+No swap devices.
+`,
+		},
+		{
+			name: "no swaps file",
+			o:    options{mbytes: true, swaps: true},
+			file: filepath.Join(t.TempDir(), "swaps"),
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          3072         512        2560
+No swap devices.
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, swaps: true},
+			file: "testdata/swaps.txt",
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":3221225472,"used":536870912,"free":2684354560},"swaps":[{"filename":"/dev/sda2","type":"partition","size":2147483648,"used":536870912,"priority":10},{"filename":"/swapfile","type":"file","size":1073741824,"used":0,"priority":-2}]}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.swapsFile = tt.file
+			mi := *mi
+			if err := c.print(&mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSwapDetailsFlag(t *testing.T) {
+	f := flag.Lookup("swap-details")
+	if f == nil {
+		t.Fatal("no --swap-details flag")
+	}
+	defer func() { *swaps = false }()
+	if err := f.Value.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	if !*swaps {
+		t.Errorf("--swap-details did not set --swaps")
+	}
+}
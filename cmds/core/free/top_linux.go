@@ -0,0 +1,102 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const procDir = "/proc"
+
+var errStatmFormat = errors.New("malformed statm")
+
+// topProcess is one of the processes --top lists. RSS is in bytes.
+type topProcess struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+	RSS     uint64 `json:"rss"`
+}
+
+// readTop returns the n processes in the proc file system at dir with the
+// largest resident set, largest first. pageSize is the size of the pages
+// statm counts in. Processes that exit while they are read are left out, as
+// are those without any memory of their own, i.e. kernel threads.
+func readTop(dir string, n int, pageSize uint64) ([]topProcess, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var procs []topProcess
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := readProcess(filepath.Join(dir, e.Name()), pageSize)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ESRCH) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.RSS == 0 {
+			continue
+		}
+		p.PID = pid
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].RSS != procs[j].RSS {
+			return procs[i].RSS > procs[j].RSS
+		}
+		return procs[i].PID < procs[j].PID
+	})
+	if len(procs) > n {
+		procs = procs[:n]
+	}
+	return procs, nil
+}
+
+// readProcess reads the resident set size and the command name of the
+// process whose proc directory is dir.
+func readProcess(dir string, pageSize uint64) (topProcess, error) {
+	statm, err := os.ReadFile(filepath.Join(dir, "statm"))
+	if err != nil {
+		return topProcess{}, err
+	}
+	// size resident shared text lib data dt, all in pages.
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return topProcess{}, fmt.Errorf("%s: %w", dir, errStatmFormat)
+	}
+	rss, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return topProcess{}, fmt.Errorf("%s: %w: %w", dir, errStatmFormat, err)
+	}
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return topProcess{}, err
+	}
+	return topProcess{
+		Command: strings.TrimSuffix(string(comm), "\n"),
+		RSS:     rss * pageSize,
+	}, nil
+}
+
+// printTop writes the processes --top found below the table.
+func (c *cmd) printTop(procs []topProcess) {
+	w := c.width()
+	fmt.Fprintf(c.stdout, "%7s %*s %s\n", "PID", w, "RSS", "COMMAND")
+	for _, p := range procs {
+		fmt.Fprintf(c.stdout, "%7d %*s %s\n", p.PID, w, c.formatValueByConfig(p.RSS), p.Command)
+	}
+}
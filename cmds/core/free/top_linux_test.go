@@ -0,0 +1,168 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+// fakeProc makes a proc file system with a process for each pid in procs,
+// with the given statm and comm. An empty statm leaves the file out, as if
+// the process had just exited.
+func fakeProc(t *testing.T, procs map[string][2]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for pid, p := range procs {
+		d := filepath.Join(dir, pid)
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if p[0] != "" {
+			if err := os.WriteFile(filepath.Join(d, "statm"), []byte(p[0]), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(d, "comm"), []byte(p[1]+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadTop(t *testing.T) {
+	dir := fakeProc(t, map[string][2]string{
+		"1":    {"5000 300 200 10 0 400 0\n", "init"},
+		"2":    {"0 0 0 0 0 0 0\n", "kthreadd"},
+		"42":   {"90000 25600 1000 10 0 30000 0\n", "chrome"},
+		"77":   {"10000 1000 500 10 0 2000 0\n", "sh"},
+		"100":  {"50000 25600 800 10 0 20000 0\n", "firefox"},
+		"4242": {"", "exited"},
+		"self": {"1 1 1 1 0 1 0\n", "not a pid"},
+	})
+
+	for _, tt := range []struct {
+		name string
+		n    int
+		want []topProcess
+	}{
+		{
+			name: "top 2, ties by pid",
+			n:    2,
+			want: []topProcess{
+				{PID: 42, Command: "chrome", RSS: 100 << 20},
+				{PID: 100, Command: "firefox", RSS: 100 << 20},
+			},
+		},
+		{
+			name: "more than there are",
+			n:    10,
+			want: []topProcess{
+				{PID: 42, Command: "chrome", RSS: 100 << 20},
+				{PID: 100, Command: "firefox", RSS: 100 << 20},
+				{PID: 77, Command: "sh", RSS: 1000 << 12},
+				{PID: 1, Command: "init", RSS: 300 << 12},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readTop(dir, tt.n, 4096)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readTop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	bad := fakeProc(t, map[string][2]string{"1": {"lots\n", "init"}})
+	if _, err := readTop(bad, 1, 4096); !errors.Is(err, errStatmFormat) {
+		t.Errorf("readTop() with a bad statm = %v, want %v", err, errStatmFormat)
+	}
+	if _, err := readTop(filepath.Join(dir, "nope"), 1, 4096); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("readTop() without proc = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestTop(t *testing.T) {
+	pages := func(b uint64) string {
+		return "1 " + strconv.FormatUint(b/uint64(os.Getpagesize()), 10) + " 0 0 0 0 0\n"
+	}
+	dir := fakeProc(t, map[string][2]string{
+		"1":   {pages(4 << 20), "init"},
+		"42":  {pages(512 << 20), "chrome"},
+		"100": {pages(128 << 20), "firefox"},
+	})
+	mi := &MemInfo{
+		Mem:  meminfo.MainMemInfo{Total: 2 << 30, Used: 1 << 30, Free: 512 << 20, Cached: 256 << 20, Buffers: 256 << 20, Available: 1536 << 20},
+		Swap: meminfo.SwapInfo{Total: 1 << 30, Used: 256 << 20, Free: 768 << 20},
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{mbytes: true, top: 2},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:           2048        1024         512           0         512        1536
+Swap:          1024         256         768
+    PID         RSS COMMAND
+     42         512 chrome
+    100         128 firefox
+`,
+		},
+		{
+			name: "human",
+			o:    options{human: true, top: 1},
+			want: `              total        used        free      shared  buff/cache   available
+Mem:          2.00G       1.00G        512M          0B        512M       1.50G
+Swap:         1.00G        256M        768M
+    PID         RSS COMMAND
+     42        512M chrome
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, top: 3},
+			want: `{"mem":{"total":2147483648,"used":1073741824,"free":536870912,"shared":0,"cached":268435456,"buffers":268435456,"available":1610612736,"slab":0,"sreclaimable":0,"sunreclaim":0,"dirty":0,"writeback":0},"swap":{"total":1073741824,"used":268435456,"free":805306368},"top":[{"pid":42,"command":"chrome","rss":536870912},{"pid":100,"command":"firefox","rss":134217728},{"pid":1,"command":"init","rss":4194304}]}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.procDir = dir
+			mi := *mi
+			if err := c.print(&mi); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := command(nil, options{top: -1}); !errors.Is(err, errTop) {
+		t.Errorf("--top -1: got %v, want %v", err, errTop)
+	}
+	for _, o := range []options{{top: 1, output: formatLine}, {top: 1, cache: true}, {top: 1, output: formatTemplate, template: "{{.Mem.Total}}"}} {
+		if _, err := command(nil, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+}
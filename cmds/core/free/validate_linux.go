@@ -0,0 +1,66 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// invariants are relations between /proc/meminfo fields that hold on any
+// sane kernel: the sum of the fields is at most max. Slab is in fact the
+// sum of SReclaimable and SUnreclaim.
+var invariants = []struct {
+	fields []string
+	max    string
+}{
+	{fields: []string{"MemFree"}, max: "MemTotal"},
+	{fields: []string{"MemAvailable"}, max: "MemTotal"},
+	{fields: []string{"MemFree", "Buffers", "Cached", "SReclaimable"}, max: "MemTotal"},
+	{fields: []string{"SReclaimable", "SUnreclaim"}, max: "Slab"},
+	{fields: []string{"SwapFree"}, max: "SwapTotal"},
+}
+
+// checkInvariants returns a description of each of the invariants m breaks.
+// Invariants with fields which are not in m, as on older kernels, are not
+// checked.
+func checkInvariants(m map[string]uint64) []string {
+	var broken []string
+	for _, inv := range invariants {
+		limit, ok := m[inv.max]
+		var sum uint64
+		for _, f := range inv.fields {
+			v, found := m[f]
+			ok = ok && found
+			sum += v
+		}
+		if ok && sum > limit {
+			broken = append(broken, fmt.Sprintf("%s = %d kB is more than %s = %d kB",
+				strings.Join(inv.fields, " + "), sum, inv.max, limit))
+		}
+	}
+	return broken
+}
+
+// validateMemInfo reads the memory information and checks that it parses and is
+// consistent, for --validate. It prints each inconsistency and returns
+// errInvalid if there are any.
+func (c *cmd) validateMemInfo() error {
+	m, err := c.meminfo()
+	if err != nil {
+		return err
+	}
+	if _, err := getMemInfo(m, c.usedClassic); err != nil {
+		return err
+	}
+	broken := checkInvariants(m)
+	for _, b := range broken {
+		fmt.Fprintln(c.stdout, b)
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errInvalid, len(broken), len(invariants))
+	}
+	return nil
+}
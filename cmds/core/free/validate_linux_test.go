@@ -0,0 +1,90 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		source string
+		want   string
+		err    error
+	}{
+		{
+			name:   "consistent",
+			source: "testdata/meminfo.txt",
+		},
+		{
+			name:   "inconsistent",
+			source: "testdata/meminfo_inconsistent.txt",
+			want: `MemFree = 9000000 kB is more than MemTotal = 8052976 kB
+MemFree + Buffers + Cached + SReclaimable = 12886856 kB is more than MemTotal = 8052976 kB
+SwapFree = 8300000 kB is more than SwapTotal = 8265724 kB
+`,
+			err: errInvalid,
+		},
+		{
+			name:   "unparsable",
+			source: "testdata/memory.stat",
+			err:    meminfo.ErrMissingField,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, options{source: tt.source, validate: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		m    map[string]uint64
+		want []string
+	}{
+		{
+			name: "available above total",
+			m:    map[string]uint64{"MemTotal": 100, "MemAvailable": 101},
+			want: []string{"MemAvailable = 101 kB is more than MemTotal = 100 kB"},
+		},
+		{
+			name: "slab parts above slab",
+			m:    map[string]uint64{"Slab": 10, "SReclaimable": 6, "SUnreclaim": 5},
+			want: []string{"SReclaimable + SUnreclaim = 11 kB is more than Slab = 10 kB"},
+		},
+		{
+			// Without MemTotal, nothing can be checked against it.
+			name: "missing fields",
+			m:    map[string]uint64{"MemFree": 100, "MemAvailable": 101},
+		},
+		{
+			name: "equal is fine",
+			m:    map[string]uint64{"MemTotal": 100, "MemFree": 100, "SwapTotal": 0, "SwapFree": 0},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkInvariants(tt.m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkInvariants() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+// noZswap is what --zswap reports on a kernel without zswap, or built
+// without it, rather than zeros that look like an empty zswap.
+const noZswap = "zswap not available"
+
+// printZswap writes the Zswap: row of the table: the memory the compressed
+// pages take up, the memory they took up before, and the ratio of the two,
+// which is "-" while zswap holds no pages. Without zswap, zi is nil and the
+// row says so.
+func (c *cmd) printZswap(zi *meminfo.ZswapInfo) {
+	if zi == nil {
+		fmt.Fprintf(c.stdout, "%-7s %s\n", "Zswap:", noZswap)
+		return
+	}
+	ratio := "-"
+	if zi.Ratio > 0 {
+		ratio = fmt.Sprintf("%.2f", zi.Ratio)
+	}
+	fmt.Fprintf(c.stdout, "%-7s compressed=%s original=%s ratio=%s\n",
+		"Zswap:", c.formatValueByConfig(zi.Compressed), c.formatValueByConfig(zi.Original), ratio)
+}
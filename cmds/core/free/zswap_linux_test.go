@@ -0,0 +1,131 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/meminfo"
+)
+
+func TestZswap(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		o      options
+		want   string
+		stderr string
+	}{
+		{
+			name: "human",
+			o:    options{human: true, zswap: true, source: "testdata/meminfo_zswap.txt"},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          7.67G       3.28G        704M       1.54G       3.70G       2.64G
+Swap:         7.88G        768K       7.88G
+Zswap:  compressed=256M original=768M ratio=3.00
+`,
+		},
+		{
+			name: "mebibytes",
+			o:    options{mbytes: true, zswap: true, source: "testdata/meminfo_zswap.txt"},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:           7864        3363         704        1579        3795        2709
+Swap:          8071           0        8071
+Zswap:  compressed=256 original=768 ratio=3.00
+`,
+		},
+		{
+			name: "not available",
+			o:    options{human: true, zswap: true, source: "testdata/meminfo.txt"},
+			want: `
+              total        used        free      shared  buff/cache   available
+Mem:          7.67G       3.28G        704M       1.54G       3.70G       2.64G
+Swap:         7.88G        768K       7.88G
+Zswap:  zswap not available
+`,
+		},
+		{
+			name: "json",
+			o:    options{output: formatJSON, zswap: true, source: "testdata/meminfo_zswap.txt"},
+			want: `
+{"mem":{"total":8246247424,"used":3527069696,"free":739037184,"shared":1656614912,"cached":3729383424,"buffers":250757120,"available":2840678400,"slab":308842496,"sreclaimable":184168448,"sunreclaim":124674048,"dirty":0,"writeback":0},"swap":{"total":8464101376,"used":786432,"free":8463314944},"zswap":{"compressed":268435456,"original":805306368,"ratio":3}}
+`,
+		},
+		{
+			name: "json not available",
+			o:    options{output: formatJSON, zswap: true, source: "testdata/meminfo.txt"},
+			want: `
+{"mem":{"total":8246247424,"used":3527069696,"free":739037184,"shared":1656614912,"cached":3729383424,"buffers":250757120,"available":2840678400,"slab":308842496,"sreclaimable":184168448,"sunreclaim":124674048,"dirty":0,"writeback":0},"swap":{"total":8464101376,"used":786432,"free":8463314944}}
+`,
+			stderr: "zswap not available\n",
+		},
+		{
+			name: "without --zswap",
+			o:    options{output: formatJSON, source: "testdata/meminfo_zswap.txt"},
+			want: `
+{"mem":{"total":8246247424,"used":3527069696,"free":739037184,"shared":1656614912,"cached":3729383424,"buffers":250757120,"available":2840678400,"slab":308842496,"sreclaimable":184168448,"sunreclaim":124674048,"dirty":0,"writeback":0},"swap":{"total":8464101376,"used":786432,"free":8463314944}}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.stderr = &stderr
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := "\n" + stdout.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if stderr.String() != tt.stderr {
+				t.Errorf("stderr: got %q, want %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+func TestZswapEmpty(t *testing.T) {
+	var stdout bytes.Buffer
+	c, err := command(&stdout, options{zswap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.printZswap(&meminfo.ZswapInfo{})
+	if want := "Zswap:  compressed=0 original=0 ratio=-\n"; stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestAverageZswap(t *testing.T) {
+	got := averageMemInfo([]*MemInfo{
+		{Zswap: &meminfo.ZswapInfo{Compressed: 100, Original: 400, Ratio: 4}},
+		{Zswap: &meminfo.ZswapInfo{Compressed: 300, Original: 600, Ratio: 2}},
+	})
+	if want := (meminfo.ZswapInfo{Compressed: 200, Original: 500, Ratio: 3}); got.Zswap == nil || *got.Zswap != want {
+		t.Errorf("averageMemInfo() Zswap = %+v, want %+v", got.Zswap, want)
+	}
+	if got := averageMemInfo([]*MemInfo{{}, {}}); got.Zswap != nil {
+		t.Errorf("without zswap: averageMemInfo() Zswap = %+v, want nil", got.Zswap)
+	}
+}
+
+func TestZswapOptionErrors(t *testing.T) {
+	for _, o := range []options{
+		{zswap: true, output: formatCSV},
+		{zswap: true, output: formatLine},
+		{zswap: true, cache: true},
+		{zswap: true, showFields: []string{"Zswap"}},
+	} {
+		if _, err := command(&bytes.Buffer{}, o); !errors.Is(err, errConflict) {
+			t.Errorf("command(%+v) = %v, want %v", o, err, errConflict)
+		}
+	}
+}
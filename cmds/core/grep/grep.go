@@ -6,7 +6,14 @@
 //
 // Synopsis:
 //
-//	grep [-clFivnhqre] [FILE]...
+//	grep [-clFivnhqr] [--color[=WHEN]] [-e PATTERN]... [-f FILE]... [PATTERN] [FILE]...
+//
+// Description:
+//
+//	grep prints the lines that match any of its patterns. Without -e or
+//	-f, the first argument is the pattern. -e and -f may be repeated, and
+//	their patterns are combined into one alternation. A pattern file has
+//	one pattern per line; an empty one matches nothing.
 //
 // Options:
 //
@@ -19,13 +26,16 @@
 //  -h, --no-filename          Suppress file name prefixes on output
 //  -q, --quiet                Don't print matches; exit on first match
 //  -r, --recursive            recursive
-//  -e, --regexp string        Pattern to match
+//  -e, --regexp string        Pattern to match; may be repeated
+//  -f, --file string          Read patterns from this file; may be repeated
+//      --color[=WHEN]         Highlight matches; WHEN is never (the default),
+//                             always, or auto, which is what --color alone
+//                             means and highlights only on a terminal
 
 package main
 
 import (
 	"bufio"
-	"bytes"
 	"flag"
 	"fmt"
 	"io"
@@ -37,14 +47,53 @@ import (
 	"strings"
 
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
+	"golang.org/x/term"
 )
 
 var errQuiet = fmt.Errorf("not found")
 
+// The escape sequences GNU grep highlights matches with.
+const (
+	colorMatch = "\x1b[01;31m\x1b[K"
+	colorReset = "\x1b[m\x1b[K"
+)
+
 type params struct {
-	expr string
+	exprs, patternFiles unixflag.StringArray
 	headers, invert, recursive, caseInsensitive, fixed,
-	noShowMatch, quiet, count, number bool
+	noShowMatch, quiet, count, number, color bool
+}
+
+// colorFlag is the value of --color. Given without a value, it means auto.
+type colorFlag string
+
+func (c *colorFlag) String() string { return string(*c) }
+
+func (c *colorFlag) Set(s string) error {
+	switch s {
+	case "true":
+		s = "auto"
+	case "always", "auto", "never":
+	default:
+		return fmt.Errorf("invalid color mode %q, must be always, auto or never", s)
+	}
+	*c = colorFlag(s)
+	return nil
+}
+
+// IsBoolFlag lets --color go without a value.
+func (c *colorFlag) IsBoolFlag() bool { return true }
+
+// useColor reports whether matches written to w are highlighted.
+func useColor(mode colorFlag, w io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "auto":
+		f, ok := w.(*os.File)
+		return ok && term.IsTerminal(int(f.Fd()))
+	}
+	return false
 }
 
 type grepCommand struct {
@@ -57,8 +106,11 @@ func run(stdin io.ReadCloser, stdout io.Writer, stderr io.Writer, args []string)
 	var c cmd
 
 	f := flag.NewFlagSet(args[0], flag.ExitOnError)
-	f.StringVar(&c.params.expr, "regexp", "", "Pattern to match")
-	f.StringVar(&c.expr, "e", "", "Pattern to match (shorthand)")
+	f.Var(&c.params.exprs, "regexp", "Pattern to match; may be repeated")
+	f.Var(&c.params.exprs, "e", "Pattern to match; may be repeated (shorthand)")
+
+	f.Var(&c.params.patternFiles, "file", "Read patterns from this file, one per line; may be repeated")
+	f.Var(&c.params.patternFiles, "f", "Read patterns from this file, one per line; may be repeated (shorthand)")
 
 	f.BoolVar(&c.params.headers, "no-filename", false, "Suppress file name prefixes on output")
 	f.BoolVar(&c.params.headers, "h", false, "Suppress file name prefixes on output (shorthand)")
@@ -90,12 +142,17 @@ func run(stdin io.ReadCloser, stdout io.Writer, stderr io.Writer, args []string)
 	f.BoolVar(&c.params.quiet, "silent", false, "Don't print matches; exit on first match")
 	f.BoolVar(&c.params.quiet, "s", false, "Don't print matches; exit on first match (shorthand)")
 
+	color := colorFlag("never")
+	f.Var(&color, "color", "Highlight matches: always, auto or never")
+	f.Var(&color, "colour", "Highlight matches: always, auto or never")
+
 	f.Usage = func() {
-		fmt.Fprint(f.Output(), "Usage: grep [-clFivnhqre] [FILE]...\n\n")
+		fmt.Fprint(f.Output(), "Usage: grep [-clFivnhqr] [--color[=WHEN]] [-e PATTERN]... [-f FILE]... [PATTERN] [FILE]...\n\n")
 		f.PrintDefaults()
 	}
 
 	f.Parse(unixflag.ArgsToGoArgs(args[1:]))
+	c.params.color = useColor(color, stdout)
 
 	c.args = f.Args()
 	c.stdin = stdin
@@ -123,6 +180,8 @@ type cmd struct {
 	params
 	matchCount int
 	showName   bool
+	// highlight finds the parts of a matching line to color.
+	highlight *regexp.Regexp
 }
 
 // grep reads data from the os.File embedded in grepCommand.
@@ -135,15 +194,7 @@ func (c *cmd) grep(f *grepCommand, re *regexp.Regexp) (ok bool) {
 	var lineNum int
 	for r.Scan() {
 		line := r.Text()
-		var m bool
-		switch {
-		case c.fixed && c.caseInsensitive:
-			m = strings.Contains(strings.ToLower(line), strings.ToLower(c.expr))
-		case c.fixed && !c.caseInsensitive:
-			m = strings.Contains(line, c.expr)
-		default:
-			m = re.MatchString(line)
-		}
+		m := re.MatchString(line)
 		if m != c.invert {
 			// in quiet mode, exit before the first match
 			if c.quiet {
@@ -190,39 +241,107 @@ func (c *cmd) printMatch(cmd *grepCommand, line string, lineNum int, match bool)
 			c.stdout.WriteByte(':')
 		}
 		// now write the line to stdout
-		c.stdout.WriteString(line)
+		if c.highlight != nil {
+			c.writeHighlighted(line)
+		} else {
+			c.stdout.WriteString(line)
+		}
 	}
 }
 
-func (c *cmd) run() error {
-	defer c.stdout.Flush()
-	// parse the expression into valid regex
-	if c.expr != "" {
-		c.args = append([]string{c.expr}, c.args...)
+// writeHighlighted writes line with all its matches colored.
+func (c *cmd) writeHighlighted(line string) {
+	var last int
+	for _, m := range c.highlight.FindAllStringIndex(line, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		c.stdout.WriteString(line[last:m[0]])
+		c.stdout.WriteString(colorMatch)
+		c.stdout.WriteString(line[m[0]:m[1]])
+		c.stdout.WriteString(colorReset)
+		last = m[1]
+	}
+	c.stdout.WriteString(line[last:])
+}
+
+// patterns returns the patterns of -e and of the -f files, in order and
+// without duplicates. Without either, the first argument is the pattern, and
+// it is taken off c.args. With no arguments either, everything matches.
+func (c *cmd) patterns() ([]string, error) {
+	if len(c.exprs) == 0 && len(c.patternFiles) == 0 {
+		if len(c.args) == 0 {
+			return []string{".*"}, nil
+		}
+		p := c.args[0]
+		c.args = c.args[1:]
+		return []string{p}, nil
+	}
+	patterns := append([]string{}, c.exprs...)
+	for _, name := range c.patternFiles {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			patterns = append(patterns, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")...)
+		}
+	}
+	seen := make(map[string]bool, len(patterns))
+	var unique []string
+	for _, p := range patterns {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
 	}
-	r := ".*"
-	if len(c.args) > 0 {
-		r = c.args[0]
+	return unique, nil
+}
+
+// compile combines the patterns into a single regular expression, which
+// matches where any of them does. No patterns, as from an empty -f file,
+// match nothing.
+func (c *cmd) compile(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return regexp.MustCompile(`[^\x00-\x{10FFFF}]`), nil
 	}
-	if c.caseInsensitive && !bytes.HasPrefix([]byte(r), []byte("(?i)")) && !c.fixed {
+	alts := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if c.fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		alts = append(alts, "(?:"+p+")")
+	}
+	r := strings.Join(alts, "|")
+	if c.caseInsensitive {
 		r = "(?i)" + r
 	}
-	var re *regexp.Regexp
-	if !c.fixed {
-		re = regexp.MustCompile(r)
-	} else if c.expr == "" {
-		c.expr = c.args[0]
+	return regexp.Compile(r)
+}
+
+func (c *cmd) run() error {
+	defer c.stdout.Flush()
+	patterns, err := c.patterns()
+	if err != nil {
+		return err
+	}
+	re, err := c.compile(patterns)
+	if err != nil {
+		return err
+	}
+	if c.color && !c.invert {
+		c.highlight = re
 	}
 
-	// if len(c.args) < 2, then we read from stdin
-	if len(c.args) < 2 {
+	// without files, we read from stdin
+	if len(c.args) == 0 {
 		if !c.grep(&grepCommand{c.stdin, "<stdin>"}, re) {
 			return nil
 		}
 	} else {
-		c.showName = (len(c.args[1:]) > 1 || c.recursive || c.noShowMatch) && !c.headers
+		c.showName = (len(c.args) > 1 || c.recursive || c.noShowMatch) && !c.headers
 		var ok bool
-		for _, v := range c.args[1:] {
+		for _, v := range c.args {
 			err := filepath.Walk(v, func(name string, fi os.FileInfo, err error) error {
 				if err != nil {
 					fmt.Fprintf(c.stderr, "grep: %v: %v\n", name, err)
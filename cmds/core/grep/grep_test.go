@@ -7,6 +7,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -72,7 +73,7 @@ func TestStdinGrep(t *testing.T) {
 			input:  "hix\n",
 			output: "hix\n",
 			err:    nil,
-			p:      params{expr: "hix"},
+			p:      params{exprs: []string{"hix"}},
 		},
 		{
 			input:  "hix\n",
@@ -143,7 +144,7 @@ func TestStdinGrep(t *testing.T) {
 			input:  "a\nb\nc\n",
 			output: "b\n",
 			err:    nil,
-			p:      params{fixed: true, expr: "b"},
+			p:      params{fixed: true, exprs: []string{"b"}},
 		},
 	}
 
@@ -307,3 +308,183 @@ func TestDefaultParams(t *testing.T) {
 		t.Errorf("got out %q, want %q", res, "hix\n")
 	}
 }
+
+func TestColor(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		in   string
+		want string
+	}{
+		{
+			name: "never",
+			args: []string{"--color=never", "b+"},
+			in:   "abba\n",
+			want: "abba\n",
+		},
+		{
+			name: "auto on a buffer",
+			args: []string{"--color", "b+"},
+			in:   "abba\n",
+			want: "abba\n",
+		},
+		{
+			name: "always",
+			args: []string{"--color=always", "b"},
+			in:   "abcb\nxyz\n",
+			want: "a" + colorMatch + "b" + colorReset + "c" + colorMatch + "b" + colorReset + "\n",
+		},
+		{
+			name: "case insensitive fixed strings",
+			args: []string{"--color=always", "-i", "-F", "a.B"},
+			in:   "xA.bx a.b\n",
+			want: "x" + colorMatch + "A.b" + colorReset + "x " + colorMatch + "a.b" + colorReset + "\n",
+		},
+		{
+			name: "line numbers",
+			args: []string{"--color=always", "-n", "^h"},
+			in:   "hi\n",
+			want: "1:" + colorMatch + "h" + colorReset + "i\n",
+		},
+		{
+			name: "empty matches",
+			args: []string{"--color=always", "x*"},
+			in:   "axb\n",
+			want: "a" + colorMatch + "x" + colorReset + "b\n",
+		},
+		{
+			name: "inverted",
+			args: []string{"--color=always", "-v", "b"},
+			in:   "abc\nxyz\n",
+			want: "xyz\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			rc := io.NopCloser(strings.NewReader(tt.in))
+			if err := run(rc, &stdout, &stdout, append([]string{"grep"}, tt.args...)); err != nil {
+				t.Fatalf("run(%q) = %v, want nil", tt.args, err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("run(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	var c colorFlag
+	if err := c.Set("sometimes"); err == nil {
+		t.Errorf("Set(%q) = nil, want error", "sometimes")
+	}
+	if err := c.Set("true"); err != nil || c != "auto" {
+		t.Errorf("Set(%q) = %v, %q, want nil, %q", "true", err, c, "auto")
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns")
+	if err := os.WriteFile(patterns, []byte("apple\nb.n\napple\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	more := filepath.Join(dir, "more")
+	if err := os.WriteFile(more, []byte("cherry\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input := "apple\nbanana\ncherry\ndate\nB.N\n"
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "multiple -e",
+			args: []string{"-e", "apple", "-e", "date"},
+			want: "apple\ndate\n",
+		},
+		{
+			name: "-e and --regexp",
+			args: []string{"-e", "cherry", "--regexp", "^d"},
+			want: "cherry\ndate\n",
+		},
+		{
+			name: "-f",
+			args: []string{"-f", patterns},
+			want: "apple\nbanana\n",
+		},
+		{
+			name: "-f and -e",
+			args: []string{"-f", patterns, "-e", "date"},
+			want: "apple\nbanana\ndate\n",
+		},
+		{
+			name: "multiple -f",
+			args: []string{"-f", patterns, "--file", more},
+			want: "apple\nbanana\ncherry\n",
+		},
+		{
+			name: "inverted",
+			args: []string{"-v", "-f", patterns, "-e", "date"},
+			want: "cherry\nB.N\n",
+		},
+		{
+			name: "fixed strings",
+			args: []string{"-F", "-f", patterns},
+			want: "apple\n",
+		},
+		{
+			name: "fixed strings ignoring case",
+			args: []string{"-F", "-i", "-e", "b.n", "-e", "APPLE"},
+			want: "apple\nB.N\n",
+		},
+		{
+			name: "empty file matches nothing",
+			args: []string{"-f", empty},
+			want: "",
+		},
+		{
+			name: "count",
+			args: []string{"-c", "-e", "a", "-e", "e"},
+			want: "4\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			rc := io.NopCloser(strings.NewReader(input))
+			if err := run(rc, &stdout, &stdout, append([]string{"grep"}, tt.args...)); err != nil {
+				t.Fatalf("run(%q) = %v, want nil", tt.args, err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("run(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternsDeduplicated(t *testing.T) {
+	c := cmd{params: params{exprs: []string{"a", "b", "a"}}}
+	got, err := c.patterns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("patterns() = %q, want %q", got, want)
+	}
+}
+
+func TestPatternErrors(t *testing.T) {
+	var stdout bytes.Buffer
+	rc := io.NopCloser(strings.NewReader("x\n"))
+	if err := run(rc, &stdout, &stdout, []string{"grep", "-f", filepath.Join(t.TempDir(), "missing")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing -f file: got %v, want %v", err, os.ErrNotExist)
+	}
+	if err := run(rc, &stdout, &stdout, []string{"grep", "-e", "a", "-e", "("}); err == nil {
+		t.Errorf("invalid pattern: got nil, want an error")
+	}
+}
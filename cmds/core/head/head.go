@@ -2,6 +2,23 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+// head prints the first part of files.
+//
+// Synopsis:
+//
+//	head [-c bytes | -n lines] [-q|-v] [FILE]...
+//
+// Description:
+//
+//	If no files are specified, read from stdin. If more than one file is
+//	specified, each is preceded by a header of the form "==> FILE <==".
+//
+// Options:
+//
+//	-c: print the first bytes of each file
+//	-n: print the first lines of each file (default: 10)
+//	-q: never print headers
+//	-v: always print headers
 package main
 
 import (
@@ -16,7 +33,7 @@ import (
 
 var errCombine = fmt.Errorf("can't combine line and byte counts")
 
-func run(stdin io.Reader, stdout, stderr io.Writer, bytes, count int, files ...string) error {
+func run(stdin io.Reader, stdout, stderr io.Writer, bytes, count int, quiet, verbose bool, files ...string) error {
 	if bytes > 0 && count > 0 {
 		return errCombine
 	}
@@ -35,8 +52,9 @@ func run(stdin io.Reader, stdout, stderr io.Writer, bytes, count int, files ...s
 	var newLineHeader bool
 	var errs error
 
+	headers := !quiet && (verbose || len(files) > 1)
 	var handle = func(r io.Reader, name string) error {
-		if len(files) > 1 {
+		if headers {
 			if newLineHeader {
 				fmt.Fprintf(stdout, "\n==> %s <==\n", name)
 			} else {
@@ -83,7 +101,7 @@ func run(stdin io.Reader, stdout, stderr io.Writer, bytes, count int, files ...s
 
 	// handle stdin
 	if len(files) == 0 {
-		return handle(stdin, "")
+		return handle(stdin, "standard input")
 	}
 
 	for _, file := range files {
@@ -107,9 +125,15 @@ func run(stdin io.Reader, stdout, stderr io.Writer, bytes, count int, files ...s
 func main() {
 	var c = flag.Int("c", 0, "Print bytes of each of the specified files")
 	var n = flag.Int("n", 0, "Print count lines of each of the specified files")
+	var quiet, verbose bool
+	flag.BoolVar(&quiet, "q", false, "Never print headers giving file names")
+	flag.BoolVar(&quiet, "quiet", false, "Never print headers giving file names")
+	flag.BoolVar(&quiet, "silent", false, "Never print headers giving file names")
+	flag.BoolVar(&verbose, "v", false, "Always print headers giving file names")
+	flag.BoolVar(&verbose, "verbose", false, "Always print headers giving file names")
 
 	flag.Parse()
-	if err := run(os.Stdin, os.Stdout, os.Stderr, *c, *n, flag.Args()...); err != nil {
+	if err := run(os.Stdin, os.Stdout, os.Stderr, *c, *n, quiet, verbose, flag.Args()...); err != nil {
 		log.Fatalf("head: %v", err)
 	}
 }
@@ -38,7 +38,7 @@ func TestHead(t *testing.T) {
 	}
 
 	t.Run("combine error", func(t *testing.T) {
-		err := run(nil, nil, nil, 1, 1, f3.Name())
+		err := run(nil, nil, nil, 1, 1, false, false, f3.Name())
 		if !errors.Is(err, errCombine) {
 			t.Errorf("expected %v, got %v", errCombine, err)
 		}
@@ -46,7 +46,7 @@ func TestHead(t *testing.T) {
 
 	t.Run("one file print lines", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		err := run(nil, stdout, nil, 0, 2, f1.Name())
+		err := run(nil, stdout, nil, 0, 2, false, false, f1.Name())
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -59,7 +59,7 @@ func TestHead(t *testing.T) {
 
 	t.Run("one file default params", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		err := run(nil, stdout, nil, 0, 0, f2.Name())
+		err := run(nil, stdout, nil, 0, 0, false, false, f2.Name())
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -72,7 +72,7 @@ func TestHead(t *testing.T) {
 
 	t.Run("two files print bytes", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		err := run(nil, stdout, nil, 3, 0, f1.Name(), f2.Name())
+		err := run(nil, stdout, nil, 3, 0, false, false, f1.Name(), f2.Name())
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -87,7 +87,7 @@ func TestHead(t *testing.T) {
 
 	t.Run("request more bytes", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		err := run(nil, stdout, nil, 10000, 0, f1.Name())
+		err := run(nil, stdout, nil, 10000, 0, false, false, f1.Name())
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -101,7 +101,7 @@ func TestHead(t *testing.T) {
 
 	t.Run("file not exists", func(t *testing.T) {
 		stderr := &bytes.Buffer{}
-		err := run(nil, nil, stderr, 0, 0, filepath.Join(dir, "filenotexists"))
+		err := run(nil, nil, stderr, 0, 0, false, false, filepath.Join(dir, "filenotexists"))
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -115,7 +115,7 @@ func TestHead(t *testing.T) {
 		stdin := strings.NewReader("hello\n")
 		stdout := &bytes.Buffer{}
 
-		err := run(stdin, stdout, nil, 1, 0)
+		err := run(stdin, stdout, nil, 1, 0, false, false)
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -129,7 +129,7 @@ func TestHead(t *testing.T) {
 		stdin := strings.NewReader("hello\nagain\n")
 		stdout := &bytes.Buffer{}
 
-		err := run(stdin, stdout, nil, 0, 1)
+		err := run(stdin, stdout, nil, 0, 1, false, false)
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -138,4 +138,57 @@ func TestHead(t *testing.T) {
 			t.Errorf("expected 'hello\n' got %q", stdout.String())
 		}
 	})
+
+	t.Run("two files print lines", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		err := run(nil, stdout, nil, 0, 1, false, false, f1.Name(), f2.Name())
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		expected := fmt.Sprintf("==> %s <==\nf11\n\n==> %s <==\nf21\n",
+			f1.Name(), f2.Name())
+		if stdout.String() != expected {
+			t.Errorf("%q != %q", expected, stdout.String())
+		}
+	})
+
+	t.Run("two files quiet", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		err := run(nil, stdout, nil, 0, 1, true, false, f1.Name(), f2.Name())
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		if stdout.String() != "f11\nf21\n" {
+			t.Errorf("expected 'f11\nf21\n' got %q", stdout.String())
+		}
+	})
+
+	t.Run("one file verbose", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		err := run(nil, stdout, nil, 0, 1, false, true, f1.Name())
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		expected := fmt.Sprintf("==> %s <==\nf11\n", f1.Name())
+		if stdout.String() != expected {
+			t.Errorf("%q != %q", expected, stdout.String())
+		}
+	})
+
+	t.Run("stdin verbose", func(t *testing.T) {
+		stdin := strings.NewReader("hello\n")
+		stdout := &bytes.Buffer{}
+
+		err := run(stdin, stdout, nil, 0, 1, false, true)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		if stdout.String() != "==> standard input <==\nhello\n" {
+			t.Errorf("unexpected output %q", stdout.String())
+		}
+	})
 }
@@ -6,7 +6,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 
@@ -19,7 +18,7 @@ const addressHelp = `Usage: ip address {add|replace} ADDR dev IFNAME [ LIFETIME
 
        ip address flush dev IFNAME [ scope SCOPE-ID ] [ label LABEL ]
 
-       ip address [ show [ dev IFNAME ] [ type TYPE ]
+       ip address [ show [ dev IFNAME ] [ scope SCOPE-ID ] [ type TYPE ] ]
 
 	   ip address help
 
@@ -42,6 +41,9 @@ var stringScope = map[string]netlink.Scope{
 	"link":   netlink.SCOPE_LINK,
 }
 
+// anyScope matches addresses of every scope in ip address show.
+const anyScope = -1
+
 func (cmd *cmd) address() error {
 	if !cmd.tokenRemains() {
 		return cmd.showAllLinks(true)
@@ -137,29 +139,110 @@ func (cmd *cmd) parseAddrAddReplace() (netlink.Link, *netlink.Addr, error) {
 }
 
 func (cmd *cmd) addressShow() error {
-	device, typeName, err := cmd.parseAddrShow()
+	device, typeName, scope, err := cmd.parseAddrShow()
 	if err != nil {
-		if errors.Is(err, ErrNotFound) {
-			return cmd.showAllLinks(true)
+		return err
+	}
+
+	links := []netlink.Link{device}
+	if device == nil {
+		if links, err = netlink.LinkList(); err != nil {
+			return fmt.Errorf("can't enumerate interfaces: %w", err)
+		}
+	}
+
+	var (
+		shown     []netlink.Link
+		addresses [][]netlink.Addr
+	)
+	for _, link := range links {
+		addrs, err := netlink.AddrList(link, cmd.Family)
+		if err != nil {
+			return fmt.Errorf("can't get addresses for link %s: %w", link.Attrs().Name, err)
 		}
 
-		return err
+		addrs = filterAddrsByScope(addrs, scope)
+		// Like iproute2, leave out links none of whose addresses
+		// match the scope.
+		if scope != anyScope && len(addrs) == 0 {
+			continue
+		}
+		shown = append(shown, link)
+		addresses = append(addresses, addrs)
+	}
+
+	var filterByType []string
+	if typeName != "" {
+		filterByType = append(filterByType, typeName)
 	}
 
-	return cmd.showLink(device, true, typeName)
+	return cmd.showLinks(addresses, shown, filterByType...)
 }
 
-func (cmd *cmd) parseAddrShow() (netlink.Link, string, error) {
-	device, err := cmd.parseDeviceName(false)
-	if err != nil {
-		return nil, "", err
+// parseAddrShow parses the optional device, scope and type of ip address
+// show. A nil link means all links and a scope of anyScope all scopes.
+func (cmd *cmd) parseAddrShow() (netlink.Link, string, int, error) {
+	var (
+		device   netlink.Link
+		typeName string
+		scope    = anyScope
+		err      error
+	)
+
+	for cmd.tokenRemains() {
+		switch cmd.nextToken("dev", "scope", "type", "device-name") {
+		case "scope":
+			if scope, err = cmd.parseScope(); err != nil {
+				return nil, "", 0, err
+			}
+		case "type":
+			typeName = cmd.nextToken("type name")
+		case "dev":
+			cmd.Cursor++
+			fallthrough
+		default:
+			cmd.ExpectedValues = []string{"device-name"}
+			if device, err = netlink.LinkByName(cmd.currentToken()); err != nil {
+				return nil, "", 0, err
+			}
+		}
 	}
-	typeName, err := cmd.parseType()
+
+	return device, typeName, scope, nil
+}
+
+// parseScope parses a SCOPE-ID: a scope name or the number carried in the
+// scope byte of an address message.
+func (cmd *cmd) parseScope() (int, error) {
+	scope := cmd.nextToken("SCOPE-ID")
+
+	if s, ok := stringScope[scope]; ok {
+		return int(s), nil
+	}
+
+	scopeInt, err := strconv.ParseUint(scope, 10, 8)
 	if err != nil {
-		return nil, "", err
+		return 0, fmt.Errorf("invalid scope value: %v", scope)
+	}
+
+	return int(scopeInt), nil
+}
+
+// filterAddrsByScope returns the addresses with the given scope, or all of
+// them for anyScope.
+func filterAddrsByScope(addrs []netlink.Addr, scope int) []netlink.Addr {
+	if scope == anyScope {
+		return addrs
+	}
+
+	var filtered []netlink.Addr
+	for _, addr := range addrs {
+		if addr.Scope == scope {
+			filtered = append(filtered, addr)
+		}
 	}
 
-	return device, typeName, nil
+	return filtered
 }
 
 func (cmd *cmd) parseAddrFlush() (netlink.Link, netlink.Addr, error) {
@@ -173,16 +256,8 @@ func (cmd *cmd) parseAddrFlush() (netlink.Link, netlink.Addr, error) {
 	for cmd.tokenRemains() {
 		switch cmd.nextToken("scope", "label") {
 		case "scope":
-			scope := cmd.nextToken("SCOPE-ID")
-
-			if s, ok := stringScope[scope]; ok {
-				addr.Scope = int(s)
-			} else {
-				scopeInt, err := strconv.ParseInt(scope, 10, 32)
-				if err != nil {
-					return nil, addr, fmt.Errorf("invalid scope value: %v", scope)
-				}
-				addr.Scope = int(scopeInt)
+			if addr.Scope, err = cmd.parseScope(); err != nil {
+				return nil, addr, err
 			}
 		case "label":
 			addr.Label = cmd.nextToken("LABEL")
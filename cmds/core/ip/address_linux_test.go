@@ -7,6 +7,8 @@ package main
 
 import (
 	"bytes"
+	"net"
+	"slices"
 	"testing"
 
 	"github.com/vishvananda/netlink"
@@ -110,6 +112,7 @@ func TestParseAddrShow(t *testing.T) {
 		cmd      cmd
 		dev      string
 		typeName string
+		scope    int
 		wantErr  bool
 	}{
 		{
@@ -119,7 +122,7 @@ func TestParseAddrShow(t *testing.T) {
 				Args:   []string{"ip", "addr", "show"},
 				Out:    new(bytes.Buffer),
 			},
-			wantErr: true,
+			scope: anyScope,
 		},
 		{
 			name: "values",
@@ -130,28 +133,123 @@ func TestParseAddrShow(t *testing.T) {
 			},
 			dev:      "lo",
 			typeName: "bridge",
+			scope:    anyScope,
+		},
+		{
+			name: "bare device",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "lo"},
+				Out:    new(bytes.Buffer),
+			},
+			dev:   "lo",
+			scope: anyScope,
+		},
+		{
+			name: "scope",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "scope", "global"},
+				Out:    new(bytes.Buffer),
+			},
+			scope: int(netlink.SCOPE_UNIVERSE),
+		},
+		{
+			name: "device and scope",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "dev", "lo", "scope", "host"},
+				Out:    new(bytes.Buffer),
+			},
+			dev:   "lo",
+			scope: int(netlink.SCOPE_HOST),
+		},
+		{
+			name: "numeric scope",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "scope", "253"},
+				Out:    new(bytes.Buffer),
+			},
+			scope: int(netlink.SCOPE_LINK),
+		},
+		{
+			name: "scope out of range",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "scope", "256"},
+				Out:    new(bytes.Buffer),
+			},
+			wantErr: true,
+		},
+		{
+			name: "bad device",
+			cmd: cmd{
+				Cursor: 2,
+				Args:   []string{"ip", "addr", "show", "dev", "fjghyy"},
+				Out:    new(bytes.Buffer),
+			},
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			link, typeStr, err := tt.cmd.parseAddrShow()
+			link, typeStr, scope, err := tt.cmd.parseAddrShow()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseAddrShow() error = %v, wantErr %t", err, tt.wantErr)
 			}
 
 			if !tt.wantErr {
-				if link.Attrs().Name != tt.dev {
-					t.Errorf("link.Name = %v, want %s", link.Attrs().Name, tt.dev)
+				switch {
+				case tt.dev == "" && link != nil:
+					t.Errorf("link = %v, want nil", link.Attrs().Name)
+				case tt.dev != "" && (link == nil || link.Attrs().Name != tt.dev):
+					t.Errorf("link = %v, want %s", link, tt.dev)
 				}
 				if typeStr != tt.typeName {
 					t.Errorf("type = %v, want %s", typeStr, tt.typeName)
 				}
+				if scope != tt.scope {
+					t.Errorf("scope = %d, want %d", scope, tt.scope)
+				}
 			}
 		})
 	}
 }
 
+func TestFilterAddrsByScope(t *testing.T) {
+	// The scopes as they come in the address messages of a host with a
+	// loopback, a link local and a global address on each family.
+	addrs := []netlink.Addr{
+		{IPNet: &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}, Scope: int(netlink.SCOPE_HOST)},
+		{IPNet: &net.IPNet{IP: net.ParseIP("::1"), Mask: net.CIDRMask(128, 128)}, Scope: int(netlink.SCOPE_HOST)},
+		{IPNet: &net.IPNet{IP: net.ParseIP("192.168.1.2"), Mask: net.CIDRMask(24, 32)}, Scope: int(netlink.SCOPE_UNIVERSE)},
+		{IPNet: &net.IPNet{IP: net.ParseIP("169.254.3.4"), Mask: net.CIDRMask(16, 32)}, Scope: int(netlink.SCOPE_LINK)},
+		{IPNet: &net.IPNet{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(64, 128)}, Scope: int(netlink.SCOPE_UNIVERSE)},
+		{IPNet: &net.IPNet{IP: net.ParseIP("fe80::2"), Mask: net.CIDRMask(64, 128)}, Scope: int(netlink.SCOPE_LINK)},
+	}
+
+	for _, tt := range []struct {
+		scope int
+		want  []string
+	}{
+		{scope: anyScope, want: []string{"127.0.0.1", "::1", "192.168.1.2", "169.254.3.4", "2001:db8::2", "fe80::2"}},
+		{scope: int(netlink.SCOPE_UNIVERSE), want: []string{"192.168.1.2", "2001:db8::2"}},
+		{scope: int(netlink.SCOPE_LINK), want: []string{"169.254.3.4", "fe80::2"}},
+		{scope: int(netlink.SCOPE_HOST), want: []string{"127.0.0.1", "::1"}},
+		{scope: int(netlink.SCOPE_SITE)},
+	} {
+		var got []string
+		for _, addr := range filterAddrsByScope(addrs, tt.scope) {
+			got = append(got, addr.IP.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filterAddrsByScope(%d) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
 func TestParseAddrFlush(t *testing.T) {
 	tests := []struct {
 		name    string
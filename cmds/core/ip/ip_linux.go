@@ -51,7 +51,7 @@ type flags struct {
 }
 
 const ipHelp = `Usage: ip [ OPTIONS ] OBJECT { COMMAND | help }
-where  OBJECT := { address |  help | link | monitor | neighbor | neighbour |
+where  OBJECT := { address |  help | link | maddress | monitor | neighbor | neighbour |
 				   route | rule | tap | tcpmetrics |
                    token | tunnel | tuntap | vrf | xfrm }
        OPTIONS := { -s[tatistics] | -d[etails] | -r[esolve] |
@@ -318,11 +318,13 @@ func (cmd *cmd) runSubCommand() error {
 		fmt.Fprint(cmd.Out, ipHelp)
 	}
 
-	switch c := cmd.findPrefix("address", "route", "link", "monitor", "neigh", "tunnel", "tuntap", "tap", "tcp_metrics", "tcpmetrics", "vrf", "xfrm", "help"); c {
+	switch c := cmd.findPrefix("address", "route", "link", "maddress", "monitor", "neigh", "tunnel", "tuntap", "tap", "tcp_metrics", "tcpmetrics", "vrf", "xfrm", "help"); c {
 	case "address":
 		return cmd.address()
 	case "link":
 		return cmd.link()
+	case "maddress":
+		return cmd.maddr()
 	case "route":
 		return cmd.route()
 	case "neigh":
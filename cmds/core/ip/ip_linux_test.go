@@ -296,6 +296,23 @@ func TestRunSubCommand(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "maddress",
+			cmd: cmd{
+				Cursor: 0,
+				Args:   []string{"maddr", "help"},
+				Out:    new(bytes.Buffer),
+			},
+		},
+		{
+			name: "maddress invalid",
+			cmd: cmd{
+				Cursor: 0,
+				Args:   []string{"maddr", "abc"},
+				Out:    new(bytes.Buffer),
+			},
+			wantErr: true,
+		},
 		{
 			name: "VRF",
 			cmd: cmd{
@@ -55,9 +55,12 @@ TYPE := { bareudp | bond |bridge | dummy |
           geneve | gre | gretap | ifb |
           ip6gre | ip6gretap | ip6tnl | ipip |
           ipoib | ipvlan | ipvtap | macvlan |
-          macvlan | sit | vlan | vrf |
+          macvlan | sit | veth | vlan | vrf |
           vti | vxlan | xfrm }
 
+ARGS := { peer [ name ] NAME  (veth) |
+          table TABLE         (vrf) }
+
 `
 
 func (cmd *cmd) linkSet() error {
@@ -305,64 +308,93 @@ func (cmd *cmd) setLinkVf(iface netlink.Link) error {
 }
 
 func (cmd *cmd) linkAdd() error {
-	typeName, attrs, err := cmd.parseLinkAttrs()
+	link, err := cmd.parseLinkAdd()
 	if err != nil {
 		return err
 	}
 
+	return cmd.handle.LinkAdd(link)
+}
+
+// parseLinkAdd builds the link described by an ip link add command line,
+// without creating it.
+func (cmd *cmd) parseLinkAdd() (netlink.Link, error) {
+	typeName, attrs, err := cmd.parseLinkAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only veth and vrf take arguments after their type.
+	if typeName != "veth" && typeName != "vrf" && cmd.tokenRemains() {
+		cmd.nextToken()
+		return nil, cmd.usage()
+	}
+
 	switch typeName {
 	case "dummy":
-		return cmd.handle.LinkAdd(&netlink.Dummy{LinkAttrs: attrs})
+		return &netlink.Dummy{LinkAttrs: attrs}, nil
 	case "ifb":
-		return cmd.handle.LinkAdd(&netlink.Ifb{LinkAttrs: attrs})
+		return &netlink.Ifb{LinkAttrs: attrs}, nil
 	case "vlan":
-		return cmd.handle.LinkAdd(&netlink.Vlan{LinkAttrs: attrs})
+		return &netlink.Vlan{LinkAttrs: attrs}, nil
 	case "macvlan":
-		return cmd.handle.LinkAdd(&netlink.Macvlan{LinkAttrs: attrs})
+		return &netlink.Macvlan{LinkAttrs: attrs}, nil
 	case "veth":
-		return cmd.handle.LinkAdd(&netlink.Veth{LinkAttrs: attrs})
+		if !cmd.tokenRemains() || cmd.nextToken("peer") != "peer" {
+			return nil, fmt.Errorf("veth requires a peer: peer [ name ] NAME")
+		}
+		if !cmd.tokenRemains() {
+			return nil, fmt.Errorf("veth peer name not specified")
+		}
+		peer := cmd.parseName()
+		if cmd.tokenRemains() {
+			cmd.nextToken()
+			return nil, cmd.usage()
+		}
+
+		return &netlink.Veth{LinkAttrs: attrs, PeerName: peer}, nil
 	case "vxlan":
-		return cmd.handle.LinkAdd(&netlink.Vxlan{LinkAttrs: attrs})
+		return &netlink.Vxlan{LinkAttrs: attrs}, nil
 	case "ipvlan":
-		return cmd.handle.LinkAdd(&netlink.IPVlan{LinkAttrs: attrs})
+		return &netlink.IPVlan{LinkAttrs: attrs}, nil
 	case "ipvtap":
-		return cmd.handle.LinkAdd(&netlink.IPVtap{IPVlan: netlink.IPVlan{LinkAttrs: attrs}})
+		return &netlink.IPVtap{IPVlan: netlink.IPVlan{LinkAttrs: attrs}}, nil
 	case "bond":
-		return cmd.handle.LinkAdd(netlink.NewLinkBond(attrs))
+		return netlink.NewLinkBond(attrs), nil
 	case "geneve":
-		return cmd.handle.LinkAdd(&netlink.Geneve{LinkAttrs: attrs})
+		return &netlink.Geneve{LinkAttrs: attrs}, nil
 	case "gretap":
-		return cmd.handle.LinkAdd(&netlink.Gretap{LinkAttrs: attrs})
+		return &netlink.Gretap{LinkAttrs: attrs}, nil
 	case "ipip":
-		return cmd.handle.LinkAdd(&netlink.Iptun{LinkAttrs: attrs})
+		return &netlink.Iptun{LinkAttrs: attrs}, nil
 	case "ip6tln":
-		return cmd.handle.LinkAdd(&netlink.Ip6tnl{LinkAttrs: attrs})
+		return &netlink.Ip6tnl{LinkAttrs: attrs}, nil
 	case "sit":
-		return cmd.handle.LinkAdd(&netlink.Sittun{LinkAttrs: attrs})
+		return &netlink.Sittun{LinkAttrs: attrs}, nil
 	case "vti":
-		return cmd.handle.LinkAdd(&netlink.Vti{LinkAttrs: attrs})
+		return &netlink.Vti{LinkAttrs: attrs}, nil
 	case "gre":
-		return cmd.handle.LinkAdd(&netlink.Gretun{LinkAttrs: attrs})
+		return &netlink.Gretun{LinkAttrs: attrs}, nil
 	case "vrf":
-		if cmd.nextToken("table") != "table" {
-			return cmd.usage()
+		if !cmd.tokenRemains() || cmd.nextToken("table") != "table" {
+			return nil, fmt.Errorf("vrf requires a routing table: table TABLE")
 		}
 		tableID, err := cmd.parseUint32("TABLE")
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		return cmd.handle.LinkAdd(&netlink.Vrf{LinkAttrs: attrs, Table: tableID})
+		return &netlink.Vrf{LinkAttrs: attrs, Table: tableID}, nil
 	case "bridge":
-		return cmd.handle.LinkAdd(&netlink.Bridge{LinkAttrs: attrs})
+		return &netlink.Bridge{LinkAttrs: attrs}, nil
 	case "xfrm":
-		return cmd.handle.LinkAdd(&netlink.Xfrmi{LinkAttrs: attrs})
+		return &netlink.Xfrmi{LinkAttrs: attrs}, nil
 	case "ipoib":
-		return cmd.handle.LinkAdd(&netlink.IPoIB{LinkAttrs: attrs})
+		return &netlink.IPoIB{LinkAttrs: attrs}, nil
 	case "bareudp":
-		return cmd.handle.LinkAdd(&netlink.BareUDP{LinkAttrs: attrs})
+		return &netlink.BareUDP{LinkAttrs: attrs}, nil
 	default:
-		return fmt.Errorf("unsupported link type %s", typeName)
+		return nil, fmt.Errorf("unsupported link type %s", typeName)
 	}
 }
 
@@ -413,6 +445,12 @@ func (cmd *cmd) parseLinkAttrs() (string, netlink.LinkAttrs, error) {
 		case "type":
 			typeName = cmd.nextToken("TYPE")
 		default:
+			if typeName != "" {
+				// Type specific arguments, e.g. a veth peer, are
+				// left for the caller.
+				cmd.lastToken()
+				return typeName, attrs, nil
+			}
 			return "", netlink.LinkAttrs{}, cmd.usage()
 		}
 	}
@@ -175,3 +175,83 @@ func TestParseLinkAttrs(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLinkAdd(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantLink netlink.Link
+		wantErr  bool
+	}{
+		{
+			name: "veth",
+			args: []string{"ip", "link", "add", "veth0", "type", "veth", "peer", "name", "veth1"},
+			wantLink: &netlink.Veth{
+				LinkAttrs: netlink.LinkAttrs{Name: "veth0"},
+				PeerName:  "veth1",
+			},
+		},
+		{
+			name: "veth with attributes and bare peer",
+			args: []string{"ip", "link", "add", "name", "veth0", "mtu", "9000", "type", "veth", "peer", "veth1"},
+			wantLink: &netlink.Veth{
+				LinkAttrs: netlink.LinkAttrs{Name: "veth0", MTU: 9000},
+				PeerName:  "veth1",
+			},
+		},
+		{
+			name:    "veth without peer",
+			args:    []string{"ip", "link", "add", "veth0", "type", "veth"},
+			wantErr: true,
+		},
+		{
+			name:    "veth without peer name",
+			args:    []string{"ip", "link", "add", "veth0", "type", "veth", "peer"},
+			wantErr: true,
+		},
+		{
+			name:    "veth with junk",
+			args:    []string{"ip", "link", "add", "veth0", "type", "veth", "peer", "name", "veth1", "abc"},
+			wantErr: true,
+		},
+		{
+			name:     "bridge",
+			args:     []string{"ip", "link", "add", "br0", "type", "bridge"},
+			wantLink: &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "br0"}},
+		},
+		{
+			name:    "bridge with junk",
+			args:    []string{"ip", "link", "add", "br0", "type", "bridge", "peer", "name", "br1"},
+			wantErr: true,
+		},
+		{
+			name:     "vrf",
+			args:     []string{"ip", "link", "add", "vrf0", "type", "vrf", "table", "10"},
+			wantLink: &netlink.Vrf{LinkAttrs: netlink.LinkAttrs{Name: "vrf0"}, Table: 10},
+		},
+		{
+			name:    "unsupported type",
+			args:    []string{"ip", "link", "add", "x0", "type", "abc"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := cmd{Cursor: 2, Args: tt.args, Out: new(bytes.Buffer)}
+			link, err := cmd.parseLinkAdd()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLinkAdd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if link.Type() != tt.wantLink.Type() {
+				t.Errorf("parseLinkAdd() type = %q, want %q", link.Type(), tt.wantLink.Type())
+			}
+			if !reflect.DeepEqual(link, tt.wantLink) {
+				t.Errorf("parseLinkAdd() = %+v, want %+v", link, tt.wantLink)
+			}
+		})
+	}
+}
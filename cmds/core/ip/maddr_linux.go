@@ -0,0 +1,248 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	maddrHelp = `Usage:	ip maddress [ show [ [ dev ] IFNAME ] ]`
+
+	// procNet is where the kernel lists the multicast groups: link layer
+	// ones in dev_mcast, IPv4 ones in igmp and IPv6 ones in igmp6.
+	procNet = "/proc/net"
+)
+
+func (cmd *cmd) maddr() error {
+	if !cmd.tokenRemains() {
+		return cmd.printMaddrs(procNet, "")
+	}
+
+	switch cmd.findPrefix("show", "list", "help") {
+	case "show", "list":
+		var dev string
+		if cmd.tokenRemains() {
+			if cmd.nextToken("dev", "device-name") == "dev" {
+				cmd.Cursor++
+			}
+			cmd.ExpectedValues = []string{"device-name"}
+			dev = cmd.currentToken()
+		}
+		return cmd.printMaddrs(procNet, dev)
+	case "help":
+		fmt.Fprint(cmd.Out, maddrHelp)
+
+		return nil
+	}
+	return cmd.usage()
+}
+
+// mcastAddr is a multicast group an interface is in, and how many times it
+// has been joined.
+type mcastAddr struct {
+	addr  string
+	users int
+}
+
+// mcastIface is the multicast groups of an interface, by family.
+type mcastIface struct {
+	index int
+	name  string
+	link  []mcastAddr
+	inet  []mcastAddr
+	inet6 []mcastAddr
+}
+
+// maddrs maps interface indices to their multicast groups.
+type maddrs map[int]*mcastIface
+
+func (m maddrs) iface(index, name string) (*mcastIface, error) {
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return nil, fmt.Errorf("bad interface index %q: %w", index, err)
+	}
+	if m[i] == nil {
+		m[i] = &mcastIface{index: i, name: name}
+	}
+	return m[i], nil
+}
+
+// readLines calls f with the fields of each line of file in dir. A missing
+// file, e.g. igmp6 without IPv6, has no lines.
+func readLines(dir, file string, f func([]string) error) error {
+	fh, err := os.Open(filepath.Join(dir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	s := bufio.NewScanner(fh)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := f(fields); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return s.Err()
+}
+
+// readMaddrs reads the multicast groups of every interface from the proc
+// files in dir.
+func readMaddrs(dir string) (maddrs, error) {
+	m := maddrs{}
+
+	// index name users global-users address
+	if err := readLines(dir, "dev_mcast", func(f []string) error {
+		if len(f) < 5 {
+			return fmt.Errorf("malformed line %q", strings.Join(f, " "))
+		}
+		hw, err := hex.DecodeString(f[4])
+		if err != nil {
+			return err
+		}
+		users, err := strconv.Atoi(f[2])
+		if err != nil {
+			return err
+		}
+		iface, err := m.iface(f[0], f[1])
+		if err != nil {
+			return err
+		}
+		iface.link = append(iface.link, mcastAddr{addr: net.HardwareAddr(hw).String(), users: users})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// A header, then for each interface a line with its index and name,
+	// followed by lines with its groups: address users timer reporter.
+	// The addresses are in host byte order.
+	var iface *mcastIface
+	if err := readLines(dir, "igmp", func(f []string) error {
+		if f[0] == "Idx" {
+			return nil
+		}
+		if len(f) >= 3 && f[2] == ":" {
+			var err error
+			iface, err = m.iface(f[0], f[1])
+			return err
+		}
+		if iface == nil || len(f) < 2 {
+			return fmt.Errorf("malformed line %q", strings.Join(f, " "))
+		}
+		group, err := strconv.ParseUint(f[0], 16, 32)
+		if err != nil {
+			return err
+		}
+		users, err := strconv.Atoi(f[1])
+		if err != nil {
+			return err
+		}
+		ip := net.IP(binary.NativeEndian.AppendUint32(nil, uint32(group)))
+		iface.inet = append(iface.inet, mcastAddr{addr: ip.String(), users: users})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// index name address users flags timer
+	if err := readLines(dir, "igmp6", func(f []string) error {
+		if len(f) < 4 {
+			return fmt.Errorf("malformed line %q", strings.Join(f, " "))
+		}
+		b, err := hex.DecodeString(f[2])
+		if err != nil || len(b) != net.IPv6len {
+			return fmt.Errorf("bad address %q", f[2])
+		}
+		users, err := strconv.Atoi(f[3])
+		if err != nil {
+			return err
+		}
+		iface, err := m.iface(f[0], f[1])
+		if err != nil {
+			return err
+		}
+		iface.inet6 = append(iface.inet6, mcastAddr{addr: net.IP(b).String(), users: users})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// printMaddrs prints the multicast groups of each interface, or only of
+// dev if it is not empty, from the proc files in dir, as ip maddress shows
+// them.
+func (cmd *cmd) printMaddrs(dir, dev string) error {
+	m, err := readMaddrs(dir)
+	if err != nil {
+		return err
+	}
+	ifaces := make([]*mcastIface, 0, len(m))
+	for _, iface := range m {
+		if dev == "" || iface.name == dev {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].index < ifaces[j].index })
+
+	for _, iface := range ifaces {
+		fmt.Fprintf(cmd.Out, "%d:\t%s\n", iface.index, iface.name)
+		for _, g := range []struct {
+			family string
+			addrs  []mcastAddr
+		}{
+			{"link ", iface.link},
+			{"inet ", iface.inet},
+			{"inet6", iface.inet6},
+		} {
+			if !cmd.showMaddrFamily(strings.TrimSpace(g.family)) {
+				continue
+			}
+			for _, a := range g.addrs {
+				fmt.Fprintf(cmd.Out, "\t%s %s", g.family, a.addr)
+				if a.users > 1 {
+					fmt.Fprintf(cmd.Out, " users %d", a.users)
+				}
+				fmt.Fprintln(cmd.Out)
+			}
+		}
+	}
+	return nil
+}
+
+// showMaddrFamily returns whether the -0, -4 and -6 options let groups of
+// family, which is link, inet or inet6, be shown.
+func (cmd *cmd) showMaddrFamily(family string) bool {
+	switch {
+	case cmd.Opts.Link:
+		return family == "link"
+	case cmd.Family == netlink.FAMILY_V4:
+		return family == "inet"
+	case cmd.Family == netlink.FAMILY_V6:
+		return family == "inet6"
+	}
+	return true
+}
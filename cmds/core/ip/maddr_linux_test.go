@@ -0,0 +1,176 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// maddrDir returns a directory with the testdata/maddr fixtures under the
+// names they have in /proc/net. They are kept with a .txt extension, as
+// cmds/.gitignore ignores files without one.
+func maddrDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"dev_mcast", "igmp", "igmp6"} {
+		b, err := os.ReadFile(filepath.Join("testdata", "maddr", name+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPrintMaddrs(t *testing.T) {
+	// testdata/maddr/igmp.txt has its addresses in little endian byte order,
+	// as the kernel writes them on little endian machines.
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("igmp fixture is little endian")
+	}
+
+	tests := []struct {
+		name   string
+		dev    string
+		family int
+		link   bool
+		want   string
+	}{
+		{
+			name:   "all",
+			family: netlink.FAMILY_ALL,
+			want: `1:	lo
+	inet  224.0.0.1
+	inet6 ff02::1
+	inet6 ff01::1
+2:	eth0
+	link  33:33:00:00:00:01
+	link  01:00:5e:00:00:01
+	link  01:00:5e:00:00:fb users 2
+	link  33:33:ff:12:ab:34
+	inet  224.0.0.251 users 2
+	inet  224.0.0.1
+	inet6 ff02::1:ff12:ab34
+	inet6 ff02::1
+3:	wlan0
+	link  33:33:00:00:00:01
+	inet  224.0.0.1
+	inet6 ff02::1
+`,
+		},
+		{
+			name:   "device",
+			dev:    "wlan0",
+			family: netlink.FAMILY_ALL,
+			want: `3:	wlan0
+	link  33:33:00:00:00:01
+	inet  224.0.0.1
+	inet6 ff02::1
+`,
+		},
+		{
+			name:   "no such device",
+			dev:    "eth1",
+			family: netlink.FAMILY_ALL,
+		},
+		{
+			name:   "inet",
+			dev:    "eth0",
+			family: netlink.FAMILY_V4,
+			want: `2:	eth0
+	inet  224.0.0.251 users 2
+	inet  224.0.0.1
+`,
+		},
+		{
+			name:   "inet6",
+			dev:    "eth0",
+			family: netlink.FAMILY_V6,
+			want: `2:	eth0
+	inet6 ff02::1:ff12:ab34
+	inet6 ff02::1
+`,
+		},
+		{
+			name:   "link",
+			dev:    "lo",
+			family: netlink.FAMILY_ALL,
+			link:   true,
+			want: `1:	lo
+`,
+		},
+	}
+
+	dir := maddrDir(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := cmd{Out: &out, Family: tt.family, Opts: flags{Link: tt.link}}
+			if err := cmd.printMaddrs(dir, tt.dev); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintMaddrsMissingFiles(t *testing.T) {
+	// Without IPv6, there is no igmp6.
+	dir := t.TempDir()
+	b, err := os.ReadFile("testdata/maddr/dev_mcast.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dev_mcast"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cmd := cmd{Out: &out}
+	if err := cmd.printMaddrs(dir, "wlan0"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3:\twlan0\n\tlink  33:33:00:00:00:01\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestReadMaddrsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{name: "short dev_mcast line", file: "dev_mcast", data: "2 eth0 1\n"},
+		{name: "bad link address", file: "dev_mcast", data: "2 eth0 1 0 01005e00000g\n"},
+		{name: "bad index", file: "dev_mcast", data: "x eth0 1 0 01005e000001\n"},
+		{name: "igmp group without interface", file: "igmp", data: "Idx\tDevice : Count Querier\tGroup Users Timer\tReporter\n\t010000E0 1 0:00000000 0\n"},
+		{name: "bad igmp group", file: "igmp", data: "1\tlo : 1 V3\n\tZZ0000E0 1 0:00000000 0\n"},
+		{name: "short igmp6 address", file: "igmp6", data: "1 lo ff02 1 0000000C 0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readMaddrs(dir); err == nil {
+				t.Error("readMaddrs() = nil, want an error")
+			}
+		})
+	}
+}
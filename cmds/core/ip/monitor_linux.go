@@ -90,7 +90,7 @@ func (cmd *cmd) monitor() error {
 		case "mroute", "netconf", "nexthop", "nsid", "prefix", "rule":
 			return fmt.Errorf("monitoring %s is not yet supported", cmd.currentToken())
 		case "help":
-			fmt.Fprint(cmd.Out, tunnelHelp)
+			fmt.Fprint(cmd.Out, monitorHelp)
 			return nil
 		default:
 			return cmd.usage()
@@ -132,72 +132,87 @@ func (cmd *cmd) printUpdates(addrUpdates chan netlink.AddrUpdate, linkUpdates ch
 
 		select {
 		case update := <-addrUpdates:
+			fmt.Fprint(cmd.Out, timestamp+formatAddrUpdate(update, linkName(update.LinkIndex)))
+		case update := <-neighUpdates:
+			fmt.Fprint(cmd.Out, timestamp+formatNeighUpdate(update, linkName(update.Neigh.LinkIndex)))
+		case update := <-routeUpdates:
+			fmt.Fprint(cmd.Out, timestamp+formatRouteUpdate(update, linkName(update.Route.LinkIndex)))
+		case update := <-linkUpdates:
+			fmt.Fprint(cmd.Out, timestamp+formatLinkUpdate(update))
+		case <-sig:
+			return nil
+		case <-done:
+			return nil
+		default:
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
 
-			link, err := netlink.LinkByIndex(update.LinkIndex)
-			if err != nil {
-				return fmt.Errorf("failed to get link by index %d: %w", update.LinkIndex, err)
-			}
-
-			var action string
-			if !update.NewAddr {
-				action = "Deleted"
-			}
-
-			fmt.Fprintf(cmd.Out, "%s%s%s %d: %s    %v %v scope %d %v\n", timestamp, addressLabel, action, update.LinkIndex, link.Attrs().Name, ipFamily(update.LinkAddress.IP), update.LinkAddress.String(), update.Scope, link.Attrs().Name)
-
-			validLft := fmt.Sprintf("%v", update.ValidLft)
-			preferedLft := fmt.Sprintf("%v", update.PreferedLft)
+// linkName returns the name of the link with the given index. The link may
+// already be gone by the time its events are printed, e.g. addresses are
+// deleted along with their link, so fall back to the index.
+func linkName(index int) string {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return fmt.Sprintf("if%d", index)
+	}
+	return link.Attrs().Name
+}
 
-			if update.ValidLft >= math.MaxInt32 {
-				validLft = "forever"
-			}
+func formatAddrUpdate(update netlink.AddrUpdate, name string) string {
+	var action string
+	if !update.NewAddr {
+		action = "Deleted "
+	}
 
-			if update.PreferedLft >= math.MaxInt32 {
-				preferedLft = "forever"
-			}
+	validLft := fmt.Sprintf("%v", update.ValidLft)
+	preferedLft := fmt.Sprintf("%v", update.PreferedLft)
 
-			fmt.Fprintf(cmd.Out, "    valid_lft %s preferred_lft %s\n", validLft, preferedLft)
+	if update.ValidLft >= math.MaxInt32 {
+		validLft = "forever"
+	}
 
-		case update := <-neighUpdates:
-			var action string
+	if update.PreferedLft >= math.MaxInt32 {
+		preferedLft = "forever"
+	}
 
-			if update.Type == syscall.RTM_DELNEIGH {
-				action = "Deleted "
-			}
+	return fmt.Sprintf("%s%s%d: %s    %v %v scope %d %v\n    valid_lft %s preferred_lft %s\n",
+		addressLabel, action, update.LinkIndex, name, ipFamily(update.LinkAddress.IP), update.LinkAddress.String(), update.Scope, name,
+		validLft, preferedLft)
+}
 
-			link, err := netlink.LinkByIndex(update.Neigh.LinkIndex)
-			if err != nil {
-				return fmt.Errorf("failed to get link by index %d: %w", update.Neigh.LinkIndex, err)
-			}
+func formatNeighUpdate(update netlink.NeighUpdate, name string) string {
+	var action string
+	if update.Type == syscall.RTM_DELNEIGH {
+		action = "Deleted "
+	}
 
-			fmt.Fprintf(cmd.Out, "%s%s%s%s dev %v lladdr %s %v\n", timestamp, neighLabel, action, update.Neigh.IP, link.Attrs().Name, update.Neigh.HardwareAddr.String(), neighStateToString(update.Neigh.State))
+	return fmt.Sprintf("%s%s%s dev %v lladdr %s %v\n", neighLabel, action, update.Neigh.IP, name, update.Neigh.HardwareAddr.String(), neighStateToString(update.Neigh.State))
+}
 
-		case update := <-routeUpdates:
-			var action string
-			switch update.Type {
-			case syscall.RTM_NEWROUTE:
-				action = "Added"
-			case syscall.RTM_DELROUTE:
-				action = "Deleted"
-			}
+func formatRouteUpdate(update netlink.RouteUpdate, name string) string {
+	var action string
+	switch update.Type {
+	case syscall.RTM_NEWROUTE:
+		action = "Added"
+	case syscall.RTM_DELROUTE:
+		action = "Deleted"
+	}
 
-			link, err := netlink.LinkByIndex(update.Route.LinkIndex)
-			if err != nil {
-				return fmt.Errorf("failed to get link by index %d: %w", update.Route.LinkIndex, err)
-			}
+	return fmt.Sprintf("%s%s %s dev %s table %d proto %s scope %s src %s\n", routeLabel, action, update.Route.Dst, name, update.Route.Table, update.Route.Protocol.String(), update.Route.Scope.String(), update.Route.Src)
+}
 
-			fmt.Fprintf(cmd.Out, "%s%s%s %s dev %s table %d proto %s scope %s src %s\n", timestamp, routeLabel, action, update.Route.Dst, link.Attrs().Name, update.Route.Table, update.Route.Protocol.String(), update.Route.Scope.String(), update.Route.Src)
-		case update := <-linkUpdates:
-			fmt.Fprintf(cmd.Out, "%s%s%d: %s: <%s>\n", timestamp, linkLabel, update.Link.Attrs().Index, update.Link.Attrs().Name, strings.Replace(strings.ToUpper(net.Flags(update.Flags).String()), "|", ",", -1))
-			fmt.Fprintf(cmd.Out, "    link/%v\n", update.Link.Attrs().EncapType)
-		case <-sig:
-			return nil
-		case <-done:
-			return nil
-		default:
-			time.Sleep(50 * time.Millisecond)
-		}
+func formatLinkUpdate(update netlink.LinkUpdate) string {
+	var action string
+	if update.Header.Type == syscall.RTM_DELLINK {
+		action = "Deleted "
 	}
+
+	attrs := update.Link.Attrs()
+	return fmt.Sprintf("%s%s%d: %s: <%s> mtu %d state %s\n    link/%v\n",
+		linkLabel, action, attrs.Index, attrs.Name, strings.Replace(strings.ToUpper(net.Flags(update.Flags).String()), "|", ",", -1),
+		attrs.MTU, strings.ToUpper(attrs.OperState.String()), attrs.EncapType)
 }
 
 func neighStateToString(state int) string {
@@ -6,8 +6,14 @@
 package main
 
 import (
+	"math"
 	"net"
+	"syscall"
 	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
 )
 
 func TestNeighStateToString(t *testing.T) {
@@ -57,3 +63,94 @@ func TestIpFamily(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatUpdates(t *testing.T) {
+	_, dst, err := net.ParseCIDR("10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, err := net.ParseMAC("00:11:22:33:44:55")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := netlink.AddrUpdate{
+		LinkAddress: net.IPNet{IP: net.ParseIP("192.168.0.2").To4(), Mask: net.CIDRMask(24, 32)},
+		LinkIndex:   2,
+		NewAddr:     false,
+		Scope:       0,
+		ValidLft:    math.MaxUint32,
+		PreferedLft: 100,
+	}
+	neigh := netlink.NeighUpdate{
+		Type:  syscall.RTM_NEWNEIGH,
+		Neigh: netlink.Neigh{LinkIndex: 2, IP: net.ParseIP("192.168.0.1"), HardwareAddr: mac, State: netlink.NUD_REACHABLE},
+	}
+	route := netlink.RouteUpdate{
+		Type:  syscall.RTM_NEWROUTE,
+		Route: netlink.Route{LinkIndex: 2, Dst: dst, Table: 254, Protocol: netlink.RouteProtocol(unix.RTPROT_KERNEL), Scope: netlink.SCOPE_LINK, Src: net.ParseIP("10.0.0.1")},
+	}
+	link := netlink.LinkUpdate{
+		IfInfomsg: nl.IfInfomsg{IfInfomsg: unix.IfInfomsg{Flags: unix.IFF_UP | unix.IFF_BROADCAST}},
+		Header:    unix.NlMsghdr{Type: syscall.RTM_DELLINK},
+		Link:      &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "eth0", MTU: 1500, OperState: netlink.OperDown, EncapType: "ether"}},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels bool
+		got    func() string
+		want   string
+	}{
+		{
+			name: "addr",
+			got:  func() string { return formatAddrUpdate(addr, "eth0") },
+			want: "Deleted 2: eth0    inet 192.168.0.2/24 scope 0 eth0\n    valid_lft forever preferred_lft 100\n",
+		},
+		{
+			name: "neigh",
+			got:  func() string { return formatNeighUpdate(neigh, "eth0") },
+			want: "192.168.0.1 dev eth0 lladdr 00:11:22:33:44:55 REACHABLE\n",
+		},
+		{
+			name: "route",
+			got:  func() string { return formatRouteUpdate(route, "eth0") },
+			want: "Added 10.0.0.0/24 dev eth0 table 254 proto kernel scope link src 10.0.0.1\n",
+		},
+		{
+			name: "link",
+			got:  func() string { return formatLinkUpdate(link) },
+			want: "Deleted 2: eth0: <UP,BROADCAST> mtu 1500 state DOWN\n    link/ether\n",
+		},
+		{
+			name:   "labelled addr",
+			labels: true,
+			got:    func() string { return formatAddrUpdate(addr, "eth0") },
+			want:   "[ADDR]Deleted 2: eth0    inet 192.168.0.2/24 scope 0 eth0\n    valid_lft forever preferred_lft 100\n",
+		},
+		{
+			name:   "labelled link",
+			labels: true,
+			got:    func() string { return formatLinkUpdate(link) },
+			want:   "[LINK]Deleted 2: eth0: <UP,BROADCAST> mtu 1500 state DOWN\n    link/ether\n",
+		},
+		{
+			name:   "labelled route",
+			labels: true,
+			got:    func() string { return formatRouteUpdate(route, "eth0") },
+			want:   "[ROUTE]Added 10.0.0.0/24 dev eth0 table 254 proto kernel scope link src 10.0.0.1\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.labels {
+				addressLabel, linkLabel, neighLabel, routeLabel = "[ADDR]", "[LINK]", "[NEIGH]", "[ROUTE]"
+				defer func() {
+					addressLabel, linkLabel, neighLabel, routeLabel = "", "", "", ""
+				}()
+			}
+			if got := tt.got(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ package main
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
@@ -571,15 +572,49 @@ func (cmd *cmd) showRoutesForAddress(addr net.IP, options *netlink.RouteGetOptio
 		if err != nil {
 			return err
 		}
-		if route.Dst == nil {
-			cmd.defaultRoute(route, link.Attrs().Name)
-		} else {
-			cmd.showRoute(route, link.Attrs().Name)
-		}
+		cmd.showRouteGet(addr, route, link.Attrs().Name, options)
 	}
 	return nil
 }
 
+// showRouteGet prints the route the kernel resolved for addr, as ip route get
+// does: where the packet goes next, through which device, and which source
+// address it gets.
+func (cmd *cmd) showRouteGet(addr net.IP, r netlink.Route, name string, options *netlink.RouteGetOptions) {
+	var b strings.Builder
+	if r.Dst != nil {
+		addr = r.Dst.IP
+	}
+	fmt.Fprintf(&b, "%s", addr)
+	if options.SrcAddr != nil {
+		fmt.Fprintf(&b, " from %s", options.SrcAddr)
+	}
+	if r.Gw != nil {
+		fmt.Fprintf(&b, " via %s", r.Gw)
+	}
+	fmt.Fprintf(&b, " dev %s", name)
+	switch r.Table {
+	case 0, unix.RT_TABLE_MAIN:
+	case unix.RT_TABLE_LOCAL:
+		b.WriteString(" table local")
+	default:
+		fmt.Fprintf(&b, " table %d", r.Table)
+	}
+	if r.Src != nil {
+		fmt.Fprintf(&b, " src %s", r.Src)
+	}
+	if options.Iif != "" {
+		fmt.Fprintf(&b, " iif %s", options.Iif)
+	}
+	if r.MTU != 0 {
+		fmt.Fprintf(&b, " mtu %d", r.MTU)
+	}
+	if cmd.Opts.Details {
+		fmt.Fprintf(&b, " type %s", routeTypeToString(r.Type))
+	}
+	fmt.Fprintln(cmd.Out, b.String())
+}
+
 // routing protocol identifier
 // specified in Linux Kernel header: include/uapi/linux/rtnetlink.h
 // See man IP-ROUTE(8) and RTNETLINK(7)
@@ -785,6 +785,91 @@ func TestParseRouteGet(t *testing.T) {
 	}
 }
 
+func TestShowRouteGet(t *testing.T) {
+	// Routes as the kernel replies to RTM_GETROUTE: the destination is a
+	// host route and the gateway and preferred source are filled in.
+	host := func(ip string) *net.IPNet {
+		a := net.ParseIP(ip)
+		if a.To4() != nil {
+			return &net.IPNet{IP: a.To4(), Mask: net.CIDRMask(32, 32)}
+		}
+		return &net.IPNet{IP: a, Mask: net.CIDRMask(128, 128)}
+	}
+	tests := []struct {
+		name    string
+		opts    flags
+		addr    string
+		route   netlink.Route
+		options netlink.RouteGetOptions
+		want    string
+	}{
+		{
+			name: "via gateway",
+			addr: "8.8.8.8",
+			route: netlink.Route{
+				Dst:   host("8.8.8.8"),
+				Gw:    net.ParseIP("10.0.2.2"),
+				Src:   net.ParseIP("10.0.2.15"),
+				Table: unix.RT_TABLE_MAIN,
+				Type:  unix.RTN_UNICAST,
+			},
+			want: "8.8.8.8 via 10.0.2.2 dev eth0 src 10.0.2.15\n",
+		},
+		{
+			name: "directly connected",
+			addr: "10.0.2.3",
+			route: netlink.Route{
+				Dst:   host("10.0.2.3"),
+				Src:   net.ParseIP("10.0.2.15"),
+				Table: unix.RT_TABLE_MAIN,
+			},
+			want: "10.0.2.3 dev eth0 src 10.0.2.15\n",
+		},
+		{
+			name: "local table",
+			addr: "127.0.0.1",
+			route: netlink.Route{
+				Dst:   host("127.0.0.1"),
+				Src:   net.ParseIP("127.0.0.1"),
+				Table: unix.RT_TABLE_LOCAL,
+				Type:  unix.RTN_LOCAL,
+			},
+			want: "127.0.0.1 dev eth0 table local src 127.0.0.1\n",
+		},
+		{
+			name: "from, iif, mtu and details",
+			opts: flags{Details: true},
+			addr: "2001:db8::1",
+			route: netlink.Route{
+				Dst:   host("2001:db8::1"),
+				Gw:    net.ParseIP("fe80::1"),
+				Table: 100,
+				MTU:   1280,
+				Type:  unix.RTN_UNICAST,
+			},
+			options: netlink.RouteGetOptions{SrcAddr: net.ParseIP("2001:db8::2"), Iif: "lo"},
+			want:    "2001:db8::1 from 2001:db8::2 via fe80::1 dev eth0 table 100 iif lo mtu 1280 type unicast\n",
+		},
+		{
+			name:  "no destination in reply",
+			addr:  "192.0.2.1",
+			route: netlink.Route{Src: net.ParseIP("192.0.2.5")},
+			want:  "192.0.2.1 dev eth0 src 192.0.2.5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := cmd{Opts: tt.opts, Out: &out}
+			cmd.showRouteGet(net.ParseIP(tt.addr), tt.route, "eth0", &tt.options)
+			if got := out.String(); got != tt.want {
+				t.Errorf("showRouteGet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestShowRoutes(t *testing.T) {
 	tests := []struct {
 		name       string
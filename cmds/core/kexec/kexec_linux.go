@@ -21,6 +21,7 @@
 //  -i, --initrd string        Use file as the kernel's initial ramdisk
 //  -l, --load                 Load the new kernel into the current kernel
 //  -L, --loadsyscall          Use the kexec load syscall (not file_load) (default true)
+//      --memmap stringArray   Add a memory range directive, SIZE@ADDR (usable) or SIZE$ADDR (reserved)
 //      --module stringArray   Load multiboot module with command line args (e.g --module="mod arg1")
 //  -p, --purgatory string     pick a purgatory, use '-p xyz' to get a list (default "default")
 //      --reuse-cmdline        Use the kernel command line from running system
@@ -60,6 +61,7 @@ type options struct {
 	initramfs     string
 	load          bool
 	loadSyscall   bool
+	memmap        []string
 	modules       []string
 	purgatory     string
 	reuseCmdline  bool
@@ -100,6 +102,8 @@ func (o *options) parseCmdline(args []string, f *flag.FlagSet) {
 	f.BoolVar(&o.loadSyscall, "loadsyscall", false, "Use the kexec_load syscall (not kexec_file_load)")
 	f.BoolVar(&o.loadSyscall, "L", false, "Use the kexec_load syscall (not kexec_file_load) (shorthand)")
 
+	f.Var((*unixflag.StringArray)(&o.memmap), "memmap", `Add a memory range directive, SIZE@ADDR (usable) or SIZE$ADDR (reserved); sizes take K, M or G suffixes`)
+
 	f.Var((*unixflag.StringArray)(&o.modules), "module", `Load multiboot module with command line args (e.g --module="mod arg1")`)
 
 	// This is broken out as it is almost never to be used. But it is valueable, nonetheless.
@@ -198,6 +202,18 @@ func run(args []string) error {
 
 	}
 
+	memmap, err := parseMemmaps(opts.memmap)
+	if err != nil {
+		return err
+	}
+	reserved := memmapReserved(memmap)
+	if len(reserved) > 0 && !opts.loadSyscall {
+		return errMemmapLoad
+	}
+	if len(memmap) > 0 {
+		newCmdline = strings.TrimSpace(newCmdline + " " + memmapCmdline(memmap))
+	}
+
 	if err := purgatory.Select(opts.purgatory); err != nil {
 		return err
 	}
@@ -241,11 +257,12 @@ func run(args []string) error {
 				}
 			}
 			image = &boot.LinuxImage{
-				Kernel:      uio.NewLazyFile(opts.kernelpath),
-				Initrd:      i,
-				Cmdline:     newCmdline,
-				LoadSyscall: opts.loadSyscall,
-				DTB:         dtb,
+				Kernel:         uio.NewLazyFile(opts.kernelpath),
+				Initrd:         i,
+				Cmdline:        newCmdline,
+				LoadSyscall:    opts.loadSyscall,
+				DTB:            dtb,
+				ReservedRanges: reserved,
 			}
 		}
 		if err := image.Load(boot.WithVerbose(opts.debug)); err != nil {
@@ -193,6 +193,16 @@ func TestParseCmdline(t *testing.T) {
 				kernelpath:   "/path/to/kernel",
 			},
 		},
+		{
+			name: "Test memmap",
+			args: []string{"kexec", "-L", "--memmap", "64M$0x10000000", "--memmap", "1G@4G", "-l", "/path/to/kernel"},
+			expected: options{
+				load:        true,
+				loadSyscall: true,
+				memmap:      []string{"64M$0x10000000", "1G@4G"},
+				kernelpath:  "/path/to/kernel",
+			},
+		},
 		{
 			name: "Test all set unix style flags",
 			args: []string{"kexec", "-delL", "/path/to/kernel"},
@@ -0,0 +1,126 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+var (
+	errMemmapSyntax  = errors.New("memmap must be SIZE@ADDR (usable) or SIZE$ADDR (reserved)")
+	errMemmapOverlap = errors.New("memmap ranges overlap")
+	errMemmapLoad    = errors.New("reserved memmap ranges can only be honoured with --loadsyscall")
+)
+
+// memmapSep maps the separator of a kernel memmap= directive to the type of
+// range it declares.
+var memmapSep = map[byte]kexec.RangeType{
+	'@': kexec.RangeRAM,
+	'$': kexec.RangeReserved,
+}
+
+// parseMemSize parses a kernel style memory size, i.e. a number with an
+// optional K, M or G suffix. The number may be given in hex with a 0x prefix.
+func parseMemSize(s string) (uint64, error) {
+	var shift uint
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			shift = 10
+		case 'm', 'M':
+			shift = 20
+		case 'g', 'G':
+			shift = 30
+		}
+		if shift != 0 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v > (1<<64-1)>>shift {
+		return 0, fmt.Errorf("%q: %w", s, strconv.ErrRange)
+	}
+	return v << shift, nil
+}
+
+// parseMemmap parses a single memmap= directive of the form SIZE@ADDR or
+// SIZE$ADDR, as understood by the Linux kernel.
+func parseMemmap(s string) (kexec.TypedRange, error) {
+	i := strings.IndexAny(s, "@$")
+	if i <= 0 || i == len(s)-1 {
+		return kexec.TypedRange{}, fmt.Errorf("%q: %w", s, errMemmapSyntax)
+	}
+	size, err := parseMemSize(s[:i])
+	if err != nil {
+		return kexec.TypedRange{}, fmt.Errorf("%q: bad size: %w", s, err)
+	}
+	addr, err := parseMemSize(s[i+1:])
+	if err != nil {
+		return kexec.TypedRange{}, fmt.Errorf("%q: bad address: %w", s, err)
+	}
+	if size == 0 {
+		return kexec.TypedRange{}, fmt.Errorf("%q: size must not be zero", s)
+	}
+	if addr+size < addr {
+		return kexec.TypedRange{}, fmt.Errorf("%q: range wraps around the address space", s)
+	}
+	return kexec.TypedRange{
+		Range: kexec.Range{Start: uintptr(addr), Size: uint(size)},
+		Type:  memmapSep[s[i]],
+	}, nil
+}
+
+// parseMemmaps parses all memmap directives and makes sure no two of them
+// describe overlapping memory.
+func parseMemmaps(specs []string) ([]kexec.TypedRange, error) {
+	var mm []kexec.TypedRange
+	for _, s := range specs {
+		r, err := parseMemmap(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range mm {
+			if r.Overlaps(o.Range) {
+				return nil, fmt.Errorf("%v and %v: %w", r, o, errMemmapOverlap)
+			}
+		}
+		mm = append(mm, r)
+	}
+	return mm, nil
+}
+
+// memmapReserved returns the reserved memmap ranges. They are handed to the
+// segment allocator, so that no kexec segment is placed in them.
+func memmapReserved(mm []kexec.TypedRange) kexec.Ranges {
+	var rs kexec.Ranges
+	for _, r := range mm {
+		if r.Type == kexec.RangeReserved {
+			rs = append(rs, r.Range)
+		}
+	}
+	return rs
+}
+
+// memmapCmdline returns the memmap= kernel parameters describing mm.
+func memmapCmdline(mm []kexec.TypedRange) string {
+	var args []string
+	for _, r := range mm {
+		sep := "@"
+		if r.Type == kexec.RangeReserved {
+			sep = "$"
+		}
+		args = append(args, fmt.Sprintf("memmap=%#x%s%#x", r.Size, sep, r.Start))
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+func TestParseMemmap(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want kexec.TypedRange
+		err  bool
+	}{
+		{in: "64M$0x10000000", want: kexec.TypedRange{Range: kexec.Range{Start: 0x10000000, Size: 64 << 20}, Type: kexec.RangeReserved}},
+		{in: "1G@4G", want: kexec.TypedRange{Range: kexec.Range{Start: 4 << 30, Size: 1 << 30}, Type: kexec.RangeRAM}},
+		{in: "4096@0x1000", want: kexec.TypedRange{Range: kexec.Range{Start: 0x1000, Size: 4096}, Type: kexec.RangeRAM}},
+		{in: "16k$1m", want: kexec.TypedRange{Range: kexec.Range{Start: 1 << 20, Size: 16 << 10}, Type: kexec.RangeReserved}},
+		{in: "64M", err: true},
+		{in: "@0x1000", err: true},
+		{in: "64M@", err: true},
+		{in: "0@0x1000", err: true},
+		{in: "64X@0x1000", err: true},
+		{in: "64M#0x1000", err: true},
+		{in: "0x10@0xffffffffffffffff", err: true},
+	} {
+		got, err := parseMemmap(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseMemmap(%q) = %v, want error %t", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("parseMemmap(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMemmapsOverlap(t *testing.T) {
+	for _, tt := range []struct {
+		specs []string
+		err   error
+	}{
+		{specs: []string{"1M$1M", "1M@2M"}},
+		{specs: []string{"1M$1M", "1M@0x1fffff"}, err: errMemmapOverlap},
+		{specs: []string{"4M@0", "1M$1M"}, err: errMemmapOverlap},
+		{specs: []string{"1M$1M", "1M$1M"}, err: errMemmapOverlap},
+		{specs: []string{"1M$1M", "bogus"}, err: errMemmapSyntax},
+	} {
+		if _, err := parseMemmaps(tt.specs); !errors.Is(err, tt.err) {
+			t.Errorf("parseMemmaps(%q) = %v, want %v", tt.specs, err, tt.err)
+		}
+	}
+}
+
+func TestMemmapCmdline(t *testing.T) {
+	mm, err := parseMemmaps([]string{"64M$0x10000000", "1G@4G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := memmapCmdline(mm), "memmap=0x4000000$0x10000000 memmap=0x40000000@0x100000000"; got != want {
+		t.Errorf("memmapCmdline() = %q, want %q", got, want)
+	}
+	want := kexec.Ranges{{Start: 0x10000000, Size: 64 << 20}}
+	if got := memmapReserved(mm); !reflect.DeepEqual(got, want) {
+		t.Errorf("memmapReserved() = %v, want %v", got, want)
+	}
+}
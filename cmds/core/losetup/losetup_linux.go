@@ -6,24 +6,190 @@
 //
 // Synopsis:
 //
-//	losetup [-Ad] FILE
-//	losetup [-Ad] DEV FILE
+//	losetup [-Ad] [--direct-io=on|off] FILE
+//	losetup [-Ad] [--direct-io=on|off] DEV FILE
+//	losetup --direct-io=on|off DEV
+//	losetup -D [-f]
 //
 // Options:
 //
 //	-A: pick any device
 //	-d: detach the device
+//	-D: detach all attached devices, except those in use
+//	-f: with -D, detach devices that are in use, too
+//	--direct-io: turn direct I/O on or off, for a device being attached
+//	             or one that already is. With direct I/O, the backing file
+//	             is read and written bypassing the page cache. Its offset
+//	             and size must be multiples of the device's logical block
+//	             size.
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/mount/loop"
 )
 
-var detach = flag.Bool("d", false, "Detach the device")
+var (
+	detach    = flag.Bool("d", false, "Detach the device")
+	detachAll = flag.Bool("D", false, "Detach all attached devices")
+	force     = flag.Bool("f", false, "With -D, also detach devices that are mounted or held by another device")
+	directIO  = flag.String("direct-io", "", "Turn direct I/O on or off (on|off)")
+)
+
+var errDirectIO = errors.New("--direct-io must be on or off")
+
+// loops describes where to find the loop devices and how to detach them.
+type loops struct {
+	sysBlock string
+	mounts   string
+	dev      string
+	clear    func(devicename string) error
+
+	setDirectIO func(devicename string, on bool) error
+}
+
+var system = loops{
+	sysBlock:    "/sys/block",
+	mounts:      "/proc/self/mounts",
+	dev:         "/dev",
+	clear:       loop.ClearFile,
+	setDirectIO: loop.SetDirectIO,
+}
+
+// parseOnOff parses the value of --direct-io.
+func parseOnOff(s string) (bool, error) {
+	switch s {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q: %w", s, errDirectIO)
+}
+
+// readSysInt reads a number from a sysfs attribute of loop device name.
+func (l loops) readSysInt(name, attr string) (int64, error) {
+	b, err := os.ReadFile(filepath.Join(l.sysBlock, name, attr))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// checkDirectIO returns an error if the backing file of loop device name is
+// not aligned as direct I/O needs it to be: its offset and the size the
+// device exposes must be multiples of the device's logical block size. The
+// kernel only says EINVAL.
+func (l loops) checkDirectIO(name string) error {
+	bs, err := l.readSysInt(name, "queue/logical_block_size")
+	if err != nil {
+		return err
+	}
+	offset, err := l.readSysInt(name, "loop/offset")
+	if err != nil {
+		return err
+	}
+	if offset%bs != 0 {
+		return fmt.Errorf("backing file offset %d is not a multiple of the %d byte block size", offset, bs)
+	}
+	size, err := l.readSysInt(name, "loop/sizelimit")
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		b, err := os.ReadFile(filepath.Join(l.sysBlock, name, "loop", "backing_file"))
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(strings.TrimSpace(string(b)))
+		if err != nil {
+			return err
+		}
+		size = fi.Size() - offset
+	}
+	if size%bs != 0 {
+		return fmt.Errorf("backing file size %d is not a multiple of the %d byte block size", size, bs)
+	}
+	return nil
+}
+
+// directIO turns direct I/O on or off for loop device devicename. Before
+// turning it on, it checks that the backing file is suitably aligned.
+func (l loops) directIO(devicename string, on bool) error {
+	if on {
+		if err := l.checkDirectIO(filepath.Base(devicename)); err != nil {
+			return fmt.Errorf("%s: can't use direct I/O: %w", devicename, err)
+		}
+	}
+	if err := l.setDirectIO(devicename, on); err != nil {
+		return fmt.Errorf("%s: setting direct I/O: %w", devicename, err)
+	}
+	return nil
+}
+
+// isDevice reports whether name is a device node rather than a file to
+// attach.
+func isDevice(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.Mode()&os.ModeDevice != 0
+}
+
+// inUse returns the reason the loop device name can not be detached safely,
+// or "" if it can.
+func (l loops) inUse(name string, mounts string) string {
+	devicename := filepath.Join(l.dev, name)
+	for _, line := range strings.Split(mounts, "\n") {
+		if f := strings.Fields(line); len(f) > 1 && f[0] == devicename {
+			return "mounted on " + f[1]
+		}
+	}
+	if holders, _ := os.ReadDir(filepath.Join(l.sysBlock, name, "holders")); len(holders) > 0 {
+		return "held by " + holders[0].Name()
+	}
+	return ""
+}
+
+// detachAll detaches every attached loop device. Devices that are mounted
+// or held by another block device are skipped, unless force is set. It
+// keeps going after failures and returns all of them.
+func (l loops) detachAll(w io.Writer, force bool) error {
+	names, err := filepath.Glob(filepath.Join(l.sysBlock, "loop*"))
+	if err != nil {
+		return err
+	}
+	mounts, err := os.ReadFile(l.mounts)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, n := range names {
+		name := filepath.Base(n)
+		// backing_file only exists while a file is attached.
+		if _, err := os.Stat(filepath.Join(n, "loop", "backing_file")); err != nil {
+			continue
+		}
+		devicename := filepath.Join(l.dev, name)
+		if reason := l.inUse(name, string(mounts)); reason != "" && !force {
+			errs = errors.Join(errs, fmt.Errorf("%s: not detached, %s", devicename, reason))
+			continue
+		}
+		if err := l.clear(devicename); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", devicename, err))
+			continue
+		}
+		fmt.Fprintln(w, "Detached", devicename)
+	}
+	return errs
+}
 
 func main() {
 	var (
@@ -33,6 +199,28 @@ func main() {
 
 	flag.Parse()
 	args := flag.Args()
+	var dio bool
+	if *directIO != "" {
+		if dio, err = parseOnOff(*directIO); err != nil {
+			log.Fatal(err)
+		}
+		if len(args) == 1 && isDevice(args[0]) {
+			if err := system.directIO(args[0], dio); err != nil {
+				log.Fatal(err)
+			}
+			os.Exit(0)
+		}
+	}
+	if *detachAll {
+		if len(args) != 0 {
+			flag.Usage()
+			log.Fatal("Syntax Error")
+		}
+		if err := system.detachAll(os.Stdout, *force); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
 	if *detach {
 		if len(args) == 1 {
 			if err := loop.ClearFile(args[0]); err != nil {
@@ -62,6 +250,11 @@ func main() {
 	if err := loop.SetFile(devicename, filename); err != nil {
 		log.Fatal("Could not set loop device:", err)
 	}
+	if *directIO != "" {
+		if err := system.directIO(devicename, dio); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	log.Printf("Attached %s to %s", devicename, filename)
 }
@@ -0,0 +1,223 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeLoops builds a sysfs with loop0 to loop4:
+//
+//	loop0: attached
+//	loop1: not attached
+//	loop2: attached and mounted
+//	loop3: attached and held by dm-0
+//	loop4: attached, but clearing it fails
+func fakeLoops(t *testing.T) (loops, *[]string) {
+	t.Helper()
+	d := t.TempDir()
+	sys := filepath.Join(d, "sys", "block")
+	for _, l := range []struct {
+		name     string
+		attached bool
+		holder   string
+	}{
+		{name: "loop0", attached: true},
+		{name: "loop1"},
+		{name: "loop2", attached: true},
+		{name: "loop3", attached: true, holder: "dm-0"},
+		{name: "loop4", attached: true},
+	} {
+		if err := os.MkdirAll(filepath.Join(sys, l.name, "loop"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(sys, l.name, "holders"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if l.attached {
+			if err := os.WriteFile(filepath.Join(sys, l.name, "loop", "backing_file"), []byte("/tmp/"+l.name+".img\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if l.holder != "" {
+			if err := os.Mkdir(filepath.Join(sys, l.name, "holders", l.holder), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Not a loop device.
+	if err := os.MkdirAll(filepath.Join(sys, "sda"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mounts := filepath.Join(d, "mounts")
+	if err := os.WriteFile(mounts, []byte("proc /proc proc rw 0 0\n/dev/loop2 /mnt ext4 ro 0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cleared []string
+	return loops{
+		sysBlock: sys,
+		mounts:   mounts,
+		dev:      "/dev",
+		clear: func(devicename string) error {
+			if devicename == "/dev/loop4" {
+				return errors.New("device or resource busy")
+			}
+			cleared = append(cleared, devicename)
+			return nil
+		},
+	}, &cleared
+}
+
+func TestDetachAll(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		force   bool
+		cleared []string
+		errs    []string
+	}{
+		{
+			name:    "skip devices in use",
+			cleared: []string{"/dev/loop0"},
+			errs: []string{
+				"/dev/loop2: not detached, mounted on /mnt",
+				"/dev/loop3: not detached, held by dm-0",
+				"/dev/loop4: device or resource busy",
+			},
+		},
+		{
+			name:    "force",
+			force:   true,
+			cleared: []string{"/dev/loop0", "/dev/loop2", "/dev/loop3"},
+			errs:    []string{"/dev/loop4: device or resource busy"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, cleared := fakeLoops(t)
+			var out bytes.Buffer
+			err := l.detachAll(&out, tt.force)
+			if !slices.Equal(*cleared, tt.cleared) {
+				t.Errorf("detached %q, want %q", *cleared, tt.cleared)
+			}
+			if err == nil {
+				t.Fatal("detachAll() = nil, want errors")
+			}
+			if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, tt.errs) {
+				t.Errorf("detachAll() errors = %q, want %q", got, tt.errs)
+			}
+			for _, dev := range tt.cleared {
+				if !strings.Contains(out.String(), "Detached "+dev+"\n") {
+					t.Errorf("output %q does not report %s", out.String(), dev)
+				}
+			}
+		})
+	}
+}
+
+func TestDetachAllNothingAttached(t *testing.T) {
+	l := loops{
+		sysBlock: t.TempDir(),
+		mounts:   filepath.Join(t.TempDir(), "mounts"),
+		dev:      "/dev",
+		clear: func(devicename string) error {
+			t.Errorf("clear(%q) called, want no calls", devicename)
+			return nil
+		},
+	}
+	if err := os.WriteFile(l.mounts, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.detachAll(&bytes.Buffer{}, false); err != nil {
+		t.Errorf("detachAll() = %v, want nil", err)
+	}
+}
+
+func TestDirectIO(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		on        bool
+		size      int64
+		offset    string
+		sizelimit string
+		set       bool
+		err       string
+	}{
+		{name: "aligned", on: true, size: 4096, offset: "0", sizelimit: "0", set: true},
+		{name: "aligned offset", on: true, size: 4096, offset: "1024", sizelimit: "0", set: true},
+		{name: "aligned size limit", on: true, size: 5000, offset: "512", sizelimit: "2048", set: true},
+		{name: "off skips checks", size: 1000, offset: "3", sizelimit: "0", set: true},
+		{name: "unaligned offset", on: true, size: 4096, offset: "100", sizelimit: "0", err: "offset 100 is not a multiple of the 512 byte block size"},
+		{name: "unaligned size", on: true, size: 4000, offset: "0", sizelimit: "0", err: "size 4000 is not a multiple of the 512 byte block size"},
+		{name: "unaligned size limit", on: true, size: 4096, offset: "0", sizelimit: "1000", err: "size 1000 is not a multiple"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := t.TempDir()
+			backing := filepath.Join(d, "disk.img")
+			if err := os.WriteFile(backing, make([]byte, tt.size), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			sys := filepath.Join(d, "sys")
+			for attr, v := range map[string]string{
+				"queue/logical_block_size": "512",
+				"loop/offset":              tt.offset,
+				"loop/sizelimit":           tt.sizelimit,
+				"loop/backing_file":        backing,
+			} {
+				p := filepath.Join(sys, "loop0", attr)
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(v+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var set bool
+			l := loops{
+				sysBlock: sys,
+				setDirectIO: func(devicename string, on bool) error {
+					if devicename != "/dev/loop0" || on != tt.on {
+						t.Errorf("setDirectIO(%q, %v), want (%q, %v)", devicename, on, "/dev/loop0", tt.on)
+					}
+					set = true
+					return nil
+				},
+			}
+			err := l.directIO("/dev/loop0", tt.on)
+			if tt.err == "" && err != nil {
+				t.Fatalf("directIO() = %v, want nil", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("directIO() = %v, want error containing %q", err, tt.err)
+			}
+			if set != tt.set {
+				t.Errorf("direct I/O set: %v, want %v", set, tt.set)
+			}
+		})
+	}
+}
+
+func TestParseOnOff(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want bool
+		err  error
+	}{
+		{in: "on", want: true},
+		{in: "off", want: false},
+		{in: "1", err: errDirectIO},
+	} {
+		got, err := parseOnOff(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseOnOff(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -11,13 +11,21 @@
 // Options:
 //
 //	-a: show hidden files
+//	-A: show hidden files, but not the . entry of the directory listed
 //	-h: show human-readable sizes
 //	-d: show directories but not their contents
 //	-F: append indicator (, one of */=>@|) to entries
 //	-l: long form
 //	-Q: quoted
+//	-r: reverse the sort order
 //	-R: equivalent to findutil's find
-//	-s: sort by size
+//	-S: sort by size
+//	--group-directories-first: list directories before other files
+//	--ignore: do not list entries whose name matches this shell pattern;
+//	          may be repeated
+//	--hide: like --ignore, but overridden by -a and -A
+//	--full-time: like -l, with the full modification time, down to the
+//	             nanosecond, and its time zone
 //
 // Bugs:
 //
@@ -33,6 +41,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/u-root/u-root/pkg/ls"
@@ -42,6 +51,7 @@ import (
 type cmd struct {
 	w         io.Writer
 	all       bool
+	almostAll bool
 	human     bool
 	directory bool
 	long      bool
@@ -49,6 +59,11 @@ type cmd struct {
 	recurse   bool
 	classify  bool
 	size      bool
+	reverse   bool
+	dirsFirst bool
+	fullTime  bool
+	ignore    unixflag.StringArray
+	hide      unixflag.StringArray
 }
 
 // file describes a file, its name, attributes, and the error
@@ -102,6 +117,13 @@ func (c cmd) listName(stringer ls.Stringer, d string, prefix bool) error {
 			f.err = err
 		}
 
+		if path != d && c.omitted(filepath.Base(path)) {
+			if osfi != nil && osfi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		files = append(files, f)
 
 		if err != nil {
@@ -119,11 +141,13 @@ func (c cmd) listName(stringer ls.Stringer, d string, prefix bool) error {
 		return nil
 	})
 
-	if c.size {
-		sort.SliceStable(files, func(i, j int) bool {
-			return files[i].lsfi.Size > files[j].lsfi.Size
-		})
+	// The directory being listed always comes first; only order what is
+	// in it.
+	entries := files
+	if len(entries) > 0 && entries[0].path == d {
+		entries = entries[1:]
 	}
+	c.order(entries)
 
 	for _, f := range files {
 		if f.err != nil {
@@ -158,6 +182,55 @@ func (c cmd) listName(stringer ls.Stringer, d string, prefix bool) error {
 	return nil
 }
 
+// order sorts files as requested. Walk returns them sorted by name, -S sorts
+// them by size, and -r reverses whichever order that is. With
+// --group-directories-first, directories are then moved ahead of everything
+// else, keeping that order within each group.
+func (c cmd) order(files []file) {
+	if c.size {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].lsfi.Size > files[j].lsfi.Size
+		})
+	}
+	if c.reverse {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+		}
+	}
+	if c.dirsFirst {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].lsfi.Mode.IsDir() && !files[j].lsfi.Mode.IsDir()
+		})
+	}
+}
+
+// omitted reports whether --ignore, or --hide without -a or -A, leaves name
+// out of the listing.
+func (c cmd) omitted(name string) bool {
+	patterns := c.ignore
+	if !c.all && !c.almostAll {
+		patterns = append(patterns[:len(patterns):len(patterns)], c.hide...)
+	}
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// shown reports whether a file called name is listed: hidden files need -a
+// or -A, and the . entry needs -a.
+func (c cmd) shown(name string) bool {
+	if c.all {
+		return true
+	}
+	if c.almostAll {
+		return name != "."
+	}
+	return !strings.HasPrefix(name, ".")
+}
+
 func indicator(fi ls.FileInfo) string {
 	if fi.Mode.IsRegular() && fi.Mode&0o111 != 0 {
 		return "*"
@@ -192,7 +265,11 @@ func (c cmd) list(names []string) error {
 		s = ls.QuotedStringer{}
 	}
 	if c.long {
-		s = ls.LongStringer{Human: c.human, Name: s}
+		l := ls.LongStringer{Human: c.human, Name: s}
+		if c.fullTime {
+			l.TimeFormat = ls.FullISOTimeFormat
+		}
+		s = l
 	}
 	// Is a name a directory? If so, list it in its own section.
 	prefix := len(names) > 1
@@ -210,6 +287,7 @@ func run(w io.Writer, args []string) error {
 	var c cmd
 	f := flag.NewFlagSet(args[0], flag.ExitOnError)
 	f.BoolVar(&c.all, "a", false, "show hidden files")
+	f.BoolVar(&c.almostAll, "A", false, "show hidden files, except for .")
 	f.BoolVar(&c.human, "h", false, "human readable sizes")
 	f.BoolVar(&c.directory, "d", false, "list directories but not their contents")
 	f.BoolVar(&c.long, "l", false, "long form")
@@ -217,8 +295,21 @@ func run(w io.Writer, args []string) error {
 	f.BoolVar(&c.recurse, "R", false, "equivalent to findutil's find")
 	f.BoolVar(&c.classify, "F", false, "append indicator (, one of */=>@|) to entries")
 	f.BoolVar(&c.size, "S", false, "sort by size")
+	f.BoolVar(&c.reverse, "r", false, "reverse the sort order")
+	f.BoolVar(&c.dirsFirst, "group-directories-first", false, "list directories before other files")
+	f.BoolVar(&c.fullTime, "full-time", false, "like -l, with the full modification time")
+	f.Var(&c.ignore, "ignore", "do not list entries matching this shell pattern")
+	f.Var(&c.hide, "hide", "do not list entries matching this shell pattern, unless -a or -A is given")
 	c.w = w
 	f.Parse(unixflag.ArgsToGoArgs(args[1:]))
+	if c.fullTime {
+		c.long = true
+	}
+	for _, p := range append(c.ignore, c.hide...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
 	return c.list(f.Args())
 }
 
@@ -9,7 +9,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"strings"
 
 	"github.com/u-root/u-root/pkg/ls"
 )
@@ -21,8 +20,8 @@ func (c cmd) printFile(stringer ls.Stringer, f file) {
 		fmt.Fprintln(c.w, f.err)
 		return
 	}
-	// Hide .files unless -a was given
-	if c.all || !strings.HasPrefix(f.lsfi.Name, ".") {
+	// Hide .files unless -a or -A was given
+	if c.shown(f.lsfi.Name) {
 		// Unless they said -p, we always print the full path
 		if !*final {
 			f.lsfi.Name = f.path
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/u-root/u-root/pkg/ls"
 	"golang.org/x/sys/unix"
@@ -250,3 +251,124 @@ func TestNotExist(t *testing.T) {
 		t.Fatalf("ls of bad name: %q does not contain %q or %q", b.String(), eexist, enoent)
 	}
 }
+
+func TestGroupDirectoriesFirst(t *testing.T) {
+	d := t.TempDir()
+	for _, v := range []string{"b", "e"} {
+		if err := os.Mkdir(filepath.Join(d, v), 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, size := range map[string]int{"a": 3, "c": 1, "f": 2} {
+		if err := os.WriteFile(filepath.Join(d, name), bytes.Repeat([]byte{'x'}, size), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "name", args: []string{"--group-directories-first"}, want: "b\ne\na\nc\nf\n"},
+		{name: "reverse", args: []string{"-r", "--group-directories-first"}, want: "e\nb\nf\nc\na\n"},
+		{name: "size", args: []string{"-F", "-S", "--group-directories-first"}, want: "b/\ne/\na\nf\nc\n"},
+		{name: "size reverse", args: []string{"-F", "-Sr", "--group-directories-first"}, want: "e/\nb/\nc\nf\na\n"},
+		{name: "reverse only", args: []string{"-r"}, want: "f\ne\nc\nb\na\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			args := append(append([]string{"ls"}, tt.args...), d)
+			if err := run(&b, args); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("ls %v = %q, want %q", tt.args, b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreHide(t *testing.T) {
+	d := t.TempDir()
+	if err := os.Mkdir(filepath.Join(d, "build"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.o", "b.go", ".hidden", ".cache.o", filepath.Join("build", "x.o"), filepath.Join("build", "y")} {
+		if err := os.WriteFile(filepath.Join(d, name), nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "none", want: "a.o\nb.go\nbuild\n"},
+		{name: "ignore", args: []string{"--ignore=*.o"}, want: "b.go\nbuild\n"},
+		{name: "ignore repeated", args: []string{"--ignore=*.o", "--ignore", "build"}, want: "b.go\n"},
+		{name: "hide", args: []string{"--hide=*.o"}, want: "b.go\nbuild\n"},
+		{name: "hide with -a", args: []string{"-a", "--hide=*.o"}, want: ".\n.cache.o\n.hidden\na.o\nb.go\nbuild\n"},
+		{name: "ignore with -a", args: []string{"-a", "--ignore=*.o"}, want: ".\n.hidden\nb.go\nbuild\n"},
+		{name: "almost all", args: []string{"-A"}, want: ".cache.o\n.hidden\na.o\nb.go\nbuild\n"},
+		{name: "hide with -A", args: []string{"-A", "--hide=.*"}, want: ".cache.o\n.hidden\na.o\nb.go\nbuild\n"},
+		{name: "ignore with -A", args: []string{"-A", "--ignore=.*"}, want: "a.o\nb.go\nbuild\n"},
+		// -R prints full paths, which don't start with a dot.
+		{name: "recursive", args: []string{"-R", "--ignore=*.o"}, want: d + "\n" + filepath.Join(d, ".hidden") + "\n" + filepath.Join(d, "b.go") + "\n" + filepath.Join(d, "build") + "\n" + filepath.Join(d, "build", "y") + "\n"},
+		{name: "recursive ignore directory", args: []string{"-R", "--ignore=build"}, want: d + "\n" + filepath.Join(d, ".cache.o") + "\n" + filepath.Join(d, ".hidden") + "\n" + filepath.Join(d, "a.o") + "\n" + filepath.Join(d, "b.go") + "\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			args := append(append([]string{"ls"}, tt.args...), d)
+			if err := run(&b, args); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("ls %v = %q, want %q", tt.args, b.String(), tt.want)
+			}
+		})
+	}
+
+	if err := run(&bytes.Buffer{}, []string{"ls", "--ignore=[", d}); err == nil {
+		t.Errorf("ls --ignore=[ succeeded, want an error")
+	}
+}
+
+func TestFullTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	d := t.TempDir()
+	base := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	for name, ns := range map[string]int{"a": 5, "b": 123456789, "c": 0} {
+		f := filepath.Join(d, name)
+		if err := os.WriteFile(f, nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(ns))
+		if err := os.Chtimes(f, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := run(&b, []string{"ls", "--full-time", d}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	want := []string{
+		"2024-03-04 05:06:07.000000005 +0000 a",
+		"2024-03-04 05:06:07.123456789 +0000 b",
+		"2024-03-04 05:06:07.000000000 +0000 c",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("ls --full-time = %q, want %d lines", b.String(), len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(lines[i], "-rw") || !strings.HasSuffix(lines[i], " "+w) {
+			t.Errorf("line %d = %q, want a long listing ending in %q", i, lines[i], w)
+		}
+	}
+}
@@ -8,7 +8,6 @@ package main
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/u-root/u-root/pkg/ls"
 )
@@ -18,8 +17,8 @@ func (c cmd) printFile(stringer ls.Stringer, f file) {
 		fmt.Fprintln(c.w, f.err)
 		return
 	}
-	// Hide .files unless -a was given
-	if c.all || !strings.HasPrefix(f.lsfi.Name, ".") {
+	// Hide .files unless -a or -A was given
+	if c.shown(f.lsfi.Name) {
 		// Print the file in the proper format.
 		if c.classify {
 			f.lsfi.Name = f.lsfi.Name + indicator(f.lsfi)
@@ -6,12 +6,21 @@
 //
 // Synopsis:
 //
-//	lsmod
+//	lsmod [--sig] [--taint]
 //
 // Description:
 //
 //	lsmod is a clone of lsmod(8)
 //
+//	--sig adds a Signed column, read from /sys/module/NAME/signature where
+//	the kernel has it. Otherwise a module which set the E (unsigned
+//	module) taint flag is shown as not signed.
+//
+//	--taint adds a Taint column with the taint flags a module set, read
+//	from /sys/module/NAME/taint, or "none" if it set none.
+//
+//	Values lsmod can't find out are shown as "-".
+//
 // Author:
 //
 //	Roland Kammerer <dev.rck@gmail.com>
@@ -19,27 +28,93 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-func run(stdout io.Writer, path string) error {
+const sysModule = "/sys/module"
+
+type params struct {
+	sig   bool
+	taint bool
+	// sysModule is the directory with a subdirectory for every module.
+	sysModule string
+}
+
+// readSysModule returns the trimmed contents of a module's sysfs file, and
+// false if it can't be read.
+func readSysModule(dir, name, file string) (string, bool) {
+	b, err := os.ReadFile(filepath.Join(dir, name, file))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
+
+// taint returns the taint flags of a module, "none" if it has none and "-"
+// if they are unknown.
+func taint(dir, name string) string {
+	t, ok := readSysModule(dir, name, "taint")
+	switch {
+	case !ok:
+		return "-"
+	case t == "":
+		return "none"
+	}
+	return t
+}
+
+// signed returns whether a module is signed: "yes", "no" or "-" if that is
+// unknown.
+func signed(dir, name string) string {
+	if s, ok := readSysModule(dir, name, "signature"); ok && s != "" {
+		switch s {
+		case "0", "no", "N":
+			return "no"
+		}
+		return "yes"
+	}
+	// E is the taint flag for loading an unsigned module. Without it,
+	// the module was either signed or signatures were not checked.
+	if t, ok := readSysModule(dir, name, "taint"); ok && strings.Contains(t, "E") {
+		return "no"
+	}
+	return "-"
+}
+
+func run(stdout io.Writer, path string, p params) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	fmt.Fprintln(stdout, "Module                  Size  Used by")
+	header := "Module                  Size  "
+	if p.sig {
+		header += "Signed "
+	}
+	if p.taint {
+		header += "Taint "
+	}
+	fmt.Fprintln(stdout, header+"Used by")
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		s := strings.Split(scanner.Text(), " ")
 		name, size, used, usedBy := s[0], s[1], s[2], s[3]
-		final := fmt.Sprintf("%-19s %8s  %s", name, size, used)
+		final := fmt.Sprintf("%-19s %8s  ", name, size)
+		if p.sig {
+			final += fmt.Sprintf("%-6s ", signed(p.sysModule, name))
+		}
+		if p.taint {
+			final += fmt.Sprintf("%-5s ", taint(p.sysModule, name))
+		}
+		final += used
 		if usedBy != "-" {
 			usedBy = usedBy[:len(usedBy)-1]
 			final += fmt.Sprintf(" %s", usedBy)
@@ -51,7 +126,11 @@ func run(stdout io.Writer, path string) error {
 }
 
 func main() {
-	if err := run(os.Stdout, "/proc/modules"); err != nil {
+	p := params{sysModule: sysModule}
+	flag.BoolVar(&p.sig, "sig", false, "Show whether each module is signed")
+	flag.BoolVar(&p.taint, "taint", false, "Show the taint flags each module set")
+	flag.Parse()
+	if err := run(os.Stdout, "/proc/modules", p); err != nil {
 		log.Fatal(err)
 	}
 }
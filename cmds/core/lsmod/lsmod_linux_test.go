@@ -8,20 +8,21 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 func TestRun(t *testing.T) {
 	t.Run("file don't exists", func(t *testing.T) {
-		err := run(nil, "filenotexists")
+		err := run(nil, "filenotexists", params{})
 		if !errors.Is(err, os.ErrNotExist) {
 			t.Errorf("expected %v, got %v", os.ErrNotExist, err)
 		}
 	})
 	t.Run("lsmod", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
-		err := run(stdout, "./testdata/modules.txt")
+		err := run(stdout, "./testdata/modules.txt", params{})
 		if err != nil {
 			t.Fatalf("expected nil, got %v", err)
 		}
@@ -31,3 +32,94 @@ func TestRun(t *testing.T) {
 		}
 	})
 }
+
+func TestSigTaint(t *testing.T) {
+	// A fake /sys/module for the modules in testdata/modules.txt.
+	sysModule := t.TempDir()
+	for _, f := range []struct {
+		module, file, content string
+	}{
+		{"nft_chain_nat", "taint", "\n"},
+		{"overlay", "taint", "OE\n"},
+		{"xt_tcpudp", "signature", "1\n"},
+		{"xt_tcpudp", "taint", "\n"},
+		{"xt_multiport", "signature", "0\n"},
+		{"xt_multiport", "taint", "\n"},
+		{"nf_defrag_ipv6", "taint", "O\n"},
+	} {
+		dir := filepath.Join(sysModule, f.module)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.file), []byte(f.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		p    params
+		want string
+	}{
+		{
+			name: "default",
+			want: `Module                  Size  Used by
+nft_chain_nat          12288  2
+overlay               192512  0
+xt_tcpudp              20480  0
+xt_nat                 12288  0
+xt_multiport           16384  0
+nf_defrag_ipv6         24576  1 nf_conntrack
+`,
+		},
+		{
+			name: "sig and taint",
+			p:    params{sig: true, taint: true},
+			want: `Module                  Size  Signed Taint Used by
+nft_chain_nat          12288  -      none  2
+overlay               192512  no     OE    0
+xt_tcpudp              20480  yes    none  0
+xt_nat                 12288  -      -     0
+xt_multiport           16384  no     none  0
+nf_defrag_ipv6         24576  -      O     1 nf_conntrack
+`,
+		},
+		{
+			name: "taint",
+			p:    params{taint: true},
+			want: `Module                  Size  Taint Used by
+nft_chain_nat          12288  none  2
+overlay               192512  OE    0
+xt_tcpudp              20480  none  0
+xt_nat                 12288  -     0
+xt_multiport           16384  none  0
+nf_defrag_ipv6         24576  O     1 nf_conntrack
+`,
+		},
+		{
+			name: "no sysfs",
+			p:    params{sig: true, taint: true, sysModule: filepath.Join(sysModule, "nonexistent")},
+			want: `Module                  Size  Signed Taint Used by
+nft_chain_nat          12288  -      -     2
+overlay               192512  -      -     0
+xt_tcpudp              20480  -      -     0
+xt_nat                 12288  -      -     0
+xt_multiport           16384  -      -     0
+nf_defrag_ipv6         24576  -      -     1 nf_conntrack
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.p.sysModule == "" {
+				tt.p.sysModule = sysModule
+			}
+			stdout := &bytes.Buffer{}
+			if err := run(stdout, "./testdata/modules.txt", tt.p); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
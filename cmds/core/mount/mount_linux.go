@@ -6,11 +6,26 @@
 //
 // Synopsis:
 //
-//	mount [-r] [-o options] [-t FSTYPE] DEV PATH
+//	mount [-r] [-o options] [-t FSTYPE[,FSTYPE...]] [--retry N [--retry-delay D]] DEV PATH
 //
 // Options:
 //
 //	-r: read only
+//	-t: filesystem type. Given a comma separated list, the types are
+//	    tried in order until one mounts; auto tries every type the kernel
+//	    lists in /proc/filesystems that needs a device. Only failures that
+//	    mean the type is wrong, EINVAL and ENODEV, move on to the next type.
+//	--retry: retry the mount up to N more times while it fails with ENOENT
+//	    or ENODEV, or no device has the LABEL= or UUID= asked for, for
+//	    devices that are still showing up, e.g. iSCSI or NBD disks in early
+//	    boot.
+//	--retry-delay: time to wait between retries (default 1s)
+//
+// Description:
+//
+//	DEV may be LABEL=label or UUID=uuid, as in fstab, to mount the block
+//	device with the filesystem of that label or UUID, as blkid finds them
+//	in the superblocks of vfat, ext4 and xfs filesystems.
 package main
 
 import (
@@ -21,8 +36,10 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/mount/loop"
 	"golang.org/x/sys/unix"
 )
@@ -30,6 +47,7 @@ import (
 var (
 	errUsage     = errors.New("usage")
 	errMountPath = errors.New("can not read mount path")
+	errNoDevice  = errors.New("no block device with a filesystem of")
 )
 
 type mountOptions []string
@@ -53,6 +71,15 @@ type cmd struct {
 	mountsPath      []string
 	options         mountOptions
 	ro              bool
+	retries         int
+	retryDelay      time.Duration
+	// mount mounts a filesystem. It can be replaced in tests.
+	mount func(dev, path, fsType, data string, flags uintptr) error
+	// sleep waits between retries. It can be replaced in tests.
+	sleep func(time.Duration)
+	// blockDevices lists the block devices to look for LABEL= and UUID=
+	// in. It can be replaced in tests.
+	blockDevices func() (block.BlockDevices, error)
 }
 
 func command(stdout, stderr io.Writer, ro bool, fsType string, opts mountOptions) *cmd {
@@ -68,7 +95,69 @@ func command(stdout, stderr io.Writer, ro bool, fsType string, opts mountOptions
 		ro:              ro,
 		options:         opts,
 		fsType:          fsType,
+		retryDelay:      time.Second,
+		sleep:           time.Sleep,
+		mount: func(dev, path, fsType, data string, flags uintptr) error {
+			_, err := mount.Mount(dev, path, fsType, data, flags)
+			return err
+		},
+		blockDevices: block.GetBlockDevices,
+	}
+}
+
+// errnoHints explains the errors mount(2) commonly fails with, which on their
+// own tend to be cryptic.
+var errnoHints = map[unix.Errno]string{
+	unix.EACCES:  "permission denied or the device is write-protected; try -r",
+	unix.EBUSY:   "already mounted or target busy",
+	unix.EINVAL:  "bad superblock, wrong fs type or bad option",
+	unix.ELOOP:   "too many symbolic links in the path",
+	unix.EMFILE:  "no free loop device or dummy block device",
+	unix.ENODEV:  "filesystem type not supported by kernel",
+	unix.ENOENT:  "mount point or device does not exist",
+	unix.ENOTBLK: "source is not a block device; try -o loop for image files",
+	unix.ENOTDIR: "mount point is not a directory",
+	unix.ENXIO:   "device does not exist or is not ready",
+	unix.EPERM:   "operation not permitted; are you root?",
+	unix.EROFS:   "device is read-only; try -r",
+}
+
+// explain adds a hint to mount errors caused by a well known errno. The
+// original error, errno included, is kept and can still be unwrapped.
+func explain(err error) error {
+	var errno unix.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	hint, ok := errnoHints[errno]
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, hint)
+}
+
+// findDevice returns the block device dev names, if it is LABEL=label or
+// UUID=uuid, and dev itself otherwise.
+func (c *cmd) findDevice(dev string) (string, error) {
+	key, value, _ := strings.Cut(dev, "=")
+	if key != "LABEL" && key != "UUID" {
+		return dev, nil
 	}
+	devs, err := c.blockDevices()
+	if err != nil {
+		return "", fmt.Errorf("error looking for %s: %w", dev, err)
+	}
+	if key == "LABEL" {
+		devs = devs.FilterFSLabel(value)
+	} else {
+		// blkid prints UUIDs in lower case, fstab may not.
+		devs = devs.FilterFSUUID(strings.ToLower(value))
+	}
+	// Devices without a label or UUID must not match an empty one.
+	if len(devs) == 0 || value == "" {
+		return "", fmt.Errorf("%w %s", errNoDevice, dev)
+	}
+	return devs[0].DevicePath(), nil
 }
 
 func loopSetup(filename string) (loopDevice string, err error) {
@@ -116,6 +205,42 @@ func (c *cmd) informIfUnknownFS(originFS string) {
 	}
 }
 
+// autoTypes returns the filesystem types the kernel supports that are
+// backed by a device, in the order /proc/filesystems lists them.
+func (c *cmd) autoTypes() ([]string, error) {
+	fs, err := os.ReadFile(c.fileSystemsPath)
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for _, f := range strings.Split(string(fs), "\n") {
+		if n := strings.Fields(f); len(n) == 1 {
+			types = append(types, n[0])
+		}
+	}
+	return types, nil
+}
+
+// mountTypes tries to mount dev on path as each of types in turn. It stops
+// at the first one that works, or that fails for a reason other than being
+// the wrong type.
+func (c *cmd) mountTypes(dev, path string, types []string, data string, flags uintptr) error {
+	var err error
+	for i, t := range types {
+		if err = c.mount(dev, path, t, data, flags); err == nil {
+			return nil
+		}
+		if !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENODEV) {
+			types = types[:i+1]
+			break
+		}
+	}
+	if err == nil {
+		return fmt.Errorf("no filesystem types to try")
+	}
+	return fmt.Errorf("tried %s: %w", strings.Join(types, ","), explain(err))
+}
+
 func (c *cmd) run(args ...string) error {
 	if len(args) == 0 {
 		for _, p := range c.mountsPath {
@@ -131,18 +256,14 @@ func (c *cmd) run(args ...string) error {
 		return errUsage
 	}
 
-	dev := args[0]
 	path := args[1]
 	var flags uintptr
 	var data []string
-	var err error
+	var loop bool
 	for _, option := range c.options {
 		switch option {
 		case "loop":
-			dev, err = loopSetup(dev)
-			if err != nil {
-				return fmt.Errorf("error setting loop device: %w", err)
-			}
+			loop = true
 		default:
 			if f, ok := opts[option]; ok {
 				flags |= f
@@ -155,27 +276,81 @@ func (c *cmd) run(args ...string) error {
 	if c.ro {
 		flags |= unix.MS_RDONLY
 	}
-	if c.fsType == "" {
-		if _, err := mount.TryMount(dev, path, strings.Join(data, ","), flags); err != nil {
+	// The device is looked up again on each retry until it is found, as
+	// a LABEL= or UUID= one may not be there yet either.
+	var dev string
+	return c.retry(func() error {
+		if dev == "" {
+			d, err := c.findDevice(args[0])
+			if err != nil {
+				return err
+			}
+			if loop {
+				if d, err = loopSetup(d); err != nil {
+					return fmt.Errorf("error setting loop device: %w", err)
+				}
+			}
+			dev = d
+		}
+		return c.mountDev(dev, path, strings.Join(data, ","), flags)
+	})
+}
+
+// mountDev mounts dev on path once, as the type or types asked for.
+func (c *cmd) mountDev(dev, path, data string, flags uintptr) error {
+	switch {
+	case c.fsType == "":
+		if _, err := mount.TryMount(dev, path, data, flags); err != nil {
+			return explain(err)
+		}
+	case c.fsType == "auto":
+		types, err := c.autoTypes()
+		if err != nil {
 			return err
 		}
-	} else {
-		if _, err := mount.Mount(dev, path, c.fsType, strings.Join(data, ","), flags); err != nil {
+		return c.mountTypes(dev, path, types, data, flags)
+	case strings.Contains(c.fsType, ","):
+		return c.mountTypes(dev, path, strings.Split(c.fsType, ","), data, flags)
+	default:
+		if err := c.mount(dev, path, c.fsType, data, flags); err != nil {
 			c.informIfUnknownFS(c.fsType)
+			return explain(err)
+		}
+	}
+	return nil
+}
+
+// retry calls f until it succeeds, fails with an error other than ENOENT,
+// ENODEV or errNoDevice, or c.retries retries have been made. Those are what
+// mounting a device that is still attaching fails with, and looking it up
+// by LABEL= or UUID=.
+func (c *cmd) retry(f func() error) error {
+	for i := 1; ; i++ {
+		err := f()
+		if err == nil || i > c.retries || !retryable(err) {
 			return err
 		}
+		fmt.Fprintf(c.stderr, "mount: %v; retry %d of %d in %v\n", err, i, c.retries, c.retryDelay)
+		c.sleep(c.retryDelay)
 	}
+}
 
-	return nil
+// retryable returns whether err means the device may just not be there yet.
+func retryable(err error) bool {
+	return errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENODEV) || errors.Is(err, errNoDevice)
 }
 
 func main() {
 	ro := flag.Bool("r", false, "Read only mount")
-	fsType := flag.String("t", "", "File system type")
+	fsType := flag.String("t", "", "File system type, or a comma separated list of types to try")
 	var options mountOptions
 	flag.Var(&options, "o", "Comma separated list of mount options")
+	retries := flag.Int("retry", 0, "Retry this many times while the device is not there yet (ENOENT or ENODEV)")
+	retryDelay := flag.Duration("retry-delay", time.Second, "Time to wait between retries")
 	flag.Parse()
 	cmd := command(os.Stdout, os.Stderr, *ro, *fsType, options)
+	cmd.retries = *retries
+	cmd.retryDelay = *retryDelay
 
 	err := cmd.run(flag.Args()...)
 	if errors.Is(err, errUsage) {
@@ -0,0 +1,336 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"golang.org/x/sys/unix"
+)
+
+func TestExplain(t *testing.T) {
+	for _, tt := range []struct {
+		errno unix.Errno
+		hint  string
+	}{
+		{unix.EBUSY, "already mounted or target busy"},
+		{unix.ENODEV, "filesystem type not supported by kernel"},
+		{unix.EINVAL, "bad superblock, wrong fs type or bad option"},
+		{unix.ENOENT, "mount point or device does not exist"},
+		{unix.ENOTBLK, "source is not a block device; try -o loop for image files"},
+		{unix.ENOTDIR, "mount point is not a directory"},
+		{unix.EPERM, "operation not permitted; are you root?"},
+		{unix.EROFS, "device is read-only; try -r"},
+		{unix.EACCES, "permission denied or the device is write-protected; try -r"},
+		{unix.ENXIO, "device does not exist or is not ready"},
+	} {
+		t.Run(tt.errno.Error(), func(t *testing.T) {
+			// Wrapped the way pkg/mount reports failures.
+			orig := &os.PathError{Op: "mount", Path: "/mnt", Err: fmt.Errorf("from device %q: %w", "/dev/sda1", tt.errno)}
+			err := explain(orig)
+			if !strings.HasSuffix(err.Error(), ": "+tt.hint) {
+				t.Errorf("explain(%v) = %q, want hint %q", tt.errno, err, tt.hint)
+			}
+			if !strings.Contains(err.Error(), tt.errno.Error()) {
+				t.Errorf("explain(%v) = %q, lost the original errno", tt.errno, err)
+			}
+			if !errors.Is(err, tt.errno) {
+				t.Errorf("errors.Is(explain(%v), %v) = false, want true", tt.errno, tt.errno)
+			}
+		})
+	}
+}
+
+func TestExplainPassesThrough(t *testing.T) {
+	for _, err := range []error{
+		errUsage,
+		unix.EIO,
+	} {
+		if got := explain(err); got != err {
+			t.Errorf("explain(%v) = %v, want it unchanged", err, got)
+		}
+	}
+}
+
+func TestMountTypes(t *testing.T) {
+	fs := filepath.Join(t.TempDir(), "filesystems")
+	if err := os.WriteFile(fs, []byte("nodev\tsysfs\nnodev\tproc\n\text4\n\tvfat\nnodev\ttmpfs\n\tsquashfs\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		fsType  string
+		results map[string]error
+		tried   []string
+		err     error
+		errText string
+	}{
+		{
+			name:    "second type works",
+			fsType:  "ext4,vfat",
+			results: map[string]error{"ext4": unix.EINVAL},
+			tried:   []string{"ext4", "vfat"},
+		},
+		{
+			name:    "first type works",
+			fsType:  "ext4,vfat",
+			results: map[string]error{},
+			tried:   []string{"ext4"},
+		},
+		{
+			name:    "all fail",
+			fsType:  "ext4,btrfs,vfat",
+			results: map[string]error{"ext4": unix.EINVAL, "btrfs": unix.ENODEV, "vfat": unix.EINVAL},
+			tried:   []string{"ext4", "btrfs", "vfat"},
+			err:     unix.EINVAL,
+			errText: "tried ext4,btrfs,vfat: ",
+		},
+		{
+			name:    "stop at errors that are not about the type",
+			fsType:  "ext4,vfat",
+			results: map[string]error{"ext4": unix.EBUSY},
+			tried:   []string{"ext4"},
+			err:     unix.EBUSY,
+			errText: "tried ext4: ",
+		},
+		{
+			name:    "auto",
+			fsType:  "auto",
+			results: map[string]error{"ext4": unix.EINVAL, "vfat": unix.EINVAL},
+			tried:   []string{"ext4", "vfat", "squashfs"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c := command(&stdout, &stderr, false, tt.fsType, nil)
+			c.fileSystemsPath = fs
+			var tried []string
+			c.mount = func(dev, path, fsType, data string, flags uintptr) error {
+				if dev != "/dev/sda1" || path != "/mnt" {
+					t.Errorf("mount(%q, %q), want (%q, %q)", dev, path, "/dev/sda1", "/mnt")
+				}
+				tried = append(tried, fsType)
+				return tt.results[fsType]
+			}
+			err := c.run("/dev/sda1", "/mnt")
+			if !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), tt.errText) {
+				t.Errorf("run() = %q, want it to start with %q", err, tt.errText)
+			}
+			if !slices.Equal(tried, tt.tried) {
+				t.Errorf("tried %q, want %q", tried, tt.tried)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		fsType  string
+		retries int
+		results []error
+		calls   int
+		err     error
+	}{
+		{
+			name:    "device shows up",
+			fsType:  "ext4",
+			retries: 3,
+			results: []error{unix.ENOENT, unix.ENOENT},
+			calls:   3,
+		},
+		{
+			name:    "driver shows up",
+			fsType:  "ext4",
+			retries: 3,
+			results: []error{unix.ENODEV},
+			calls:   2,
+		},
+		{
+			name:    "type list",
+			fsType:  "ext4,vfat",
+			retries: 1,
+			results: []error{unix.ENODEV, unix.ENODEV},
+			calls:   3,
+		},
+		{
+			name:    "out of retries",
+			fsType:  "ext4",
+			retries: 2,
+			results: []error{unix.ENOENT, unix.ENOENT, unix.ENOENT},
+			calls:   3,
+			err:     unix.ENOENT,
+		},
+		{
+			name:    "no retries",
+			fsType:  "ext4",
+			results: []error{unix.ENOENT},
+			calls:   1,
+			err:     unix.ENOENT,
+		},
+		{
+			name:    "other errors are not retried",
+			fsType:  "ext4",
+			retries: 3,
+			results: []error{unix.EBUSY},
+			calls:   1,
+			err:     unix.EBUSY,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c := command(&stdout, &stderr, false, tt.fsType, nil)
+			c.retries = tt.retries
+			c.retryDelay = 500 * time.Millisecond
+			var calls int
+			c.mount = func(dev, path, fsType, data string, flags uintptr) error {
+				calls++
+				if calls <= len(tt.results) {
+					return tt.results[calls-1]
+				}
+				return nil
+			}
+			var slept []time.Duration
+			c.sleep = func(d time.Duration) {
+				slept = append(slept, d)
+			}
+			if err := c.run("/dev/nbd0", "/mnt"); !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("mount called %d times, want %d", calls, tt.calls)
+			}
+			if got := strings.Count(stderr.String(), "retry "); got != len(slept) {
+				t.Errorf("logged %d retries, slept %d times: %q", got, len(slept), stderr.String())
+			}
+			for _, d := range slept {
+				if d != c.retryDelay {
+					t.Errorf("slept %v, want %v", d, c.retryDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	devs := block.BlockDevices{
+		&block.BlockDev{Name: "sda1", FsUUID: "abcd-1234", FsLabel: "EFI"},
+		&block.BlockDev{Name: "sda2", FsUUID: "02175989-d49f-4e8e-836e-99300af66fc1", FsLabel: "rootfs"},
+		&block.BlockDev{Name: "nvme0n1p1", FsUUID: "5a6b7c8d-0000-4e8e-836e-99300af66fc1"},
+	}
+	for _, tt := range []struct {
+		name string
+		dev  string
+		want string
+		err  error
+	}{
+		{name: "label", dev: "LABEL=rootfs", want: "/dev/sda2"},
+		{name: "uuid", dev: "UUID=5a6b7c8d-0000-4e8e-836e-99300af66fc1", want: "/dev/nvme0n1p1"},
+		{name: "upper case uuid", dev: "UUID=ABCD-1234", want: "/dev/sda1"},
+		{name: "device", dev: "/dev/sdb1", want: "/dev/sdb1"},
+		{name: "other key", dev: "PARTUUID=abcd", want: "PARTUUID=abcd"},
+		{name: "no such label", dev: "LABEL=data", err: errNoDevice},
+		{name: "no such uuid", dev: "UUID=1234-abcd", err: errNoDevice},
+		{name: "empty label", dev: "LABEL=", err: errNoDevice},
+		{name: "empty uuid", dev: "UUID=", err: errNoDevice},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := command(nil, nil, false, "", nil)
+			c.blockDevices = func() (block.BlockDevices, error) {
+				return devs, nil
+			}
+			got, err := c.findDevice(tt.dev)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("findDevice(%q) = %v, want %v", tt.dev, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("findDevice(%q) = %q, want %q", tt.dev, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunByLabel(t *testing.T) {
+	c := command(nil, nil, false, "ext4", nil)
+	c.blockDevices = func() (block.BlockDevices, error) {
+		return block.BlockDevices{&block.BlockDev{Name: "sda2", FsLabel: "rootfs"}}, nil
+	}
+	var mounted string
+	c.mount = func(dev, path, fsType, data string, flags uintptr) error {
+		mounted = dev
+		return nil
+	}
+	if err := c.run("LABEL=rootfs", "/mnt"); err != nil {
+		t.Fatal(err)
+	}
+	if mounted != "/dev/sda2" {
+		t.Errorf("mounted %q, want %q", mounted, "/dev/sda2")
+	}
+
+	errList := errors.New("no /sys/class/block")
+	c.blockDevices = func() (block.BlockDevices, error) { return nil, errList }
+	if err := c.run("UUID=abcd-1234", "/mnt"); !errors.Is(err, errList) {
+		t.Errorf("run() = %v, want %v", err, errList)
+	}
+}
+
+func TestRetryByUUID(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		retries int
+		lookups int
+		mounted string
+		err     error
+	}{
+		{name: "device shows up", retries: 3, lookups: 2, mounted: "/dev/sdb1"},
+		{name: "no retries", lookups: 1, err: errNoDevice},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			c := command(nil, &stderr, false, "ext4", nil)
+			c.retries = tt.retries
+			c.sleep = func(time.Duration) {}
+			// The disk is still attaching at the first lookup.
+			var lookups int
+			c.blockDevices = func() (block.BlockDevices, error) {
+				lookups++
+				if lookups == 1 {
+					return block.BlockDevices{&block.BlockDev{Name: "sda1", FsUUID: "1111-2222"}}, nil
+				}
+				return block.BlockDevices{
+					&block.BlockDev{Name: "sda1", FsUUID: "1111-2222"},
+					&block.BlockDev{Name: "sdb1", FsUUID: "abcd-1234"},
+				}, nil
+			}
+			var mounted string
+			c.mount = func(dev, path, fsType, data string, flags uintptr) error {
+				mounted = dev
+				return nil
+			}
+			if err := c.run("UUID=ABCD-1234", "/mnt"); !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, want %v", err, tt.err)
+			}
+			if lookups != tt.lookups {
+				t.Errorf("looked up the devices %d times, want %d", lookups, tt.lookups)
+			}
+			if mounted != tt.mounted {
+				t.Errorf("mounted %q, want %q", mounted, tt.mounted)
+			}
+		})
+	}
+}
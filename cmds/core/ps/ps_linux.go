@@ -6,7 +6,7 @@
 //
 // Synopsis:
 //
-//	ps [-Aaex] [aux]
+//	ps [-Aaefhx] [-u USER,...] [-p PID,...] [--command REGEX] [-o COLUMN,...] [--no-headers] [aux]
 //
 // Description:
 //
@@ -20,10 +20,29 @@
 //	 -e: select all processes. Identical to -A.
 //	 -x: BSD-Like style, with STAT Column and long CommandLine
 //	 -a: print all process except whose are session leaders or unlinked with terminal
-//	aux: see every process on the system using BSD syntax
+//	 -f: show the full command line of each process; kernel threads, which
+//	     have none, show their name in brackets
+//	 -h: show the VSZ and RSS columns of aux in K, M or G rather than in
+//	     kibibytes
+//	 -u: select processes owned by these users, by name or UID
+//	 -p: select processes with these PIDs
+//	 --command: select processes whose name or command line matches REGEX
+//	 -o: show these columns, in this order, rather than the default ones:
+//	     pid, ppid, pgrp, sid, tty, stat, vsz, rss, time, comm (CMD),
+//	     args (COMMAND), etime and stime. etime is the time since the
+//	     process started, as [[DD-]HH:]MM:SS. stime is when it started:
+//	     HH:MM today, MmmDD earlier this year and the year before that.
+//	 --no-headers: leave out the header line, e.g. to feed the output to awk
+//	aux: see every process on the system using BSD syntax, along with its
+//	     virtual (VSZ) and resident (RSS) memory size in kibibytes
+//
+//	-u, -p and --command may be combined; a process has to match all of
+//	them. Without -a or -x, they look at every process, not just the
+//	current session.
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"io"
@@ -31,10 +50,13 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
@@ -44,12 +66,23 @@ var (
 	every   bool
 	x       bool
 	nSidTty bool
+	full    bool
+	human   bool
 	aux     = false
+
+	noHeaders bool
+
+	userList  string
+	pidList   string
+	cmdRegexp string
+	columns   string
 )
 
 var (
 	psUsage = "ps: ps [flags] [aux]"
 	eUID    = os.Geteuid()
+
+	passwdFile = "/etc/passwd"
 )
 
 const (
@@ -62,8 +95,37 @@ var (
 	// by convention, the first element of the path is "/proc"
 	// This allows us to point to any place as our "/proc"
 	procdir = "/proc"
+
+	// bootTime is when the system booted, from the btime line of
+	// procdir/stat. It is zero if that is unknown.
+	bootTime time.Time
+	// now is the time etime counts to. It can be replaced in tests.
+	now = time.Now
 )
 
+// column is a column -o can ask for.
+type column struct {
+	header string
+	field  string // of process
+}
+
+// psColumns maps the -o names of the columns to them.
+var psColumns = map[string]column{
+	"pid":   {"PID", "Pid"},
+	"ppid":  {"PPID", "Ppid"},
+	"pgrp":  {"PGRP", "Pgrp"},
+	"sid":   {"SID", "Sid"},
+	"tty":   {"TTY", "Ctty"},
+	"stat":  {"STAT", "State"},
+	"vsz":   {"VSZ", "VSZ"},
+	"rss":   {"RSS", "RSS"},
+	"time":  {"TIME", "Time"},
+	"comm":  {"CMD", "Cmd"},
+	"args":  {"COMMAND", "Args"},
+	"etime": {"ELAPSED", "Elapsed"},
+	"stime": {"STIME", "Started"},
+}
+
 // Process contains both kernel-dependent and kernel-independent information.
 type Process struct {
 	process
@@ -132,6 +194,11 @@ type process struct {
 	ExitCode    string // the thread's exit_code in the form reported by the waitpid system call (end of stat)
 	Ctty        string // extra member (don't parsed from stat)
 	Time        string // extra member (don't parsed from stat)
+	VSZ         string // extra member (don't parsed from stat)
+	RSS         string // extra member (don't parsed from stat)
+	Elapsed     string // extra member (don't parsed from stat)
+	Started     string // extra member (don't parsed from stat)
+	Args        string // extra member (don't parsed from stat)
 }
 
 // Parse all content of stat to a Process Struct
@@ -147,14 +214,35 @@ func (p *Process) readStat(s string) error {
 
 	p.Time = p.getTime()
 	p.Ctty = p.getCtty()
+	p.VSZ, p.RSS = p.getMem()
+	p.Elapsed, p.Started = p.getStart()
 	p.Cmd = strings.TrimSuffix(strings.TrimPrefix(p.Cmd, "("), ")")
+	p.Args = fullCommand(p.Cmd, p.cmdline)
 	if x && p.cmdline != "" {
 		p.Cmd = p.cmdline
 	}
+	if full {
+		p.Cmd = fullCommand(p.Cmd, p.cmdline)
+	}
 
 	return nil
 }
 
+// argv turns the contents of /proc/PID/cmdline, whose arguments are
+// separated by NUL bytes, into a command line.
+func argv(cmdline string) string {
+	return strings.TrimRight(strings.ReplaceAll(cmdline, "\x00", " "), " ")
+}
+
+// fullCommand returns the command line of a process, or, for kernel threads,
+// which have an empty cmdline, its name in brackets.
+func fullCommand(comm, cmdline string) string {
+	if c := argv(cmdline); c != "" {
+		return c
+	}
+	return "[" + comm + "]"
+}
+
 // Parse data from various strings in the Process struct
 func (p *Process) Parse() error {
 	err := p.readStat(p.stat)
@@ -206,6 +294,24 @@ func (p Process) GetUID() (int, error) {
 	return -1, fmt.Errorf("no Uid string in %s", p.status)
 }
 
+// getMem returns the virtual and resident memory size of the process, in
+// kibibytes or, with -h, in human-readable form.
+func (p process) getMem() (string, string) {
+	vsize, _ := strconv.ParseUint(p.Vsize, 10, 64)
+	rss, _ := strconv.ParseUint(p.Rss, 10, 64)
+	// Vsize is in bytes, Rss in pages.
+	return memSize(vsize >> 10), memSize(rss * uint64(os.Getpagesize()) >> 10)
+}
+
+// memSize formats a size given in kibibytes.
+func memSize(kb uint64) string {
+	if !human || kb == 0 {
+		return strconv.FormatUint(kb, 10)
+	}
+	// IBytes says e.g. "1.5 MiB"; the column only has room for "1.5M".
+	return strings.TrimSuffix(strings.ReplaceAll(humanize.IBytes(kb<<10), " ", ""), "iB")
+}
+
 // Get total time stat formated hh:mm:ss
 func (p process) getTime() string {
 	utime, _ := strconv.Atoi(p.Utime)
@@ -220,6 +326,71 @@ func (p process) getTime() string {
 	return fmt.Sprintf("%02d:%02d:%02d", hrs, mins, secs)
 }
 
+// getStart returns the time since the process started, formatted by
+// formatElapsed, and when it started, formatted by formatStart. Both are "?"
+// if the boot time is unknown.
+func (p process) getStart() (string, string) {
+	ticks, err := strconv.ParseInt(p.StartTime, 10, 64)
+	if err != nil || bootTime.IsZero() {
+		return "?", "?"
+	}
+	start := bootTime.Add(time.Duration(ticks) * time.Second / userHZ)
+	t := now()
+	return formatElapsed(t.Sub(start)), formatStart(start, t)
+}
+
+// formatElapsed formats d as [[DD-]HH:]MM:SS, like procps does for etime.
+func formatElapsed(d time.Duration) string {
+	secs := int64(d / time.Second)
+	if secs < 0 {
+		// The clock was set back since the process started.
+		secs = 0
+	}
+	days, hrs, mins := secs/86400, secs/3600%24, secs/60%60
+	secs %= 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hrs, mins, secs)
+	case hrs > 0:
+		return fmt.Sprintf("%02d:%02d:%02d", hrs, mins, secs)
+	}
+	return fmt.Sprintf("%02d:%02d", mins, secs)
+}
+
+// formatStart formats the start time of a process like procps does for
+// stime: the time of day if it started today, the month and day if it
+// started this year, else the year.
+func formatStart(start, now time.Time) string {
+	start, now = start.Local(), now.Local()
+	switch {
+	case start.YearDay() == now.YearDay() && start.Year() == now.Year():
+		return start.Format("15:04")
+	case start.Year() == now.Year():
+		return start.Format("Jan02")
+	}
+	return start.Format("2006")
+}
+
+// readBootTime returns the boot time from the btime line of a /proc/stat
+// file.
+func readBootTime(stat string) (time.Time, error) {
+	s, err := file(stat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, l := range strings.Split(s, "\n") {
+		f := strings.Fields(l)
+		if len(f) == 2 && f[0] == "btime" {
+			secs, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime line in %s", stat)
+}
+
 func getAllGlobNames() []string {
 	psglob = os.Getenv("UROOT_PSPATH")
 	if psglob == "" {
@@ -284,7 +455,7 @@ func (pT *ProcessTable) doTable(statFileNames []string) error {
 		if err != nil {
 			continue
 		}
-		if x {
+		if x || full || cmdRegexp != "" || columns != "" {
 			p.cmdline, err = file(filepath.Join(d, "cmdline"))
 			if err != nil {
 				continue
@@ -329,6 +500,8 @@ func (pT *ProcessTable) doTable(statFileNames []string) error {
 // need more complex processing for UROOT_PSPATH.
 func (pT *ProcessTable) LoadTable() error {
 	g := getAllGlobNames()
+	// Without it, etime and stime show "?", which is no reason to fail.
+	bootTime, _ = readBootTime(filepath.Join(procdir, "stat"))
 	n, err := getAllStatNames(g)
 	if err != nil {
 		return err
@@ -428,9 +601,11 @@ func (pT *ProcessTable) PrepareString() {
 		TTY      = pT.MaxLength("Ctty")
 		STAT     = 4 | pT.MaxLength("State") // min : 4
 		TIME     = pT.MaxLength("Time")
-		CMD      = pT.MaxLength("Cmd")
+		// Human-readable sizes vary in width, too.
+		VSZ = max([]int{len("VSZ"), pT.MaxLength("VSZ")})
+		RSS = max([]int{len("RSS"), pT.MaxLength("RSS")})
 	)
-	for _, f := range pT.headers {
+	for i, f := range pT.headers {
 		switch f {
 		case "PID":
 			formated = fmt.Sprintf("%%%dv ", PID)
@@ -438,10 +613,21 @@ func (pT *ProcessTable) PrepareString() {
 			formated = fmt.Sprintf("%%-%dv    ", TTY)
 		case "STAT":
 			formated = fmt.Sprintf("%%-%dv    ", STAT)
+		case "VSZ":
+			formated = fmt.Sprintf("%%%dv ", VSZ)
+		case "RSS":
+			formated = fmt.Sprintf("%%%dv ", RSS)
 		case "TIME":
 			formated = fmt.Sprintf("%%%dv ", TIME)
-		case "CMD":
-			formated = fmt.Sprintf("%%-%dv ", CMD)
+		case "CMD", "COMMAND":
+			formated = fmt.Sprintf("%%-%dv ", pT.MaxLength(pT.fields[i]))
+			if i == len(pT.headers)-1 {
+				// Nothing follows to line up with, and command
+				// lines can be very long.
+				formated = "%v "
+			}
+		default:
+			formated = fmt.Sprintf("%%%dv ", max([]int{len(f), pT.MaxLength(pT.fields[i])}))
 		}
 		fstring = append(fstring, formated)
 	}
@@ -449,6 +635,93 @@ func (pT *ProcessTable) PrepareString() {
 	pT.fstring = fstring
 }
 
+// selector restricts the processes ps prints. Only the criteria that were
+// given are checked, and a process has to meet all of them.
+type selector struct {
+	uids    map[int]bool
+	pids    map[int]bool
+	command *regexp.Regexp
+}
+
+// newSelector parses the -u, -p and --command arguments.
+func newSelector(users, pids, command string) (*selector, error) {
+	sel := &selector{}
+	if users != "" {
+		sel.uids = make(map[int]bool)
+		for _, u := range strings.Split(users, ",") {
+			uid, err := lookupUID(u)
+			if err != nil {
+				return nil, err
+			}
+			sel.uids[uid] = true
+		}
+	}
+	if pids != "" {
+		sel.pids = make(map[int]bool)
+		for _, p := range strings.Split(pids, ",") {
+			pid, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pid %q: %w", p, err)
+			}
+			sel.pids[pid] = true
+		}
+	}
+	if command != "" {
+		re, err := regexp.Compile(command)
+		if err != nil {
+			return nil, err
+		}
+		sel.command = re
+	}
+	return sel, nil
+}
+
+// active returns true if any selection criteria were given.
+func (sel *selector) active() bool {
+	return sel.uids != nil || sel.pids != nil || sel.command != nil
+}
+
+// match returns true if p meets all of the selection criteria.
+func (sel *selector) match(p *Process) bool {
+	if sel.uids != nil && !sel.uids[p.uid] {
+		return false
+	}
+	if sel.pids != nil && !sel.pids[p.Pidno] {
+		return false
+	}
+	if sel.command != nil {
+		if !sel.command.MatchString(p.Cmd) && !sel.command.MatchString(argv(p.cmdline)) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupUID returns the UID of a user given by name, as found in
+// passwdFile, or by number.
+func lookupUID(name string) (int, error) {
+	if uid, err := strconv.Atoi(name); err == nil {
+		return uid, nil
+	}
+	f, err := os.Open(passwdFile)
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) < 3 || fields[0] != name {
+			continue
+		}
+		return strconv.Atoi(fields[2])
+	}
+	if err := s.Err(); err != nil {
+		return -1, err
+	}
+	return -1, fmt.Errorf("unknown user name: %s", name)
+}
+
 // For now, just read /proc/pid/stat and dump its brains.
 func ps(w io.Writer, args ...string) error {
 	// The original ps was designed before many flag conventions existed.
@@ -463,6 +736,20 @@ func ps(w io.Writer, args ...string) error {
 			return nil
 		}
 	}
+	sel, err := newSelector(userList, pidList, cmdRegexp)
+	if err != nil {
+		return err
+	}
+	var cols []column
+	if columns != "" {
+		for _, name := range strings.Split(columns, ",") {
+			c, ok := psColumns[strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("unknown column %q", name)
+			}
+			cols = append(cols, c)
+		}
+	}
 	pT := NewProcessTable()
 	if err := pT.LoadTable(); err != nil {
 		return err
@@ -475,9 +762,14 @@ func ps(w io.Writer, args ...string) error {
 	sort.Sort(pT)
 
 	switch {
+	case cols != nil:
+		for _, c := range cols {
+			pT.headers = append(pT.headers, c.header)
+			pT.fields = append(pT.fields, c.field)
+		}
 	case aux:
-		pT.headers = []string{"PID", "PGRP", "SID", "TTY", "STAT", "TIME", "COMMAND"}
-		pT.fields = []string{"Pid", "Pgrp", "Sid", "Ctty", "State", "Time", "Cmd"}
+		pT.headers = []string{"PID", "PGRP", "SID", "TTY", "STAT", "VSZ", "RSS", "TIME", "COMMAND"}
+		pT.fields = []string{"Pid", "Pgrp", "Sid", "Ctty", "State", "VSZ", "RSS", "Time", "Cmd"}
 	case x:
 		pT.headers = []string{"PID", "TTY", "STAT", "TIME", "COMMAND"}
 		pT.fields = []string{"Pid", "Ctty", "State", "Time", "Cmd"}
@@ -487,7 +779,9 @@ func ps(w io.Writer, args ...string) error {
 	}
 
 	pT.PrepareString()
-	pT.PrintHeader(w)
+	if !noHeaders {
+		pT.PrintHeader(w)
+	}
 	for index, p := range pT.table {
 		switch {
 		case nSidTty:
@@ -502,7 +796,7 @@ func ps(w io.Writer, args ...string) error {
 				continue
 			}
 
-		case all || every:
+		case all || every || sel.active():
 			// pass, print all
 
 		default:
@@ -513,6 +807,10 @@ func ps(w io.Writer, args ...string) error {
 			}
 		}
 
+		if !sel.match(p) {
+			continue
+		}
+
 		pT.PrintProcess(index, w)
 	}
 
@@ -534,6 +832,19 @@ func main() {
 	f.BoolVar(&nSidTty, "anSIDTTY", false, "Print all process except whose are session leaders or unlinked with terminal")
 	f.BoolVar(&nSidTty, "a", false, "Print all process except whose are session leaders or unlinked with terminal (shorthand)")
 
+	f.BoolVar(&full, "full", false, "Show the full command line of each process")
+	f.BoolVar(&full, "f", false, "Show the full command line of each process (shorthand)")
+
+	f.BoolVar(&human, "human", false, "Show VSZ and RSS in K, M or G")
+	f.BoolVar(&human, "h", false, "Show VSZ and RSS in K, M or G (shorthand)")
+
+	f.StringVar(&userList, "u", "", "Select processes owned by these users, by name or UID (comma separated)")
+	f.StringVar(&pidList, "p", "", "Select processes with these PIDs (comma separated)")
+	f.StringVar(&cmdRegexp, "command", "", "Select processes whose name or command line matches this regular expression")
+	f.StringVar(&columns, "o", "", "Show these columns (comma separated): pid, ppid, pgrp, sid, tty, stat, vsz, rss, time, comm, args, etime, stime")
+
+	f.BoolVar(&noHeaders, "no-headers", false, "Do not print the header line")
+
 	f.Parse(unixflag.OSArgsToGoArgs())
 	if err := ps(os.Stdout, f.Args()...); err != nil {
 		log.Fatal(err)
@@ -8,8 +8,12 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPs(t *testing.T) {
@@ -28,7 +32,7 @@ func TestPs(t *testing.T) {
 			name: "aux",
 			args: []string{"aux"},
 			x:    true,
-			want: []string{"PID", "PGRP", "SID", "TTY", "STAT", "TIME", "COMMAND"},
+			want: []string{"PID", "PGRP", "SID", "TTY", "STAT", "VSZ", "RSS", "TIME", "COMMAND"},
 		},
 		{
 			name: "flag x",
@@ -162,3 +166,370 @@ nonvoluntary_ctxt_switches:	3746
 	}
 
 }
+
+type fakeProcess struct {
+	pid     int
+	comm    string
+	uid     int
+	cmdline string
+	// vsize is in bytes and rss in pages. If they are zero, the
+	// process gets about 220 MiB and 9 MiB.
+	vsize, rss uint64
+}
+
+// fakeProc writes a /proc-like tree for the given processes and points ps
+// at it.
+func fakeProc(t *testing.T, procs []fakeProcess) {
+	t.Helper()
+	d := t.TempDir()
+	for _, p := range procs {
+		pd := filepath.Join(d, fmt.Sprint(p.pid))
+		if err := os.Mkdir(pd, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if p.vsize == 0 && p.rss == 0 {
+			p.vsize, p.rss = 230821888, 2325
+		}
+		stat := fmt.Sprintf("%d (%s) S 0 1 1 0 -1 4194560 45535 23809816 88 2870 76 378 35944 9972 20 0 1 0 2 %d %d 18446744073709551615 1 1 0 0 0 0 671173123 4096 1260 0 0 0 17 2 0 0 69 0 0 0 0 0 0 0 0 0 0", p.pid, p.comm, p.vsize, p.rss)
+		status := fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", p.comm, p.uid, p.uid, p.uid, p.uid)
+		for name, content := range map[string]string{"stat": stat, "status": status, "cmdline": p.cmdline} {
+			if err := os.WriteFile(filepath.Join(pd, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Setenv("UROOT_PSPATH", d)
+
+	passwd := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(passwd, []byte("root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldPasswd := passwdFile
+	passwdFile = passwd
+	t.Cleanup(func() { passwdFile = oldPasswd })
+}
+
+func TestSelect(t *testing.T) {
+	fakeProc(t, []fakeProcess{
+		{pid: 1, comm: "init", uid: 0, cmdline: "/init\x00"},
+		{pid: 20, comm: "sshd", uid: 0, cmdline: "/bin/sshd\x00-port\x002222\x00"},
+		{pid: 300, comm: "sh", uid: 1000, cmdline: "/bin/sh\x00"},
+		{pid: 301, comm: "sleep", uid: 1000, cmdline: "sleep\x00100\x00"},
+	})
+
+	for _, tt := range []struct {
+		name    string
+		users   string
+		pids    string
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{name: "user name", users: "alice", want: []string{"sh", "sleep"}},
+		{name: "uid", users: "0", want: []string{"init", "sshd"}},
+		{name: "several users", users: "root,alice", want: []string{"init", "sshd", "sh", "sleep"}},
+		{name: "pids", pids: "1,301", want: []string{"init", "sleep"}},
+		{name: "command name", command: "^s", want: []string{"sshd", "sh", "sleep"}},
+		{name: "command line", command: "port 2222", want: []string{"sshd"}},
+		{name: "user and command", users: "alice", command: "^s", want: []string{"sh", "sleep"}},
+		{name: "user, pid and command", users: "alice", pids: "20,301", command: "^s", want: []string{"sleep"}},
+		{name: "no match", users: "root", pids: "300", want: nil},
+		{name: "unknown user", users: "bob", wantErr: true},
+		{name: "bad pid", pids: "one", wantErr: true},
+		{name: "bad regexp", command: "(", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			all, every, x, nSidTty, aux = false, false, false, false, false
+			userList, pidList, cmdRegexp = tt.users, tt.pids, tt.command
+			defer func() { userList, pidList, cmdRegexp = "", "", "" }()
+
+			var buf bytes.Buffer
+			err := ps(&buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ps() = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			var got []string
+			for _, l := range lines[1:] {
+				f := strings.Fields(l)
+				got = append(got, f[len(f)-1])
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ps selected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFull(t *testing.T) {
+	fakeProc(t, []fakeProcess{
+		{pid: 2, comm: "kthreadd", uid: 0, cmdline: ""},
+		{pid: 20, comm: "sshd", uid: 0, cmdline: "/bin/sshd\x00-port\x002222\x00"},
+		{pid: 43, comm: "kworker/0:1", uid: 0, cmdline: ""},
+		{pid: 300, comm: "sh", uid: 1000, cmdline: "/bin/sh\x00-c\x00echo hi\x00"},
+	})
+
+	for _, tt := range []struct {
+		name string
+		full bool
+		want []string
+	}{
+		{name: "comm", want: []string{"kthreadd", "sshd", "kworker/0:1", "sh"}},
+		{name: "full", full: true, want: []string{"[kthreadd]", "/bin/sshd -port 2222", "[kworker/0:1]", "/bin/sh -c echo hi"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			all, every, x, nSidTty, aux, full = false, true, false, false, false, tt.full
+			defer func() { every, full = false, false }()
+
+			var buf bytes.Buffer
+			if err := ps(&buf); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			var got []string
+			for _, l := range lines[1:] {
+				// PID, TTY and TIME come first.
+				f := strings.Fields(l)
+				got = append(got, strings.Join(f[3:], " "))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("CMD column = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHuman(t *testing.T) {
+	page := uint64(os.Getpagesize())
+	fakeProc(t, []fakeProcess{
+		{pid: 1, comm: "tiny", vsize: 8 << 10, rss: 1},
+		{pid: 2, comm: "small", vsize: 1536 << 10, rss: (100 << 10) / page},
+		{pid: 3, comm: "large", vsize: 12 << 30, rss: (3 << 30) / page},
+	})
+
+	for _, tt := range []struct {
+		name  string
+		human bool
+		want  [][2]string
+	}{
+		{
+			name: "kibibytes",
+			want: [][2]string{
+				{"8", fmt.Sprint(page >> 10)},
+				{"1536", "100"},
+				{fmt.Sprint(12 << 20), fmt.Sprint(3 << 20)},
+			},
+		},
+		{
+			name:  "human",
+			human: true,
+			want: [][2]string{
+				{"8.0K", fmt.Sprintf("%d.0K", page>>10)},
+				{"1.5M", "100K"},
+				{"12G", "3.0G"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			all, every, x, nSidTty, aux, full, human = false, false, false, false, false, false, tt.human
+			defer func() { all, every, x, aux, human = false, false, false, false, false }()
+
+			var buf bytes.Buffer
+			if err := ps(&buf, "aux"); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+			if len(lines) != len(tt.want)+1 {
+				t.Fatalf("ps aux printed %q, want a header and %d processes", lines, len(tt.want))
+			}
+			// The columns are right aligned: VSZ and RSS end at the
+			// same offset in every line.
+			var vszEnd, rssEnd int
+			for i, l := range lines[1:] {
+				// The TTY of the fake processes is "?" and the STAT
+				// "S", so VSZ and RSS are fields 5 and 6.
+				f := strings.Fields(l)
+				if got := [2]string{f[5], f[6]}; got != tt.want[i] {
+					t.Errorf("VSZ, RSS of %s = %q, want %q", f[len(f)-1], got, tt.want[i])
+				}
+				v := strings.Index(l, " "+f[5]+" ") + 1 + len(f[5])
+				r := v + strings.Index(l[v:], f[6]+" ") + len(f[6])
+				if i == 0 {
+					vszEnd, rssEnd = v, r
+				} else if v != vszEnd || r != rssEnd {
+					t.Errorf("line %q is not aligned with %q", l, lines[1])
+				}
+			}
+			if got := memSize(0); got != "0" {
+				t.Errorf("memSize(0) = %q, want %q", got, "0")
+			}
+		})
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00"},
+		{59 * time.Second, "00:59"},
+		{61*time.Minute + 5*time.Second, "01:01:05"},
+		{25*time.Hour + 2*time.Minute + 3*time.Second, "1-01:02:03"},
+		{123*24*time.Hour + 500*time.Millisecond, "123-00:00:00"},
+		{-time.Minute, "00:00"},
+	} {
+		if got := formatElapsed(tt.d); got != tt.want {
+			t.Errorf("formatElapsed(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestGetStart(t *testing.T) {
+	oldBoot, oldNow := bootTime, now
+	defer func() { bootTime, now = oldBoot, oldNow }()
+	bootTime = time.Date(2023, time.March, 1, 8, 0, 0, 0, time.Local)
+
+	for _, tt := range []struct {
+		name      string
+		startTime string // in clock ticks after boot
+		now       time.Time
+		etime     string
+		stime     string
+	}{
+		{
+			name:      "today",
+			startTime: fmt.Sprint(90 * userHZ),
+			now:       time.Date(2023, time.March, 1, 9, 0, 0, 0, time.Local),
+			etime:     "58:30",
+			stime:     "08:01",
+		},
+		{
+			name:      "this year",
+			startTime: fmt.Sprint(3600 * userHZ),
+			now:       time.Date(2023, time.March, 3, 12, 30, 15, 0, time.Local),
+			etime:     "2-03:30:15",
+			stime:     "Mar01",
+		},
+		{
+			name:      "last year",
+			startTime: "0",
+			now:       time.Date(2024, time.January, 1, 9, 0, 0, 0, time.Local),
+			etime:     "306-01:00:00",
+			stime:     "2023",
+		},
+		{
+			name:      "bad start time",
+			startTime: "x",
+			now:       time.Date(2023, time.March, 1, 9, 0, 0, 0, time.Local),
+			etime:     "?",
+			stime:     "?",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now = func() time.Time { return tt.now }
+			etime, stime := process{StartTime: tt.startTime}.getStart()
+			if etime != tt.etime || stime != tt.stime {
+				t.Errorf("getStart() = %q, %q, want %q, %q", etime, stime, tt.etime, tt.stime)
+			}
+		})
+	}
+
+	bootTime = time.Time{}
+	if etime, stime := (process{StartTime: "100"}).getStart(); etime != "?" || stime != "?" {
+		t.Errorf("getStart() without boot time = %q, %q, want %q, %q", etime, stime, "?", "?")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	fakeProc(t, []fakeProcess{
+		{pid: 1, comm: "init", cmdline: "/init\x00"},
+		{pid: 20, comm: "sshd", cmdline: "/bin/sshd\x00"},
+	})
+	// The fake processes started 2 ticks after boot.
+	btime := time.Date(2023, time.March, 1, 8, 0, 0, 0, time.Local)
+	if err := os.WriteFile(filepath.Join(os.Getenv("UROOT_PSPATH"), "stat"), []byte(fmt.Sprintf("cpu  1 2 3 4\nbtime %d\nprocesses 20\n", btime.Unix())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldNow := now
+	defer func() { now = oldNow }()
+	now = func() time.Time { return btime.Add(75 * time.Minute) }
+
+	all, every, x, nSidTty, aux = false, true, false, false, false
+	defer func() { every, columns = false, "" }()
+
+	columns = "pid,etime,stime,comm"
+	var buf bytes.Buffer
+	if err := ps(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// The PID column is only as wide as the widest PID, as it always was.
+	want := `PID  ELAPSED STIME CMD 
+ 1 01:14:59 08:00 init 
+20 01:14:59 08:00 sshd 
+`
+	if buf.String() != want {
+		t.Errorf("ps -o %s printed\n%q\nwant\n%q", columns, buf.String(), want)
+	}
+
+	columns = "pid,args,pgrp"
+	buf.Reset()
+	if err := ps(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want = `PID COMMAND   PGRP 
+ 1 /init        1 
+20 /bin/sshd    1 
+`
+	if buf.String() != want {
+		t.Errorf("ps -o %s printed\n%q\nwant\n%q", columns, buf.String(), want)
+	}
+
+	columns = "pid,bogus"
+	if err := ps(&buf); err == nil {
+		t.Error("unknown column: got nil, want an error")
+	}
+}
+
+func TestNoHeaders(t *testing.T) {
+	fakeProc(t, []fakeProcess{
+		{pid: 1, comm: "init", cmdline: "/init\x00"},
+		{pid: 20, comm: "sshd", cmdline: "/bin/sshd\x00"},
+	})
+
+	for _, tt := range []struct {
+		name    string
+		x, aux  bool
+		columns string
+	}{
+		{name: "default"},
+		{name: "bsd", x: true},
+		{name: "aux", aux: true},
+		{name: "columns", columns: "pid,args,pgrp"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			all, every, x, nSidTty, aux, columns = tt.aux, true, tt.x, false, tt.aux, tt.columns
+			defer func() { all, every, x, aux, columns, noHeaders = false, false, false, false, "", false }()
+
+			var with, without bytes.Buffer
+			if err := ps(&with); err != nil {
+				t.Fatal(err)
+			}
+			noHeaders = true
+			if err := ps(&without); err != nil {
+				t.Fatal(err)
+			}
+			// Only the header line goes; the rows keep their layout.
+			_, rows, ok := strings.Cut(with.String(), "\n")
+			if !ok || rows == "" {
+				t.Fatalf("ps printed %q, want a header and rows", with.String())
+			}
+			if without.String() != rows {
+				t.Errorf("ps --no-headers printed\n%q\nwant\n%q", without.String(), rows)
+			}
+		})
+	}
+}
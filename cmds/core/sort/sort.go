@@ -23,11 +23,16 @@
 //	-b: 	 Ignore leading blank characters when comparing lines.
 //	-n:      Compare according to string numerical value.
 //	-o FILE: Specify the name of an output file to be used instead of the standard output.
+//	-t CHAR: Use CHAR as the field separator instead of runs of blanks.
+//	-k KEYDEF: Sort on the fields F1[,F2] (1-based, inclusive), optionally
+//	         followed by the modifiers b, f, n or r which apply to the key only,
+//	         e.g. -k2,2n. Lines with equal keys are ordered by the whole line.
 package main
 
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -45,8 +50,15 @@ var (
 	ignoreBlanks = flag.Bool("b", false, "Ignore leading blank characters when comparing lines.")
 	numeric      = flag.Bool("n", false, "Compare according to string numerical value.")
 	outputFile   = flag.String("o", "", "Specify the name of an output file to be used instead of the standard output.")
+	key          = flag.String("k", "", "Sort via a key definition F1[,F2][bfnr].")
+	separator    string
 )
 
+func init() {
+	flag.StringVar(&separator, "t", "", "Use the given character as field separator.")
+	flag.StringVar(&separator, "field-separator", "", "Use the given character as field separator.")
+}
+
 type ignoreCaseSort []string
 
 func (a ignoreCaseSort) Len() int           { return len(a) }
@@ -95,10 +107,131 @@ func (a numericSort) Less(i, j int) bool {
 	return ln < rn
 }
 
+// keySpec is a parsed -k key definition.
+type keySpec struct {
+	// start and end are the 1-based first and last fields of the key.
+	// end is 0 when the key extends to the end of the line.
+	start, end   int
+	numeric      bool
+	reverse      bool
+	ignoreCase   bool
+	ignoreBlanks bool
+}
+
+// parseKey parses key definitions of the form F1[,F2][bfnr].
+func parseKey(def string) (*keySpec, error) {
+	k := &keySpec{}
+	pos := strings.TrimRight(def, "bfnr")
+	for _, o := range def[len(pos):] {
+		switch o {
+		case 'b':
+			k.ignoreBlanks = true
+		case 'f':
+			k.ignoreCase = true
+		case 'n':
+			k.numeric = true
+		case 'r':
+			k.reverse = true
+		}
+	}
+	start, end, hasEnd := strings.Cut(pos, ",")
+	var err error
+	if k.start, err = strconv.Atoi(start); err != nil || k.start < 1 {
+		return nil, fmt.Errorf("invalid key %q: invalid start field", def)
+	}
+	if hasEnd {
+		if k.end, err = strconv.Atoi(end); err != nil || k.end < k.start {
+			return nil, fmt.Errorf("invalid key %q: invalid end field", def)
+		}
+	}
+	return k, nil
+}
+
+// fields splits line into fields. With a separator, every separator ends a
+// field, so adjacent separators produce empty fields. Without one, a field
+// is a run of blanks followed by non-blanks, as in POSIX sort.
+func fields(line, sep string) []string {
+	if sep != "" {
+		return strings.Split(line, sep)
+	}
+	var f []string
+	start := 0
+	for i := 1; i <= len(line); i++ {
+		if i == len(line) || (unicode.IsSpace(rune(line[i])) && !unicode.IsSpace(rune(line[i-1]))) {
+			f = append(f, line[start:i])
+			start = i
+		}
+	}
+	return f
+}
+
+// extract returns the part of line selected by the key.
+func (k *keySpec) extract(line, sep string) string {
+	f := fields(line, sep)
+	if k.start > len(f) {
+		return ""
+	}
+	end := len(f)
+	if k.end != 0 && k.end < end {
+		end = k.end
+	}
+	return strings.Join(f[k.start-1:end], sep)
+}
+
+type keySort struct {
+	lines []string
+	keys  []string
+	spec  *keySpec
+}
+
+func (a keySort) Len() int { return len(a.lines) }
+func (a keySort) Swap(i, j int) {
+	a.lines[i], a.lines[j] = a.lines[j], a.lines[i]
+	a.keys[i], a.keys[j] = a.keys[j], a.keys[i]
+}
+
+func (a keySort) compareKeys(i, j int) int {
+	l, r := a.keys[i], a.keys[j]
+	if a.spec.ignoreBlanks || a.spec.numeric {
+		l = strings.TrimLeftFunc(l, unicode.IsSpace)
+		r = strings.TrimLeftFunc(r, unicode.IsSpace)
+	}
+	if a.spec.numeric {
+		// treat all non-numeric keys as zeros
+		ln, _ := strconv.ParseFloat(l, 64)
+		rn, _ := strconv.ParseFloat(r, 64)
+		switch {
+		case ln < rn:
+			return -1
+		case ln > rn:
+			return 1
+		}
+		return 0
+	}
+	if a.spec.ignoreCase {
+		l, r = strings.ToUpper(l), strings.ToUpper(r)
+	}
+	return strings.Compare(l, r)
+}
+
+func (a keySort) Less(i, j int) bool {
+	c := a.compareKeys(i, j)
+	if a.spec.reverse {
+		c = -c
+	}
+	if c == 0 {
+		// last resort comparison of the whole line
+		return a.lines[i] < a.lines[j]
+	}
+	return c < 0
+}
+
 var errNotOrdered = errors.New("not ordered")
 
 type params struct {
 	outputFile   string
+	separator    string
+	key          string
 	reverse      bool
 	ordered      bool
 	unique       bool
@@ -113,6 +246,8 @@ type cmd struct {
 	stderr io.Writer
 	params params
 	args   []string
+
+	keySpec *keySpec
 }
 
 func command(stdin io.ReadCloser, stdout, stderr io.Writer, p params, args []string) *cmd {
@@ -126,6 +261,23 @@ func command(stdin io.ReadCloser, stdout, stderr io.Writer, p params, args []str
 }
 
 func (c *cmd) run() error {
+	if len(c.params.separator) > 1 {
+		return fmt.Errorf("field separator %q must be a single character", c.params.separator)
+	}
+	if c.params.key != "" {
+		k, err := parseKey(c.params.key)
+		if err != nil {
+			return err
+		}
+		// global ordering options apply to a key without its own modifiers
+		if !k.numeric && !k.reverse && !k.ignoreCase && !k.ignoreBlanks {
+			k.numeric = c.params.numeric
+			k.ignoreCase = c.params.ignoreCase
+			k.ignoreBlanks = c.params.ignoreBlanks
+		}
+		c.keySpec = k
+	}
+
 	// Input files
 	from := []io.ReadCloser{}
 	for _, v := range c.args {
@@ -193,6 +345,12 @@ func (c *cmd) run() error {
 func (c *cmd) sortInterface(lines []string) sort.Interface {
 	var si sort.Interface
 	switch {
+	case c.keySpec != nil:
+		keys := make([]string, len(lines))
+		for i, l := range lines {
+			keys[i] = c.keySpec.extract(l, c.params.separator)
+		}
+		si = keySort{lines: lines, keys: keys, spec: c.keySpec}
 	case c.params.ignoreBlanks && c.params.ignoreCase:
 		si = ignoreBlanksCaseSort(lines)
 	case c.params.ignoreBlanks:
@@ -263,7 +421,7 @@ func (c *cmd) writeOutput(w io.Writer, s string) error {
 func main() {
 	flag.Parse()
 	p := params{reverse: *reverse, ordered: *ordered, outputFile: *outputFile, unique: *unique,
-		ignoreCase: *ignoreCase, ignoreBlanks: *ignoreBlanks, numeric: *numeric}
+		ignoreCase: *ignoreCase, ignoreBlanks: *ignoreBlanks, numeric: *numeric, separator: separator, key: *key}
 	if err := command(os.Stdin, os.Stdout, os.Stderr, p, flag.Args()).run(); err != nil {
 		if err == errNotOrdered {
 			os.Exit(1)
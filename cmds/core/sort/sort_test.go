@@ -276,3 +276,99 @@ func TestSortFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestSortKey(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		params  params
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "numeric middle column",
+			params: params{separator: ",", key: "2,2n"},
+			input:  "apple,10,red\nbanana,9,yellow\ncherry,100,red\n",
+			want:   "banana,9,yellow\napple,10,red\ncherry,100,red\n",
+		},
+		{
+			name:   "lexical middle column",
+			params: params{separator: ",", key: "2,2"},
+			input:  "apple,10,red\nbanana,9,yellow\ncherry,100,red\n",
+			want:   "apple,10,red\ncherry,100,red\nbanana,9,yellow\n",
+		},
+		{
+			name:   "empty fields",
+			params: params{separator: ",", key: "3,3n"},
+			input:  "a,,3\nb,2,\nc,,1\n",
+			want:   "b,2,\nc,,1\na,,3\n",
+		},
+		{
+			name:   "key to end of line",
+			params: params{separator: ":", key: "2"},
+			input:  "x:b:2\ny:b:1\nz:a:9\n",
+			want:   "z:a:9\ny:b:1\nx:b:2\n",
+		},
+		{
+			name:   "reverse key modifier",
+			params: params{separator: ",", key: "2,2nr"},
+			input:  "a,1\nb,3\nc,2\n",
+			want:   "b,3\nc,2\na,1\n",
+		},
+		{
+			name:   "global numeric",
+			params: params{key: "2,2", numeric: true},
+			input:  "a  10\nb 9\nc   100\n",
+			want:   "b 9\na  10\nc   100\n",
+		},
+		{
+			name:   "blank separated",
+			params: params{key: "2,2n"},
+			input:  "x 3\ny 1\nz 2\n",
+			want:   "y 1\nz 2\nx 3\n",
+		},
+		{
+			name:   "ties broken by line",
+			params: params{separator: ",", key: "2,2n"},
+			input:  "b,1\na,1\n",
+			want:   "a,1\nb,1\n",
+		},
+		{
+			name:   "ordered with key",
+			params: params{separator: ",", key: "2,2n", ordered: true},
+			input:  "b,1\na,2\n",
+		},
+		{
+			name:    "bad key",
+			params:  params{key: "0"},
+			input:   "a\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad key range",
+			params:  params{key: "3,2"},
+			input:   "a\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad separator",
+			params:  params{separator: "ab", key: "1"},
+			input:   "a\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin := io.NopCloser(strings.NewReader(tt.input))
+			stdout := &bytes.Buffer{}
+
+			err := command(stdin, stdout, nil, tt.params, nil).run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sort err: = %v, want error: %v", err, tt.wantErr)
+			}
+
+			if stdout.String() != tt.want {
+				t.Errorf("sort = %q, want: %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
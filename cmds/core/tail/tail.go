@@ -6,14 +6,19 @@
 // the end of the file as it grows.
 //
 // Synopsis:
-//     tail [-f] [-n lines_to_show] [FILE]
+//     tail [-f] [-n lines_to_show] [-q|-v] [FILE]...
 //
 // Description:
-//     If no files are specified, read from stdin.
+//     If no files are specified, read from stdin. If more than one file is
+//     specified, each is preceded by a header of the form "==> FILE <==".
+//     A file that can't be read is reported and skipped; tail goes on with
+//     the others and exits with an error at the end.
 //
 // Options:
 //     -f: follow the end of the file as it grows
 //     -n: specify the number of lines to show (default: 10)
+//     -q: never print headers
+//     -v: always print headers
 
 // Missing features:
 // - follow-mode (i.e. tail -f)
@@ -22,6 +27,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -34,8 +40,18 @@ import (
 var (
 	flagFollow   = flag.Bool("f", false, "follow the end of the file")
 	flagNumLines = flag.Int("n", 10, "specify the number of lines to show")
+	flagQuiet    bool
+	flagVerbose  bool
 )
 
+func init() {
+	flag.BoolVar(&flagQuiet, "q", false, "never print headers giving file names")
+	flag.BoolVar(&flagQuiet, "quiet", false, "never print headers giving file names")
+	flag.BoolVar(&flagQuiet, "silent", false, "never print headers giving file names")
+	flag.BoolVar(&flagVerbose, "v", false, "always print headers giving file names")
+	flag.BoolVar(&flagVerbose, "verbose", false, "always print headers giving file names")
+}
+
 type readAtSeeker interface {
 	io.ReaderAt
 	io.Seeker
@@ -264,22 +280,10 @@ func tail(inFile *os.File, writer io.Writer, config tailConfig) error {
 	return nil
 }
 
-func run(reader *os.File, writer io.Writer, follow bool, numLines int, followDuration time.Duration, args []string) error {
-	var (
-		inFile *os.File
-		err    error
-	)
-	switch len(args) {
-	case 0:
-		inFile = reader
-	case 1:
-		inFile, err = os.Open(args[0])
-		if err != nil {
-			return err
-		}
-	default:
-		// TODO support multiple files
-		return fmt.Errorf("tail: can only read one file at a time")
+func run(reader *os.File, writer io.Writer, follow bool, numLines int, followDuration time.Duration, quiet, verbose bool, args []string) error {
+	if follow && len(args) > 1 {
+		// TODO support following multiple files
+		return fmt.Errorf("tail: can only follow one file at a time")
 	}
 
 	// TODO: add support for parsing + (from beggining of the file)
@@ -288,12 +292,44 @@ func run(reader *os.File, writer io.Writer, follow bool, numLines int, followDur
 		numLines = -1 * numLines
 	}
 	config := tailConfig{follow: follow, numLines: uint(numLines), followDuration: followDuration}
-	return tail(inFile, writer, config)
+
+	headers := !quiet && (verbose || len(args) > 1)
+	if len(args) == 0 {
+		if headers {
+			fmt.Fprintf(writer, "==> standard input <==\n")
+		}
+		return tail(reader, writer, config)
+	}
+
+	// As in coreutils, a file that can't be read does not stop the others
+	// from being printed; the errors are returned at the end.
+	var errs []error
+	printed := false
+	for _, name := range args {
+		inFile, err := os.Open(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if headers {
+			if printed {
+				fmt.Fprintln(writer)
+			}
+			fmt.Fprintf(writer, "==> %s <==\n", name)
+		}
+		printed = true
+		err = tail(inFile, writer, config)
+		inFile.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func main() {
 	flag.Parse()
-	if err := run(os.Stdin, os.Stdout, *flagFollow, *flagNumLines, 500*time.Millisecond, flag.Args()); err != nil {
+	if err := run(os.Stdin, os.Stdout, *flagFollow, *flagNumLines, 500*time.Millisecond, flagQuiet, flagVerbose, flag.Args()); err != nil {
 		log.Fatalf("tail: %v", err)
 	}
 }
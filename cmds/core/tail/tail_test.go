@@ -6,8 +6,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -82,7 +84,7 @@ func TestTailRun(t *testing.T) {
 	}
 
 	var b bytes.Buffer
-	err = run(os.Stdin, &b, false, 10, time.Second, []string{f.Name()})
+	err = run(os.Stdin, &b, false, 10, time.Second, false, false, []string{f.Name()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -91,13 +93,13 @@ func TestTailRun(t *testing.T) {
 		t.Errorf("tail output does not match, want %q, got %q", input, b.String())
 	}
 
-	err = run(nil, nil, false, 10, time.Second, []string{"a", "b"})
+	err = run(nil, nil, true, 10, time.Second, false, false, []string{"a", "b"})
 	if err == nil {
-		t.Error("tail should return an error if more than one file specified")
+		t.Error("tail should return an error if more than one file is followed")
 	}
 
 	b.Truncate(0)
-	err = run(f, &b, false, -1, time.Second, nil)
+	err = run(f, &b, false, -1, time.Second, false, false, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -107,6 +109,107 @@ func TestTailRun(t *testing.T) {
 	}
 }
 
+func TestTailHeaders(t *testing.T) {
+	dir := t.TempDir()
+	f1 := filepath.Join(dir, "f1")
+	f2 := filepath.Join(dir, "f2")
+	if err := os.WriteFile(f1, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f2, []byte("c\nd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		quiet   bool
+		verbose bool
+		args    []string
+		want    string
+	}{
+		{
+			name: "two files",
+			args: []string{f1, f2},
+			want: "==> " + f1 + " <==\nb\n\n==> " + f2 + " <==\nd\n",
+		},
+		{
+			name:  "two files quiet",
+			quiet: true,
+			args:  []string{f1, f2},
+			want:  "b\nd\n",
+		},
+		{
+			name: "one file",
+			args: []string{f1},
+			want: "b\n",
+		},
+		{
+			name:    "one file verbose",
+			verbose: true,
+			args:    []string{f1},
+			want:    "==> " + f1 + " <==\nb\n",
+		},
+		{
+			name:    "quiet wins over verbose",
+			quiet:   true,
+			verbose: true,
+			args:    []string{f1},
+			want:    "b\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := run(nil, &b, false, 1, time.Second, tt.quiet, tt.verbose, tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("tail = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTailMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	f1 := filepath.Join(dir, "f1")
+	missing := filepath.Join(dir, "missing")
+	f2 := filepath.Join(dir, "f2")
+	if err := os.WriteFile(f1, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f2, []byte("c\nd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "in the middle",
+			args: []string{f1, missing, f2},
+			want: "==> " + f1 + " <==\nb\n\n==> " + f2 + " <==\nd\n",
+		},
+		{
+			name: "first",
+			args: []string{missing, f1, f2},
+			want: "==> " + f1 + " <==\nb\n\n==> " + f2 + " <==\nd\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := run(nil, &b, false, 1, time.Second, false, false, tt.args)
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("run() = %v, want %v", err, os.ErrNotExist)
+			}
+			if b.String() != tt.want {
+				t.Errorf("tail = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
 type syncWriter struct {
 	ch chan []byte
 }
@@ -133,7 +236,7 @@ func TestTailFollow(t *testing.T) {
 	}
 
 	go func() {
-		run(f, sw, true, 10, 100*time.Millisecond, nil)
+		run(f, sw, true, 10, 100*time.Millisecond, false, false, nil)
 	}()
 	ff, err := os.OpenFile(f.Name(), os.O_RDWR, 0644)
 	if err != nil {
@@ -15,31 +15,82 @@
 //	   tar -cvf x.tar file1 file2 ...    # create
 //	   tar -tvf x.tar                    # list
 //	   tar -xvf x.tar directory/         # extract
+//	   tar -xf x.tar --to-command=cmd    # extract to a command
+//	   tar -rvf x.tar file3 ...          # append
+//	   tar -uvf x.tar directory/         # append what changed
+//
+//	An archive named "-" is read from standard input or written to
+//	standard output, so tar can sit at either end of a pipe:
+//	   tar -cf - directory/ | tar -xf - out/
+//	When the archive goes to standard output, -v lists names on standard
+//	error instead. -r and -u need to seek in the archive, so they don't
+//	work on "-" or other pipes.
+//
+//	With --to-command, -x writes no files. Instead, it runs the command
+//	with /bin/sh for each regular file in the archive, with the contents
+//	of the file on its standard input, as GNU tar does. The command finds
+//	the name of the file in $TAR_FILENAME and its size in $TAR_SIZE, as
+//	well as its permissions and owner in $TAR_MODE, $TAR_UID, $TAR_GID,
+//	$TAR_UNAME and $TAR_GNAME. If the command fails, tar stops.
 //
 // Options:
 //
 //	-c: create a new tar archive from the given directory
 //	-x: extract a tar archive to the given directory
-//	-v: verbose, print each filename (optional)
-//	-f: tar filename (required)
+//	-v: verbose, print each filename (optional); with -t and -x, print
+//	    mode, owner/group, size and modification time as well
+//	-f: tar filename (required), - for standard input or output
 //	-t: list the contents of an archive
+//	-r: append files to the end of an existing archive
+//	-u: like -r, but only append files newer than their copy in the archive
+//	--owner: with -c, record this user name or uid as owner of all members
+//	--group: with -c, record this group name or gid as group of all members
+//	--mode: with -c, record these octal permission bits for all members
+//	-k, --keep-old-files: with -x, fail if a file to extract already exists
+//	--skip-old-files: with -x, silently leave existing files alone
+//	--overwrite: with -x, write over existing files in place; by default
+//	             they are removed and created anew
+//	--to-command: with -x, pipe each file to this command rather than
+//	              writing it; no directory is given
+//	-S, --sparse: with -c, -r and -u, store files with holes in the GNU
+//	              sparse format, leaving out runs of zero blocks; with -x,
+//	              turn such runs into holes. Sparse members are always
+//	              extracted as sparse files.
+//	--transform: rename members as they are stored or extracted, and the
+//	             targets of links, with a sed-like s/pattern/replacement/
+//	             expression, e.g. s,^src/,dst/,. pattern is a Go regexp,
+//	             and the replacement may use & for the match and \1 to \9
+//	             for its groups. A g flag replaces every match rather than
+//	             the first. Members renamed to nothing are left out.
 //
 // TODO: The arguments deviates slightly from gnu tar.
 package main
 
 import (
+	"archive/tar"
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/tarutil"
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
+// stdio is the archive name standing for standard input or output.
+const stdio = "-"
+
 type cmd struct {
-	p    params
-	args []string
+	p      params
+	args   []string
+	stdin  io.Reader
+	stdout io.Writer
 }
 
 type params struct {
@@ -47,8 +98,19 @@ type params struct {
 	create      bool
 	extract     bool
 	list        bool
+	append      bool
+	update      bool
 	noRecursion bool
 	verbose     bool
+	owner       string
+	group       string
+	mode        string
+	keepOld     bool
+	skipOld     bool
+	overwrite   bool
+	sparse      bool
+	toCommand   string
+	transform   string
 }
 
 var (
@@ -56,8 +118,15 @@ var (
 	errCreateAndList        = fmt.Errorf("cannot supply both -c and -t")
 	errExtractAndList       = fmt.Errorf("cannot supply both -x and -t")
 	errEmptyFile            = fmt.Errorf("file is required")
-	errMissingMandatoryFlag = fmt.Errorf("must supply at least one of: -c, -x, -t")
+	errMissingMandatoryFlag = fmt.Errorf("must supply at least one of: -c, -x, -t, -r, -u")
+	errAppendMode           = fmt.Errorf("-r and -u can't be combined with each other or -c, -x or -t")
+	errAppendStdio          = fmt.Errorf("-r and -u need a seekable archive, not standard input or output")
 	errExtractArgsLen       = fmt.Errorf("args length should be 1")
+	errOverrideNotCreate    = fmt.Errorf("--owner, --group and --mode require -c, -r or -u")
+	errExistingMode         = fmt.Errorf("only one of --keep-old-files, --skip-old-files and --overwrite can be given")
+	errExistingNotExtract   = fmt.Errorf("--keep-old-files, --skip-old-files and --overwrite require -x")
+	errToCommand            = fmt.Errorf("--to-command requires -x and takes no directory")
+	errTransform            = fmt.Errorf("want s/pattern/replacement/ with an optional g flag")
 )
 
 func command(p params, args []string) (*cmd, error) {
@@ -70,63 +139,340 @@ func command(p params, args []string) (*cmd, error) {
 	if p.extract && p.list {
 		return nil, errExtractAndList
 	}
-	if p.extract && len(args) != 1 {
+	if (p.append || p.update) && (p.append && p.update || p.create || p.extract || p.list) {
+		return nil, errAppendMode
+	}
+	if p.toCommand != "" && (!p.extract || len(args) != 0) {
+		return nil, errToCommand
+	}
+	if p.extract && p.toCommand == "" && len(args) != 1 {
 		return nil, errExtractArgsLen
 	}
-	if !p.extract && !p.create && !p.list {
+	if !p.extract && !p.create && !p.list && !p.append && !p.update {
 		return nil, errMissingMandatoryFlag
 	}
 	if p.file == "" {
 		return nil, errEmptyFile
 	}
+	if (p.append || p.update) && p.file == stdio {
+		return nil, errAppendStdio
+	}
+	if !p.create && !p.append && !p.update && (p.owner != "" || p.group != "" || p.mode != "") {
+		return nil, errOverrideNotCreate
+	}
+	if countTrue(p.keepOld, p.skipOld, p.overwrite) > 1 {
+		return nil, errExistingMode
+	}
+	if !p.extract && (p.keepOld || p.skipOld || p.overwrite) {
+		return nil, errExistingNotExtract
+	}
 
 	return &cmd{
-		p:    p,
-		args: args,
+		p:      p,
+		args:   args,
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
 	}, nil
 }
 
-func (c *cmd) run() error {
-	opts := &tarutil.Opts{
-		NoRecursion: c.p.noRecursion,
+func countTrue(b ...bool) int {
+	var n int
+	for _, v := range b {
+		if v {
+			n++
+		}
 	}
-	if c.p.verbose {
-		opts.Filters = []tarutil.Filter{tarutil.VerboseFilter}
+	return n
+}
+
+// appendTo adds the files to the end of the archive, creating it if it does
+// not exist yet.
+func (c *cmd) appendTo(opts *tarutil.Opts) error {
+	f, err := os.OpenFile(c.p.file, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	if err := tarutil.AppendTar(f, c.args, opts, c.p.update); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	switch {
-	case c.p.create:
-		f, err := os.Create(c.p.file)
-		if err != nil {
+// archiveReader opens the archive for extraction or listing. Standard
+// input is usually a pipe, so nothing may seek on the returned reader.
+func (c *cmd) archiveReader() (io.ReadCloser, error) {
+	if c.p.file == stdio {
+		return io.NopCloser(bufio.NewReader(c.stdin)), nil
+	}
+	return os.Open(c.p.file)
+}
+
+// create writes the archive, to standard output if the file is "-".
+func (c *cmd) create(opts *tarutil.Opts) error {
+	if c.p.file == stdio {
+		w := bufio.NewWriter(c.stdout)
+		if err := tarutil.CreateTar(w, c.args, opts); err != nil {
 			return err
 		}
-		if err := tarutil.CreateTar(f, c.args, opts); err != nil {
-			f.Close()
-			return err
+		return w.Flush()
+	}
+	f, err := os.Create(c.p.file)
+	if err != nil {
+		return err
+	}
+	if err := tarutil.CreateTar(f, c.args, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lookupOwner resolves a user name or numeric uid.
+func lookupOwner(owner string) (uid int, name string, err error) {
+	if id, err := strconv.Atoi(owner); err == nil {
+		if u, err := user.LookupId(owner); err == nil {
+			name = u.Username
 		}
-		if err := f.Close(); err != nil {
-			return err
+		return id, name, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, "", err
+	}
+	uid, err = strconv.Atoi(u.Uid)
+	return uid, u.Username, err
+}
+
+// lookupGroup resolves a group name or numeric gid.
+func lookupGroup(group string) (gid int, name string, err error) {
+	if id, err := strconv.Atoi(group); err == nil {
+		if g, err := user.LookupGroupId(group); err == nil {
+			name = g.Name
 		}
-	case c.p.extract:
-		f, err := os.Open(c.p.file)
-		if err != nil {
-			return err
+		return id, name, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, "", err
+	}
+	gid, err = strconv.Atoi(g.Gid)
+	return gid, g.Name, err
+}
+
+// overrideFilter returns a filter which replaces the ownership and
+// permissions recorded for each member, or nil if there is nothing to
+// override.
+func (c *cmd) overrideFilter() (tarutil.Filter, error) {
+	if c.p.owner == "" && c.p.group == "" && c.p.mode == "" {
+		return nil, nil
+	}
+	var (
+		uid, gid     int
+		uname, gname string
+		mode         int64
+		err          error
+	)
+	if c.p.owner != "" {
+		if uid, uname, err = lookupOwner(c.p.owner); err != nil {
+			return nil, fmt.Errorf("invalid owner %q: %w", c.p.owner, err)
 		}
-		defer f.Close()
-		if err := tarutil.ExtractDir(f, c.args[0], opts); err != nil {
-			return err
+	}
+	if c.p.group != "" {
+		if gid, gname, err = lookupGroup(c.p.group); err != nil {
+			return nil, fmt.Errorf("invalid group %q: %w", c.p.group, err)
 		}
-	case c.p.list:
-		f, err := os.Open(c.p.file)
-		if err != nil {
-			return err
+	}
+	if c.p.mode != "" {
+		if mode, err = strconv.ParseInt(c.p.mode, 8, 64); err != nil || mode&^0o7777 != 0 {
+			return nil, fmt.Errorf("invalid mode %q", c.p.mode)
 		}
-		defer f.Close()
-		if err := tarutil.ListArchive(f); err != nil {
-			return err
+	}
+	return func(hdr *tar.Header) bool {
+		if c.p.owner != "" {
+			hdr.Uid, hdr.Uname = uid, uname
+		}
+		if c.p.group != "" {
+			hdr.Gid, hdr.Gname = gid, gname
+		}
+		if c.p.mode != "" {
+			hdr.Mode = hdr.Mode&^0o7777 | mode
 		}
+		return true
+	}, nil
+}
+
+// parseTransform parses a --transform expression, s/pattern/replacement/
+// with an optional g flag, into a function which renames a member. Any
+// character after the s may take the place of the slashes; a backslash
+// before it makes it part of the pattern or replacement instead.
+func parseTransform(expr string) (func(string) string, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return nil, errTransform
 	}
+	delim := expr[1]
+	var parts []string
+	var b strings.Builder
+	for i := 2; i < len(expr); i++ {
+		switch ch := expr[i]; {
+		case ch == '\\' && i+1 < len(expr) && expr[i+1] == delim:
+			b.WriteByte(delim)
+			i++
+		case ch == '\\' && i+1 < len(expr):
+			b.WriteString(expr[i : i+2])
+			i++
+		case ch == delim && len(parts) < 2:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if len(parts) != 2 {
+		return nil, errTransform
+	}
+	var global bool
+	switch b.String() {
+	case "":
+	case "g":
+		global = true
+	default:
+		return nil, fmt.Errorf("%w: unknown flags %q", errTransform, b.String())
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// Turn the sed replacement into a template for regexp.Expand.
+	var tmpl strings.Builder
+	repl := parts[1]
+	for i := 0; i < len(repl); i++ {
+		switch ch := repl[i]; {
+		case ch == '\\' && i+1 < len(repl):
+			i++
+			if d := repl[i]; d >= '0' && d <= '9' {
+				fmt.Fprintf(&tmpl, "${%c}", d)
+			} else if d == '$' {
+				tmpl.WriteString("$$")
+			} else {
+				tmpl.WriteByte(d)
+			}
+		case ch == '&':
+			tmpl.WriteString("${0}")
+		case ch == '$':
+			tmpl.WriteString("$$")
+		default:
+			tmpl.WriteByte(ch)
+		}
+	}
+	t := tmpl.String()
+
+	return func(name string) string {
+		if global {
+			return re.ReplaceAllString(name, t)
+		}
+		loc := re.FindStringSubmatchIndex(name)
+		if loc == nil {
+			return name
+		}
+		return name[:loc[0]] + string(re.ExpandString(nil, t, name, loc)) + name[loc[1]:]
+	}, nil
+}
 
+// transformFilter returns a filter which renames each member, and the
+// target of hard and symbolic links, with the --transform expression, or
+// nil if there is none. Members renamed to nothing are left out; link
+// targets renamed to nothing are kept as they were.
+func (c *cmd) transformFilter() (tarutil.Filter, error) {
+	if c.p.transform == "" {
+		return nil, nil
+	}
+	rename, err := parseTransform(c.p.transform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --transform %q: %w", c.p.transform, err)
+	}
+	return func(hdr *tar.Header) bool {
+		hdr.Name = rename(hdr.Name)
+		if hdr.Typeflag == tar.TypeLink || hdr.Typeflag == tar.TypeSymlink {
+			if target := rename(hdr.Linkname); target != "" {
+				hdr.Linkname = target
+			}
+		}
+		return hdr.Name != ""
+	}, nil
+}
+
+func (c *cmd) run() error {
+	opts := &tarutil.Opts{
+		NoRecursion: c.p.noRecursion,
+		Sparse:      c.p.sparse,
+	}
+	switch {
+	case c.p.keepOld:
+		opts.Existing = tarutil.KeepExisting
+	case c.p.skipOld:
+		opts.Existing = tarutil.SkipExisting
+	case c.p.overwrite:
+		opts.Existing = tarutil.OverwriteExisting
+	}
+	override, err := c.overrideFilter()
+	if err != nil {
+		return err
+	}
+	if override != nil {
+		opts.Filters = append(opts.Filters, override)
+	}
+	transform, err := c.transformFilter()
+	if err != nil {
+		return err
+	}
+	if transform != nil {
+		opts.Filters = append(opts.Filters, transform)
+	}
+	if c.p.verbose {
+		switch {
+		case c.p.extract:
+			opts.Filters = append(opts.Filters, tarutil.LongVerboseFilter)
+		case c.p.file == stdio:
+			// Standard output carries the archive.
+			opts.Filters = append(opts.Filters, tarutil.VerboseLogFilter)
+		default:
+			opts.Filters = append(opts.Filters, tarutil.VerboseFilter)
+		}
+	}
+
+	if c.p.create {
+		return c.create(opts)
+	}
+	if c.p.append || c.p.update {
+		return c.appendTo(opts)
+	}
+
+	f, err := c.archiveReader()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if c.p.extract && c.p.toCommand != "" {
+		err = tarutil.ExtractToCommand(f, c.p.toCommand, opts)
+	} else if c.p.extract {
+		err = tarutil.ExtractDir(f, c.args[0], opts)
+	} else if c.p.verbose {
+		err = tarutil.ListArchiveVerbose(f)
+	} else {
+		err = tarutil.ListArchive(f)
+	}
+	if err != nil {
+		return err
+	}
+	if c.p.file == stdio {
+		// The archive ends before the padding of its last block. Read
+		// that too, so the writing end of the pipe is not cut short.
+		if _, err := io.Copy(io.Discard, f); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -136,8 +482,19 @@ func main() {
 		extract     bool
 		file        string
 		list        bool
+		appendFiles bool
+		update      bool
 		noRecursion bool
 		verbose     bool
+		owner       string
+		group       string
+		mode        string
+		keepOld     bool
+		skipOld     bool
+		overwrite   bool
+		sparse      bool
+		toCommand   string
+		transform   string
 	)
 	f := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
@@ -153,13 +510,36 @@ func main() {
 	f.BoolVar(&list, "list", false, "list the contents of an archive")
 	f.BoolVar(&list, "t", false, "list the contents of an archive (shorthand)")
 
+	f.BoolVar(&appendFiles, "append", false, "append files to the end of an archive")
+	f.BoolVar(&appendFiles, "r", false, "append files to the end of an archive (shorthand)")
+
+	f.BoolVar(&update, "update", false, "append files newer than their copy in the archive")
+	f.BoolVar(&update, "u", false, "append files newer than their copy in the archive (shorthand)")
+
 	f.BoolVar(&noRecursion, "no-recursion", false, "do not automatically recurse into directories")
 
 	f.BoolVar(&verbose, "verbose", false, "print each filename")
 	f.BoolVar(&verbose, "v", false, "print each filename (shorthand)")
 
+	f.StringVar(&owner, "owner", "", "force user name or uid for added files")
+	f.StringVar(&group, "group", "", "force group name or gid for added files")
+	f.StringVar(&mode, "mode", "", "force octal permission bits for added files")
+
+	f.BoolVar(&keepOld, "keep-old-files", false, "fail if a file to extract already exists")
+	f.BoolVar(&keepOld, "k", false, "fail if a file to extract already exists (shorthand)")
+	f.BoolVar(&skipOld, "skip-old-files", false, "do not replace existing files when extracting")
+	f.BoolVar(&overwrite, "overwrite", false, "overwrite existing files in place when extracting")
+
+	f.BoolVar(&sparse, "sparse", false, "handle sparse files efficiently")
+	f.BoolVar(&sparse, "S", false, "handle sparse files efficiently (shorthand)")
+
+	f.StringVar(&toCommand, "to-command", "", "pipe extracted files to this command")
+
+	f.StringVar(&transform, "transform", "", "rename members with a sed-like s/pattern/replacement/[g] expression")
+
 	f.Parse(unixflag.OSArgsToGoArgs())
-	cmd, err := command(params{file: file, create: create, extract: extract, list: list, noRecursion: noRecursion, verbose: verbose}, f.Args())
+	cmd, err := command(params{file: file, create: create, extract: extract, list: list, append: appendFiles, update: update, noRecursion: noRecursion, verbose: verbose,
+		owner: owner, group: group, mode: mode, keepOld: keepOld, skipOld: skipOld, overwrite: overwrite, sparse: sparse, toCommand: toCommand, transform: transform}, f.Args())
 	if err != nil {
 		f.Usage()
 		log.Fatal(err)
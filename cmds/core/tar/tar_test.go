@@ -5,8 +5,15 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -134,6 +141,41 @@ func TestCommandErrors(t *testing.T) {
 			p:    params{extract: true, file: ""},
 			args: []string{"1"},
 		},
+		{
+			err: errAppendMode,
+			p:   params{append: true, update: true, file: "x.tar"},
+		},
+		{
+			err: errAppendMode,
+			p:   params{append: true, create: true, file: "x.tar"},
+		},
+		{
+			err:  errAppendMode,
+			p:    params{update: true, extract: true, file: "x.tar"},
+			args: []string{"1"},
+		},
+		{
+			err: errAppendStdio,
+			p:   params{append: true, file: "-"},
+		},
+		{
+			err:  errExistingMode,
+			p:    params{extract: true, file: "x.tar", keepOld: true, overwrite: true},
+			args: []string{"1"},
+		},
+		{
+			err: errExistingNotExtract,
+			p:   params{create: true, file: "x.tar", skipOld: true},
+		},
+		{
+			err: errToCommand,
+			p:   params{create: true, file: "x.tar", toCommand: "cat"},
+		},
+		{
+			err:  errToCommand,
+			p:    params{extract: true, file: "x.tar", toCommand: "cat"},
+			args: []string{"1"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -143,3 +185,549 @@ func TestCommandErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "dir"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "dir", "file"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		p         params
+		wantUID   int
+		wantGID   int
+		wantUname string
+		wantGname string
+		wantMode  map[string]int64
+	}{
+		{
+			name:     "numeric ids",
+			p:        params{owner: "1234", group: "5678"},
+			wantUID:  1234,
+			wantGID:  5678,
+			wantMode: map[string]int64{"dir": 0o700, "dir/file": 0o600},
+		},
+		{
+			name:      "names",
+			p:         params{owner: "root", group: "root", mode: "644"},
+			wantUname: "root",
+			wantGname: "root",
+			wantMode:  map[string]int64{"dir": 0o644, "dir/file": 0o644},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.p.file = "out.tar"
+			tt.p.create = true
+			c, err := command(tt.p, []string{"dir"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open("out.tar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			tr := tar.NewReader(f)
+			var n int
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				n++
+				if hdr.Uid != tt.wantUID || hdr.Gid != tt.wantGID {
+					t.Errorf("%s: uid, gid = %d, %d, want %d, %d", hdr.Name, hdr.Uid, hdr.Gid, tt.wantUID, tt.wantGID)
+				}
+				if tt.wantUname != "" && hdr.Uname != tt.wantUname {
+					t.Errorf("%s: uname = %q, want %q", hdr.Name, hdr.Uname, tt.wantUname)
+				}
+				if tt.wantGname != "" && hdr.Gname != tt.wantGname {
+					t.Errorf("%s: gname = %q, want %q", hdr.Name, hdr.Gname, tt.wantGname)
+				}
+				if got := hdr.Mode & 0o7777; got != tt.wantMode[hdr.Name] {
+					t.Errorf("%s: mode = %o, want %o", hdr.Name, got, tt.wantMode[hdr.Name])
+				}
+			}
+			if n != len(tt.wantMode) {
+				t.Errorf("got %d members, want %d", n, len(tt.wantMode))
+			}
+		})
+	}
+}
+
+func TestOverrideErrors(t *testing.T) {
+	if _, err := command(params{file: "x.tar", list: true, owner: "0"}, nil); err != errOverrideNotCreate {
+		t.Errorf("expected %v, got %v", errOverrideNotCreate, err)
+	}
+	for _, p := range []params{
+		{file: "x.tar", create: true, mode: "999"},
+		{file: "x.tar", create: true, mode: "17777"},
+		{file: "x.tar", create: true, owner: "no-such-user-u-root"},
+		{file: "x.tar", create: true, group: "no-such-group-u-root"},
+	} {
+		c, err := command(p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.overrideFilter(); err == nil {
+			t.Errorf("overrideFilter(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("src", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join("src", "a"):        "hello from a pipe",
+		filepath.Join("src", "sub", "b"): "and from a subdirectory",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create, err := command(params{file: "-", create: true, verbose: true}, []string{"src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extract, err := command(params{file: "-", extract: true}, []string{"out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("out", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// An io.Pipe can't seek, just like a shell pipe.
+	r, w := io.Pipe()
+	create.stdout = w
+	extract.stdin = r
+	errc := make(chan error, 1)
+	go func() {
+		err := create.run()
+		w.CloseWithError(err)
+		errc <- err
+	}()
+	if err := extract.run(); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for name, content := range files {
+		b, err := os.ReadFile(filepath.Join("out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: got %q, want %q", name, b, content)
+		}
+	}
+}
+
+func TestPipeList(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("file", []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	create, err := command(params{file: "-", create: true}, []string{"file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	create.stdout = &archive
+	if err := create.run(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Len() == 0 || archive.Len()%512 != 0 {
+		t.Fatalf("archive is %d bytes, want a positive multiple of 512", archive.Len())
+	}
+
+	list, err := command(params{file: "-", list: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hide the archive behind a plain io.Reader so it can't be seeked.
+	list.stdin = struct{ io.Reader }{&archive}
+	if err := list.run(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Len() != 0 {
+		t.Errorf("%d bytes of the archive were left unread", archive.Len())
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"first": "in the archive", "second": "appended"} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, step := range []struct {
+		p    params
+		args []string
+	}{
+		{p: params{file: "x.tar", create: true}, args: []string{"first"}},
+		{p: params{file: "x.tar", append: true}, args: []string{"second"}},
+		{p: params{file: "x.tar", extract: true}, args: []string{"out"}},
+	} {
+		c, err := command(step.p, step.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.run(); err != nil {
+			t.Fatalf("%+v: %v", step.p, err)
+		}
+	}
+
+	for name, content := range map[string]string{"first": "in the archive", "second": "appended"} {
+		b, err := os.ReadFile(filepath.Join("out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: got %q, want %q", name, b, content)
+		}
+	}
+}
+
+func TestExtractExisting(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("file", []byte("in the archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := command(params{file: "x.tar", create: true}, []string{"file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		p       params
+		err     error
+		content string
+	}{
+		{name: "default", content: "in the archive"},
+		{name: "overwrite", p: params{overwrite: true}, content: "in the archive"},
+		{name: "keep-old-files", p: params{keepOld: true}, err: os.ErrExist, content: "already there"},
+		{name: "skip-old-files", p: params{skipOld: true}, content: "already there"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(tmpDir, tt.name)
+			if err := os.Mkdir(out, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(out, "file"), []byte("already there"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tt.p.file, tt.p.extract = "x.tar", true
+			c, err := command(tt.p, []string{out})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run: got %v, want %v", err, tt.err)
+			}
+			b, err := os.ReadFile(filepath.Join(out, "file"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.content {
+				t.Errorf("got %q, want %q", b, tt.content)
+			}
+		})
+	}
+}
+
+func TestToCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skipf("no shell: %v", err)
+	}
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"dir/a": "first\n", "dir/b": "second\n"} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := command(params{file: "x.tar", create: true}, []string{"dir"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RECORD", filepath.Join(tmpDir, "record"))
+	c, err = command(params{file: "x.tar", extract: true, toCommand: `{ echo "$TAR_FILENAME $TAR_SIZE"; cat; } >> "$RECORD"`}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dir/a 6\nfirst\ndir/b 7\nsecond\n"; string(got) != want {
+		t.Errorf("command got %q, want %q", got, want)
+	}
+
+	c, err = command(params{file: "x.tar", extract: true, toCommand: "exit 1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *exec.ExitError
+	if err := c.run(); !errors.As(err, &exitErr) {
+		t.Errorf("run with a failing command: got %v, want an exit error", err)
+	}
+}
+
+func TestSparse(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	const size = 1 << 20
+	content := []byte("data before a hole")
+	f, err := os.Create("sparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		p    params
+		args []string
+	}{
+		{p: params{file: "x.tar", create: true, sparse: true}, args: []string{"sparse"}},
+		{p: params{file: "x.tar", extract: true}, args: []string{"out"}},
+	} {
+		c, err := command(step.p, step.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.run(); err != nil {
+			t.Fatalf("%+v: %v", step.p, err)
+		}
+	}
+
+	fi, err := os.Stat("x.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() >= size {
+		t.Errorf("archive is %d bytes, want the hole left out", fi.Size())
+	}
+	b, err := os.ReadFile(filepath.Join("out", "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(content, make([]byte, size-len(content))...)
+	if !bytes.Equal(b, want) {
+		t.Errorf("extracted %d bytes starting %q, want %d bytes starting %q", len(b), b[:min(len(b), len(content))], size, content)
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		in   string
+		want string
+	}{
+		{expr: "s/^src/dst/", in: "src/a", want: "dst/a"},
+		{expr: "s/^src/dst/", in: "other/src", want: "other/src"},
+		{expr: "s/a/b/", in: "banana", want: "bbnana"},
+		{expr: "s/a/b/g", in: "banana", want: "bbnbnb"},
+		{expr: `s,^\(.*\)\.txt$,\1.md,`, in: "doc/x.txt", want: "doc/x.txt"},
+		{expr: `s,^(.*)\.txt$,\1.md,`, in: "doc/x.txt", want: "doc/x.md"},
+		{expr: `s/[0-9]+/<&>/g`, in: "v1/r22", want: "v<1>/r<22>"},
+		{expr: `s/x/\&$1/`, in: "x", want: "&$1"},
+		{expr: `s/\//_/g`, in: "a/b/c", want: "a_b_c"},
+		{expr: "s|^|prefix/|", in: "a", want: "prefix/a"},
+		{expr: "s/.*//", in: "gone", want: ""},
+	} {
+		rename, err := parseTransform(tt.expr)
+		if err != nil {
+			t.Errorf("parseTransform(%q) = %v, want nil", tt.expr, err)
+			continue
+		}
+		if got := rename(tt.in); got != tt.want {
+			t.Errorf("parseTransform(%q)(%q) = %q, want %q", tt.expr, tt.in, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "s", "y/a/b/", "s/a/b", "s/a/", "s/a/b/x", "s/a/b/g/", "s/(/b/"} {
+		if _, err := parseTransform(expr); err == nil {
+			t.Errorf("parseTransform(%q) = nil, want an error", expr)
+		}
+	}
+	if _, err := parseTransform("s/a/b/i"); !errors.Is(err, errTransform) {
+		t.Errorf("parseTransform with unknown flags = %v, want %v", err, errTransform)
+	}
+}
+
+// archiveNames returns the names of the members of the archive file, with
+// the targets of links after "->".
+func archiveNames(t *testing.T, file string) []string {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := hdr.Name
+		if hdr.Linkname != "" {
+			name += " -> " + hdr.Linkname
+		}
+		names = append(names, name)
+	}
+}
+
+func TestTransformCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("src", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("src", "sub", "file"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src/sub/file", filepath.Join("src", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := command(params{file: "out.tar", create: true, transform: "s,^src,dst,"}, []string{"src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dst", "dst/link -> dst/sub/file", "dst/sub", "dst/sub/file"}
+	if got := archiveNames(t, "out.tar"); !slices.Equal(got, want) {
+		t.Errorf("got members %q, want %q", got, want)
+	}
+
+	// Members renamed to nothing are left out.
+	c, err = command(params{file: "out.tar", create: true, transform: "s,^src/sub.*,,"}, []string{"src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"src", "src/link -> src/sub/file"}
+	if got := archiveNames(t, "out.tar"); !slices.Equal(got, want) {
+		t.Errorf("got members %q, want %q", got, want)
+	}
+}
+
+func TestTransformExtract(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("src", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("src", "sub", "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := command(params{file: "in.tar", create: true}, []string{"src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = command(params{file: "in.tar", extract: true, transform: `s/\.txt$/.md/`}, []string{"out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join("out", "src", "sub", "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("got %q, want %q", b, "hello")
+	}
+	if _, err := os.Stat(filepath.Join("out", "src", "sub", "a.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a.txt was extracted under its old name: %v", err)
+	}
+
+	c, err = command(params{file: "in.tar", extract: true, transform: "s/a/b"}, []string{"out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); !errors.Is(err, errTransform) {
+		t.Errorf("run() with a bad --transform = %v, want %v", err, errTransform)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/upath"
+)
+
+// linkRE matches href and src attributes, with double, single or no quotes.
+var linkRE = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// links returns the absolute URLs referenced by href and src attributes in
+// page, resolved against base.
+func links(base *url.URL, page []byte) []*url.URL {
+	var urls []*url.URL
+	for _, m := range linkRE.FindAllSubmatch(page, -1) {
+		ref := string(bytes.Join(m[1:], nil))
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			continue
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// localPath returns the path under prefix at which u is mirrored. A u whose
+// path, once decoded, climbs out of the directory of its host with .., as
+// /%2e%2e/x does, is an error, so that a server can't have files written
+// outside the prefix.
+func localPath(prefix string, u *url.URL) (string, error) {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	host, err := upath.SafeFilepathJoin(prefix, u.Host)
+	if err != nil {
+		return "", err
+	}
+	return upath.SafeFilepathJoin(host, filepath.FromSlash(strings.TrimPrefix(p, "/")))
+}
+
+type target struct {
+	u     *url.URL
+	depth int
+}
+
+// mirror downloads root and, breadth first, the pages it links to up to
+// c.level levels deep. Failures to fetch linked pages are reported once
+// the whole tree has been walked.
+func (c *cmd) mirror(ctx context.Context, root *url.URL) error {
+	allowed := map[string]bool{root.Host: true}
+	for _, d := range strings.Split(c.domains, ",") {
+		if d != "" {
+			allowed[d] = true
+		}
+	}
+	seen := map[string]bool{root.String(): true}
+	queue := []target{{u: root}}
+
+	var errs error
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+
+		page, err := c.save(ctx, t.u)
+		if err != nil {
+			if t.u == root {
+				return err
+			}
+			log.Printf("%v: %v", t.u, err)
+			errs = errors.Join(errs, fmt.Errorf("%v: %w", t.u, err))
+			continue
+		}
+		if page == nil || t.depth >= c.level {
+			continue
+		}
+		for _, l := range links(t.u, page) {
+			if (l.Scheme != "http" && l.Scheme != "https") || !allowed[l.Host] || seen[l.String()] {
+				continue
+			}
+			seen[l.String()] = true
+			queue = append(queue, target{u: l, depth: t.depth + 1})
+		}
+	}
+	return errs
+}
+
+// save downloads u into its mirrored location. If the content is HTML, it
+// is returned so its links can be followed.
+func (c *cmd) save(ctx context.Context, u *url.URL) ([]byte, error) {
+	p, err := localPath(c.prefix, u)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.schemes.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if rc, ok := r.(io.Closer); ok {
+		defer rc.Close()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Only HTML is kept in memory, everything else is streamed.
+	var head [512]byte
+	n, err := io.ReadFull(r, head[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	isHTML := strings.HasPrefix(http.DetectContentType(head[:n]), "text/html")
+
+	var page bytes.Buffer
+	w := io.Writer(f)
+	if isHTML {
+		w = io.MultiWriter(f, &page)
+	}
+	if _, err := io.Copy(w, io.MultiReader(bytes.NewReader(head[:n]), r)); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if !isHTML {
+		return nil, nil
+	}
+	return page.Bytes(), nil
+}
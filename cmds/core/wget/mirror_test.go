@@ -0,0 +1,204 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type site struct {
+	mu    sync.Mutex
+	hits  map[string]int
+	pages map[string]string
+}
+
+func (s *site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	s.mu.Unlock()
+	page, ok := s.pages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(page))
+}
+
+func TestMirror(t *testing.T) {
+	s := &site{
+		hits: map[string]int{},
+		pages: map[string]string{
+			"/":            `<html><body><a href="a.html">a</a> <a href='/dir/'>dir</a> <a href="http://elsewhere.invalid/x">x</a></body></html>`,
+			"/a.html":      `<html><body><a href="/">home</a><img src="img.bin"><a href=b.html#top>b</a></body></html>`,
+			"/b.html":      `<html><body><a href="c.html">c</a></body></html>`,
+			"/c.html":      `<html><body>too deep</body></html>`,
+			"/img.bin":     "\x00\x01\x02binary",
+			"/dir/":        `<html><body><a href="../a.html">a</a></body></html>`,
+			"/unreachable": "nobody links here",
+		},
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c, err := command("wget", "-r", "-l", "2", "-P", dir, srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]string{
+		"index.html":     s.pages["/"],
+		"a.html":         s.pages["/a.html"],
+		"b.html":         s.pages["/b.html"],
+		"img.bin":        s.pages["/img.bin"],
+		"dir/index.html": s.pages["/dir/"],
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, u.Host, p))
+		if err != nil {
+			t.Errorf("%s was not saved: %v", p, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", p, got, want)
+		}
+	}
+	for _, p := range []string{"c.html", "unreachable"} {
+		if _, err := os.Stat(filepath.Join(dir, u.Host, p)); !os.IsNotExist(err) {
+			t.Errorf("%s should not have been fetched: %v", p, err)
+		}
+	}
+	for p, n := range s.hits {
+		if n != 1 {
+			t.Errorf("%s was fetched %d times, want 1", p, n)
+		}
+	}
+}
+
+func TestMirrorBrokenLink(t *testing.T) {
+	s := &site{
+		hits: map[string]int{},
+		pages: map[string]string{
+			"/": `<html><a href="/missing">m</a></html>`,
+		},
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c, err := command("wget", "-r", "-P", t.TempDir(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err == nil {
+		t.Errorf("run() = nil, want error for broken link")
+	}
+}
+
+func TestMirrorOutsidePrefix(t *testing.T) {
+	s := &site{
+		hits: map[string]int{},
+		pages: map[string]string{
+			"/":              `<html><a href="/%2e%2e/%2e%2e/%2e%2e/evil">evil</a><a href="/a/%2e%2e/ok.html">ok</a></html>`,
+			"/../../../evil": "escaped",
+			"/a/../ok.html":  "fine",
+		},
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := t.TempDir()
+	prefix := filepath.Join(base, "a", "b", "c")
+	c, err := command("wget", "-r", "-P", prefix, srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err == nil {
+		t.Errorf("run() = nil, want an error for the link out of the prefix")
+	}
+	if n := s.hits["/../../../evil"]; n != 0 {
+		t.Errorf("the link out of the prefix was fetched %d times, want 0", n)
+	}
+	if err := filepath.Walk(base, func(p string, _ os.FileInfo, err error) error {
+		if filepath.Base(p) == "evil" {
+			t.Errorf("%s was written", p)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A .. that stays in the directory of the host is fine.
+	if got, err := os.ReadFile(filepath.Join(prefix, u.Host, "ok.html")); err != nil || string(got) != "fine" {
+		t.Errorf("ok.html = %q, %v, want %q", got, err, "fine")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	for _, tt := range []struct {
+		url  string
+		want string
+	}{
+		{url: "http://example.com", want: "p/example.com/index.html"},
+		{url: "http://example.com/dir/", want: "p/example.com/dir/index.html"},
+		{url: "http://example.com/a/b.html", want: "p/example.com/a/b.html"},
+		{url: "http://example.com/a/%2e%2e/b.html", want: "p/example.com/b.html"},
+		{url: "http://example.com/%2e%2e/b.html"},
+		{url: "http://example.com/a/%2e%2e/%2e%2e/%2e%2e/evil"},
+		{url: "http://../evil"},
+	} {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := localPath("p", u)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("localPath(%q) = %q, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("localPath(%q) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	base, err := url.Parse("http://example.com/dir/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := links(base, []byte(`<a HREF="x.html#frag"><img src='/i.png'><script src=s.js></script><a href="https://other.com/">`))
+	want := []string{
+		"http://example.com/dir/x.html",
+		"http://example.com/i.png",
+		"http://example.com/dir/s.js",
+		"https://other.com/",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("links() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("links()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
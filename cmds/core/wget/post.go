@@ -0,0 +1,105 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/curl"
+)
+
+var (
+	errPostBoth   = errors.New("--post-data and --post-file are mutually exclusive")
+	errPostMirror = errors.New("--post-data, --post-file and --header can not be used with -r")
+	errPostScheme = errors.New("--post-data, --post-file and --header need an http or https URL")
+	errHeader     = errors.New("--header needs NAME: VALUE")
+)
+
+// postContentType is the Content-Type of a POST, unless --header sets one.
+const postContentType = "application/x-www-form-urlencoded"
+
+// parseHeader splits a --header argument, e.g. "Accept: text/plain", into
+// its name and value.
+func parseHeader(h string) (string, string, error) {
+	name, value, ok := strings.Cut(h, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("%w: %q", errHeader, h)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// request fetches u with c.client, as a POST of --post-data or --post-file
+// if either is given and as a GET otherwise, with the --header headers. The
+// file is streamed rather than read in first.
+func (c *cmd) request(ctx context.Context, u *url.URL) (io.Reader, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %v", errPostScheme, u)
+	}
+	method := http.MethodGet
+	var body io.Reader
+	var size int64 = -1
+	switch {
+	case c.postFile != "":
+		f, err := os.Open(c.postFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
+		}
+		method, body = http.MethodPost, f
+	case c.postSet:
+		method, body = http.MethodPost, strings.NewReader(c.postData)
+		size = int64(len(c.postData))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		// Without a length, a file goes out chunked.
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		req.Header.Set("Content-Type", postContentType)
+	}
+	set := map[string]bool{}
+	for _, h := range c.headers {
+		name, value, _ := parseHeader(h)
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		// A header given more than once is sent more than once, but
+		// the first one replaces the default Content-Type.
+		if !set[http.CanonicalHeaderKey(name)] {
+			req.Header.Del(name)
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+		req.Header.Add(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &curl.HTTPClientCodeError{Err: curl.ErrStatusNotOk, HTTPCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// echo replies with the method, the Content-Type, the Content-Length, an
+// X-Test header if there is one, and the body of the request.
+func echo(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(w, "%s %q %d %q\n%s", r.Method, r.Header.Get("Content-Type"), r.ContentLength, r.Header.Values("X-Test"), body)
+}
+
+func TestPost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echo))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	big := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	bigFile := filepath.Join(dir, "big")
+	if err := os.WriteFile(bigFile, big, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{
+		{
+			name: "post data",
+			args: []string{"--post-data", "a=1&b=2"},
+			want: `POST "application/x-www-form-urlencoded" 7 []` + "\na=1&b=2",
+		},
+		{
+			name: "empty post data",
+			args: []string{"--post-data="},
+			want: `POST "application/x-www-form-urlencoded" 0 []` + "\n",
+		},
+		{
+			name: "post file",
+			args: []string{"--post-file", bigFile},
+			want: fmt.Sprintf(`POST "application/x-www-form-urlencoded" %d []`+"\n%s", len(big), big),
+		},
+		{
+			name: "empty post file",
+			args: []string{"--post-file", emptyFile},
+			want: `POST "application/x-www-form-urlencoded" 0 []` + "\n",
+		},
+		{
+			name: "content type header",
+			args: []string{"--post-data", `{"a":1}`, "--header", "content-type: application/json"},
+			want: `POST "application/json" 7 []` + "\n" + `{"a":1}`,
+		},
+		{
+			name: "repeated header",
+			args: []string{"--post-data", "x", "--header", "X-Test: 1", "--header", "X-Test:2"},
+			want: `POST "application/x-www-form-urlencoded" 1 ["1" "2"]` + "\nx",
+		},
+		{
+			name: "header on a get",
+			args: []string{"--header", "X-Test: get"},
+			want: `GET "" 0 ["get"]` + "\n",
+		},
+		{
+			name: "missing post file",
+			args: []string{"--post-file", filepath.Join(dir, "nope")},
+			err:  os.ErrNotExist,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			args := append(append([]string{"wget", "-O", out}, tt.args...), srv.URL+"/echo")
+			c, err := command(args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("server got\n%.200s\nwant\n%.200s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{name: "data and file", args: []string{"wget", "--post-data", "a", "--post-file", "f", "a"}, err: errPostBoth},
+		{name: "empty data and file", args: []string{"wget", "--post-data=", "--post-file", "f", "a"}, err: errPostBoth},
+		{name: "recursive", args: []string{"wget", "-r", "--post-data", "a", "a"}, err: errPostMirror},
+		{name: "recursive header", args: []string{"wget", "-r", "--header", "A: b", "a"}, err: errPostMirror},
+		{name: "no colon", args: []string{"wget", "--header", "Accept", "a"}, err: errHeader},
+		{name: "no name", args: []string{"wget", "--header", ": b", "a"}, err: errHeader},
+		{name: "space in name", args: []string{"wget", "--header", "X Test: b", "a"}, err: errHeader},
+		{name: "after the url", args: []string{"wget", "a", "--post-data", "x"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := flags(tt.args...); !errors.Is(err, tt.err) {
+				t.Errorf("flags(%q) = %v, want %v", tt.args, err, tt.err)
+			}
+		})
+	}
+
+	c, err := command("wget", "--post-data", "x", "-O", filepath.Join(t.TempDir(), "out"), "file:///etc/hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); !errors.Is(err, errPostScheme) {
+		t.Errorf("POST to a file URL = %v, want %v", err, errPostScheme)
+	}
+}
+
+func TestPostError(t *testing.T) {
+	srv := httptest.NewServer(handler{})
+	defer srv.Close()
+
+	c, err := command("wget", "--post-data", "x", "-O", filepath.Join(t.TempDir(), "out"), srv.URL+"/500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("run() = %v, want an error with the HTTP code", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	errTimeout        = errors.New("timeouts must not be negative")
+	errConnectTimeout = errors.New("connect timeout")
+	errReadTimeout    = errors.New("read timeout")
+)
+
+// seconds converts a timeout given in seconds, as wget takes them.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// timeoutConn is a net.Conn whose reads fail once no data has arrived for
+// timeout.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read implements io.Reader.
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	if err := c.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = fmt.Errorf("%w: no data for %v", errReadTimeout, c.timeout)
+	}
+	return n, err
+}
+
+// httpClient returns an HTTP client that connects with c.dial, gives up on
+// connecting after connect, and on a server that sends nothing, neither the
+// response header nor more of the body, for read. Zero means to wait forever.
+func (c *cmd) httpClient(connect, read time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dctx := ctx
+		if connect > 0 {
+			var cancel context.CancelFunc
+			dctx, cancel = context.WithTimeout(ctx, connect)
+			defer cancel()
+		}
+		conn, err := c.dial(dctx, network, addr)
+		if err != nil {
+			// Tell the connect timeout apart from the --timeout
+			// one, which ends ctx.
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil, fmt.Errorf("%s: %w after %v", addr, errConnectTimeout, connect)
+			}
+			return nil, err
+		}
+		if read > 0 {
+			conn = &timeoutConn{Conn: conn, timeout: read}
+		}
+		return conn, nil
+	}
+	return &http.Client{Transport: t}
+}
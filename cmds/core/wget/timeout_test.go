@@ -0,0 +1,141 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//go:build !tinygo || tinygo.enable
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stallingDial never connects, it waits until it is given up on.
+func stallingDial(ctx context.Context, _, _ string) (net.Conn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeouts(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stall-body":
+			w.Write([]byte("some of the body"))
+			w.(http.Flusher).Flush()
+		case "/stall-header":
+		default:
+			w.Write([]byte(content))
+			return
+		}
+		select {
+		case <-stop:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	// Let the stalled handlers go before closing the server.
+	defer close(stop)
+
+	for _, tt := range []struct {
+		name    string
+		path    string
+		stall   bool
+		args    []string
+		want    error
+		notWant []error
+	}{
+		{
+			name: "no timeouts",
+			path: "/200",
+			args: []string{"--connect-timeout", "0", "--read-timeout", "0", "-T", "0"},
+		},
+		{
+			name: "timeouts that do not fire",
+			path: "/200",
+			args: []string{"--connect-timeout", "10", "--read-timeout", "10", "-T", "10"},
+		},
+		{
+			name:  "connect stalls",
+			stall: true,
+			args:  []string{"--connect-timeout", "0.05", "--read-timeout", "10"},
+			want:  errConnectTimeout,
+		},
+		{
+			name:    "connect stalls without connect timeout",
+			stall:   true,
+			args:    []string{"--read-timeout", "0.05", "--timeout", "0.3"},
+			want:    context.DeadlineExceeded,
+			notWant: []error{errConnectTimeout, errReadTimeout},
+		},
+		{
+			name: "body stalls",
+			path: "/stall-body",
+			args: []string{"--connect-timeout", "10", "--read-timeout", "0.05"},
+			want: errReadTimeout,
+		},
+		{
+			name:    "body stalls without read timeout",
+			path:    "/stall-body",
+			args:    []string{"--connect-timeout", "0.05", "--timeout", "0.3"},
+			want:    context.DeadlineExceeded,
+			notWant: []error{errConnectTimeout, errReadTimeout},
+		},
+		{
+			name: "header stalls",
+			path: "/stall-header",
+			args: []string{"--read-timeout", "0.05", "--timeout", "10"},
+			want: errReadTimeout,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			args := append([]string{"wget", "-O", out, srv.URL + tt.path}, tt.args...)
+			c, err := command(args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stall {
+				c.dial = stallingDial
+			}
+			start := time.Now()
+			err = c.run()
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("run() took %v, the timeouts did not fire", d)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("run() = %v, want %v", err, tt.want)
+			}
+			for _, e := range tt.notWant {
+				if errors.Is(err, e) {
+					t.Errorf("run() = %v, want it not to be %v", err, e)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeoutFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"wget", "-T", "-1", "a"},
+		{"wget", "--connect-timeout", "-1", "a"},
+		{"wget", "a", "--read-timeout", "-0.5"},
+	} {
+		if _, err := flags(args...); !errors.Is(err, errTimeout) {
+			t.Errorf("flags(%q) = %v, want %v", args, err, errTimeout)
+		}
+	}
+	p, err := flags("wget", "--connect-timeout", "1.5", "a", "--read-timeout", "2", "--timeout", "30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.connectTimeout != 1.5 || p.readTimeout != 2 || p.timeout != 30 {
+		t.Errorf("flags() = %+v, want connect 1.5, read 2 and overall 30 seconds", p)
+	}
+}
@@ -7,12 +7,46 @@
 //
 // Synopsis:
 //
-//	wget URL
+//	wget [-O FILE] [--check-sha256 HEX] [TIMEOUTS] [--post-data DATA | --post-file FILE] [--header HEADER]... URL
+//	wget -r [-l DEPTH] [-D DOMAINS] [-P PREFIX] [TIMEOUTS] URL
 //
 // Description:
 //
 //	Returns a non-zero code on failure.
 //
+//	With -r, links found in fetched HTML pages (href and src attributes)
+//	are followed up to DEPTH levels deep, and the pages are saved under
+//	PREFIX/HOST/PATH. Only links to the host of URL and to the hosts listed
+//	in DOMAINS are followed. Links whose PATH leads out of PREFIX/HOST with
+//	.. are not fetched, and count as failures.
+//
+//	TIMEOUTS are --connect-timeout, --read-timeout and -T, all in seconds.
+//	The connect timeout limits how long connecting to an HTTP server may
+//	take, and the read timeout how long it may go without sending
+//	anything. -T limits the whole download. Zero, the default, means no
+//	timeout.
+//
+//	--post-data and --post-file send an HTTP POST rather than a GET, with
+//	the data or the contents of the file, which is streamed, as the body.
+//	Its Content-Type is application/x-www-form-urlencoded, unless a
+//	--header sets another.
+//
+// Options:
+//
+//	-O:           output file, - for stdout
+//	-r:           download recursively (also --recursive)
+//	-l:           maximum recursion depth (default 5)
+//	-D:           comma separated list of additional hosts to follow (also --domains)
+//	-P:           directory to save recursive downloads to (default .)
+//	--check-sha256: fail, and remove the output file, unless the download
+//	              has this hex encoded SHA-256 digest
+//	--connect-timeout: seconds to wait for an HTTP connection
+//	--read-timeout: seconds to wait for more data from an HTTP server
+//	-T:           seconds the whole download may take (also --timeout)
+//	--post-data:  POST this string
+//	--post-file:  POST the contents of this file
+//	--header:     send this "NAME: VALUE" header; may be repeated
+//
 // Notes:
 //
 //	There are a few differences with GNU wget:
@@ -25,32 +59,78 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
 	"github.com/u-root/uio/uio"
 )
 
-var errEmptyURL = errors.New("empty url")
+var (
+	errEmptyURL       = errors.New("empty url")
+	errDigest         = errors.New("--check-sha256 needs a 64 digit hex SHA-256 digest")
+	errDigestMismatch = errors.New("SHA-256 mismatch")
+	errDigestMirror   = errors.New("--check-sha256 can not be used with -r")
+)
 
-type cmd struct {
+var schemes = curl.Schemes{
+	"tftp": curl.DefaultTFTPClient,
+	"http": curl.DefaultHTTPClient,
+
+	// curl.DefaultSchemes doesn't support HTTPS by default.
+	"https": curl.DefaultHTTPClient,
+	"file":  &curl.LocalFileClient{},
+}
+
+type params struct {
 	url        string
 	outputPath string
+	recursive  bool
+	level      int
+	domains    string
+	prefix     string
+	sha256     string
+
+	timeout, connectTimeout, readTimeout float64
+
+	postData string
+	postFile string
+	// postSet is whether --post-data was given, so that an empty
+	// --post-data still POSTs.
+	postSet bool
+	headers unixflag.StringArray
+}
+
+type cmd struct {
+	params
+	schemes curl.Schemes
+	// client makes the requests with --post-data, --post-file or
+	// --header, which the schemes can not. nil means to use the schemes.
+	client *http.Client
+	// dial connects to HTTP servers when a timeout, --post-data,
+	// --post-file or --header is given. It can be replaced in tests.
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // flags parses wget flags
 // wget is old school, and allows flags after the URL.
 // This code does not process the -- flag specified in the
 // man page, as the command itself does not seem to either.
-func flags(args ...string) (string, string, error) {
+func flags(args ...string) (params, error) {
 	// -- takes priority over everything else.
 	// flag package does not allow - as a flag.
 	// except, in spite of the docs, wget on linux seems
@@ -60,42 +140,95 @@ func flags(args ...string) (string, string, error) {
 	// If at some point one wishes to add -- support,
 	// the slices package is a good place to start.
 
+	var p params
 	if len(args) == 0 {
-		return "", "", errEmptyURL
+		return params{}, errEmptyURL
 	}
 
 	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
-	var outPath = f.String("O", "", "output file")
+	f.StringVar(&p.outputPath, "O", "", "output file")
+	f.BoolVar(&p.recursive, "r", false, "download recursively")
+	f.BoolVar(&p.recursive, "recursive", false, "download recursively")
+	f.IntVar(&p.level, "l", 5, "maximum recursion depth")
+	f.StringVar(&p.domains, "D", "", "comma separated list of additional hosts to follow")
+	f.StringVar(&p.domains, "domains", "", "comma separated list of additional hosts to follow")
+	f.StringVar(&p.prefix, "P", ".", "directory to save recursive downloads to")
+	f.StringVar(&p.sha256, "check-sha256", "", "expected hex encoded SHA-256 digest of the download")
+	f.Float64Var(&p.timeout, "T", 0, "seconds the whole download may take")
+	f.Float64Var(&p.timeout, "timeout", 0, "seconds the whole download may take")
+	f.Float64Var(&p.connectTimeout, "connect-timeout", 0, "seconds to wait for an HTTP connection")
+	f.Float64Var(&p.readTimeout, "read-timeout", 0, "seconds to wait for more data from an HTTP server")
+	f.StringVar(&p.postData, "post-data", "", "POST this string")
+	f.StringVar(&p.postFile, "post-file", "", "POST the contents of this file")
+	f.Var(&p.headers, "header", "send this NAME: VALUE header")
 
 	if err := f.Parse(args[1:]); err != nil {
-		return "", "", err
+		return params{}, err
 	}
 
 	if len(f.Args()) == 0 {
-		return "", "", errEmptyURL
+		return params{}, errEmptyURL
 	}
 
-	URL := f.Args()[0]
+	p.url = f.Args()[0]
 
 	// Now, it is allowed to have switches after the URL,
 	// handle following flags
 	if err := f.Parse(f.Args()[1:]); err != nil {
-		return "", "", err
+		return params{}, err
 	}
 
-	return *outPath, URL, nil
+	if p.sha256 != "" {
+		if d, err := hex.DecodeString(p.sha256); err != nil || len(d) != sha256.Size {
+			return params{}, fmt.Errorf("%w: %q", errDigest, p.sha256)
+		}
+		if p.recursive {
+			return params{}, errDigestMirror
+		}
+	}
+	if p.timeout < 0 || p.connectTimeout < 0 || p.readTimeout < 0 {
+		return params{}, errTimeout
+	}
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "post-data" {
+			p.postSet = true
+		}
+	})
+	if p.postSet && p.postFile != "" {
+		return params{}, errPostBoth
+	}
+	if (p.postSet || p.postFile != "" || len(p.headers) > 0) && p.recursive {
+		return params{}, errPostMirror
+	}
+	for _, h := range p.headers {
+		if _, _, err := parseHeader(h); err != nil {
+			return params{}, err
+		}
+	}
+
+	return p, nil
 }
 
 func command(args ...string) (*cmd, error) {
-	outPath, URL, err := flags(args...)
+	p, err := flags(args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &cmd{
-		outputPath: outPath,
-		url:        URL,
-	}, nil
+	c := &cmd{params: p, schemes: schemes, dial: (&net.Dialer{}).DialContext}
+	if p.connectTimeout > 0 || p.readTimeout > 0 {
+		h := curl.NewHTTPClient(c.httpClient(seconds(p.connectTimeout), seconds(p.readTimeout)))
+		c.schemes = curl.Schemes{
+			"tftp":  curl.DefaultTFTPClient,
+			"http":  h,
+			"https": h,
+			"file":  &curl.LocalFileClient{},
+		}
+	}
+	if p.postSet || p.postFile != "" || len(p.headers) > 0 {
+		c.client = c.httpClient(seconds(p.connectTimeout), seconds(p.readTimeout))
+	}
+	return c, nil
 }
 
 func (c *cmd) run() error {
@@ -110,6 +243,17 @@ func (c *cmd) run() error {
 		return err
 	}
 
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, seconds(c.timeout))
+		defer cancel()
+	}
+
+	if c.recursive {
+		return c.mirror(ctx, parsedURL)
+	}
+
 	if c.outputPath == "" {
 		c.outputPath = defaultOutputPath(parsedURL.Path)
 	}
@@ -117,27 +261,43 @@ func (c *cmd) run() error {
 		c.outputPath = "/dev/stdout"
 	}
 
-	schemes := curl.Schemes{
-		"tftp": curl.DefaultTFTPClient,
-		"http": curl.DefaultHTTPClient,
-
-		// curl.DefaultSchemes doesn't support HTTPS by default.
-		"https": curl.DefaultHTTPClient,
-		"file":  &curl.LocalFileClient{},
+	var reader io.Reader
+	if c.client != nil {
+		reader, err = c.request(ctx, parsedURL)
+	} else {
+		reader, err = c.schemes.FetchWithoutCache(ctx, parsedURL)
 	}
-
-	reader, err := schemes.FetchWithoutCache(context.Background(), parsedURL)
 	if err != nil {
 		return fmt.Errorf("failed to download %v: %w", c.url, err)
 	}
 
-	if err := uio.ReadIntoFile(reader, c.outputPath); err != nil {
-		return err
+	if c.sha256 == "" {
+		return uio.ReadIntoFile(reader, c.outputPath)
 	}
 
+	// Hash the body while it is written out, rather than reading it
+	// back afterwards.
+	h := sha256.New()
+	if err := uio.ReadIntoFile(io.TeeReader(reader, h), c.outputPath); err != nil {
+		c.removeOutput()
+		return err
+	}
+	want, _ := hex.DecodeString(c.sha256)
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		c.removeOutput()
+		return fmt.Errorf("%v: %w: got %x, want %x", c.url, errDigestMismatch, got, want)
+	}
 	return nil
 }
 
+// removeOutput removes what a download that can't be verified against
+// --check-sha256 has written, unless it went to stdout.
+func (c *cmd) removeOutput() {
+	if c.outputPath != "/dev/stdout" {
+		os.Remove(c.outputPath)
+	}
+}
+
 func usage() {
 	log.Printf("Usage: %s [ARGS] URL\n", os.Args[0])
 	flag.PrintDefaults()
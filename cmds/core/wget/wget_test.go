@@ -10,12 +10,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -209,15 +212,15 @@ func TestFlags(t *testing.T) {
 		{name: "url with -O last", args: []string{"wget", "a", "-O", "b"}, out: "b", url: "a", err: nil},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			o, u, err := flags(tt.args...)
+			p, err := flags(tt.args...)
 			if !errors.Is(err, tt.err) {
 				t.Errorf("err:got %v, want %v", err, tt.err)
 			}
-			if o != tt.out {
-				t.Errorf("out:got %q, want %q", o, tt.out)
+			if p.outputPath != tt.out {
+				t.Errorf("out:got %q, want %q", p.outputPath, tt.out)
 			}
-			if u != tt.url {
-				t.Errorf("url:got %q,want %q", u, tt.url)
+			if p.url != tt.url {
+				t.Errorf("url:got %q,want %q", p.url, tt.url)
 			}
 		})
 
@@ -237,3 +240,82 @@ func TestStdout(t *testing.T) {
 		t.Errorf("expected nil got %v", err)
 	}
 }
+
+func TestCheckSHA256(t *testing.T) {
+	srv := httptest.NewServer(handler{})
+	defer srv.Close()
+
+	sum := sha256.Sum256([]byte(content))
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("0", 64)
+
+	for _, tt := range []struct {
+		name    string
+		digest  string
+		err     error
+		created bool
+	}{
+		{name: "match", digest: good, created: true},
+		{name: "match upper case", digest: strings.ToUpper(good), created: true},
+		{name: "mismatch", digest: bad, err: errDigestMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			c, err := command("wget", "--check-sha256", tt.digest, "-O", out, srv.URL+"/200")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			b, err := os.ReadFile(out)
+			if tt.created {
+				if err != nil || string(b) != content {
+					t.Errorf("output = %q, %v, want %q", b, err, content)
+				}
+			} else if !os.IsNotExist(err) {
+				t.Errorf("output of failed download was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckSHA256Truncated(t *testing.T) {
+	// The server promises more than it sends, so the download fails part
+	// of the way through.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out")
+	c, err := command("wget", "--check-sha256", strings.Repeat("0", 64), "-O", out, srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err == nil {
+		t.Fatal("run() = nil, want an error for the truncated download")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output of truncated download was not removed: %v", err)
+	}
+}
+
+func TestCheckSHA256Flags(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{name: "not hex", args: []string{"wget", "--check-sha256", strings.Repeat("z", 64), "a"}, err: errDigest},
+		{name: "too short", args: []string{"wget", "--check-sha256", "abcd", "a"}, err: errDigest},
+		{name: "recursive", args: []string{"wget", "-r", "--check-sha256", strings.Repeat("0", 64), "a"}, err: errDigestMirror},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := flags(tt.args...); !errors.Is(err, tt.err) {
+				t.Errorf("flags(%q) = %v, want %v", tt.args, err, tt.err)
+			}
+		})
+	}
+}
@@ -6,26 +6,48 @@
 //
 // CopyTree in particular copies entire trees of files.
 //
-// Only directories, symlinks, and regular files are currently supported.
+// Directories, symlinks and regular files are supported. FIFOs, sockets and
+// device nodes are supported with Options.CopySpecial on systems with mknod.
 package cp
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // ErrSkip can be returned by PreCallback to skip a file.
 var ErrSkip = errors.New("skip")
 
+// ErrVerify is returned, in an *os.PathError with the destination, when a
+// copied file does not read back the same with Options.Verify.
+var ErrVerify = errors.New("copy does not match the source")
+
 // Options are configuration options for how copying files should behave.
 type Options struct {
 	// If NoFollowSymlinks is set, Copy copies the symlink itself rather
 	// than following the symlink and copying the file it points to.
 	NoFollowSymlinks bool
 
+	// If CopySpecial is set, FIFOs, sockets and device nodes are
+	// recreated at the destination with mknod rather than rejected.
+	CopySpecial bool
+
+	// If OneFileSystem is set, CopyTree does not descend into directories
+	// on a different file system than the root of the tree. The
+	// directories themselves are still created.
+	OneFileSystem bool
+
+	// If Verify is set, each regular file is read back after it is
+	// copied, and its SHA-256 compared with that of the source. Other
+	// files are not verified.
+	Verify bool
+
 	// PreCallback is called on each file to be copied before it is copied
 	// if specified.
 	//
@@ -37,9 +59,29 @@ type Options struct {
 	PreCallback func(src, dst string, srcfi os.FileInfo) error
 
 	// PostCallback is called on each file after it is copied if specified.
+	//
+	// With Jobs greater than one, PostCallback may be called from several
+	// goroutines at once.
 	PostCallback func(src, dst string)
+
+	// Jobs is the number of files CopyTree copies at once. Directories are
+	// always created before anything is copied into them. Values below two
+	// mean one file at a time.
+	//
+	// PreCallback is still called for one file at a time, in tree order.
+	// Unlike a sequential copy, a failure does not stop the copy; all
+	// errors are returned together.
+	Jobs int
 }
 
+// dstWriter wraps the destination of a regular file while it is written. It
+// can be replaced in tests.
+var dstWriter = func(f *os.File) io.Writer { return f }
+
+// deviceOf returns the device number of the file system a file is on. It can
+// be replaced in tests.
+var deviceOf = device
+
 // Default are the default options. Default follows symlinks.
 var Default = Options{}
 
@@ -55,21 +97,27 @@ func (o Options) stat(path string) (os.FileInfo, error) {
 	return os.Stat(path)
 }
 
-// Copy copies a file at src to dst.
-func (o Options) Copy(src, dst string) error {
+// prepare stats src and runs PreCallback on it. It returns a nil FileInfo if
+// the file is to be skipped.
+func (o Options) prepare(src, dst string) (os.FileInfo, error) {
 	srcInfo, err := o.stat(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if o.PreCallback != nil {
 		if err := o.PreCallback(src, dst, srcInfo); err == ErrSkip {
-			return nil
+			return nil, nil
 		} else if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	if err := copyFile(src, dst, srcInfo); err != nil {
+	return srcInfo, nil
+}
+
+// copy copies a prepared file and runs PostCallback.
+func (o Options) copy(src, dst string, srcInfo os.FileInfo) error {
+	if err := o.copyFile(src, dst, srcInfo); err != nil {
 		return err
 	}
 	if o.PostCallback != nil {
@@ -78,8 +126,47 @@ func (o Options) Copy(src, dst string) error {
 	return nil
 }
 
+// Copy copies a file at src to dst.
+func (o Options) Copy(src, dst string) error {
+	srcInfo, err := o.prepare(src, dst)
+	if err != nil || srcInfo == nil {
+		return err
+	}
+	return o.copy(src, dst, srcInfo)
+}
+
+// boundary returns a function which CopyTree calls on every file it walks
+// in src. With OneFileSystem, it returns filepath.SkipDir for directories on
+// a different file system than src.
+func (o Options) boundary(src string) (func(fi os.FileInfo) error, error) {
+	if !o.OneFileSystem {
+		return func(os.FileInfo) error { return nil }, nil
+	}
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return nil, err
+	}
+	dev, ok := deviceOf(fi)
+	return func(fi os.FileInfo) error {
+		if !ok || !fi.IsDir() {
+			return nil
+		}
+		if d, ok := deviceOf(fi); ok && d != dev {
+			return filepath.SkipDir
+		}
+		return nil
+	}, nil
+}
+
 // CopyTree recursively copies all files in the src tree to dst.
 func (o Options) CopyTree(src, dst string) error {
+	if o.Jobs > 1 {
+		return o.copyTreeParallel(src, dst)
+	}
+	boundary, err := o.boundary(src)
+	if err != nil {
+		return err
+	}
 	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -89,8 +176,76 @@ func (o Options) CopyTree(src, dst string) error {
 		if err != nil {
 			return err
 		}
-		return o.Copy(path, filepath.Join(dst, rel))
+		if err := o.Copy(path, filepath.Join(dst, rel)); err != nil {
+			return err
+		}
+		return boundary(fi)
+	})
+}
+
+// copyTreeParallel is CopyTree with o.Jobs workers. The tree is walked in a
+// single goroutine, which creates directories itself and hands everything
+// else to the workers. Since a directory is walked before its contents, it
+// always exists by the time they are copied.
+func (o Options) copyTreeParallel(src, dst string) error {
+	boundary, err := o.boundary(src)
+	if err != nil {
+		return err
+	}
+
+	type job struct {
+		src, dst string
+		fi       os.FileInfo
+	}
+	var (
+		jobs = make(chan job)
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := 0; i < o.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := o.copy(j.src, j.dst, j.fi); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		srcInfo, err := o.prepare(path, target)
+		if err != nil || srcInfo == nil {
+			return err
+		}
+		if srcInfo.IsDir() {
+			if err := o.copy(path, target, srcInfo); err != nil {
+				return err
+			}
+			return boundary(fi)
+		}
+		jobs <- job{src: path, dst: target, fi: srcInfo}
+		return nil
 	})
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 // Copy src file to dst file using Default's config.
@@ -104,14 +259,14 @@ func CopyTree(src, dst string) error {
 	return Default.CopyTree(src, dst)
 }
 
-func copyFile(src, dst string, srcInfo os.FileInfo) error {
+func (o Options) copyFile(src, dst string, srcInfo os.FileInfo) error {
 	m := srcInfo.Mode()
 	switch {
 	case m.IsDir():
 		return os.MkdirAll(dst, srcInfo.Mode().Perm())
 
 	case m.IsRegular():
-		return copyRegularFile(src, dst, srcInfo)
+		return o.copyRegularFile(src, dst, srcInfo)
 
 	case m&os.ModeSymlink == os.ModeSymlink:
 		// Yeah, this may not make any sense logically. But this is how
@@ -122,6 +277,9 @@ func copyFile(src, dst string, srcInfo os.FileInfo) error {
 		}
 		return os.Symlink(target, dst)
 
+	case o.CopySpecial && m&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice) != 0:
+		return copySpecial(src, dst, srcInfo)
+
 	default:
 		return &os.PathError{
 			Op:   "copy",
@@ -131,7 +289,7 @@ func copyFile(src, dst string, srcInfo os.FileInfo) error {
 	}
 }
 
-func copyRegularFile(src, dst string, srcfi os.FileInfo) error {
+func (o Options) copyRegularFile(src, dst string, srcfi os.FileInfo) error {
 	srcf, err := os.Open(src)
 	if err != nil {
 		return err
@@ -144,6 +302,35 @@ func copyRegularFile(src, dst string, srcfi os.FileInfo) error {
 	}
 	defer dstf.Close()
 
-	_, err = io.Copy(dstf, srcf)
-	return err
+	if !o.Verify {
+		_, err = io.Copy(dstWriter(dstf), srcf)
+		return err
+	}
+	// Hash the source as it is copied rather than read it twice.
+	h := sha256.New()
+	if _, err := io.Copy(dstWriter(dstf), io.TeeReader(srcf, h)); err != nil {
+		return err
+	}
+	if err := dstf.Sync(); err != nil {
+		return err
+	}
+	return verify(dst, h.Sum(nil))
+}
+
+// verify reads dst back and checks that its SHA-256 is sum.
+func verify(dst string, sum []byte) error {
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return &os.PathError{Op: "verify", Path: dst, Err: ErrVerify}
+	}
+	return nil
 }
@@ -7,10 +7,12 @@ package cp
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"golang.org/x/sys/unix"
@@ -135,3 +137,251 @@ func TestCopySimple(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyTreeSpecial(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "dev")
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Mkfifo(filepath.Join(src, "fifo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	nullDev := int(unix.Mkdev(1, 3))
+	haveDev := true
+	if err := unix.Mknod(filepath.Join(src, "null"), unix.S_IFCHR|0o666, nullDev); errors.Is(err, unix.EPERM) {
+		t.Logf("Can not create device nodes, only testing FIFOs: %v", err)
+		haveDev = false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dev")
+	if err := NoFollowSymlinks.CopyTree(src, dst); err == nil {
+		t.Fatalf("CopyTree without CopySpecial: got nil, want an error")
+	}
+
+	dst = filepath.Join(t.TempDir(), "dev")
+	opts := Options{NoFollowSymlinks: true, CopySpecial: true}
+	if err := opts.CopyTree(src, dst); err != nil {
+		t.Fatalf("CopyTree(%q, %q) = %v, want nil", src, dst, err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "fifo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Type() != fs.ModeNamedPipe || fi.Mode().Perm() != 0o600 {
+		t.Errorf("copied fifo has mode %v, want %v", fi.Mode(), fs.ModeNamedPipe|0o600)
+	}
+
+	if !haveDev {
+		return
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(filepath.Join(dst, "null"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFCHR {
+		t.Errorf("copied device has type %#o, want %#o", st.Mode&unix.S_IFMT, unix.S_IFCHR)
+	}
+	if int(st.Rdev) != nullDev {
+		t.Errorf("copied device is %d:%d, want 1:3", unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev)))
+	}
+}
+
+func TestCopyTreeJobs(t *testing.T) {
+	src := t.TempDir()
+	want := map[string]string{}
+	for i := 0; i < 4; i++ {
+		dir := filepath.Join(src, fmt.Sprintf("dir%d", i), "sub")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 8; j++ {
+			name := filepath.Join(dir, fmt.Sprintf("file%d", j))
+			content := strings.Repeat(name, j+1)
+			if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			rel, _ := filepath.Rel(src, name)
+			want[rel] = content
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	var copied atomic.Int32
+	opts := Options{
+		Jobs:         4,
+		PostCallback: func(src, dst string) { copied.Add(1) },
+	}
+	if err := opts.CopyTree(src, dst); err != nil {
+		t.Fatalf("CopyTree(%q, %q) = %v, want nil", src, dst, err)
+	}
+	for rel, content := range want {
+		b, err := os.ReadFile(filepath.Join(dst, rel))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("%s: got %q, want %q", rel, b, content)
+		}
+	}
+	// The root, 4 dirN and 4 sub directories, and the files.
+	if n := int(copied.Load()); n != 9+len(want) {
+		t.Errorf("PostCallback was called %d times, want %d", n, 9+len(want))
+	}
+}
+
+func TestCopyTreeJobsErrors(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(src, name), testdata, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Directories in the way of a and c make both of them fail.
+	dst := t.TempDir()
+	for _, name := range []string{"a", "c"} {
+		if err := os.Mkdir(filepath.Join(dst, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Options{Jobs: 2}.CopyTree(src, dst)
+	for _, name := range []string{"a", "c"} {
+		if err == nil || !strings.Contains(err.Error(), filepath.Join(dst, name)) {
+			t.Errorf("CopyTree error %v does not mention %s", err, name)
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "b")); err != nil || string(b) != string(testdata) {
+		t.Errorf("b was not copied: %q, %v", b, err)
+	}
+}
+
+func TestCopyTreeOneFileSystem(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"file", "dir/file", "mnt/file", "mnt/sub/file"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, testdata, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pretend that mnt is the mount point of another file system.
+	defer func(d func(os.FileInfo) (uint64, bool)) { deviceOf = d }(deviceOf)
+	deviceOf = func(fi os.FileInfo) (uint64, bool) {
+		if fi.Name() == "mnt" {
+			return 2, true
+		}
+		return 1, true
+	}
+
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "cross",
+			present: []string{"file", "dir/file", "mnt", "mnt/file", "mnt/sub/file"},
+		},
+		{
+			name:    "one file system",
+			opts:    Options{OneFileSystem: true},
+			present: []string{"file", "dir/file", "mnt"},
+			absent:  []string{"mnt/file", "mnt/sub"},
+		},
+		{
+			name:    "one file system with jobs",
+			opts:    Options{OneFileSystem: true, Jobs: 4},
+			present: []string{"file", "dir/file", "mnt"},
+			absent:  []string{"mnt/file", "mnt/sub"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "copy")
+			if err := tt.opts.CopyTree(src, dst); err != nil {
+				t.Fatalf("CopyTree(%q, %q) = %v, want nil", src, dst, err)
+			}
+			for _, name := range tt.present {
+				if _, err := os.Lstat(filepath.Join(dst, name)); err != nil {
+					t.Errorf("%s was not copied: %v", name, err)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, err := os.Lstat(filepath.Join(dst, name)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s: got %v, want %v", name, err, os.ErrNotExist)
+				}
+			}
+		})
+	}
+}
+
+// corruptWriter flips a bit in the first byte written through it.
+type corruptWriter struct {
+	w    io.Writer
+	done bool
+}
+
+func (c *corruptWriter) Write(p []byte) (int, error) {
+	if !c.done && len(p) > 0 {
+		c.done = true
+		b := append([]byte{}, p...)
+		b[0] ^= 1
+		return c.w.Write(b)
+	}
+	return c.w.Write(p)
+}
+
+func TestCopyVerify(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a", "dir/b"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, testdata, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "empty"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{Verify: true, NoFollowSymlinks: true}
+	if err := opts.CopyTree(src, filepath.Join(t.TempDir(), "copy")); err != nil {
+		t.Fatalf("CopyTree with Verify = %v, want nil", err)
+	}
+
+	defer func(w func(*os.File) io.Writer) { dstWriter = w }(dstWriter)
+	dstWriter = func(f *os.File) io.Writer { return &corruptWriter{w: f} }
+
+	dst := filepath.Join(t.TempDir(), "a")
+	err := opts.Copy(filepath.Join(src, "a"), dst)
+	if !errors.Is(err, ErrVerify) {
+		t.Fatalf("Copy of a corrupted file = %v, want %v", err, ErrVerify)
+	}
+	if !strings.Contains(err.Error(), dst) {
+		t.Errorf("Copy error %q does not mention %s", err, dst)
+	}
+
+	// Without Verify, the corruption goes unnoticed.
+	if err := (Options{}).Copy(filepath.Join(src, "a"), dst); err != nil {
+		t.Errorf("Copy without Verify = %v, want nil", err)
+	}
+
+	// Empty files and symlinks have nothing to corrupt.
+	for _, name := range []string{"empty", "link"} {
+		if err := opts.Copy(filepath.Join(src, name), filepath.Join(t.TempDir(), name)); err != nil {
+			t.Errorf("Copy of %s with Verify = %v, want nil", name, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+
+package cp
+
+import "os"
+
+// device returns false: there are no device numbers to compare file systems
+// by.
+func device(fi os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+
+package cp
+
+import (
+	"fmt"
+	"os"
+)
+
+func copySpecial(src, dst string, srcInfo os.FileInfo) error {
+	return &os.PathError{
+		Op:   "copy",
+		Path: src,
+		Err:  fmt.Errorf("unsupported file mode %s", srcInfo.Mode()),
+	}
+}
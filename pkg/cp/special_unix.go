@@ -0,0 +1,26 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package cp
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// copySpecial recreates the FIFO, socket or device node src at dst, keeping
+// its device number.
+func copySpecial(src, dst string, srcInfo os.FileInfo) error {
+	st, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return &os.PathError{Op: "copy", Path: src, Err: fmt.Errorf("no device information for mode %s", srcInfo.Mode())}
+	}
+	if err := mknod(dst, uint32(st.Mode), uint64(st.Rdev)); err != nil {
+		return &os.PathError{Op: "mknod", Path: dst, Err: err}
+	}
+	return nil
+}
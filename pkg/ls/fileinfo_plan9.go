@@ -79,6 +79,9 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 type LongStringer struct {
 	Human bool
 	Name  Stringer
+	// TimeFormat is the time.Layout of the modification time. Empty
+	// means DefaultTimeFormat.
+	TimeFormat string
 }
 
 // FileString implements Stringer.FileString.
@@ -94,6 +97,6 @@ func (ls LongStringer) FileString(fi FileInfo) string {
 		fi.Mode.String(),
 		fi.UID,
 		size,
-		fi.MTime.Format("Jan _2 15:04"),
+		fi.MTime.Format(timeFormat(ls.TimeFormat)),
 		ls.Name.FileString(fi))
 }
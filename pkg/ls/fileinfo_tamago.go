@@ -136,6 +136,9 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 type LongStringer struct {
 	Human bool
 	Name  Stringer
+	// TimeFormat is the time.Layout of the modification time. Empty
+	// means DefaultTimeFormat.
+	TimeFormat string
 }
 
 // FileString implements Stringer.FileString.
@@ -165,7 +168,7 @@ func (ls LongStringer) FileString(fi FileInfo) string {
 		0, // unix.Major(fi.Rdev),
 		0, // unix.Minor(fi.Rdev),
 		size,
-		fi.MTime.Format("Jan _2 15:04"),
+		fi.MTime.Format(timeFormat(ls.TimeFormat)),
 		ls.Name.FileString(fi))
 
 	if fi.Mode&os.ModeType == os.ModeSymlink {
@@ -137,6 +137,9 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 type LongStringer struct {
 	Human bool
 	Name  Stringer
+	// TimeFormat is the time.Layout of the modification time. Empty
+	// means DefaultTimeFormat.
+	TimeFormat string
 }
 
 // FileString implements Stringer.FileString.
@@ -166,7 +169,7 @@ func (ls LongStringer) FileString(fi FileInfo) string {
 		unix.Major(fi.Rdev),
 		unix.Minor(fi.Rdev),
 		size,
-		fi.MTime.Format("Jan _2 15:04"),
+		fi.MTime.Format(timeFormat(ls.TimeFormat)),
 		ls.Name.FileString(fi))
 
 	if fi.Mode&os.ModeType == os.ModeSymlink {
@@ -75,6 +75,9 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 type LongStringer struct {
 	Human bool
 	Name  Stringer
+	// TimeFormat is the time.Layout of the modification time. Empty
+	// means DefaultTimeFormat.
+	TimeFormat string
 }
 
 // FileString implements Stringer.FileString.
@@ -90,6 +93,6 @@ func (ls LongStringer) FileString(fi FileInfo) string {
 		fi.Mode.String(),
 		fi.UID,
 		size,
-		fi.MTime.Format("Jan _2 15:04"),
+		fi.MTime.Format(timeFormat(ls.TimeFormat)),
 		ls.Name.FileString(fi))
 }
@@ -4,3 +4,19 @@
 
 // Package ls implements formatting tools to list files like the Linux ls tool.
 package ls
+
+// DefaultTimeFormat is the time.Layout LongStringer formats the modification
+// time with, unless it is given another.
+const DefaultTimeFormat = "Jan _2 15:04"
+
+// FullISOTimeFormat is the time.Layout of GNU ls's --full-time, with
+// nanoseconds and the time zone.
+const FullISOTimeFormat = "2006-01-02 15:04:05.000000000 -0700"
+
+// timeFormat returns the time.Layout for a LongStringer TimeFormat of f.
+func timeFormat(f string) string {
+	if f == "" {
+		return DefaultTimeFormat
+	}
+	return f
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package meminfo
+
+// Fields maps the /proc/meminfo names of the values MemInfo holds to the
+// MemInfo fields they end up in. Cached is not one of them: Mem.Cached
+// also includes SReclaimable, as it does in procps, so it is not the
+// kernel's value. That is in the mapping Read returns.
+var Fields = map[string]string{
+	"MemTotal":     "Mem.Total",
+	"MemFree":      "Mem.Free",
+	"MemAvailable": "Mem.Available",
+	"Buffers":      "Mem.Buffers",
+	"Shmem":        "Mem.Shared",
+	"Slab":         "Mem.Slab",
+	"SReclaimable": "Mem.SReclaimable",
+	"SUnreclaim":   "Mem.SUnreclaim",
+	"Dirty":        "Mem.Dirty",
+	"Writeback":    "Mem.Writeback",
+	"SwapTotal":    "Swap.Total",
+	"SwapFree":     "Swap.Free",
+}
+
+// memInfoFields returns the value of each MemInfo field, by its path.
+var memInfoFields = map[string]func(*MemInfo) uint64{
+	"Mem.Total":        func(mi *MemInfo) uint64 { return mi.Mem.Total },
+	"Mem.Used":         func(mi *MemInfo) uint64 { return mi.Mem.Used },
+	"Mem.Free":         func(mi *MemInfo) uint64 { return mi.Mem.Free },
+	"Mem.Shared":       func(mi *MemInfo) uint64 { return mi.Mem.Shared },
+	"Mem.Cached":       func(mi *MemInfo) uint64 { return mi.Mem.Cached },
+	"Mem.Buffers":      func(mi *MemInfo) uint64 { return mi.Mem.Buffers },
+	"Mem.Available":    func(mi *MemInfo) uint64 { return mi.Mem.Available },
+	"Mem.Slab":         func(mi *MemInfo) uint64 { return mi.Mem.Slab },
+	"Mem.SReclaimable": func(mi *MemInfo) uint64 { return mi.Mem.SReclaimable },
+	"Mem.SUnreclaim":   func(mi *MemInfo) uint64 { return mi.Mem.SUnreclaim },
+	"Mem.Dirty":        func(mi *MemInfo) uint64 { return mi.Mem.Dirty },
+	"Mem.Writeback":    func(mi *MemInfo) uint64 { return mi.Mem.Writeback },
+	"Swap.Total":       func(mi *MemInfo) uint64 { return mi.Swap.Total },
+	"Swap.Used":        func(mi *MemInfo) uint64 { return mi.Swap.Used },
+	"Swap.Free":        func(mi *MemInfo) uint64 { return mi.Swap.Free },
+}
+
+// FieldByName returns the value, in bytes, of a MemInfo field. name is
+// either the field's /proc/meminfo name, e.g. MemAvailable, or its path in
+// MemInfo, e.g. Mem.Available. Computed values, like Mem.Used, and values
+// that differ from the kernel's, like Mem.Cached, only have the latter.
+func FieldByName(mi MemInfo, name string) (uint64, bool) {
+	if path, ok := Fields[name]; ok {
+		name = path
+	}
+	get, ok := memInfoFields[name]
+	if !ok {
+		return 0, false
+	}
+	return get(&mi), true
+}
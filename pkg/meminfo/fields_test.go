@@ -0,0 +1,63 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package meminfo
+
+import "testing"
+
+func TestFieldByName(t *testing.T) {
+	mi := MemInfo{
+		Mem:  MainMemInfo{Total: 1, Used: 2, Free: 3, Shared: 4, Cached: 5, Buffers: 6, Available: 7, Slab: 11, SReclaimable: 12, SUnreclaim: 13, Dirty: 14, Writeback: 15},
+		Swap: SwapInfo{Total: 8, Used: 9, Free: 10},
+	}
+	for _, tt := range []struct {
+		name string
+		want uint64
+		ok   bool
+	}{
+		{name: "MemTotal", want: 1, ok: true},
+		{name: "MemFree", want: 3, ok: true},
+		{name: "MemAvailable", want: 7, ok: true},
+		{name: "Buffers", want: 6, ok: true},
+		{name: "Shmem", want: 4, ok: true},
+		{name: "Slab", want: 11, ok: true},
+		{name: "SReclaimable", want: 12, ok: true},
+		{name: "SUnreclaim", want: 13, ok: true},
+		{name: "Dirty", want: 14, ok: true},
+		{name: "Writeback", want: 15, ok: true},
+		{name: "SwapTotal", want: 8, ok: true},
+		{name: "SwapFree", want: 10, ok: true},
+		{name: "Mem.Total", want: 1, ok: true},
+		{name: "Mem.Used", want: 2, ok: true},
+		{name: "Mem.Free", want: 3, ok: true},
+		{name: "Mem.Shared", want: 4, ok: true},
+		{name: "Mem.Cached", want: 5, ok: true},
+		{name: "Mem.Buffers", want: 6, ok: true},
+		{name: "Mem.Available", want: 7, ok: true},
+		{name: "Swap.Total", want: 8, ok: true},
+		{name: "Swap.Used", want: 9, ok: true},
+		{name: "Swap.Free", want: 10, ok: true},
+		{name: "Mem.Dirty", want: 14, ok: true},
+		// Mem.Cached is not the kernel's Cached.
+		{name: "Cached"},
+		{name: "Committed_AS"},
+		{name: "memtotal"},
+		{name: "Mem"},
+		{name: ""},
+	} {
+		got, ok := FieldByName(mi, tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FieldByName(%q) = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	// Every alias must lead to a field.
+	for name, path := range Fields {
+		if _, ok := memInfoFields[path]; !ok {
+			t.Errorf("%s maps to %s, which is not a MemInfo field", name, path)
+		}
+	}
+}
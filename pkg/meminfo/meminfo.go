@@ -0,0 +1,275 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package meminfo reads the memory usage information the Linux kernel
+// reports in /proc/meminfo.
+package meminfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// File is where the kernel reports the memory information.
+const File = "/proc/meminfo"
+
+// ErrMissingField is returned when a field needed to compute a value is not
+// in the meminfo.
+var ErrMissingField = errors.New("missing required field from meminfo")
+
+// ErrMalformedLine is returned for a meminfo line that is not a name, a
+// colon and a number, optionally followed by kB.
+var ErrMalformedLine = errors.New("malformed meminfo line")
+
+// MainMemInfo is the physical memory information, in bytes.
+type MainMemInfo struct {
+	Total     uint64 `json:"total"`
+	Used      uint64 `json:"used"`
+	Free      uint64 `json:"free"`
+	Shared    uint64 `json:"shared"`
+	Cached    uint64 `json:"cached"`
+	Buffers   uint64 `json:"buffers"`
+	Available uint64 `json:"available"`
+	// Slab is the kernel's own memory, SReclaimable and SUnreclaim the
+	// parts of it that can and can not be reclaimed. Dirty is waiting to
+	// be written back to disk and Writeback is being written back. They
+	// are 0 on kernels that do not report them.
+	Slab         uint64 `json:"slab"`
+	SReclaimable uint64 `json:"sreclaimable"`
+	SUnreclaim   uint64 `json:"sunreclaim"`
+	Dirty        uint64 `json:"dirty"`
+	Writeback    uint64 `json:"writeback"`
+	// UsedPercent is Used as a percentage of Total. Parsing leaves it
+	// nil, for callers to fill in if they want to report it.
+	UsedPercent *float64 `json:"used_percent,omitempty"`
+}
+
+// SwapInfo is the swap space information, in bytes.
+type SwapInfo struct {
+	Total uint64 `json:"total"`
+	Used  uint64 `json:"used"`
+	Free  uint64 `json:"free"`
+	// UsedPercent is as in MainMemInfo.
+	UsedPercent *float64 `json:"used_percent,omitempty"`
+}
+
+// ZswapInfo is the information of zswap, the compressed cache of pages on
+// their way to swap, in bytes.
+type ZswapInfo struct {
+	// Compressed is the memory the compressed pages take up, and Original
+	// the memory they took up before they were compressed.
+	Compressed uint64 `json:"compressed"`
+	Original   uint64 `json:"original"`
+	// Ratio is Original / Compressed, or 0 if zswap holds no pages.
+	Ratio float64 `json:"ratio"`
+}
+
+// MemInfo represents the main memory and swap space information in a
+// structured manner, suitable for JSON encoding.
+type MemInfo struct {
+	Mem  MainMemInfo `json:"mem"`
+	Swap SwapInfo    `json:"swap"`
+}
+
+// Read returns a mapping that represents the fields read from r, whose
+// content is compatible with /proc/meminfo. The values are as the kernel
+// reports them, i.e. mostly in kibibytes.
+//
+// Malformed lines are left out of the mapping, which is returned all the
+// same, along with an error that wraps ErrMalformedLine for each of them,
+// naming its line number and content. Callers that can do without those
+// fields may go on with the mapping.
+func Read(r io.Reader) (map[string]uint64, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]uint64)
+	var errs []error
+	for i, line := range bytes.Split(buf, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		key, value, err := parseLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %q: %w", i+1, line, err))
+			continue
+		}
+		ret[key] = value
+	}
+	return ret, errors.Join(errs...)
+}
+
+// parseLine splits a meminfo line into its name and value.
+func parseLine(line []byte) (string, uint64, error) {
+	key, rest, ok := bytes.Cut(line, []byte{':'})
+	if !ok || len(bytes.TrimSpace(key)) == 0 {
+		return "", 0, ErrMalformedLine
+	}
+	v, unit, _ := bytes.Cut(bytes.TrimSpace(rest), []byte{' '})
+	if len(unit) > 0 && string(bytes.TrimSpace(unit)) != "kB" {
+		return "", 0, ErrMalformedLine
+	}
+	value, err := strconv.ParseUint(string(v), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrMalformedLine, err)
+	}
+	return string(key), value, nil
+}
+
+// ReadFile is Read for the content of file.
+func ReadFile(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := Read(f)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", file, err)
+	}
+	return m, nil
+}
+
+// Parse reads /proc/meminfo and returns its main memory and swap space
+// information.
+func Parse() (*MemInfo, error) {
+	m, err := ReadFile(File)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFields(m, false)
+}
+
+// ParseFields returns the main memory and swap space information from the
+// fields of a meminfo, as Read returns them. See ParseMem for classic.
+func ParseFields(m map[string]uint64, classic bool) (*MemInfo, error) {
+	mmi, err := ParseMem(m, classic)
+	if err != nil {
+		return nil, err
+	}
+	si, err := ParseSwap(m)
+	if err != nil {
+		return nil, err
+	}
+	return &MemInfo{Mem: *mmi, Swap: *si}, nil
+}
+
+// ParseMem returns the physical memory information. Only the relevant
+// fields will be used from the input map.
+//
+// Used is MemTotal - MemFree - Buffers - Cached - SReclaimable, as procps-ng
+// 3.3.10 and later compute it: reclaimable slab counts as cache. With classic
+// set, SReclaimable is left out of the cache and so counts as used, which is
+// what older procps and most other free implementations report.
+//
+// Kernels older than 3.14 do not report MemAvailable. Available is then an
+// estimate, as estimateAvailable makes it.
+func ParseMem(m map[string]uint64, classic bool) (*MainMemInfo, error) {
+	if err := requireFields(m,
+		"MemTotal",
+		"MemFree",
+		"Buffers",
+		"Cached",
+		"Shmem",
+		"SReclaimable",
+	); err != nil {
+		return nil, err
+	}
+
+	// These values are expressed in kibibytes, convert them to bytes.
+	memTotal := m["MemTotal"] << 10
+	memFree := m["MemFree"] << 10
+	memShared := m["Shmem"] << 10
+	memCached := (m["Cached"] + m["SReclaimable"]) << 10
+	if classic {
+		memCached = m["Cached"] << 10
+	}
+	memBuffers := m["Buffers"] << 10
+	memUsed := memTotal - memFree - memCached - memBuffers
+	memAvailable, ok := m["MemAvailable"]
+	if !ok {
+		memAvailable = estimateAvailable(m)
+	}
+	memAvailable <<= 10
+
+	// These are optional, and missing ones are 0.
+	return &MainMemInfo{
+		Total:        memTotal,
+		Used:         memUsed,
+		Free:         memFree,
+		Shared:       memShared,
+		Cached:       memCached,
+		Buffers:      memBuffers,
+		Available:    memAvailable,
+		Slab:         m["Slab"] << 10,
+		SReclaimable: m["SReclaimable"] << 10,
+		SUnreclaim:   m["SUnreclaim"] << 10,
+		Dirty:        m["Dirty"] << 10,
+		Writeback:    m["Writeback"] << 10,
+	}, nil
+}
+
+// estimateAvailable approximates MemAvailable, in kibibytes, for kernels
+// which do not report it. Like the kernel's si_mem_available, it counts
+// free memory, the file backed page cache, Active(file) and Inactive(file),
+// and the reclaimable slab. The kernel keeps back part of the latter two, up
+// to the low watermarks of the zones, which are not in the meminfo, so this
+// overestimates a little. Kernels before 2.6.28 do not split the page cache
+// by type either, and there it is just MemFree and Cached. It is never more
+// than MemTotal.
+func estimateAvailable(m map[string]uint64) uint64 {
+	active, okActive := m["Active(file)"]
+	inactive, okInactive := m["Inactive(file)"]
+	if !okActive || !okInactive {
+		return min(m["MemFree"]+m["Cached"], m["MemTotal"])
+	}
+	return min(m["MemFree"]+active+inactive+m["SReclaimable"], m["MemTotal"])
+}
+
+// ParseSwap returns the swap space information. Only the relevant fields
+// will be used from the input map.
+func ParseSwap(m map[string]uint64) (*SwapInfo, error) {
+	if err := requireFields(m, "SwapTotal", "SwapFree"); err != nil {
+		return nil, err
+	}
+	// These values are expressed in kibibytes, convert them to bytes.
+	return &SwapInfo{
+		Total: m["SwapTotal"] << 10,
+		Used:  (m["SwapTotal"] - m["SwapFree"]) << 10,
+		Free:  m["SwapFree"] << 10,
+	}, nil
+}
+
+// ParseZswap returns the zswap information, from the Zswap and Zswapped
+// fields. Kernels built without zswap, and those older than 5.19, do not
+// report them, and the error then wraps ErrMissingField.
+func ParseZswap(m map[string]uint64) (*ZswapInfo, error) {
+	if err := requireFields(m, "Zswap", "Zswapped"); err != nil {
+		return nil, err
+	}
+	zi := &ZswapInfo{
+		Compressed: m["Zswap"] << 10,
+		Original:   m["Zswapped"] << 10,
+	}
+	if zi.Compressed > 0 {
+		zi.Ratio = float64(zi.Original) / float64(zi.Compressed)
+	}
+	return zi, nil
+}
+
+// requireFields returns an error naming the first of fields that is not in
+// m.
+func requireFields(m map[string]uint64, fields ...string) error {
+	for _, f := range fields {
+		if _, ok := m[f]; !ok {
+			return fmt.Errorf("%s: %w", f, ErrMissingField)
+		}
+	}
+	return nil
+}
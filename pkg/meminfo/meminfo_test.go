@@ -0,0 +1,246 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package meminfo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T) map[string]uint64 {
+	t.Helper()
+	m, err := ReadFile("testdata/meminfo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRead(t *testing.T) {
+	m := readFixture(t)
+	for name, want := range map[string]uint64{
+		"MemTotal":     8052976,
+		"MemFree":      721716,
+		"MemAvailable": 2774100,
+		"Buffers":      244880,
+		"Cached":       3462124,
+		"Shmem":        1617788,
+		"SwapTotal":    8265724,
+		"SwapFree":     8264956,
+		"SReclaimable": 179852,
+	} {
+		if m[name] != want {
+			t.Errorf("%s: got %v, want %v", name, m[name], want)
+		}
+	}
+
+	if _, err := Read(strings.NewReader("MemTotal: lots kB\n")); err == nil {
+		t.Error("bad value: got nil, want an error")
+	}
+	if _, err := ReadFile("testdata/nonexistent"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	m, err := ReadFile("testdata/meminfo_malformed.txt")
+	if !errors.Is(err, ErrMalformedLine) {
+		t.Fatalf("got %v, want %v", err, ErrMalformedLine)
+	}
+	if want := `testdata/meminfo_malformed.txt: line 7: "Active:          35628x4 kB": `; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %q, want it to start with %q", err, want)
+	}
+	// The other lines are still read.
+	if _, ok := m["Active"]; ok {
+		t.Errorf("Active = %d, want it left out", m["Active"])
+	}
+	if len(m) != len(readFixture(t))-1 || m["Inactive"] != 2840612 {
+		t.Errorf("got %v, want every field but Active", m)
+	}
+
+	for _, tt := range []struct {
+		name string
+		in   string
+		err  error
+		want map[string]uint64
+	}{
+		{name: "kB", in: "MemTotal: 1024 kB\n", want: map[string]uint64{"MemTotal": 1024}},
+		{name: "no unit", in: "HugePages_Total:       0\n", want: map[string]uint64{"HugePages_Total": 0}},
+		{name: "blank lines", in: "\nMemTotal: 1 kB\n\n", want: map[string]uint64{"MemTotal": 1}},
+		{name: "not a number", in: "MemTotal: lots kB\n", err: ErrMalformedLine, want: map[string]uint64{}},
+		{name: "no colon", in: "MemTotal 1024 kB\nMemFree: 1 kB\n", err: ErrMalformedLine, want: map[string]uint64{"MemFree": 1}},
+		{name: "no value", in: "MemTotal:\n", err: ErrMalformedLine, want: map[string]uint64{}},
+		{name: "other unit", in: "MemTotal: 1 MB\n", err: ErrMalformedLine, want: map[string]uint64{}},
+		{name: "no name", in: ": 1 kB\n", err: ErrMalformedLine, want: map[string]uint64{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Read(strings.NewReader(tt.in))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("got %v, want %v", m, tt.want)
+			}
+		})
+	}
+
+	// Each malformed line gets its own error.
+	_, err = Read(strings.NewReader("MemTotal: x kB\nMemFree: 1 kB\nBuffers: y kB\n"))
+	if got := err.Error(); !strings.Contains(got, "line 1: ") || !strings.Contains(got, "line 3: ") {
+		t.Errorf("got %q, want errors for lines 1 and 3", got)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	m := readFixture(t)
+	for _, tt := range []struct {
+		name    string
+		classic bool
+		want    MemInfo
+	}{
+		{
+			// Used is (8052976 - 721716 - 244880 - 3462124 - 179852) KiB
+			name: "default",
+			want: MemInfo{
+				Mem:  MainMemInfo{Total: 8246247424, Used: 3527069696, Free: 739037184, Shared: 1656614912, Cached: 3729383424, Buffers: 250757120, Available: 2840678400, Slab: 308842496, SReclaimable: 184168448, SUnreclaim: 124674048, Dirty: 1310720, Writeback: 65536},
+				Swap: SwapInfo{Total: 8464101376, Used: 786432, Free: 8463314944},
+			},
+		},
+		{
+			// Used is (8052976 - 721716 - 244880 - 3462124) KiB
+			name:    "classic",
+			classic: true,
+			want: MemInfo{
+				Mem:  MainMemInfo{Total: 8246247424, Used: 3711238144, Free: 739037184, Shared: 1656614912, Cached: 3545214976, Buffers: 250757120, Available: 2840678400, Slab: 308842496, SReclaimable: 184168448, SUnreclaim: 124674048, Dirty: 1310720, Writeback: 65536},
+				Swap: SwapInfo{Total: 8464101376, Used: 786432, Free: 8463314944},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mi, err := ParseFields(m, tt.classic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *mi != tt.want {
+				t.Errorf("ParseFields() = %+v, want %+v", *mi, tt.want)
+			}
+			mmi := mi.Mem
+			if got := mmi.Used + mmi.Free + mmi.Buffers + mmi.Cached; got != mmi.Total {
+				t.Errorf("Used + Free + Buffers + Cached = %v, want Total %v", got, mmi.Total)
+			}
+		})
+	}
+}
+
+func TestParseMinimal(t *testing.T) {
+	// Kernels without the optional fields still work, with 0 for them.
+	m, err := ReadFile("testdata/meminfo_minimal.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := ParseFields(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MainMemInfo{Total: 8246247424, Used: 3527069696, Free: 739037184, Shared: 1656614912, Cached: 3729383424, Buffers: 250757120, Available: 2840678400, SReclaimable: 184168448}
+	if mi.Mem != want {
+		t.Errorf("ParseFields() = %+v, want %+v", mi.Mem, want)
+	}
+}
+
+func TestParseMissingFields(t *testing.T) {
+	for _, f := range []string{"MemTotal", "MemFree", "Buffers", "Cached", "Shmem", "SReclaimable", "SwapTotal", "SwapFree"} {
+		m := readFixture(t)
+		delete(m, f)
+		if _, err := ParseFields(m, false); !errors.Is(err, ErrMissingField) || !strings.Contains(err.Error(), f) {
+			t.Errorf("without %s: got %v, want %v naming it", f, err, ErrMissingField)
+		}
+	}
+}
+
+func TestParseWithoutMemAvailable(t *testing.T) {
+	// A 3.10 kernel, which does not report MemAvailable.
+	m, err := ReadFile("testdata/meminfo_3.10.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := ParseFields(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MemFree + Active(file) + Inactive(file) + SReclaimable.
+	if want := uint64(1203212+800000+800000+153600) << 10; mi.Mem.Available != want {
+		t.Errorf("Available = %d, want %d", mi.Mem.Available, want)
+	}
+	if mi.Mem.Available <= mi.Mem.Free || mi.Mem.Available > mi.Mem.Total {
+		t.Errorf("Available = %d, want between Free %d and Total %d", mi.Mem.Available, mi.Mem.Free, mi.Mem.Total)
+	}
+
+	// Older kernels do not split the page cache by type either.
+	delete(m, "Active(file)")
+	delete(m, "Inactive(file)")
+	mi, err = ParseFields(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MemFree + Cached.
+	if want := uint64(1203212+1843200) << 10; mi.Mem.Available != want {
+		t.Errorf("without file pages: Available = %d, want %d", mi.Mem.Available, want)
+	}
+}
+
+func TestEstimateAvailableCapped(t *testing.T) {
+	m := map[string]uint64{"MemTotal": 1000, "MemFree": 600, "Active(file)": 300, "Inactive(file)": 300, "SReclaimable": 100}
+	if got := estimateAvailable(m); got != 1000 {
+		t.Errorf("estimateAvailable() = %d, want MemTotal 1000", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	mi, err := ParseFields(readFixture(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"mem":{"total":8246247424,"used":3527069696,"free":739037184,"shared":1656614912,"cached":3729383424,"buffers":250757120,"available":2840678400,"slab":308842496,"sreclaimable":184168448,"sunreclaim":124674048,"dirty":1310720,"writeback":65536},"swap":{"total":8464101376,"used":786432,"free":8463314944}}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestParseZswap(t *testing.T) {
+	m, err := ReadFile("testdata/meminfo_zswap.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zi, err := ParseZswap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ZswapInfo{Compressed: 256 << 20, Original: 768 << 20, Ratio: 3}); *zi != want {
+		t.Errorf("ParseZswap() = %+v, want %+v", *zi, want)
+	}
+
+	// An empty zswap has no ratio.
+	zi, err = ParseZswap(map[string]uint64{"Zswap": 0, "Zswapped": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *zi != (ZswapInfo{}) {
+		t.Errorf("empty zswap: got %+v, want zeros", *zi)
+	}
+
+	// Without zswap, the fields are not there at all.
+	if _, err := ParseZswap(readFixture(t)); !errors.Is(err, ErrMissingField) || !strings.Contains(err.Error(), "Zswap") {
+		t.Errorf("without zswap: got %v, want %v naming Zswap", err, ErrMissingField)
+	}
+}
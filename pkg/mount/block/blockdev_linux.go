@@ -47,9 +47,10 @@ var (
 
 // BlockDev maps a device name to a BlockStat structure for a given block device
 type BlockDev struct {
-	Name   string
-	FSType string
-	FsUUID string
+	Name    string
+	FSType  string
+	FsUUID  string
+	FsLabel string
 }
 
 // Device makes sure the block device exists and returns a handle to it.
@@ -63,8 +64,8 @@ func Device(maybeDevpath string) (*BlockDev, error) {
 	}
 
 	devpath := filepath.Join("/dev/", devname)
-	if uuid, err := getFSUUID(devpath); err == nil {
-		return &BlockDev{Name: devname, FsUUID: uuid}, nil
+	if uuid, label, err := getFSInfo(devpath); err == nil {
+		return &BlockDev{Name: devname, FsUUID: uuid, FsLabel: label}, nil
 	}
 	return &BlockDev{Name: devname}, nil
 }
@@ -225,30 +226,34 @@ func (b *BlockDev) PCIInfo() (*pci.PCI, error) {
 	return pci.OnePCI(p)
 }
 
-func getFSUUID(devpath string) (string, error) {
+func getFSInfo(devpath string) (string, string, error) {
 	file, err := os.Open(devpath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer file.Close()
+	return fsInfo(file)
+}
 
-	fsuuid, err := tryFAT32(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	fsuuid, err = tryFAT16(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	fsuuid, err = tryEXT4(file)
-	if err == nil {
-		return fsuuid, nil
+// fsInfo returns the UUID and the label of the filesystem in file. The label
+// is empty if the filesystem has none.
+func fsInfo(file io.ReaderAt) (string, string, error) {
+	for _, try := range []func(io.ReaderAt) (string, string, error){tryFAT32, tryFAT16, tryEXT4, tryXFS} {
+		if fsuuid, label, err := try(file); err == nil {
+			return fsuuid, label, nil
+		}
 	}
-	fsuuid, err = tryXFS(file)
-	if err == nil {
-		return fsuuid, nil
+	return "", "", fmt.Errorf("unknown UUID (not vfat, ext4, nor xfs)")
+}
+
+// readLabel reads a label of size bytes at off, and trims the NULs or
+// spaces it is padded with.
+func readLabel(file io.ReaderAt, off int64, size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := file.ReadAt(b, off); err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("unknown UUID (not vfat, ext4, nor xfs)")
+	return strings.TrimRight(string(b), "\x00 "), nil
 }
 
 // See https://www.nongnu.org/ext2-doc/ext2.html#DISK-ORGANISATION.
@@ -265,30 +270,38 @@ const (
 	// Offset of UUID in superblock.
 	ext2SprblkUUIDOff  = 104
 	ext2SprblkUUIDSize = 16
+
+	// Offset of volume name in superblock.
+	ext2SprblkLabelOff  = 120
+	ext2SprblkLabelSize = 16
 )
 
-func tryEXT4(file io.ReaderAt) (string, error) {
+func tryEXT4(file io.ReaderAt) (string, string, error) {
 	var off int64
 
 	// Read magic number.
 	b := make([]byte, ext2SprblkMagicSize)
 	off = ext2SprblkOff + ext2SprblkMagicOff
 	if _, err := file.ReadAt(b, off); err != nil {
-		return "", err
+		return "", "", err
 	}
 	magic := binary.LittleEndian.Uint16(b[:2])
 	if magic != ext2SprblkMagic {
-		return "", fmt.Errorf("ext4 magic not found")
+		return "", "", fmt.Errorf("ext4 magic not found")
 	}
 
 	// Filesystem UUID.
 	b = make([]byte, ext2SprblkUUIDSize)
 	off = ext2SprblkOff + ext2SprblkUUIDOff
 	if _, err := file.ReadAt(b, off); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	label, err := readLabel(file, ext2SprblkOff+ext2SprblkLabelOff, ext2SprblkLabelSize)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), label, nil
 }
 
 // See https://de.wikipedia.org/wiki/File_Allocation_Table#Aufbau.
@@ -303,26 +316,46 @@ const (
 	// Offset of filesystem ID / serial number. Treated as short filesystem UUID.
 	fat16IDOff  = 0x27
 	fat16IDSize = 4
+
+	// Offset of volume label.
+	fat16LabelOff = 0x2b
+	fatLabelSize  = 11
+
+	// fatNoLabel is the volume label of FAT filesystems without one.
+	fatNoLabel = "NO NAME"
 )
 
-func tryFAT16(file io.ReaderAt) (string, error) {
+// fatLabel reads the FAT volume label at off.
+func fatLabel(file io.ReaderAt, off int64) (string, error) {
+	label, err := readLabel(file, off, fatLabelSize)
+	if label == fatNoLabel {
+		label = ""
+	}
+	return label, err
+}
+
+func tryFAT16(file io.ReaderAt) (string, string, error) {
 	// Read magic number.
 	b := make([]byte, fat16MagicSize)
 	if _, err := file.ReadAt(b, fat16MagicOff); err != nil {
-		return "", err
+		return "", "", err
 	}
 	magic := string(b)
 	if magic != fat16Magic && magic != fat12Magic {
-		return "", fmt.Errorf("fat16 magic not found")
+		return "", "", fmt.Errorf("fat16 magic not found")
 	}
 
 	// Filesystem UUID.
 	b = make([]byte, fat16IDSize)
 	if _, err := file.ReadAt(b, fat16IDOff); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), nil
+	label, err := fatLabel(file, fat16LabelOff)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), label, nil
 }
 
 // See https://de.wikipedia.org/wiki/File_Allocation_Table#Aufbau.
@@ -336,26 +369,33 @@ const (
 	// Offset of filesystem ID / serial number. Treated as short filesystem UUID.
 	fat32IDOff  = 67
 	fat32IDSize = 4
+
+	// Offset of volume label.
+	fat32LabelOff = 0x47
 )
 
-func tryFAT32(file io.ReaderAt) (string, error) {
+func tryFAT32(file io.ReaderAt) (string, string, error) {
 	// Read magic number.
 	b := make([]byte, fat32MagicSize)
 	if _, err := file.ReadAt(b, fat32MagicOff); err != nil {
-		return "", err
+		return "", "", err
 	}
 	magic := string(b)
 	if magic != fat32Magic {
-		return "", fmt.Errorf("fat32 magic not found")
+		return "", "", fmt.Errorf("fat32 magic not found")
 	}
 
 	// Filesystem UUID.
 	b = make([]byte, fat32IDSize)
 	if _, err := file.ReadAt(b, fat32IDOff); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), nil
+	label, err := fatLabel(file, fat32LabelOff)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), label, nil
 }
 
 const (
@@ -363,26 +403,32 @@ const (
 	xfsMagicSize = 4
 	xfsUUIDOff   = 32
 	xfsUUIDSize  = 16
+	xfsLabelOff  = 108
+	xfsLabelSize = 12
 )
 
-func tryXFS(file io.ReaderAt) (string, error) {
+func tryXFS(file io.ReaderAt) (string, string, error) {
 	// Read magic number.
 	b := make([]byte, xfsMagicSize)
 	if _, err := file.ReadAt(b, 0); err != nil {
-		return "", err
+		return "", "", err
 	}
 	magic := string(b)
 	if magic != xfsMagic {
-		return "", fmt.Errorf("xfs magic not found")
+		return "", "", fmt.Errorf("xfs magic not found")
 	}
 
 	// Filesystem UUID.
 	b = make([]byte, xfsUUIDSize)
 	if _, err := file.ReadAt(b, xfsUUIDOff); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	label, err := readLabel(file, xfsLabelOff, xfsLabelSize)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), label, nil
 }
 
 // BlockDevices is a list of block devices.
@@ -461,6 +507,18 @@ func (b BlockDevices) FilterFSUUID(fsuuid string) BlockDevices {
 	return partitions
 }
 
+// FilterFSLabel returns a list of BlockDev objects whose underlying block
+// device has a filesystem with the given label.
+func (b BlockDevices) FilterFSLabel(label string) BlockDevices {
+	partitions := make(BlockDevices, 0)
+	for _, device := range b {
+		if device.FsLabel == label {
+			partitions = append(partitions, device)
+		}
+	}
+	return partitions
+}
+
 // FilterZeroSize attempts to find block devices that have at least one block
 // of content.
 //
@@ -5,6 +5,7 @@
 package block
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -242,6 +243,118 @@ func TestBlockDevicesFilterFSUUID(t *testing.T) {
 	}
 }
 
+func TestBlockDevicesFilterFSLabel(t *testing.T) {
+	devs := BlockDevices{
+		&BlockDev{Name: "devA", FsLabel: "boot"},
+		&BlockDev{Name: "devB", FsLabel: "root"},
+		&BlockDev{Name: "devC"},
+	}
+
+	devs = devs.FilterFSLabel("root")
+
+	want := BlockDevices{
+		&BlockDev{Name: "devB", FsLabel: "root"},
+	}
+	if !reflect.DeepEqual(devs, want) {
+		t.Fatalf("Filtered block devices: \n\t%v \nwant: \n\t%v", devs, want)
+	}
+}
+
+// superblock returns the start of a filesystem with the given fields, each
+// written at its offset.
+func superblock(fields map[int64][]byte) *bytes.Reader {
+	b := make([]byte, 4096)
+	for off, v := range fields {
+		copy(b[off:], v)
+	}
+	return bytes.NewReader(b)
+}
+
+func TestFSInfo(t *testing.T) {
+	uuid := []byte{0x02, 0x17, 0x59, 0x89, 0xd4, 0x9f, 0x4e, 0x8e, 0x83, 0x6e, 0x99, 0x30, 0x0a, 0xf6, 0x6f, 0xc1}
+	serial := []byte{0x34, 0x12, 0xcd, 0xab}
+	for _, tt := range []struct {
+		name      string
+		file      *bytes.Reader
+		wantUUID  string
+		wantLabel string
+		wantErr   bool
+	}{
+		{
+			name: "ext4",
+			file: superblock(map[int64][]byte{
+				ext2SprblkOff + ext2SprblkMagicOff: {0x53, 0xef},
+				ext2SprblkOff + ext2SprblkUUIDOff:  uuid,
+				ext2SprblkOff + ext2SprblkLabelOff: []byte("rootfs"),
+			}),
+			wantUUID:  "02175989-d49f-4e8e-836e-99300af66fc1",
+			wantLabel: "rootfs",
+		},
+		{
+			name: "ext4 without label",
+			file: superblock(map[int64][]byte{
+				ext2SprblkOff + ext2SprblkMagicOff: {0x53, 0xef},
+				ext2SprblkOff + ext2SprblkUUIDOff:  uuid,
+			}),
+			wantUUID: "02175989-d49f-4e8e-836e-99300af66fc1",
+		},
+		{
+			name: "fat16",
+			file: superblock(map[int64][]byte{
+				fat16MagicOff: []byte(fat16Magic),
+				fat16IDOff:    serial,
+				fat16LabelOff: []byte("EFI        "),
+			}),
+			wantUUID:  "abcd-1234",
+			wantLabel: "EFI",
+		},
+		{
+			name: "fat32",
+			file: superblock(map[int64][]byte{
+				fat32MagicOff: []byte(fat32Magic),
+				fat32IDOff:    serial,
+				fat32LabelOff: []byte("BOOT DISK  "),
+			}),
+			wantUUID:  "abcd-1234",
+			wantLabel: "BOOT DISK",
+		},
+		{
+			name: "fat32 without label",
+			file: superblock(map[int64][]byte{
+				fat32MagicOff: []byte(fat32Magic),
+				fat32IDOff:    serial,
+				fat32LabelOff: []byte("NO NAME    "),
+			}),
+			wantUUID: "abcd-1234",
+		},
+		{
+			name: "xfs",
+			file: superblock(map[int64][]byte{
+				0:           []byte(xfsMagic),
+				xfsUUIDOff:  uuid,
+				xfsLabelOff: []byte("data"),
+			}),
+			wantUUID:  "02175989-d49f-4e8e-836e-99300af66fc1",
+			wantLabel: "data",
+		},
+		{
+			name:    "unknown",
+			file:    superblock(nil),
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uuid, label, err := fsInfo(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fsInfo() = %v, want error: %t", err, tt.wantErr)
+			}
+			if uuid != tt.wantUUID || label != tt.wantLabel {
+				t.Errorf("fsInfo() = %q, %q, want %q, %q", uuid, label, tt.wantUUID, tt.wantLabel)
+			}
+		})
+	}
+}
+
 func TestGetMountpointByDevice(t *testing.T) {
 	LinuxMountsPath = "testdata/mounts"
 
@@ -72,6 +72,33 @@ func SetFile(devicename, filename string) error {
 	return SetFD(int(device.Fd()), int(file.Fd()))
 }
 
+// directIOArg returns the LOOP_SET_DIRECT_IO argument that turns direct I/O
+// on or off.
+func directIOArg(on bool) int {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+// SetDirectIOFD turns direct I/O on or off for loop device fd. With direct
+// I/O, the loop device reads and writes its backing file bypassing the page
+// cache, so the data is not cached twice.
+func SetDirectIOFD(fd int, on bool) error {
+	return unix.IoctlSetInt(fd, unix.LOOP_SET_DIRECT_IO, directIOArg(on))
+}
+
+// SetDirectIO turns direct I/O on or off for loop device "devicename".
+func SetDirectIO(devicename string, on bool) error {
+	device, err := os.Open(devicename)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	return SetDirectIOFD(int(device.Fd()), on)
+}
+
 // ClearFile clears the fd association of the loop device "devicename".
 func ClearFile(devicename string) error {
 	device, err := os.Open(devicename)
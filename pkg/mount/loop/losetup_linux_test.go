@@ -38,6 +38,15 @@ func TestFindDevice(t *testing.T) {
 	}
 }
 
+func TestDirectIOArg(t *testing.T) {
+	if got := directIOArg(true); got != 1 {
+		t.Errorf("directIOArg(true) = %d, want 1", got)
+	}
+	if got := directIOArg(false); got != 0 {
+		t.Errorf("directIOArg(false) = %d, want 0", got)
+	}
+}
+
 func TestSetFile(t *testing.T) {
 	guest.SkipIfNotInVM(t)
 
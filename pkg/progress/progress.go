@@ -24,6 +24,7 @@ type ProgressData struct {
 	endTimeMutex sync.Mutex
 	variable     *int64 // must be aligned for atomic operations
 	quit         chan struct{}
+	stopped      bool
 	w            io.Writer
 }
 
@@ -65,8 +66,24 @@ func (p *ProgressData) Begin() {
 	}
 }
 
-// End - Ends the progress and send quit signal to the channel
-func (p *ProgressData) End() {
+// Stop stops the progress without printing the grand total, so that other
+// output can come before End prints it. In progress mode, it ends the line
+// the progress was being printed on.
+func (p *ProgressData) Stop() {
+	if p.stopped {
+		return
+	}
+	p.stop()
+	if p.mode == "progress" {
+		fmt.Fprint(p.w, "\n")
+	}
+}
+
+func (p *ProgressData) stop() {
+	if p.stopped {
+		return
+	}
+	p.stopped = true
 	if p.mode == "progress" {
 		// Properly synchronize goroutine.
 		p.quit <- struct{}{}
@@ -76,6 +93,11 @@ func (p *ProgressData) End() {
 		p.end = time.Now()
 		p.endTimeMutex.Unlock()
 	}
+}
+
+// End - Ends the progress and send quit signal to the channel
+func (p *ProgressData) End() {
+	p.stop()
 	if p.mode == "progress" || p.mode == "xfer" {
 		// Print grand total.
 		p.print("\n")
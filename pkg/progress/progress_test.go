@@ -6,6 +6,7 @@ package progress
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 )
@@ -90,3 +91,32 @@ func TestProgressEnd(t *testing.T) {
 		})
 	}
 }
+
+func TestProgressStop(t *testing.T) {
+	for _, mode := range []string{"none", "xfer", "progress"} {
+		t.Run(mode, func(t *testing.T) {
+			someVariable := int64(1)
+			b := &bytes.Buffer{}
+			p := New(b, mode, &someVariable)
+			p.Begin()
+			p.Stop()
+			stopped := b.String()
+			if mode == "progress" && !strings.HasSuffix(stopped, "\n") {
+				t.Errorf("Stop() printed %q, want it to end the progress line", stopped)
+			}
+			if mode != "progress" && stopped != "" {
+				t.Errorf("Stop() printed %q, want nothing", stopped)
+			}
+			p.Stop()
+			if b.String() != stopped {
+				t.Errorf("second Stop() printed %q, want nothing", b.String()[len(stopped):])
+			}
+
+			p.End()
+			total := b.String()[len(stopped):]
+			if got, want := strings.Contains(total, "copied"), mode != "none"; got != want {
+				t.Errorf("End() after Stop() printed %q, want the grand total: %t", total, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tarutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// zeroBlock is a block of zeros to compare file contents against.
+var zeroBlock [blockSize]byte
+
+// isZero returns true if b, which is at most a block long, is all zeros.
+func isZero(b []byte) bool {
+	return bytes.Equal(b, zeroBlock[:len(b)])
+}
+
+// fragment is a run of data in a sparse file. Everything between two
+// fragments is a hole.
+type fragment struct {
+	off, len int64
+}
+
+// sparseFragments finds the data in the first size bytes of f. Runs of at
+// least one whole block of zeros are left out as holes. It returns no
+// fragments and false if there are no holes.
+func sparseFragments(f io.ReaderAt, size int64) ([]fragment, bool, error) {
+	var frags []fragment
+	holes := false
+	buf := make([]byte, 64*blockSize)
+	for off := int64(0); off < size; {
+		n, err := f.ReadAt(buf[:min(int64(len(buf)), size-off)], off)
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		if n == 0 {
+			return nil, false, fmt.Errorf("file shrank to %d bytes while reading it: %w", off, io.ErrUnexpectedEOF)
+		}
+		for i := 0; i < n; i += blockSize {
+			b := buf[i:min(i+blockSize, n)]
+			if isZero(b) && len(b) == blockSize {
+				holes = true
+				continue
+			}
+			at := off + int64(i)
+			if l := len(frags) - 1; l >= 0 && frags[l].off+frags[l].len == at {
+				frags[l].len += int64(len(b))
+			} else {
+				frags = append(frags, fragment{off: at, len: int64(len(b))})
+			}
+		}
+		off += int64(n)
+	}
+	if !holes {
+		return nil, false, nil
+	}
+	if l := len(frags) - 1; l < 0 || frags[l].off+frags[l].len < size {
+		// A file ending in a hole ends with an empty fragment, so
+		// that readers which only look at the map see its size, as
+		// GNU tar does.
+		frags = append(frags, fragment{off: size})
+	}
+	return frags, true, nil
+}
+
+// formatNumeric writes x into the tar header field b, in octal if it fits
+// and in base-256 otherwise, as GNU tar does.
+func formatNumeric(b []byte, x int64) {
+	if s := fmt.Sprintf("%0*o", len(b)-1, x); len(s) < len(b) {
+		copy(b, s)
+		b[len(b)-1] = 0
+		return
+	}
+	for i := len(b) - 1; i > 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+	b[0] = 0x80
+}
+
+// formatSparse fills the sparse entries of a GNU header, or of a sparse
+// extension block, with as many fragments as fit and sets the extended
+// flag behind them if any are left. It returns the fragments left.
+func formatSparse(b []byte, frags []fragment) []fragment {
+	n := len(b) / 24
+	for i := 0; i < n && len(frags) > 0; i++ {
+		formatNumeric(b[i*24:][:12], frags[0].off)
+		formatNumeric(b[i*24+12:][:12], frags[0].len)
+		frags = frags[1:]
+	}
+	if len(frags) > 0 {
+		b[n*24] = 1
+	}
+	return frags
+}
+
+// sparseHeader returns the header blocks of a GNU sparse member, i.e. one
+// with typeflag 'S', for hdr with the data in frags. archive/tar can read
+// these but not write them, so it only formats a plain GNU header, which is
+// then turned into a sparse one.
+func sparseHeader(hdr *tar.Header, frags []fragment) ([]byte, error) {
+	h := *hdr
+	h.Format = tar.FormatGNU
+	h.Size = 0
+	var b bytes.Buffer
+	if err := tar.NewWriter(&b).WriteHeader(&h); err != nil {
+		return nil, err
+	}
+	// GNU long names come first, the header proper is the last block.
+	blk := b.Bytes()[b.Len()-blockSize:]
+
+	var size int64
+	for _, f := range frags {
+		size += f.len
+	}
+	formatNumeric(blk[124:136], size)
+	blk[156] = tar.TypeGNUSparse
+	frags = formatSparse(blk[386:483], frags)
+	formatNumeric(blk[483:495], hdr.Size)
+
+	copy(blk[148:156], "        ")
+	var sum int64
+	for _, c := range blk {
+		sum += int64(c)
+	}
+	copy(blk[148:156], fmt.Sprintf("%06o\x00 ", sum))
+
+	for len(frags) > 0 {
+		var ext [blockSize]byte
+		frags = formatSparse(ext[:505], frags)
+		b.Write(ext[:])
+	}
+	return b.Bytes(), nil
+}
+
+// writeSparse writes the regular file f, which hdr describes, to the archive
+// w as a GNU sparse member holding only the data in frags. tw must be the
+// tar.Writer on w; the member goes around it.
+func writeSparse(tw *tar.Writer, w io.Writer, hdr *tar.Header, f *os.File, frags []fragment) error {
+	blks, err := sparseHeader(hdr, frags)
+	if err != nil {
+		return err
+	}
+	// Pad the previous member.
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.Write(blks); err != nil {
+		return err
+	}
+	var n int64
+	for _, frag := range frags {
+		if _, err := io.Copy(w, io.NewSectionReader(f, frag.off, frag.len)); err != nil {
+			return err
+		}
+		n += frag.len
+	}
+	if pad := -n & (blockSize - 1); pad > 0 {
+		if _, err := w.Write(zeroBlock[:pad]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSparse returns true if hdr is a sparse member, in either the GNU or
+// the PAX format.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// copySparse writes the contents of r to f, seeking over runs of whole
+// blocks of zeros instead of writing them, so that they become holes.
+func copySparse(f *os.File, r io.Reader) error {
+	buf := make([]byte, 64*blockSize)
+	var off int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		for i := 0; i < n; {
+			zero := n-i >= blockSize && isZero(buf[i:i+blockSize])
+			j := min(i+blockSize, n)
+			for j < n {
+				b := buf[j:min(j+blockSize, n)]
+				if (len(b) == blockSize && isZero(b)) != zero {
+					break
+				}
+				j += len(b)
+			}
+			if !zero {
+				if _, err := f.WriteAt(buf[i:j], off); err != nil {
+					return err
+				}
+			}
+			off += int64(j - i)
+			i = j
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// The file may end in a hole.
+	return f.Truncate(off)
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package tarutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// diskUsage returns the number of bytes allocated to file.
+func diskUsage(t *testing.T, file string) int64 {
+	t.Helper()
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestSparseFragments(t *testing.T) {
+	data := func(n int) []byte { return bytes.Repeat([]byte{'x'}, n) }
+	hole := func(n int) []byte { return make([]byte, n) }
+	for _, tt := range []struct {
+		name   string
+		blocks [][]byte
+		want   []fragment
+		sparse bool
+	}{
+		{name: "empty"},
+		{name: "no holes", blocks: [][]byte{data(3000)}},
+		{name: "zeros shorter than a block", blocks: [][]byte{data(10), hole(511), data(1)}},
+		{
+			name:   "hole in the middle",
+			blocks: [][]byte{data(blockSize), hole(2 * blockSize), data(100)},
+			want:   []fragment{{0, blockSize}, {3 * blockSize, 100}},
+			sparse: true,
+		},
+		{
+			name:   "hole at both ends",
+			blocks: [][]byte{hole(blockSize), data(blockSize), hole(blockSize)},
+			want:   []fragment{{blockSize, blockSize}, {3 * blockSize, 0}},
+			sparse: true,
+		},
+		{
+			name:   "all zeros",
+			blocks: [][]byte{hole(4 * blockSize)},
+			want:   []fragment{{4 * blockSize, 0}},
+			sparse: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Join(tt.blocks, nil)
+			got, sparse, err := sparseFragments(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sparse != tt.sparse || len(got) != len(tt.want) {
+				t.Fatalf("sparseFragments() = %v, %v, want %v, %v", got, sparse, tt.want, tt.sparse)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("sparseFragments() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSparseRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.Mkdir(src, 0o777); err != nil {
+		t.Fatal(err)
+	}
+
+	// 8 MiB with a little data at the start, in the middle and in a
+	// partial block at the end, and enough fragments for the sparse map
+	// to need extension blocks.
+	const size = 8<<20 + 100
+	f, err := os.Create(filepath.Join(src, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 30; i++ {
+		if _, err := f.WriteAt([]byte("fragment"), i*64<<10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.WriteAt([]byte("end"), size-3); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(src, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarFile := filepath.Join(tmpDir, "test.tar")
+	f, err = os.Create(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateTar(f, []string{"disk.img"}, &Opts{ChangeDirectory: src, Sparse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 64<<10 {
+		t.Errorf("archive of a sparse file is %d bytes, want at most %d", fi.Size(), 64<<10)
+	}
+	f, err = os.Open(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	hdr, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Typeflag != tar.TypeGNUSparse || hdr.Name != "disk.img" || hdr.Size != size {
+		t.Errorf("header: got type %q, name %q, size %d, want %q, %q, %d", hdr.Typeflag, hdr.Name, hdr.Size, tar.TypeGNUSparse, "disk.img", size)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmpDir, "dst")
+	if err := ExtractDir(f, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("extracted file differs from the original")
+	}
+	// Only check for holes where the source has them, as not every file
+	// system supports them.
+	if diskUsage(t, filepath.Join(src, "disk.img")) < size {
+		if du := diskUsage(t, filepath.Join(dst, "disk.img")); du >= size {
+			t.Errorf("extracted file uses %d bytes on disk, want it sparse", du)
+		}
+	}
+
+	// And GNU tar, which invented the format, must agree.
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("no system tar")
+	}
+	gnuDst := filepath.Join(tmpDir, "gnu")
+	if err := os.Mkdir(gnuDst, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("tar", "-xf", tarFile, "-C", gnuDst).CombinedOutput(); err != nil {
+		t.Fatalf("system tar could not extract the archive: %v: %s", err, out)
+	}
+	got, err = os.ReadFile(filepath.Join(gnuDst, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("file extracted by system tar differs from the original")
+	}
+}
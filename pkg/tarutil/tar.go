@@ -11,7 +11,10 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/u-root/u-root/pkg/upath"
 )
@@ -31,8 +34,39 @@ type Opts struct {
 	// Change to this directory before any operations. This is equivalent
 	// to "tar -C DIR".
 	ChangeDirectory string
+
+	// Existing says what extracting does with files which are already
+	// there. Existing directories are always kept and used.
+	Existing Existing
+
+	// Sparse stores regular files with runs of zero blocks as GNU sparse
+	// members, which leave the zeros out, and extracts every file that
+	// way, seeking over the zeros so that they become holes. Sparse
+	// members are always extracted sparsely. This is tar -S.
+	Sparse bool
 }
 
+// Existing says what extracting does with files which are already there.
+type Existing int
+
+const (
+	// UnlinkExisting removes an existing file and creates a new one in its
+	// place. This is the default.
+	UnlinkExisting Existing = iota
+
+	// OverwriteExisting writes over an existing file in place, so other
+	// hard links to it see the new contents too. This is tar --overwrite.
+	OverwriteExisting
+
+	// KeepExisting fails extraction when a file exists. This is tar
+	// --keep-old-files.
+	KeepExisting
+
+	// SkipExisting silently leaves existing files alone and carries on.
+	// This is tar --skip-old-files.
+	SkipExisting
+)
+
 // passesFilters returns true if the given file passes all filters, false otherwise.
 func passesFilters(hdr *tar.Header, filters []Filter) bool {
 	for _, filter := range filters {
@@ -69,6 +103,15 @@ func ListArchive(tarFile io.Reader) error {
 	})
 }
 
+// ListArchiveVerbose lists the contents of the given tar archive in the long
+// format of GNU tar's -tv.
+func ListArchiveVerbose(tarFile io.Reader) error {
+	return applyToArchive(tarFile, func(tr *tar.Reader, hdr *tar.Header) error {
+		fmt.Println(LongListing(hdr))
+		return nil
+	})
+}
+
 // ExtractDir extracts all the contents of the tar file to the given directory.
 func ExtractDir(tarFile io.Reader, dir string, opts *Opts) error {
 	if opts == nil {
@@ -93,7 +136,41 @@ func ExtractDir(tarFile io.Reader, dir string, opts *Opts) error {
 		if !passesFilters(hdr, opts.Filters) {
 			return nil
 		}
-		return createFileInRoot(hdr, tr, dir)
+		return createFileInRoot(hdr, tr, dir, opts.Existing, opts.Sparse || isSparse(hdr))
+	})
+}
+
+// ExtractToCommand runs command with /bin/sh for each regular file in the
+// archive, with the contents of the file on its standard input, rather than
+// writing the file to disk. This is GNU tar's --to-command. As there, the
+// command finds out about the file in the environment: TAR_FILENAME is its
+// name, TAR_SIZE its size, TAR_MODE its octal permissions, TAR_UID,
+// TAR_GID, TAR_UNAME and TAR_GNAME its owner. Other members are skipped. A
+// command which fails stops the extraction.
+func ExtractToCommand(tarFile io.Reader, command string, opts *Opts) error {
+	if opts == nil {
+		opts = &Opts{}
+	}
+
+	return applyToArchive(tarFile, func(tr *tar.Reader, hdr *tar.Header) error {
+		if !passesFilters(hdr, opts.Filters) || !hdr.FileInfo().Mode().IsRegular() {
+			return nil
+		}
+		cmd := exec.Command("/bin/sh", "-c", command)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tr, os.Stdout, os.Stderr
+		cmd.Env = append(os.Environ(),
+			"TAR_FILENAME="+hdr.Name,
+			"TAR_SIZE="+strconv.FormatInt(hdr.Size, 10),
+			fmt.Sprintf("TAR_MODE=%04o", hdr.Mode&0o7777),
+			"TAR_UID="+strconv.Itoa(hdr.Uid),
+			"TAR_GID="+strconv.Itoa(hdr.Gid),
+			"TAR_UNAME="+hdr.Uname,
+			"TAR_GNAME="+hdr.Gname,
+		)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%q: %s: %w", hdr.Name, command, err)
+		}
+		return nil
 	})
 }
 
@@ -181,6 +258,17 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 					f.Close()
 					hdr.Size = int64(b.Len())
 					r = b
+				} else if opts.Sparse {
+					frags, ok, err := sparseFragments(f, hdr.Size)
+					if err != nil {
+						f.Close()
+						return err
+					}
+					if ok {
+						err := writeSparse(tw, tarFile, hdr, f, frags)
+						f.Close()
+						return err
+					}
 				}
 
 				if err := tw.WriteHeader(hdr); err != nil {
@@ -199,7 +287,81 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 	return tw.Close()
 }
 
-func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
+// blockSize is the size of a tar block.
+const blockSize = 512
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// endOfArchive returns the offset of the end-of-archive marker of the
+// archive read from r, i.e. where new members have to be written, and the
+// modification times of its members.
+func endOfArchive(r io.Reader) (int64, map[string]time.Time, error) {
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
+	var end int64
+	mtimes := map[string]time.Time{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return end, mtimes, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return 0, nil, err
+		}
+		// Member data is padded to whole blocks.
+		end = (cr.n + blockSize - 1) / blockSize * blockSize
+		if t, ok := mtimes[hdr.Name]; !ok || hdr.ModTime.After(t) {
+			mtimes[hdr.Name] = hdr.ModTime
+		}
+	}
+}
+
+// AppendTar adds files to the end of an existing tar archive, like tar -r.
+// An empty file is a valid, empty archive. If update is set, files are only
+// added if they are newer than the latest member of the same name, like
+// tar -u.
+func AppendTar(tarFile io.ReadWriteSeeker, files []string, opts *Opts, update bool) error {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	end, mtimes, err := endOfArchive(tarFile)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	if update {
+		o := *opts
+		o.Filters = append([]Filter{func(hdr *tar.Header) bool {
+			// The archive only stores whole seconds.
+			t, ok := mtimes[hdr.Name]
+			return !ok || hdr.ModTime.Round(time.Second).After(t)
+		}}, opts.Filters...)
+		opts = &o
+	}
+	// The new members overwrite the end-of-archive marker, and CreateTar
+	// writes a new one behind them.
+	if _, err := tarFile.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+	return CreateTar(tarFile, files, opts)
+}
+
+func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string, existing Existing, sparse bool) error {
 	fi := hdr.FileInfo()
 	path, err := upath.SafeFilepathJoin(rootDir, hdr.Name)
 	if err != nil {
@@ -209,6 +371,21 @@ func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
 		return nil
 	}
 
+	if !fi.IsDir() {
+		if _, err := os.Lstat(path); err == nil {
+			switch existing {
+			case KeepExisting:
+				return fmt.Errorf("%q: %w", path, os.ErrExist)
+			case SkipExisting:
+				return nil
+			case UnlinkExisting:
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
 	switch fi.Mode() & os.ModeType {
 	case os.ModeSymlink:
 		// TODO: support symlinks
@@ -219,7 +396,14 @@ func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
 		if err != nil {
 			return err
 		}
-		if _, err := io.Copy(f, r); err != nil {
+		copyData := func(f *os.File, r io.Reader) error {
+			_, err := io.Copy(f, r)
+			return err
+		}
+		if sparse {
+			copyData = copySparse
+		}
+		if err := copyData(f, r); err != nil {
 			f.Close()
 			return err
 		}
@@ -263,6 +447,85 @@ func VerboseFilter(hdr *tar.Header) bool {
 	return true
 }
 
+// LongVerboseFilter prints a long listing line for every file, as
+// LongListing formats it.
+func LongVerboseFilter(hdr *tar.Header) bool {
+	fmt.Println(LongListing(hdr))
+	return true
+}
+
+// LongListing describes hdr the way GNU tar -tv does:
+//
+//	-rw-r--r-- user/group      1234 2006-01-02 15:04 name
+//
+// Modification times are shown in local time.
+func LongListing(hdr *tar.Header) string {
+	owner := hdr.Uname
+	if owner == "" {
+		owner = strconv.Itoa(hdr.Uid)
+	}
+	group := hdr.Gname
+	if group == "" {
+		group = strconv.Itoa(hdr.Gid)
+	}
+	size := strconv.FormatInt(hdr.Size, 10)
+	if hdr.Typeflag == tar.TypeChar || hdr.Typeflag == tar.TypeBlock {
+		size = fmt.Sprintf("%d,%d", hdr.Devmajor, hdr.Devminor)
+	}
+	// GNU tar pads owner, group and size to a total of 19 columns.
+	ug := owner + "/" + group
+	width := max(19-len(ug)-1, len(size))
+
+	s := fmt.Sprintf("%s %s %*s %s %s", modeString(hdr), ug, width, size, hdr.ModTime.Local().Format("2006-01-02 15:04"), hdr.Name)
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		s += " -> " + hdr.Linkname
+	case tar.TypeLink:
+		s += " link to " + hdr.Linkname
+	}
+	return s
+}
+
+// modeString returns the ls style permission string for hdr, e.g.
+// "drwxr-xr-x".
+func modeString(hdr *tar.Header) string {
+	m := []byte("----------")
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		m[0] = 'd'
+	case tar.TypeSymlink:
+		m[0] = 'l'
+	case tar.TypeLink:
+		m[0] = 'h'
+	case tar.TypeChar:
+		m[0] = 'c'
+	case tar.TypeBlock:
+		m[0] = 'b'
+	case tar.TypeFifo:
+		m[0] = 'p'
+	}
+	const rwx = "rwxrwxrwx"
+	for i := range rwx {
+		if hdr.Mode&(1<<(8-i)) != 0 {
+			m[i+1] = rwx[i]
+		}
+	}
+	special := func(bit int64, i int, set, unset byte) {
+		if hdr.Mode&bit == 0 {
+			return
+		}
+		if m[i] == '-' {
+			m[i] = unset
+		} else {
+			m[i] = set
+		}
+	}
+	special(0o4000, 3, 's', 'S')
+	special(0o2000, 6, 's', 'S')
+	special(0o1000, 9, 't', 'T')
+	return string(m)
+}
+
 // VerboseLogFilter logs the name of every file.
 func VerboseLogFilter(hdr *tar.Header) bool {
 	log.Println(hdr.Name)
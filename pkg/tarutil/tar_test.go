@@ -5,11 +5,14 @@
 package tarutil
 
 import (
+	"archive/tar"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func extractAndCompare(t *testing.T, tarFile string, files []struct{ name, body string }) {
@@ -48,6 +51,112 @@ func TestExtractDir(t *testing.T) {
 	extractAndCompare(t, "testdata/test.tar", files)
 }
 
+func TestExtractToCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skipf("no shell: %v", err)
+	}
+	record := filepath.Join(t.TempDir(), "record")
+	t.Setenv("RECORD", record)
+
+	f, err := os.Open("testdata/test.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// The directory is skipped.
+	cmd := `{ echo "$TAR_FILENAME $TAR_SIZE $TAR_MODE $TAR_UNAME"; cat; } >> "$RECORD"`
+	if err := ExtractToCommand(f, cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.txt 6 0644 ryanoleary\nhello\ndir/b.txt 6 0644 ryanoleary\nworld\n"
+	if string(got) != want {
+		t.Errorf("command got %q, want %q", got, want)
+	}
+}
+
+func TestExtractToCommandFails(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skipf("no shell: %v", err)
+	}
+	record := filepath.Join(t.TempDir(), "record")
+	t.Setenv("RECORD", record)
+
+	f, err := os.Open("testdata/test.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// The command fails for the first file, so it never sees the second.
+	err = ExtractToCommand(f, `echo "$TAR_FILENAME" >> "$RECORD"; exit 3`, nil)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("ExtractToCommand() = %v, want exit status 3", err)
+	}
+	got, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "a.txt\n" {
+		t.Errorf("command ran for %q, want only a.txt", got)
+	}
+}
+
+func TestExtractDirExisting(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		existing Existing
+		err      error
+		// a.txt is extracted over "old\n", which is hard linked to link.
+		a, link string
+	}{
+		{name: "unlink", existing: UnlinkExisting, a: "hello\n", link: "old\n"},
+		{name: "overwrite", existing: OverwriteExisting, a: "hello\n", link: "hello\n"},
+		{name: "keep", existing: KeepExisting, err: os.ErrExist, a: "old\n", link: "old\n"},
+		{name: "skip", existing: SkipExisting, a: "old\n", link: "old\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			a := filepath.Join(tmpDir, "a.txt")
+			if err := os.WriteFile(a, []byte("old\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			link := filepath.Join(tmpDir, "link")
+			if err := os.Link(a, link); err != nil {
+				t.Skipf("hard links not supported: %v", err)
+			}
+
+			f, err := os.Open("testdata/test.tar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := ExtractDir(f, tmpDir, &Opts{Existing: tt.existing}); !errors.Is(err, tt.err) {
+				t.Fatalf("ExtractDir: got %v, want %v", err, tt.err)
+			}
+
+			for name, want := range map[string]string{a: tt.a, link: tt.link} {
+				b, err := os.ReadFile(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s: got %q, want %q", filepath.Base(name), b, want)
+				}
+			}
+			if tt.err == nil {
+				// Extraction carries on past the existing file.
+				if _, err := os.Stat(filepath.Join(tmpDir, "dir", "b.txt")); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
 func TestCreateTarSingleFile(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -177,3 +286,151 @@ func TestListArchive(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLongListing(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	mtime := time.Date(2024, 3, 9, 17, 5, 42, 0, time.UTC)
+	for _, tt := range []struct {
+		hdr  *tar.Header
+		want string
+	}{
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0o755, Uname: "root", Gname: "root", ModTime: mtime},
+			want: "drwxr-xr-x root/root         0 2024-03-09 17:05 dir/",
+		},
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0o644, Size: 5, Uname: "alice", Gname: "users", ModTime: mtime},
+			want: "-rw-r--r-- alice/users       5 2024-03-09 17:05 dir/file",
+		},
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeSymlink, Name: "dir/link", Linkname: "file", Mode: 0o777, Uid: 1000, Gid: 100, ModTime: mtime},
+			want: "lrwxrwxrwx 1000/100          0 2024-03-09 17:05 dir/link -> file",
+		},
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeReg, Name: "dir/suid", Mode: 0o4755, Size: 1234567890, Uname: "root", Gname: "wheel", ModTime: mtime},
+			want: "-rwsr-xr-x root/wheel 1234567890 2024-03-09 17:05 dir/suid",
+		},
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeLink, Name: "dir/hard", Linkname: "dir/file", Mode: 0o1640, Uname: "alice", Gname: "users", ModTime: mtime},
+			want: "hrw-r----T alice/users       0 2024-03-09 17:05 dir/hard link to dir/file",
+		},
+		{
+			hdr:  &tar.Header{Typeflag: tar.TypeChar, Name: "dev/null", Mode: 0o666, Devmajor: 1, Devminor: 3, Uname: "root", Gname: "root", ModTime: mtime},
+			want: "crw-rw-rw- root/root       1,3 2024-03-09 17:05 dev/null",
+		},
+	} {
+		if got := LongListing(tt.hdr); got != tt.want {
+			t.Errorf("LongListing(%q) = %q, want %q", tt.hdr.Name, got, tt.want)
+		}
+	}
+}
+
+func archiveNames(t *testing.T, tarFile string) []string {
+	t.Helper()
+	f, err := os.Open(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	if err := applyToArchive(f, func(tr *tar.Reader, hdr *tar.Header) error {
+		names = append(names, hdr.Name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestAppendTar(t *testing.T) {
+	tmpDir := t.TempDir()
+	b, err := os.ReadFile("testdata/test.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarFile := filepath.Join(tmpDir, "test.tar")
+	if err := os.WriteFile(tarFile, b, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(tmpDir, "src")
+	if err := os.Mkdir(src, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "c.txt"), []byte("appended\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(tarFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendTar(f, []string{"c.txt"}, &Opts{ChangeDirectory: src}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	extractAndCompare(t, tarFile, []struct{ name, body string }{
+		{"a.txt", "hello\n"},
+		{"dir/b.txt", "world\n"},
+		{"c.txt", "appended\n"},
+	})
+}
+
+func TestAppendTarUpdate(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, body := range map[string]string{"old": "unchanged\n", "new": "first\n"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(body), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	then := time.Now().Add(-time.Hour)
+	for _, name := range []string{"old", "new"} {
+		if err := os.Chtimes(filepath.Join(tmpDir, name), then, then); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Appending to an empty file starts a new archive.
+	tarFile := filepath.Join(tmpDir, "test.tar")
+	f, err := os.Create(tarFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	opts := &Opts{ChangeDirectory: tmpDir}
+	if err := AppendTar(f, []string{"old", "new"}, opts, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "new"), []byte("second\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "added"), []byte("added\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendTar(f, []string{"old", "new", "added"}, opts, true); err != nil {
+		t.Fatal(err)
+	}
+
+	got := archiveNames(t, tarFile)
+	want := []string{"old", "new", "new", "added"}
+	if len(got) != len(want) {
+		t.Fatalf("archive has %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("archive has %q, want %q", got, want)
+		}
+	}
+	// The later copy wins on extraction.
+	extractAndCompare(t, tarFile, []struct{ name, body string }{
+		{"old", "unchanged\n"},
+		{"new", "second\n"},
+		{"added", "added\n"},
+	})
+}